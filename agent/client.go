@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/attestation"
+)
+
+// Registration is the body sent to /agent/register.
+// It mirrors the JSON shape of store.Agent without importing the store
+// package (and its Redis/Postgres drivers) into the agent binary.
+type Registration struct {
+	NodeID    string            `json:"node_id"`
+	Hostname  string            `json:"hostname"`
+	IPAddress string            `json:"ip_address"`
+	Port      int               `json:"port"`
+	Version   string            `json:"version"`
+	Status    string            `json:"status"`
+	Tier      string            `json:"tier,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// ControlPlaneClient talks to the control plane API on behalf of the agent.
+// All calls are authenticated with the agent JWT (FLUX_AGENT_TOKEN).
+type ControlPlaneClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	pollClient *http.Client        // No client timeout: long-polls are bounded by wait
+	signer     *attestation.Signer // nil when attestation is not configured
+}
+
+// NewControlPlaneClient creates a new client.
+// TLS roots come from the system pool; SSL_CERT_FILE is honoured by crypto/x509.
+func NewControlPlaneClient(baseURL, token string, signer *attestation.Signer) *ControlPlaneClient {
+	return &ControlPlaneClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		pollClient: &http.Client{},
+		signer:     signer,
+	}
+}
+
+// Register upserts the agent record via /agent/register.
+func (c *ControlPlaneClient) Register(ctx context.Context, reg *Registration) error {
+	headers := map[string]string{}
+	if c.signer != nil {
+		claim, err := c.signer.CreateClaim()
+		if err != nil {
+			return fmt.Errorf("failed to create attestation claim: %w", err)
+		}
+		sig, err := attestation.EncodeClaim(claim)
+		if err != nil {
+			return err
+		}
+		headers[attestation.SignatureHeader] = sig
+	}
+	return c.post(ctx, "/agent/register", reg, headers)
+}
+
+// Heartbeat reports liveness via /agent/heartbeat.
+func (c *ControlPlaneClient) Heartbeat(ctx context.Context, nodeID string) error {
+	return c.post(ctx, "/agent/heartbeat", map[string]string{"node_id": nodeID}, nil)
+}
+
+// ReportResult posts a finished job to /jobs/result.
+func (c *ControlPlaneClient) ReportResult(ctx context.Context, result JobResult) error {
+	return c.post(ctx, "/jobs/result", result, nil)
+}
+
+// NextJob long-polls /agent/jobs/next for the next job leased to this node.
+// Returns nil (no error) when the poll expired without a job.
+func (c *ControlPlaneClient) NextJob(ctx context.Context, nodeID string, wait time.Duration) (*executeRequest, error) {
+	path := "/agent/jobs/next"
+	u := fmt.Sprintf("%s%s?node_id=%s&wait=%s", c.baseURL, path, url.QueryEscape(nodeID), wait)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	// The shared client's timeout is shorter than a long-poll
+	resp, err := c.pollClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact control plane: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &statusError{Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	var job executeRequest
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	return &job, nil
+}
+
+// statusError is returned for non-2xx responses so callers can react to
+// specific codes (e.g. 429 storm protection).
+type statusError struct {
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.Path, e.StatusCode, e.Body)
+}
+
+func (c *ControlPlaneClient) post(ctx context.Context, path string, payload interface{}, headers map[string]string) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact control plane: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
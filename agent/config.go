@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the agent runtime configuration.
+// All values are read from the environment so the same binary works in
+// docker-compose, Kubernetes and bare-metal installs.
+type Config struct {
+	NodeID          string
+	ControlPlaneURL string
+	Token           string // FLUX_AGENT_TOKEN (JWT with role=agent)
+
+	// Transport: "push" accepts /execute, "pull" long-polls /agent/jobs/next
+	Transport string
+	PollWait  time.Duration
+
+	// Inbound /execute listener (push mode; /health in both modes)
+	ListenAddr    string
+	AdvertiseAddr string // Address the control plane dials (Agent.IPAddress)
+	AdvertisePort int    // Port the control plane dials (Agent.Port)
+
+	HeartbeatInterval time.Duration
+	Tier              string
+
+	// Execution limits
+	JobTimeout        time.Duration
+	MaxOutputBytes    int
+	MaxConcurrentJobs int
+	Shell             string
+
+	// Attestation (optional)
+	PrivateKeyFile string
+}
+
+// LoadConfig reads the agent configuration from environment variables.
+func LoadConfig() (*Config, error) {
+	hostname, _ := os.Hostname()
+
+	cfg := &Config{
+		NodeID:            getEnv("NODE_ID", hostname),
+		ControlPlaneURL:   getEnv("CONTROL_PLANE_URL", "http://localhost:8080"),
+		Token:             os.Getenv("FLUX_AGENT_TOKEN"),
+		AdvertiseAddr:     getEnv("AGENT_ADVERTISE_ADDR", hostname),
+		Tier:              os.Getenv("AGENT_TIER"),
+		Transport:         getEnv("AGENT_TRANSPORT", "push"),
+		PollWait:          25 * time.Second, // Under the control plane's 30s cap
+		Shell:             getEnv("AGENT_SHELL", "/bin/sh"),
+		PrivateKeyFile:    os.Getenv("AGENT_PRIVATE_KEY_FILE"),
+		HeartbeatInterval: 10 * time.Second,
+		JobTimeout:        5 * time.Minute, // Matches Reconciler.maxTaskRuntime
+		MaxOutputBytes:    64 * 1024,
+		MaxConcurrentJobs: 4,
+	}
+
+	port, err := getEnvInt("AGENT_PORT", 8081)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ListenAddr = fmt.Sprintf(":%d", port)
+	cfg.AdvertisePort = port
+
+	if cfg.HeartbeatInterval, err = getEnvDuration("HEARTBEAT_INTERVAL", cfg.HeartbeatInterval); err != nil {
+		return nil, err
+	}
+	if cfg.PollWait, err = getEnvDuration("AGENT_POLL_WAIT", cfg.PollWait); err != nil {
+		return nil, err
+	}
+	if cfg.JobTimeout, err = getEnvDuration("JOB_TIMEOUT", cfg.JobTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.MaxOutputBytes, err = getEnvInt("MAX_OUTPUT_BYTES", cfg.MaxOutputBytes); err != nil {
+		return nil, err
+	}
+	if cfg.MaxConcurrentJobs, err = getEnvInt("MAX_CONCURRENT_JOBS", cfg.MaxConcurrentJobs); err != nil {
+		return nil, err
+	}
+
+	if cfg.NodeID == "" {
+		return nil, fmt.Errorf("NODE_ID is required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("FLUX_AGENT_TOKEN is required")
+	}
+	if cfg.Transport != "push" && cfg.Transport != "pull" {
+		return nil, fmt.Errorf("invalid AGENT_TRANSPORT %q (want push or pull)", cfg.Transport)
+	}
+	if cfg.MaxConcurrentJobs < 1 {
+		cfg.MaxConcurrentJobs = 1
+	}
+	return cfg, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) (int, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, val, err)
+	}
+	return n, nil
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, val, err)
+	}
+	return d, nil
+}
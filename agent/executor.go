@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// JobResult is the payload posted back to the control plane on /jobs/result.
+// Field names match the decoder in API.handleJobResult.
+type JobResult struct {
+	JobID    string `json:"job_id"`
+	Status   string `json:"status"` // "completed" or "failed"
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// Executor runs shell commands with a hard timeout and bounded output.
+type Executor struct {
+	shell          string
+	timeout        time.Duration
+	maxOutputBytes int
+}
+
+// NewExecutor creates a new Executor.
+func NewExecutor(shell string, timeout time.Duration, maxOutputBytes int) *Executor {
+	return &Executor{
+		shell:          shell,
+		timeout:        timeout,
+		maxOutputBytes: maxOutputBytes,
+	}
+}
+
+// Run executes the command and always returns a result.
+// timeout overrides the executor default when positive.
+// IMPORTANT:
+//   - A non-zero exit code is still "completed": check commands rely on exit codes.
+//   - "failed" is reserved for commands that could not run to completion
+//     (spawn error, timeout, cancellation).
+func (e *Executor) Run(ctx context.Context, jobID string, command string, timeout time.Duration) JobResult {
+	if timeout <= 0 {
+		timeout = e.timeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := newCappedBuffer(e.maxOutputBytes)
+	stderr := newCappedBuffer(e.maxOutputBytes)
+
+	cmd := exec.CommandContext(runCtx, e.shell, "-c", command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait forever on orphaned children holding the pipes open
+	cmd.WaitDelay = 2 * time.Second
+
+	err := cmd.Run()
+
+	result := JobResult{
+		JobID:  jobID,
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case runCtx.Err() == context.DeadlineExceeded:
+		result.Status = "failed"
+		result.ExitCode = -1
+		result.Stderr += fmt.Sprintf("\n[agent] command timed out after %v", timeout)
+	case ctx.Err() != nil:
+		result.Status = "failed"
+		result.ExitCode = -1
+		result.Stderr += "\n[agent] command cancelled: agent shutting down"
+	case err == nil:
+		result.Status = "completed"
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		result.Status = "completed"
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.Status = "failed"
+		result.ExitCode = -1
+		result.Stderr += fmt.Sprintf("\n[agent] failed to run command: %v", err)
+	}
+
+	return result
+}
+
+// cappedBuffer is an io.Writer that keeps at most max bytes and silently
+// discards the rest, so a chatty command cannot exhaust agent memory.
+type cappedBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{max: max}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	remaining := b.max - len(b.buf)
+	if remaining <= 0 {
+		if len(p) > 0 {
+			b.truncated = true
+		}
+		return len(p), nil // Pretend success so the child doesn't get SIGPIPE
+	}
+	if len(p) > remaining {
+		b.buf = append(b.buf, p[:remaining]...)
+		b.truncated = true
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return string(b.buf) + fmt.Sprintf("\n[agent] output truncated at %d bytes", b.max)
+	}
+	return string(b.buf)
+}
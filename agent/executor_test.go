@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecutor_NonZeroExitIsCompleted(t *testing.T) {
+	e := NewExecutor("/bin/sh", 5*time.Second, 1024)
+
+	res := e.Run(context.Background(), "job-1", "echo hello; exit 3", 0)
+
+	if res.Status != "completed" {
+		t.Fatalf("expected completed, got %s", res.Status)
+	}
+	if res.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", res.ExitCode)
+	}
+	if strings.TrimSpace(res.Stdout) != "hello" {
+		t.Fatalf("unexpected stdout: %q", res.Stdout)
+	}
+}
+
+func TestExecutor_OutputCapped(t *testing.T) {
+	e := NewExecutor("/bin/sh", 5*time.Second, 16)
+
+	res := e.Run(context.Background(), "job-2", "yes | head -c 10000", 0)
+
+	if res.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", res.ExitCode, res.Stderr)
+	}
+	if !strings.HasPrefix(res.Stdout, "y\ny\ny\ny\ny\ny\ny\ny\n") || !strings.Contains(res.Stdout, "output truncated at 16 bytes") {
+		t.Fatalf("output not capped: %q", res.Stdout)
+	}
+}
+
+func TestExecutor_TimeoutFails(t *testing.T) {
+	e := NewExecutor("/bin/sh", time.Minute, 1024)
+
+	// Per-job timeout from the dispatch payload overrides the default
+	start := time.Now()
+	res := e.Run(context.Background(), "job-3", "sleep 10", 200*time.Millisecond)
+
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("timeout not enforced: took %v", time.Since(start))
+	}
+	if res.Status != "failed" || res.ExitCode != -1 {
+		t.Fatalf("expected failed/-1, got %s/%d", res.Status, res.ExitCode)
+	}
+	if !strings.Contains(res.Stderr, "timed out") {
+		t.Fatalf("expected timeout note, got %q", res.Stderr)
+	}
+}
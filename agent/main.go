@@ -0,0 +1,220 @@
+// Command agent is the reference FluxForge execution agent.
+//
+// It registers with the control plane, sends periodic heartbeats, receives
+// jobs either pushed on POST /execute (replying 202 Accepted) or leased from
+// GET /agent/jobs/next (AGENT_TRANSPORT=pull, for agents behind NAT), and
+// reports stdout, stderr and exit code back on POST /jobs/result.
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/attestation"
+)
+
+// Version is the agent version reported on registration.
+// Override at build time with -ldflags "-X main.Version=..."
+var Version = "0.1.0"
+
+func main() {
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid agent configuration: %v", err)
+	}
+	log.Printf("Agent starting. Node ID: %s", cfg.NodeID)
+
+	var signer *attestation.Signer
+	if cfg.PrivateKeyFile != "" {
+		signer, err = loadSigner(cfg)
+		if err != nil {
+			log.Fatalf("Failed to initialize attestation signer: %v", err)
+		}
+		log.Println("Attestation enabled: registrations will carry X-Agent-Signature")
+	} else {
+		log.Println("Warning: AGENT_PRIVATE_KEY_FILE not set, registering without attestation")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	client := NewControlPlaneClient(cfg.ControlPlaneURL, cfg.Token, signer)
+	executor := NewExecutor(cfg.Shell, cfg.JobTimeout, cfg.MaxOutputBytes)
+	server := NewServer(ctx, executor, client, cfg.MaxConcurrentJobs)
+
+	httpServer := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		log.Printf("Agent listening on %s (advertised as %s:%d)", cfg.ListenAddr, cfg.AdvertiseAddr, cfg.AdvertisePort)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Agent listener failed: %v", err)
+		}
+	}()
+
+	hostname, _ := os.Hostname()
+	reg := &Registration{
+		NodeID:    cfg.NodeID,
+		Hostname:  hostname,
+		IPAddress: cfg.AdvertiseAddr,
+		Port:      cfg.AdvertisePort,
+		Version:   Version,
+		Status:    "active",
+		Tier:      cfg.Tier,
+		Metadata: map[string]string{
+			"os":        runtime.GOOS,
+			"arch":      runtime.GOARCH,
+			"transport": cfg.Transport,
+		},
+	}
+
+	if err := registerWithRetry(ctx, client, reg); err != nil {
+		log.Printf("Registration aborted: %v", err)
+	} else {
+		if cfg.Transport == "pull" {
+			log.Printf("Pull mode: polling control plane for jobs (wait %v)", cfg.PollWait)
+			go server.Poll(cfg.NodeID, cfg.PollWait)
+		}
+		runHeartbeats(ctx, client, reg, cfg.HeartbeatInterval)
+	}
+
+	// Shutdown: stop accepting jobs, then let running jobs finish reporting
+	log.Println("Agent shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	httpServer.Shutdown(shutdownCtx)
+	server.Wait()
+	log.Println("Agent stopped")
+}
+
+// registerWithRetry keeps trying until the control plane accepts us or ctx ends.
+func registerWithRetry(ctx context.Context, client *ControlPlaneClient, reg *Registration) error {
+	backoff := 1 * time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		err := client.Register(ctx, reg)
+		if err == nil {
+			log.Printf("Successfully registered with control plane as %s", reg.NodeID)
+			return nil
+		}
+		log.Printf("⚠️ Registration failed: %v (retrying in %v)", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// runHeartbeats sends heartbeats until ctx is cancelled.
+// If the control plane has forgotten us (e.g. store wiped), re-register.
+func runHeartbeats(ctx context.Context, client *ControlPlaneClient, reg *Registration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := client.Heartbeat(ctx, reg.NodeID)
+			if err == nil {
+				log.Println("Heartbeat sent successfully")
+				continue
+			}
+
+			log.Printf("⚠️ Heartbeat failed: %v", err)
+			var se *statusError
+			if errors.As(err, &se) && se.StatusCode != http.StatusTooManyRequests && se.StatusCode != http.StatusUnauthorized {
+				// 5xx on heartbeat usually means "agent not found"; re-register
+				if err := client.Register(ctx, reg); err != nil {
+					log.Printf("⚠️ Re-registration failed: %v", err)
+				} else {
+					log.Printf("Successfully registered with control plane as %s", reg.NodeID)
+				}
+			}
+		}
+	}
+}
+
+// loadSigner builds an attestation signer from the configured private key
+// and the SHA-256 of the running agent binary.
+func loadSigner(cfg *Config) (*attestation.Signer, error) {
+	keyPEM, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	key, err := parseRSAPrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	binaryHash, err := executableHash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash agent binary: %w", err)
+	}
+
+	return attestation.NewSigner(key, cfg.NodeID, Version, binaryHash), nil
+}
+
+func parseRSAPrivateKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("failed to parse PEM block containing private key")
+	}
+
+	// openssl genrsa emits PKCS#1 on older versions and PKCS#8 on 3.x
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return key, nil
+}
+
+// executableHash returns "sha256:<hex>" of the running binary.
+func executableHash() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
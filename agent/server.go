@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// executeRequest is the body the Dispatcher POSTs to /execute.
+type executeRequest struct {
+	JobID          string `json:"job_id"`
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // 0 = agent default
+}
+
+// Server exposes the /execute contract used by Dispatcher.DispatchJob.
+// IMPORTANT:
+// - 202 Accepted = job taken (execution is async)
+// - Results are delivered later via POST /jobs/result
+type Server struct {
+	executor *Executor
+	client   *ControlPlaneClient
+
+	// slots bounds the number of concurrently running commands
+	slots chan struct{}
+
+	// running tracks in-flight job IDs so a redelivered dispatch
+	// is acknowledged without executing the command twice.
+	mu      sync.Mutex
+	running map[string]bool
+
+	ctx context.Context // Cancelled on agent shutdown
+	wg  sync.WaitGroup
+}
+
+// NewServer creates a new Server.
+func NewServer(ctx context.Context, executor *Executor, client *ControlPlaneClient, maxConcurrent int) *Server {
+	return &Server{
+		executor: executor,
+		client:   client,
+		slots:    make(chan struct{}, maxConcurrent),
+		running:  make(map[string]bool),
+		ctx:      ctx,
+	}
+}
+
+// Handler returns the HTTP routes served by the agent.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/execute", s.handleExecute)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req executeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.JobID == "" || req.Command == "" {
+		http.Error(w, "job_id and command are required", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if s.running[req.JobID] {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// Non-blocking slot acquisition: the dispatcher only waits 5s,
+	// so reject immediately instead of queueing behind long commands.
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.mu.Unlock()
+		http.Error(w, "Agent at capacity", http.StatusServiceUnavailable)
+		return
+	}
+	s.launchLocked(req)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Poll runs pull mode: lease jobs from /agent/jobs/next instead of
+// accepting /execute, for agents that accept no inbound connections.
+// A job is only leased once a slot is free to run it.
+func (s *Server) Poll(nodeID string, wait time.Duration) {
+	backoff := 1 * time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		select {
+		case s.slots <- struct{}{}:
+		case <-s.ctx.Done():
+			return
+		}
+
+		req, err := s.client.NextJob(s.ctx, nodeID, wait)
+		if err != nil {
+			<-s.slots
+			if s.ctx.Err() != nil {
+				return
+			}
+			log.Printf("⚠️ Job poll failed: %v (retrying in %v)", err, backoff)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = 1 * time.Second
+
+		if req == nil {
+			<-s.slots // Long-poll expired with nothing queued
+			continue
+		}
+
+		s.mu.Lock()
+		if s.running[req.JobID] {
+			s.mu.Unlock()
+			<-s.slots
+			continue
+		}
+		s.launchLocked(*req)
+		s.mu.Unlock()
+	}
+}
+
+// launchLocked starts the job. Caller holds s.mu and a slot.
+func (s *Server) launchLocked(req executeRequest) {
+	s.running[req.JobID] = true
+	s.wg.Add(1)
+	go s.run(req)
+}
+
+func (s *Server) run(req executeRequest) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, req.JobID)
+		s.mu.Unlock()
+		<-s.slots
+		s.wg.Done()
+	}()
+
+	log.Printf("Executing job %s: %s", req.JobID, req.Command)
+	start := time.Now()
+	result := s.executor.Run(s.ctx, req.JobID, req.Command, time.Duration(req.TimeoutSeconds)*time.Second)
+	log.Printf("Command finished for job %s: status=%s exit_code=%d duration=%v stdout_bytes=%d",
+		req.JobID, result.Status, result.ExitCode, time.Since(start), len(result.Stdout))
+
+	s.report(result)
+}
+
+// report delivers the result with retries. The control plane may be mid
+// failover, and a lost result leaves the reconciler waiting until timeout.
+func (s *Server) report(result JobResult) {
+	backoff := 500 * time.Millisecond
+	const maxAttempts = 6
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		// Use a detached context so results still flush during shutdown
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := s.client.ReportResult(ctx, result)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		var se *statusError
+		if errors.As(err, &se) && se.StatusCode >= 400 && se.StatusCode < 500 && se.StatusCode != http.StatusTooManyRequests {
+			log.Printf("❌ Result for job %s rejected: %v", result.JobID, err)
+			return
+		}
+
+		log.Printf("⚠️ Failed to report result for job %s (attempt %d/%d): %v", result.JobID, attempt, maxAttempts, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf("❌ Giving up reporting result for job %s", result.JobID)
+}
+
+// Wait blocks until all running jobs have finished and reported.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
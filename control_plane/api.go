@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -12,11 +13,14 @@ import (
 
 	"golang.org/x/time/rate"
 
+	"github.com/itskum47/FluxForge/control_plane/attestation"
 	"github.com/itskum47/FluxForge/control_plane/coordination"
 	"github.com/itskum47/FluxForge/control_plane/idempotency"
 	"github.com/itskum47/FluxForge/control_plane/incident"
+	"github.com/itskum47/FluxForge/control_plane/jobnotify"
 	"github.com/itskum47/FluxForge/control_plane/middleware"
 	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/resilience"
 	"github.com/itskum47/FluxForge/control_plane/scheduler"
 	"github.com/itskum47/FluxForge/control_plane/store"
 )
@@ -34,6 +38,15 @@ type API struct {
 
 	idempotency *idempotency.Store
 
+	// incidents persists captured incident snapshots
+	incidents incident.Repository
+
+	// verifier checks agent attestation on registration (nil = warn only)
+	verifier *attestation.Verifier
+
+	// forwarder relays reconciles for other shards to their owning pod
+	forwarder *ShardForwarder
+
 	// Storm Protection
 	heartbeatLimiter *rate.Limiter
 	reconcileLimiter *rate.Limiter
@@ -47,6 +60,7 @@ func NewAPI(store store.Store, dispatcher *Dispatcher, reconciler *Reconciler, s
 		scheduler:   scheduler,
 		elector:     elector,
 		idempotency: idempotencyStore,
+		incidents:   incident.NewMemoryRepository(),
 		// Allow 100 heartbeats/sec, burst 200
 		heartbeatLimiter: rate.NewLimiter(rate.Limit(100), 200),
 		// Allow 10 reconciles/sec, burst 20
@@ -62,6 +76,16 @@ func NewAPI(store store.Store, dispatcher *Dispatcher, reconciler *Reconciler, s
 	return api
 }
 
+// SetAttestationVerifier enables X-Agent-Signature verification on /agent/register.
+func (a *API) SetAttestationVerifier(v *attestation.Verifier) {
+	a.verifier = v
+}
+
+// SetDegradedMode reports degraded mode on the dashboard.
+func (a *API) SetDegradedMode(dm *resilience.DegradedMode) {
+	a.dashboardService.SetDegradedMode(dm)
+}
+
 // Wrapper for capturing response
 type responseRecorder struct {
 	http.ResponseWriter
@@ -128,8 +152,8 @@ func (a *API) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Attestation Stub
-	if sig := r.Header.Get("X-Agent-Signature"); sig == "" {
+	sig := r.Header.Get(attestation.SignatureHeader)
+	if sig == "" {
 		log.Println("Warning: Agent registration missing X-Agent-Signature")
 	}
 
@@ -145,6 +169,15 @@ func (a *API) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Attestation: enforced only when a verifier is configured
+	if a.verifier != nil && a.verifier.IsEnabled() {
+		if err := a.verifyAttestation(sig, agent.NodeID); err != nil {
+			log.Printf("Rejected registration for %s: %v", agent.NodeID, err)
+			http.Error(w, "Attestation failed", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// Set defaults
 	if agent.Status == "" {
 		agent.Status = "active"
@@ -172,6 +205,21 @@ func (a *API) handleRegister(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "registered"})
 }
 
+// verifyAttestation validates the X-Agent-Signature claim for nodeID.
+func (a *API) verifyAttestation(sig string, nodeID string) error {
+	if sig == "" {
+		return fmt.Errorf("missing %s", attestation.SignatureHeader)
+	}
+	claim, err := attestation.DecodeClaim(sig)
+	if err != nil {
+		return err
+	}
+	if claim.NodeID != nodeID {
+		return fmt.Errorf("claim node_id %s does not match %s", claim.NodeID, nodeID)
+	}
+	return a.verifier.Verify(claim)
+}
+
 // handleSetAdmissionMode updates the scheduler admission mode (Pilot Kill Switch).
 func (a *API) handleSetAdmissionMode(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -233,6 +281,12 @@ func (a *API) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := a.store.UpdateAgentHeartbeat(r.Context(), tenantID, req.NodeID, time.Now()); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// 410 tells a decommissioned agent to stop; 404 to re-register
+			status := a.deletedStatus(r.Context(), tenantID, store.ResourceAgent, req.NodeID)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
 		log.Printf("Failed to update heartbeat for %s: %v", req.NodeID, err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -242,25 +296,95 @@ func (a *API) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-func (a *API) handleListAgents(w http.ResponseWriter, r *http.Request) {
+// maxJobPollWait caps /agent/jobs/next long-polls so idle agents don't pin
+// connections through proxies with shorter idle timeouts.
+const maxJobPollWait = 30 * time.Second
+
+// handleNextJob lets pull-mode agents lease their next queued job.
+// GET /agent/jobs/next?node_id=X&wait=25s
+// 200 with the job, or 204 when nothing was queued before wait elapsed.
+func (a *API) handleNextJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	nodeID := r.URL.Query().Get("node_id")
+	if nodeID == "" {
+		http.Error(w, "node_id is required", http.StatusBadRequest)
+		return
+	}
+
+	wait := maxJobPollWait
+	if raw := r.URL.Query().Get("wait"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			http.Error(w, "Invalid wait duration", http.StatusBadRequest)
+			return
+		}
+		if d < wait {
+			wait = d
+		}
+	}
+
 	tenantID, err := middleware.GetTenantFromContext(r.Context())
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	// tenantID := r.URL.Query().Get("tenant_id") // Deprecated
-	agents, err := a.store.ListAgents(r.Context(), tenantID)
+
+	agent, err := a.store.GetAgent(r.Context(), tenantID, nodeID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if agent == nil {
+		http.Error(w, "Agent not registered", http.StatusNotFound)
+		return
+	}
+
+	job, err := a.dispatcher.Pull().Next(r.Context(), tenantID, nodeID, wait)
 	if err != nil {
+		log.Printf("Failed to lease job for %s: %v", nodeID, err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if job == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.Printf("Job %s leased by agent %s", job.JobID, nodeID)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(agents)
+	json.NewEncoder(w).Encode(newJobAssignment(job))
+}
+
+func (a *API) handleListAgents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := a.store.QueryAgents(r.Context(), tenantID, opts)
+	if errors.Is(err, store.ErrInvalidListOptions) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writePage(w, r, page.Agents, page.NextCursor)
 }
 
 // -- Phase 2: Remote Execution --
@@ -363,15 +487,21 @@ func (a *API) handleListJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nodeID := r.URL.Query().Get("node_id")
-	// store interface ListJobs(ctx, tenantID, nodeID, limit)
-	jobs, err := a.store.ListJobs(r.Context(), tenantID, nodeID, 50) // Default limit 50
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := a.store.QueryJobs(r.Context(), tenantID, opts)
+	if errors.Is(err, store.ErrInvalidListOptions) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(jobs)
+	writePage(w, r, page.Jobs, page.NextCursor)
 }
 
 func (a *API) handleJobResult(w http.ResponseWriter, r *http.Request) {
@@ -406,6 +536,16 @@ func (a *API) handleJobResult(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Job %s completed with status: %s", result.JobID, result.Status)
+
+	// Wake the reconciler waiting on this job (here or on the leader replica)
+	if a.reconciler != nil && (result.Status == "completed" || result.Status == "failed") {
+		a.reconciler.Completions().Notify(r.Context(), jobnotify.Completion{
+			TenantID: tenantID,
+			JobID:    result.JobID,
+			Status:   result.Status,
+		})
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -442,7 +582,8 @@ func (a *API) handleCreateState(w http.ResponseWriter, r *http.Request) {
 	}
 	state.TenantID = tenantID
 
-	if err := a.store.UpsertState(r.Context(), tenantID, &state); err != nil {
+	ctx := store.WithChangeInfo(r.Context(), changeInfo(r, r.Header.Get("X-Change-Reason")))
+	if err := a.store.UpsertState(ctx, tenantID, &state); err != nil {
 		log.Printf("Failed to create state: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -479,6 +620,10 @@ func (a *API) handleGetState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if state == nil {
+		if a.deletedStatus(r.Context(), tenantID, store.ResourceState, stateID) == http.StatusGone {
+			http.Error(w, "State was deleted", http.StatusGone)
+			return
+		}
 		http.Error(w, "State not found", http.StatusNotFound)
 		return
 	}
@@ -498,14 +643,21 @@ func (a *API) handleListStates(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	// tenantID := r.URL.Query().Get("tenant_id") // Deprecated
-	states, err := a.store.ListStates(r.Context(), tenantID)
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := a.store.QueryStates(r.Context(), tenantID, opts)
+	if errors.Is(err, store.ErrInvalidListOptions) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(states)
+	writePage(w, r, page.States, page.NextCursor)
 }
 
 func (a *API) handleReconcileState(w http.ResponseWriter, r *http.Request) {
@@ -552,6 +704,31 @@ func (a *API) handleReconcileState(w http.ResponseWriter, r *http.Request) {
 
 	// Submit to Scheduler
 	if err := a.scheduler.Submit(task); err != nil {
+		var wrongShard *scheduler.WrongShardError
+		if errors.As(err, &wrongShard) && a.forwarder != nil {
+			resp, ferr := a.forwarder.Forward(r.Context(), task)
+			if ferr != nil {
+				log.Printf("Failed to forward task for node %s to shard %d: %v", task.NodeID, wrongShard.Owner, ferr)
+				http.Error(w, "Service Overloaded", http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":  resp.Status,
+				"task_id": resp.TaskID,
+				"shard":   resp.Shard,
+			})
+			return
+		}
+		if errors.Is(err, scheduler.ErrDuplicateTask) {
+			// Already queued, or running and rerun once it finishes; either
+			// way a pending run picks up this state
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]string{
+				"status": "reconciliation_already_queued",
+			})
+			return
+		}
 		log.Printf("Scheduler rejected task: %v", err)
 		http.Error(w, "Service Overloaded", http.StatusServiceUnavailable)
 		return
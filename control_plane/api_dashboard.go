@@ -29,6 +29,11 @@ type DashboardMetrics struct {
 	DriftedStates int `json:"drifted_states"`
 	ActiveAgents  int `json:"active_agents"`
 
+	// Degraded Mode: the store is down and served from cache
+	Degraded      bool            `json:"degraded"`
+	PendingWrites int             `json:"pending_writes"`
+	Dependencies  map[string]bool `json:"dependencies,omitempty"`
+
 	// Multi-Cluster Support (Phase 6.4)
 	ClusterID   string `json:"cluster_id"`
 	ClusterRole string `json:"cluster_role"` // leader, follower, standby
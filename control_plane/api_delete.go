@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// handleDeleteState deletes a desired state.
+// DELETE /states/{state_id}
+// The state is replaced by a tombstone (GET then answers 410 Gone), its
+// scheduler task is cancelled and its queued jobs are marked cancelled.
+func (a *API) handleDeleteState(w http.ResponseWriter, r *http.Request) {
+	stateID := strings.TrimPrefix(r.URL.Path, "/states/")
+	if stateID == "" || strings.Contains(stateID, "/") {
+		http.Error(w, "Invalid state ID", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cancelled, err := a.deleteState(r.Context(), tenantID, stateID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "State not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete state %s: %v", stateID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "deleted",
+		"state_id":       stateID,
+		"cancelled_jobs": cancelled,
+	})
+}
+
+// handleDeleteAgent decommissions an agent.
+// DELETE /agents/{node_id}[?cascade=true]
+// An agent that still has desired states is refused with 409 Conflict
+// unless cascade=true, which deletes those states first. The agent's queued
+// jobs are cancelled and its heartbeats answered with 410 Gone.
+func (a *API) handleDeleteAgent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	nodeID := strings.TrimPrefix(r.URL.Path, "/agents/")
+	if nodeID == "" || strings.Contains(nodeID, "/") {
+		http.Error(w, "Invalid node ID", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx := r.Context()
+
+	agent, err := a.store.GetAgent(ctx, tenantID, nodeID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if agent == nil {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	states, err := a.nodeStates(ctx, tenantID, nodeID)
+	if err != nil {
+		log.Printf("Failed to list states for agent %s: %v", nodeID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(states) > 0 && r.URL.Query().Get("cascade") != "true" {
+		http.Error(w, fmt.Sprintf("Agent has %d desired states; delete them first or retry with ?cascade=true", len(states)), http.StatusConflict)
+		return
+	}
+
+	cancelled := 0
+	for _, state := range states {
+		n, err := a.deleteState(ctx, tenantID, state.StateID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to delete state %s of agent %s: %v", state.StateID, nodeID, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		cancelled += n
+	}
+
+	// Jobs submitted directly against the node, not through a state
+	n, err := a.cancelQueuedJobs(ctx, tenantID, store.ListOptions{NodeID: nodeID}, func(*store.Job) bool { return true })
+	cancelled += n
+	if err != nil {
+		log.Printf("Failed to cancel jobs of agent %s: %v", nodeID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.store.DeleteAgent(ctx, tenantID, nodeID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to delete agent %s: %v", nodeID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Agent %s decommissioned (%d states, %d jobs cancelled)", nodeID, len(states), cancelled)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "deleted",
+		"node_id":        nodeID,
+		"deleted_states": len(states),
+		"cancelled_jobs": cancelled,
+	})
+}
+
+// deleteState tombstones a state, cancels its scheduler task and cancels
+// its queued jobs. It returns the number of jobs cancelled.
+func (a *API) deleteState(ctx context.Context, tenantID, stateID string) (int, error) {
+	state, err := a.store.GetState(ctx, tenantID, stateID)
+	if err != nil {
+		return 0, err
+	}
+	if state == nil {
+		return 0, fmt.Errorf("state %s: %w", stateID, store.ErrNotFound)
+	}
+	if err := a.store.DeleteState(ctx, tenantID, stateID); err != nil {
+		return 0, err
+	}
+	a.cancelTask(ctx, state)
+
+	return a.cancelQueuedJobs(ctx, tenantID, store.ListOptions{NodeID: state.NodeID}, func(j *store.Job) bool {
+		return j.StateID == stateID
+	})
+}
+
+// cancelTask cancels the state's scheduler task here and, when another
+// shard owns its node, on the owning pod, the way reconcile submits are
+// forwarded. The state is already deleted, so a failed forward is only
+// logged: a task that still runs finds no state to reconcile.
+func (a *API) cancelTask(ctx context.Context, state *store.DesiredState) {
+	// Queued here before a rebalance moved the node, or owned here
+	a.scheduler.Cancel(state.TenantID, state.StateID)
+	if a.forwarder == nil || a.scheduler.OwnerOf(state.NodeID) == a.scheduler.ShardIndex() {
+		return
+	}
+	if _, err := a.forwarder.ForwardCancel(ctx, state.TenantID, state.NodeID, state.StateID); err != nil {
+		log.Printf("Failed to cancel the task of deleted state %s on its shard: %v", state.StateID, err)
+	}
+}
+
+// nodeStates returns every desired state bound to a node.
+func (a *API) nodeStates(ctx context.Context, tenantID, nodeID string) ([]*store.DesiredState, error) {
+	var states []*store.DesiredState
+	opts := store.ListOptions{NodeID: nodeID, Limit: store.MaxPageSize}
+	for {
+		page, err := a.store.QueryStates(ctx, tenantID, opts)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, page.States...)
+		if page.NextCursor == "" {
+			return states, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// cancelQueuedJobs marks the queued jobs matched by opts and match as
+// cancelled, so agents never claim them. Each update only applies to a job
+// still queued: one an agent claimed since the listing keeps running and
+// finishes.
+func (a *API) cancelQueuedJobs(ctx context.Context, tenantID string, opts store.ListOptions, match func(*store.Job) bool) (int, error) {
+	opts.Status = "queued"
+	opts.Limit = store.MaxPageSize
+	cancelled := 0
+	for {
+		page, err := a.store.QueryJobs(ctx, tenantID, opts)
+		if err != nil {
+			return cancelled, err
+		}
+		for _, job := range page.Jobs {
+			if !match(job) {
+				continue
+			}
+			err := a.store.UpdateJobStatus(store.WithExpectedJobStatus(ctx, "queued"), tenantID, job.JobID, "cancelled", 0, "", "")
+			if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrJobStatusConflict) {
+				continue
+			}
+			if err != nil {
+				return cancelled, err
+			}
+			cancelled++
+		}
+		if page.NextCursor == "" {
+			return cancelled, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// deletedStatus answers a read of a missing record: 410 Gone if it was
+// deleted, 404 otherwise.
+func (a *API) deletedStatus(ctx context.Context, tenantID string, resource store.Resource, id string) int {
+	tomb, err := a.store.GetTombstone(ctx, tenantID, resource, id)
+	if err == nil && tomb != nil {
+		return http.StatusGone
+	}
+	return http.StatusNotFound
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/itskum47/FluxForge/control_plane/idempotency"
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/scheduler"
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/streaming"
+)
+
+func TestDeleteAgentCascade(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	dispatcher := NewDispatcher(s)
+	reconciler := NewReconciler(s, dispatcher, streaming.NewLogPublisher())
+	sched := scheduler.NewScheduler(s, reconciler, 0, 1, scheduler.DefaultSchedulerConfig())
+	sched.RehydrateQueue(ctx) // Activate without starting workers
+	api := NewAPI(s, dispatcher, reconciler, sched, nil, idempotency.NewStore(nil))
+
+	if err := s.UpsertAgent(ctx, "t1", &store.Agent{NodeID: "n1", Status: "active"}); err != nil {
+		t.Fatalf("UpsertAgent: %v", err)
+	}
+	for _, id := range []string{"s1", "s2"} {
+		if err := s.UpsertState(ctx, "t1", &store.DesiredState{StateID: id, NodeID: "n1", Status: "pending"}); err != nil {
+			t.Fatalf("UpsertState: %v", err)
+		}
+	}
+	for _, job := range []*store.Job{
+		{JobID: "j1", NodeID: "n1", StateID: "s1", Status: "queued"},
+		{JobID: "j2", NodeID: "n1", Status: "queued"},
+		{JobID: "j3", NodeID: "n1", StateID: "s2", Status: "completed"},
+	} {
+		if err := s.CreateJob(ctx, "t1", job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	if err := sched.Submit(&scheduler.ReconciliationTask{ReqID: "r1", NodeID: "n1", TenantID: "t1", StateID: "s1", Priority: 5}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TenantKey, "t1"))
+		w := httptest.NewRecorder()
+		switch {
+		case strings.HasPrefix(path, "/agents/"):
+			api.handleDeleteAgent(w, req)
+		case path == "/agent/heartbeat":
+			api.handleHeartbeat(w, req)
+		case method == http.MethodDelete:
+			api.handleDeleteState(w, req)
+		default:
+			api.handleGetState(w, req)
+		}
+		return w
+	}
+
+	if w := do(http.MethodDelete, "/agents/n1", ""); w.Code != http.StatusConflict {
+		t.Fatalf("DELETE without cascade = %d, want 409", w.Code)
+	}
+	if a, _ := s.GetAgent(ctx, "t1", "n1"); a == nil {
+		t.Fatal("refused delete removed the agent")
+	}
+
+	if w := do(http.MethodDelete, "/agents/n1?cascade=true", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE with cascade = %d %s", w.Code, w.Body)
+	}
+	if a, _ := s.GetAgent(ctx, "t1", "n1"); a != nil {
+		t.Error("agent survived cascade delete")
+	}
+	if states, _ := s.ListStates(ctx, "t1"); len(states) != 0 {
+		t.Errorf("states survived cascade delete: %v", states)
+	}
+	if sched.GetMetrics().QueueDepth != 0 {
+		t.Error("scheduler task for a deleted state is still queued")
+	}
+	for id, want := range map[string]string{"j1": "cancelled", "j2": "cancelled", "j3": "completed"} {
+		if job, _ := s.GetJob(ctx, "t1", id); job == nil || job.Status != want {
+			t.Errorf("job %s = %+v, want status %s", id, job, want)
+		}
+	}
+
+	if w := do(http.MethodGet, "/states/s1", ""); w.Code != http.StatusGone {
+		t.Errorf("GET deleted state = %d, want 410", w.Code)
+	}
+	if w := do(http.MethodGet, "/states/never", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET unknown state = %d, want 404", w.Code)
+	}
+	if w := do(http.MethodPost, "/agent/heartbeat", `{"node_id":"n1"}`); w.Code != http.StatusGone {
+		t.Errorf("heartbeat from deleted agent = %d, want 410", w.Code)
+	}
+	if w := do(http.MethodDelete, "/agents/n1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", w.Code)
+	}
+	if w := do(http.MethodDelete, "/states/s2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE deleted state = %d, want 404", w.Code)
+	}
+}
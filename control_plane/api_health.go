@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/itskum47/FluxForge/control_plane/resilience"
+)
+
+// healthHandler reports whether the control plane is serving normally
+// ("ok") or degraded, with each dependency and the writes waiting for
+// replay. It answers 200 either way: a degraded replica still serves from
+// cache, and restarting it would lose the queued writes.
+func healthHandler(degraded *resilience.DegradedMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := struct {
+			Status        string          `json:"status"`
+			Dependencies  map[string]bool `json:"dependencies"`
+			PendingWrites int             `json:"pending_writes"`
+		}{
+			Status:        "ok",
+			Dependencies:  degraded.HealthCheck(r.Context()),
+			PendingWrites: degraded.GetPendingWriteCount(),
+		}
+		if resp.Dependencies["degraded"] {
+			resp.Status = "degraded"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
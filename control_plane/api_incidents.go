@@ -3,99 +3,143 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/itskum47/FluxForge/control_plane/incident"
+	"github.com/itskum47/FluxForge/control_plane/middleware"
 	"github.com/itskum47/FluxForge/control_plane/timeline"
 )
 
 // IncidentSnapshot represents a captured incident for replay.
-type IncidentSnapshot struct {
-	IncidentID    string `json:"incident_id"`
-	StateID       string `json:"state_id"`
-	NodeID        string `json:"node_id"`
-	TenantID      string `json:"tenant_id"`
-	FailureReason string `json:"failure_reason"`
-	Timestamp     int64  `json:"timestamp"`
-
-	// Snapshots
-	SchedulerSnapshot SchedulerSnapshot         `json:"scheduler_snapshot"`
-	LeaderSnapshot    LeaderSnapshot            `json:"leader_snapshot"`
-	Timeline          []timeline.ReconcileEvent `json:"timeline"`
-}
-
-type SchedulerSnapshot struct {
-	QueueDepth          int     `json:"queue_depth"`
-	ActiveTasks         int     `json:"active_tasks"`
-	WorkerSaturation    float64 `json:"worker_saturation"`
-	CircuitBreakerState string  `json:"circuit_breaker_state"`
-	RuntimeMode         string  `json:"runtime_mode"`
-}
+type IncidentSnapshot = incident.Snapshot
 
-type LeaderSnapshot struct {
-	IsLeader     bool   `json:"is_leader"`
-	CurrentEpoch int64  `json:"current_epoch"`
-	NodeID       string `json:"node_id"`
+// SetIncidentRepository configures where captured incidents are persisted.
+func (a *API) SetIncidentRepository(repo incident.Repository) {
+	a.incidents = repo
 }
 
-// handleListIncidents returns all captured incidents.
+// handleListIncidents returns captured incidents for the tenant, newest first.
+// Filters: state_id, node_id, since, until (RFC3339), limit, cursor.
+// The array shape is kept for the dashboard; the next page cursor is
+// returned in the X-Next-Cursor header.
 func (a *API) handleListIncidents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// For now, return empty list (incidents would be stored in DB)
-	incidents := []IncidentSnapshot{}
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filter, err := parseIncidentFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	incidents, next, err := a.incidents.List(r.Context(), tenantID, filter)
+	if err != nil {
+		log.Printf("Failed to list incidents: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 	json.NewEncoder(w).Encode(incidents)
 }
 
-// handleReplayIncident simulates an incident replay.
+func parseIncidentFilter(r *http.Request) (incident.ListFilter, error) {
+	v := r.URL.Query()
+	filter := incident.ListFilter{
+		StateID: v.Get("state_id"),
+		NodeID:  v.Get("node_id"),
+		Cursor:  v.Get("cursor"),
+	}
+	if raw := v.Get("since"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since: %v", err)
+		}
+		filter.Since = t.Unix()
+	}
+	if raw := v.Get("until"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until: %v", err)
+		}
+		filter.Until = t.Unix()
+	}
+	if raw := v.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid limit: %s", raw)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// handleReplayIncident rebuilds the incident from persisted timeline events,
+// state transitions and jobs. Optional ?window=1h widens the look-back.
 func (a *API) handleReplayIncident(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Extract incident ID from path
 	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 5 {
+	if len(pathParts) < 5 || pathParts[4] == "" {
 		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
 		return
 	}
 	incidentID := pathParts[4]
 
-	// For now, return a simulated replay timeline
-	replay := map[string]interface{}{
-		"incident_id": incidentID,
-		"status":      "replay_complete",
-		"timeline": []map[string]interface{}{
-			{
-				"timestamp": time.Now().Add(-5 * time.Minute).Unix(),
-				"event":     "State submitted",
-				"details":   "Desired state created",
-			},
-			{
-				"timestamp": time.Now().Add(-4 * time.Minute).Unix(),
-				"event":     "Queued for reconciliation",
-				"details":   "Priority: 5, Queue depth: 42",
-			},
-			{
-				"timestamp": time.Now().Add(-3 * time.Minute).Unix(),
-				"event":     "Reconciliation started",
-				"details":   "Worker assigned",
-			},
-			{
-				"timestamp": time.Now().Add(-2 * time.Minute).Unix(),
-				"event":     "Reconciliation failed",
-				"details":   "Agent timeout",
-			},
-		},
+	window := incident.DefaultReplayWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	snap, err := a.incidents.Get(r.Context(), tenantID, incidentID)
+	if errors.Is(err, incident.ErrNotFound) {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load incident %s: %v", incidentID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	replay, err := incident.BuildReplay(r.Context(), a.store, a.scheduler.GetTimeline(), snap, window)
+	if err != nil {
+		log.Printf("Failed to replay incident %s: %v", incidentID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -103,7 +147,10 @@ func (a *API) handleReplayIncident(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(replay)
 }
 
-// handleCaptureIncidentSnapshot captures current state for an incident.
+// errStateNotFound is returned when capturing an incident for an unknown state.
+var errStateNotFound = errors.New("state not found")
+
+// handleCaptureIncidentSnapshot captures and persists current state for an incident.
 func (a *API) handleCaptureIncidentSnapshot(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -116,6 +163,13 @@ func (a *API) handleCaptureIncidentSnapshot(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+
 	// Capture asynchronously to prevent blocking scheduler
 	resultChan := make(chan IncidentSnapshot, 1)
 	errorChan := make(chan error, 1)
@@ -124,7 +178,7 @@ func (a *API) handleCaptureIncidentSnapshot(w http.ResponseWriter, r *http.Reque
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
 
-		snapshot, err := a.captureIncidentAsync(ctx, stateID)
+		snapshot, err := a.captureIncidentAsync(ctx, tenantID, stateID, reason)
 		if err != nil {
 			errorChan <- err
 			return
@@ -141,6 +195,10 @@ func (a *API) handleCaptureIncidentSnapshot(w http.ResponseWriter, r *http.Reque
 		json.NewEncoder(w).Encode(snapshot)
 
 	case err := <-errorChan:
+		if errors.Is(err, errStateNotFound) {
+			http.Error(w, "State not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to capture incident: %v", err), http.StatusInternalServerError)
 
 	case <-time.After(5 * time.Second):
@@ -148,14 +206,29 @@ func (a *API) handleCaptureIncidentSnapshot(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-// captureIncidentAsync performs the actual incident capture with timeout.
-func (a *API) captureIncidentAsync(ctx context.Context, stateID string) (IncidentSnapshot, error) {
+// captureIncidentAsync performs the actual incident capture with timeout
+// and persists the snapshot so it can be listed and replayed later.
+func (a *API) captureIncidentAsync(ctx context.Context, tenantID string, stateID string, reason string) (IncidentSnapshot, error) {
+	state, err := a.store.GetState(ctx, tenantID, stateID)
+	if err != nil {
+		return IncidentSnapshot{}, err
+	}
+	if state == nil {
+		return IncidentSnapshot{}, errStateNotFound
+	}
+	if reason == "" {
+		reason = state.LastError
+	}
+	if reason == "" {
+		reason = "Captured via API"
+	}
+
 	// Capture current state
 	schedMetrics := a.scheduler.GetMetrics()
-	var leaderState LeaderSnapshot
+	var leaderState incident.LeaderSnapshot
 	if a.elector != nil {
 		state := a.elector.GetState()
-		leaderState = LeaderSnapshot{
+		leaderState = incident.LeaderSnapshot{
 			IsLeader:     state.IsLeader,
 			CurrentEpoch: state.CurrentEpoch,
 			NodeID:       state.NodeID,
@@ -163,15 +236,24 @@ func (a *API) captureIncidentAsync(ctx context.Context, stateID string) (Inciden
 	}
 
 	// Get timeline events for this state
-	tl := a.scheduler.GetTimeline()
-	events := tl.GetAllEvents()
+	now := time.Now()
+	events, err := a.scheduler.GetTimeline().Query(ctx, timeline.Query{
+		TenantID: tenantID,
+		StateID:  stateID,
+		Since:    now.Add(-incident.DefaultReplayWindow),
+	})
+	if err != nil {
+		return IncidentSnapshot{}, err
+	}
 
-	return IncidentSnapshot{
-		IncidentID:    fmt.Sprintf("incident-%d", time.Now().Unix()),
+	snapshot := IncidentSnapshot{
+		IncidentID:    "incident-" + generateUUID(),
 		StateID:       stateID,
-		Timestamp:     time.Now().Unix(),
-		FailureReason: "Captured via API",
-		SchedulerSnapshot: SchedulerSnapshot{
+		NodeID:        state.NodeID,
+		TenantID:      tenantID,
+		Timestamp:     now.Unix(),
+		FailureReason: reason,
+		SchedulerSnapshot: incident.SchedulerSnapshot{
 			QueueDepth:          schedMetrics.QueueDepth,
 			ActiveTasks:         schedMetrics.ActiveTasks,
 			WorkerSaturation:    schedMetrics.WorkerSaturation,
@@ -180,5 +262,48 @@ func (a *API) captureIncidentAsync(ctx context.Context, stateID string) (Inciden
 		},
 		LeaderSnapshot: leaderState,
 		Timeline:       events,
-	}, nil
+	}
+
+	if err := a.incidents.Save(ctx, &snapshot); err != nil {
+		return IncidentSnapshot{}, fmt.Errorf("failed to persist incident: %w", err)
+	}
+	return snapshot, nil
+}
+
+// debugSnapshotLimit bounds the timeline events of a debug snapshot
+// requested without a limit.
+const debugSnapshotLimit = 1000
+
+// handleDebugSnapshot serves the scheduler snapshot with the caller's
+// timeline events, filtered by parseTimelineQuery. tenant_id may only name
+// the caller's own tenant.
+func (a *API) handleDebugSnapshot(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	q, _, err := parseTimelineQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.TenantID != "" && q.TenantID != tenantID {
+		http.Error(w, "Forbidden: tenant_id is not your tenant", http.StatusForbidden)
+		return
+	}
+	q.TenantID = tenantID
+	if q.Limit == 0 {
+		q.Limit = debugSnapshotLimit
+	}
+
+	events, err := a.scheduler.GetTimeline().Query(r.Context(), q)
+	if err != nil {
+		http.Error(w, "Timeline query failed", http.StatusInternalServerError)
+		return
+	}
+	snapshot := a.scheduler.GetSnapshot()
+	snapshot["timeline_events"] = events
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/itskum47/FluxForge/control_plane/idempotency"
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/scheduler"
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/streaming"
+	"github.com/itskum47/FluxForge/control_plane/timeline"
+)
+
+func TestDebugSnapshotScopedToTenant(t *testing.T) {
+	s := store.NewMemoryStore()
+	dispatcher := NewDispatcher(s)
+	reconciler := NewReconciler(s, dispatcher, streaming.NewLogPublisher())
+	sched := scheduler.NewScheduler(s, reconciler, 0, 1, scheduler.DefaultSchedulerConfig())
+	api := NewAPI(s, dispatcher, reconciler, sched, nil, idempotency.NewStore(nil))
+
+	sched.GetTimeline().Record(timeline.ReconcileEvent{ReqID: "r1", Stage: "QUEUED", TenantID: "t1"})
+	sched.GetTimeline().Record(timeline.ReconcileEvent{ReqID: "r2", Stage: "QUEUED", TenantID: "t2"})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/scheduler/debug/snapshot?"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TenantKey, "t1"))
+		w := httptest.NewRecorder()
+		api.handleDebugSnapshot(w, req)
+		return w
+	}
+
+	for _, query := range []string{"", "tenant_id=t1", "req_id=r1"} {
+		w := get(query)
+		var snapshot struct {
+			Events []timeline.ReconcileEvent `json:"timeline_events"`
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET ?%s = %d", query, w.Code)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(snapshot.Events) != 1 || snapshot.Events[0].ReqID != "r1" {
+			t.Errorf("GET ?%s events = %+v; want only t1's", query, snapshot.Events)
+		}
+	}
+	if w := get("tenant_id=t2"); w.Code != http.StatusForbidden {
+		t.Errorf("GET ?tenant_id=t2 = %d, want 403", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/scheduler/debug/snapshot", nil)
+	w := httptest.NewRecorder()
+	api.handleDebugSnapshot(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET without a tenant = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// parseListOptions reads the paging and filter parameters shared by the
+// /agents, /states and /jobs listings:
+//
+//	limit, cursor, order (asc|desc)
+//	status, node_id, tier
+//	created_since, created_before, updated_since, updated_before (RFC 3339)
+//	label=key=value (repeatable; agents must carry every label)
+func parseListOptions(q url.Values) (store.ListOptions, error) {
+	opts := store.ListOptions{
+		Cursor: q.Get("cursor"),
+		Order:  store.SortOrder(q.Get("order")),
+		Status: q.Get("status"),
+		NodeID: q.Get("node_id"),
+		Tier:   q.Get("tier"),
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("limit must be a positive integer")
+		}
+		opts.Limit = limit
+	}
+
+	for param, dst := range map[string]*time.Time{
+		"created_since":  &opts.CreatedSince,
+		"created_before": &opts.CreatedBefore,
+		"updated_since":  &opts.UpdatedSince,
+		"updated_before": &opts.UpdatedBefore,
+	} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be an RFC 3339 time", param)
+			}
+			*dst = t
+		}
+	}
+
+	for _, label := range q["label"] {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok || k == "" {
+			return opts, fmt.Errorf("label must be key=value")
+		}
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string)
+		}
+		opts.Labels[k] = v
+	}
+	return opts, nil
+}
+
+// writePage writes one page of a listing. The body stays a bare JSON array;
+// the cursor for the next page goes in X-Next-Cursor and a Link header.
+func writePage(w http.ResponseWriter, r *http.Request, items interface{}, nextCursor string) {
+	if nextCursor != "" {
+		q := r.URL.Query()
+		q.Set("cursor", nextCursor)
+		next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		w.Header().Set("X-Next-Cursor", nextCursor)
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func TestListJobsPagination(t *testing.T) {
+	s := store.NewMemoryStore()
+	api := &API{store: s}
+
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"j0", "j1", "j2"} {
+		job := &store.Job{JobID: id, NodeID: "n1", Status: "completed", CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := s.CreateJob(context.Background(), "t1", job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+
+	list := func(query string) ([]store.Job, *httptest.ResponseRecorder) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/jobs?"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.TenantKey, "t1"))
+		w := httptest.NewRecorder()
+		api.handleListJobs(w, req)
+		var jobs []store.Job
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &jobs); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return jobs, w
+	}
+
+	jobs, w := list("limit=2&node_id=n1")
+	if w.Code != http.StatusOK || len(jobs) != 2 || jobs[0].JobID != "j2" || jobs[1].JobID != "j1" {
+		t.Fatalf("first page = %d %v", w.Code, jobs)
+	}
+	cursor := w.Header().Get("X-Next-Cursor")
+	if cursor == "" || w.Header().Get("Link") == "" {
+		t.Fatalf("first page has no next cursor (headers %v)", w.Header())
+	}
+
+	jobs, w = list("limit=2&node_id=n1&cursor=" + cursor)
+	if w.Code != http.StatusOK || len(jobs) != 1 || jobs[0].JobID != "j0" {
+		t.Fatalf("second page = %d %v", w.Code, jobs)
+	}
+	if w.Header().Get("X-Next-Cursor") != "" {
+		t.Error("last page has a next cursor")
+	}
+
+	for _, query := range []string{"limit=zero", "cursor=bogus", "order=up", "tier=premium", "created_since=yesterday", "label=novalue"} {
+		if _, w := list(query); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /jobs?%s = %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/itskum47/FluxForge/control_plane/migration"
+)
+
+// storeMigrationHandler serves the cut-over switch of a live store
+// migration. A switch is stored on the coordinator and followed by every
+// replica (see migration.Router).
+//
+//	GET  /admin/store-migration   routing and the last sync report (leader only)
+//	POST /admin/store-migration   {"read_from": "<backend>", "primary": "<backend>"}; either may be omitted
+func storeMigrationHandler(dual *migration.DualStore, router *migration.Router, syncer *migration.Syncer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req struct {
+				ReadFrom string `json:"read_from"`
+				Primary  string `json:"primary"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			for _, name := range []string{req.ReadFrom, req.Primary} {
+				if name != "" && name != dual.Status().Primary && name != dual.Status().Secondary {
+					http.Error(w, "Unknown backend "+name, http.StatusBadRequest)
+					return
+				}
+			}
+			if _, err := router.Switch(r.Context(), req.Primary, req.ReadFrom); err != nil {
+				if errors.Is(err, migration.ErrConcurrentSwitch) {
+					http.Error(w, "Routing was switched concurrently; retry", http.StatusConflict)
+					return
+				}
+				log.Printf("Store migration switch failed: %v", err)
+				http.Error(w, "Failed to switch routing", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("🚨 ADMIN ACTION: Store migration routing set to %+v", dual.Status())
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := struct {
+			migration.Status
+			LastSync *migration.Report `json:"last_sync"`
+		}{Status: dual.Status()}
+		if syncer != nil {
+			resp.LastSync = syncer.LastReport()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/scheduler"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// changeInfo attributes a state change to the caller: the token's subject,
+// or its role for tokens without one.
+func changeInfo(r *http.Request, reason string) store.ChangeInfo {
+	info := store.ChangeInfo{Reason: reason}
+	if claims, err := middleware.GetClaimsFromContext(r.Context()); err == nil {
+		info.Author = claims.Subject
+		if info.Author == "" {
+			info.Author = "role:" + claims.Role
+		}
+	}
+	return info
+}
+
+// handleStateRevisions serves a state's revision history:
+//
+//	GET /states/{state_id}/revisions                    all revisions, oldest first
+//	GET /states/{state_id}/revisions/{n}                one revision
+//	GET /states/{state_id}/revisions/diff?from=N&to=M   field-level diff (to defaults to the latest)
+func (a *API) handleStateRevisions(w http.ResponseWriter, r *http.Request) {
+	// /states/{state_id}/revisions[/...]
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 || len(pathParts) > 4 || pathParts[2] != "revisions" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	stateID := pathParts[1]
+
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	revs, err := a.store.ListStateRevisions(r.Context(), tenantID, stateID)
+	if err != nil {
+		log.Printf("Failed to list revisions of state %s: %v", stateID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(revs) == 0 {
+		status := a.deletedStatus(r.Context(), tenantID, store.ResourceState, stateID)
+		http.Error(w, "State not found", status)
+		return
+	}
+
+	var result interface{} = revs
+	switch {
+	case len(pathParts) == 3:
+	case pathParts[3] == "diff":
+		q := r.URL.Query()
+		from, err := revisionParam(q.Get("from"), 0, revs)
+		if err != nil {
+			http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := revisionParam(q.Get("to"), revs[len(revs)-1].Revision, revs)
+		if err != nil {
+			http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = map[string]interface{}{
+			"state_id": stateID,
+			"from":     from.Revision,
+			"to":       to.Revision,
+			"changes":  store.DiffStates(&from.State, &to.State),
+		}
+	default:
+		rev, err := revisionParam(pathParts[3], 0, revs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		result = rev
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// revisionParam finds the revision named by param in revs; an empty param
+// means def (0 = required).
+func revisionParam(param string, def int, revs []*store.StateRevision) (*store.StateRevision, error) {
+	n := def
+	if param != "" {
+		var err error
+		if n, err = strconv.Atoi(param); err != nil {
+			return nil, fmt.Errorf("revision must be an integer")
+		}
+	} else if def == 0 {
+		return nil, fmt.Errorf("revision is required")
+	}
+	for _, rev := range revs {
+		if rev.Revision == n {
+			return rev, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not found", n)
+}
+
+// handleRollbackState restores a state's spec from an earlier revision.
+// POST /states/{state_id}/rollback?to=N
+// The rollback is itself a new revision (reason from X-Change-Reason, or
+// "rollback to revision N"), and the state is reconciled right away.
+func (a *API) handleRollbackState(w http.ResponseWriter, r *http.Request) {
+	// /states/{state_id}/rollback
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 {
+		http.Error(w, "Invalid state ID", http.StatusBadRequest)
+		return
+	}
+	stateID := pathParts[1]
+
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || to < 1 {
+		http.Error(w, "to must be a revision number", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	state, err := a.store.GetState(r.Context(), tenantID, stateID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if state == nil {
+		status := a.deletedStatus(r.Context(), tenantID, store.ResourceState, stateID)
+		http.Error(w, "State not found", status)
+		return
+	}
+	rev, err := a.store.GetStateRevision(r.Context(), tenantID, stateID, to)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if rev == nil {
+		http.Error(w, fmt.Sprintf("Revision %d not found", to), http.StatusNotFound)
+		return
+	}
+
+	// Restore the spec; status starts over like a fresh create
+	state.NodeID = rev.State.NodeID
+	state.CheckCmd = rev.State.CheckCmd
+	state.ApplyCmd = rev.State.ApplyCmd
+	state.DesiredExitCode = rev.State.DesiredExitCode
+	state.JobTimeoutSeconds = rev.State.JobTimeoutSeconds
+	state.CheckInterval = rev.State.CheckInterval
+	state.Status = "pending"
+	state.LastError = ""
+
+	reason := r.Header.Get("X-Change-Reason")
+	if reason == "" {
+		reason = fmt.Sprintf("rollback to revision %d", to)
+	}
+	// Only over the version read: a concurrent edit is not silently undone
+	ctx := store.WithChangeInfo(r.Context(), changeInfo(r, reason))
+	ctx = store.WithExpectedVersion(ctx, state.Version)
+	if err := a.store.UpsertState(ctx, tenantID, state); err != nil {
+		if errors.Is(err, store.ErrVersionConflict) {
+			http.Error(w, "State changed during the rollback; retry", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to roll back state %s: %v", stateID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("State %s rolled back to revision %d (now revision %d)", stateID, to, state.Version)
+
+	if err := a.queueReconcile(r, tenantID, state); err != nil {
+		log.Printf("Rolled back state %s, but its reconciliation was not queued: %v", stateID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// queueReconcile submits a reconciliation of state to the scheduler,
+// forwarded to the owning shard like handleReconcileState's. One already
+// queued for the state covers it.
+func (a *API) queueReconcile(r *http.Request, tenantID string, state *store.DesiredState) error {
+	task := &scheduler.ReconciliationTask{
+		ReqID:    generateUUID(),
+		NodeID:   state.NodeID,
+		TenantID: tenantID,
+		Priority: 5,
+		Deadline: time.Now().Add(1 * time.Minute),
+		StateID:  state.StateID,
+	}
+	err := a.scheduler.Submit(task)
+	var wrongShard *scheduler.WrongShardError
+	if errors.As(err, &wrongShard) && a.forwarder != nil {
+		_, err = a.forwarder.Forward(r.Context(), task)
+	}
+	if errors.Is(err, scheduler.ErrDuplicateTask) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/itskum47/FluxForge/control_plane/auth"
+	"github.com/itskum47/FluxForge/control_plane/idempotency"
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/scheduler"
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/streaming"
+)
+
+func TestStateRevisionsAndRollback(t *testing.T) {
+	s := store.NewMemoryStore()
+	dispatcher := NewDispatcher(s)
+	reconciler := NewReconciler(s, dispatcher, streaming.NewLogPublisher())
+	sched := scheduler.NewScheduler(s, reconciler, 0, 1, scheduler.DefaultSchedulerConfig())
+	api := NewAPI(s, dispatcher, reconciler, sched, nil, idempotency.NewStore(nil))
+
+	do := func(method, path, body, reason string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		ctx := context.WithValue(req.Context(), middleware.TenantKey, "t1")
+		ctx = context.WithValue(ctx, middleware.ClaimsContextKey, &auth.Claims{TenantID: "t1", Role: "admin", Subject: "alice"})
+		req = req.WithContext(ctx)
+		if reason != "" {
+			req.Header.Set("X-Change-Reason", reason)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	for _, apply := range []string{"apply-v1", "apply-v2"} {
+		body := `{"state_id":"s1","node_id":"n1","check_cmd":"check","apply_cmd":"` + apply + `"}`
+		if w := do(http.MethodPost, "/states", body, "set "+apply, api.handleCreateState); w.Code != http.StatusCreated {
+			t.Fatalf("create state = %d %s", w.Code, w.Body)
+		}
+	}
+
+	w := do(http.MethodGet, "/states/s1/revisions", "", "", api.handleStateRevisions)
+	var revs []store.StateRevision
+	if err := json.Unmarshal(w.Body.Bytes(), &revs); err != nil || len(revs) != 2 {
+		t.Fatalf("revisions = %d %s", w.Code, w.Body)
+	}
+	if revs[0].Author != "alice" || revs[0].Reason != "set apply-v1" || revs[1].State.ApplyCmd != "apply-v2" {
+		t.Errorf("revisions = %+v", revs)
+	}
+
+	w = do(http.MethodGet, "/states/s1/revisions/diff?from=1&to=2", "", "", api.handleStateRevisions)
+	var diff struct {
+		Changes []store.FieldChange `json:"changes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil || len(diff.Changes) != 1 ||
+		diff.Changes[0].Field != "apply_cmd" || diff.Changes[0].From != "apply-v1" || diff.Changes[0].To != "apply-v2" {
+		t.Errorf("diff = %d %s", w.Code, w.Body)
+	}
+
+	if w := do(http.MethodPost, "/states/s1/rollback?to=1", "", "", api.handleRollbackState); w.Code != http.StatusOK {
+		t.Fatalf("rollback = %d %s", w.Code, w.Body)
+	}
+	rev, _ := s.GetStateRevision(context.Background(), "t1", "s1", 3)
+	if rev == nil || rev.State.ApplyCmd != "apply-v1" || rev.Reason != "rollback to revision 1" || rev.Author != "alice" {
+		t.Errorf("rollback revision = %+v", rev)
+	}
+
+	for path, want := range map[string]int{
+		"/states/s1/revisions/9":           http.StatusNotFound,
+		"/states/s1/revisions/diff?to=2":   http.StatusBadRequest,
+		"/states/nope/revisions":           http.StatusNotFound,
+		"/states/s1/revisions/diff?from=x": http.StatusBadRequest,
+	} {
+		if w := do(http.MethodGet, path, "", "", api.handleStateRevisions); w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+	if w := do(http.MethodPost, "/states/s1/rollback?to=7", "", "", api.handleRollbackState); w.Code != http.StatusNotFound {
+		t.Errorf("rollback to missing revision = %d, want 404", w.Code)
+	}
+}
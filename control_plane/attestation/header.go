@@ -0,0 +1,33 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// SignatureHeader carries the agent's attestation claim on /agent/register.
+const SignatureHeader = "X-Agent-Signature"
+
+// EncodeClaim serializes a claim for transport in the SignatureHeader.
+// Format: base64(JSON(AttestationClaim))
+func EncodeClaim(claim *AttestationClaim) (string, error) {
+	data, err := json.Marshal(claim)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claim: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodeClaim parses a SignatureHeader value produced by EncodeClaim.
+func DecodeClaim(header string) (*AttestationClaim, error) {
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim encoding: %w", err)
+	}
+	var claim AttestationClaim
+	if err := json.Unmarshal(data, &claim); err != nil {
+		return nil, fmt.Errorf("invalid claim payload: %w", err)
+	}
+	return &claim, nil
+}
@@ -18,6 +18,7 @@ type Claims struct {
 	TenantID string `json:"tenant_id"`
 	Role     string `json:"role"`
 	// Standard Claims
+	Subject   string `json:"sub,omitempty"` // The user or service acting; recorded as revision author
 	Issuer    string `json:"iss"`
 	Audience  string `json:"aud"` // We treat single string for native simplicity
 	ExpiresAt int64  `json:"exp"`
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/idempotency"
+	"github.com/itskum47/FluxForge/control_plane/jobnotify"
+	"github.com/itskum47/FluxForge/control_plane/migration"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// backends are the storage and coordination services the control plane
+// runs on, chosen by configureBackends.
+type backends struct {
+	store        store.Store
+	storeBackend string            // STORE_BACKEND: the primary of a migration
+	coordinator  store.Coordinator // nil = standalone: no leader election
+	bus          jobnotify.Bus     // nil = completions delivered in-process only
+	redis        *store.RedisStore // nil unless a backend uses Redis
+	postgres     *store.PostgresStore
+	bolt         *store.BoltStore
+	dual         *migration.DualStore // nil unless migrating between stores
+}
+
+// configureBackends opens the backends selected by the environment.
+//
+//	STORE_BACKEND            redis (default) | postgres | bolt | memory
+//	COORDINATION_BACKEND     redis | postgres | bolt | none (default: same as
+//	                         the store, none for memory)
+//	REDIS_ADDR               Redis address (default localhost:6379)
+//	DATABASE_URL             Postgres connection string
+//	BOLT_PATH                bolt store file (default fluxforge.db)
+//	STORE_SECONDARY_BACKEND  redis | postgres | bolt: mirror every write here
+//	                         to migrate live from STORE_BACKEND (default: none;
+//	                         see migration.DualStore)
+//	STORE_READ_FROM          primary (default) | secondary; a switch made
+//	                         through /admin/store-migration overrides it
+//
+// Leader election, the lock janitor, shard membership and the job
+// completion bus all run on the coordination backend, so a Postgres-only
+// deployment needs no Redis. The bolt backend is a single file owned by a
+// single process, for edge and development deployments: it has no bus, as
+// there are no other replicas to notify.
+func configureBackends(ctx context.Context) *backends {
+	b := &backends{}
+
+	openRedis := func() *store.RedisStore {
+		if b.redis == nil {
+			addr := getEnvOrDefault("REDIS_ADDR", "localhost:6379")
+			rs, err := store.NewRedisStore(addr, "", 0)
+			if err != nil {
+				log.Fatalf("Failed to connect to Redis at %s: %v", addr, err)
+			}
+			log.Printf("✅ Connected to Redis at %s", addr)
+			b.redis = rs
+		}
+		return b.redis
+	}
+	openPostgres := func() *store.PostgresStore {
+		if b.postgres == nil {
+			pg, err := postgresForAuxiliary(ctx)
+			if err != nil {
+				log.Fatalf("Failed to connect to Postgres: %v", err)
+			}
+			log.Println("✅ Connected to Postgres")
+			pg.StartExpiry(ctx, time.Minute)
+			b.postgres = pg
+		}
+		return b.postgres
+	}
+	openBolt := func() *store.BoltStore {
+		if b.bolt == nil {
+			path := getEnvOrDefault("BOLT_PATH", "fluxforge.db")
+			bs, err := store.NewBoltStore(path)
+			if err != nil {
+				log.Fatalf("Failed to open bolt store %s: %v", path, err)
+			}
+			log.Printf("✅ Opened bolt store %s", path)
+			bs.StartExpiry(ctx, time.Minute)
+			b.bolt = bs
+		}
+		return b.bolt
+	}
+
+	openStore := func(name string) store.Store {
+		switch name {
+		case "redis":
+			rs := openRedis()
+			// Index states written before the indexes existed, then keep
+			// repairing drift in the background
+			repair, err := rs.RebuildStateIndexes(ctx)
+			if err != nil {
+				log.Fatalf("Failed to rebuild Redis state indexes: %v", err)
+			}
+			log.Printf("State indexes: %d states, %d entries added, %d removed", repair.States, repair.Added, repair.Removed)
+			added, removed, err := rs.RebuildCreatedIndexes(ctx)
+			if err != nil {
+				log.Fatalf("Failed to rebuild Redis created-order indexes: %v", err)
+			}
+			log.Printf("Created-order indexes: %d entries added, %d removed", added, removed)
+			rs.StartIndexRepair(ctx, time.Hour)
+			return rs
+		case "postgres":
+			return openPostgres()
+		case "bolt":
+			return openBolt()
+		case "memory":
+			return store.NewMemoryStore()
+		}
+		log.Fatalf("Unknown store backend %q (want redis, postgres, bolt or memory)", name)
+		return nil
+	}
+
+	storeBackend := getEnvOrDefault("STORE_BACKEND", "redis")
+	b.store, b.storeBackend = openStore(storeBackend), storeBackend
+	if secondary := os.Getenv("STORE_SECONDARY_BACKEND"); secondary != "" {
+		if secondary == storeBackend || secondary == "memory" {
+			log.Fatalf("Invalid STORE_SECONDARY_BACKEND %q: want a persistent backend other than %s", secondary, storeBackend)
+		}
+		b.dual = migration.NewDualStore(storeBackend, b.store, secondary, openStore(secondary))
+		switch readFrom := getEnvOrDefault("STORE_READ_FROM", "primary"); readFrom {
+		case "primary":
+		case "secondary":
+			b.dual.SetReadFrom(secondary)
+		default:
+			log.Fatalf("Invalid STORE_READ_FROM %q (want primary or secondary)", readFrom)
+		}
+		b.store = b.dual
+		log.Printf("Store migration: writing to %s and %s, reading from %s", storeBackend, secondary, b.dual.Status().ReadFrom)
+	}
+
+	defaultCoordination := storeBackend
+	if storeBackend == "memory" {
+		defaultCoordination = "none"
+	}
+	coordBackend := getEnvOrDefault("COORDINATION_BACKEND", defaultCoordination)
+	switch coordBackend {
+	case "redis":
+		rs := openRedis()
+		b.coordinator, b.bus = rs, rs
+	case "postgres":
+		pg := openPostgres()
+		b.coordinator, b.bus = pg, pg
+	case "bolt":
+		b.coordinator = openBolt()
+	case "none":
+	default:
+		log.Fatalf("Unknown COORDINATION_BACKEND %q (want redis, postgres, bolt or none)", coordBackend)
+	}
+
+	log.Printf("Store backend: %s, coordination backend: %s", storeBackend, coordBackend)
+	return b
+}
+
+// persistenceDefault is the default timeline and incident backend: Redis
+// if it is in use, else Postgres if it is, else memory.
+func (b *backends) persistenceDefault() string {
+	switch {
+	case b.redis != nil:
+		return "redis"
+	case b.postgres != nil:
+		return "postgres"
+	default:
+		return "memory"
+	}
+}
+
+// idempotencyBackend is where API idempotency keys live: Redis, Postgres
+// or the bolt file, whichever is in use first, else nil for in-memory.
+func (b *backends) idempotencyBackend() idempotency.Backend {
+	switch {
+	case b.redis != nil:
+		return b.redis
+	case b.postgres != nil:
+		return b.postgres
+	case b.bolt != nil:
+		return b.bolt
+	default:
+		return nil
+	}
+}
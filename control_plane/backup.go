@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/itskum47/FluxForge/control_plane/backup"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+const exportUsage = `usage: fluxforge-control-plane export [flags]
+
+Writes a logical backup (versioned NDJSON) of the store selected by
+STORE_BACKEND: agents, states with their revisions, jobs and, for a full
+backup, the leader election epochs. Stop the control plane first for a
+backup that is consistent across records.
+
+flags:
+  -o file       write to file instead of stdout
+  -tenant id    export only this tenant (repeatable)
+`
+
+const importUsage = `usage: fluxforge-control-plane import [flags]
+
+Restores a backup written by export into the store selected by
+STORE_BACKEND. The whole backup is verified, and checked against records
+that already exist, before anything is written. Exporting from one backend
+and importing into another migrates between them.
+
+An import that fails while writing (the store went away) leaves the
+records written so far. Run it again with -resume and the same backup and
+flags to finish it.
+
+flags:
+  -i file       read from file instead of stdin
+  -tenant id    import only this tenant (repeatable; epochs are skipped)
+  -dry-run      verify and report what would be imported, write nothing
+  -resume       skip records an earlier, failed import of this backup wrote
+`
+
+// tenantFlags collects repeated -tenant flags.
+type tenantFlags []string
+
+func (f *tenantFlags) String() string { return strings.Join(*f, ",") }
+
+func (f *tenantFlags) Set(v string) error {
+	if v == "" {
+		return errors.New("tenant must not be empty")
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+// runExport implements the "export" subcommand and returns the exit code.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("o", "", "output file (default stdout)")
+	var tenants tenantFlags
+	fs.Var(&tenants, "tenant", "tenant to export (repeatable)")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, exportUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx := context.Background()
+	s, closeStore, err := openCLIStore(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	defer closeStore()
+
+	opts := backup.ExportOptions{}
+	if len(tenants) > 0 {
+		opts.Tenants = tenants
+	} else {
+		opts.EpochResources = epochResources()
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	counts, err := backup.Export(ctx, s, w, opts)
+	if err == nil && *out != "" {
+		err = w.(*os.File).Sync()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		if *out != "" {
+			os.Remove(*out)
+		}
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Exported %s\n", counts)
+	return 0
+}
+
+// runImport implements the "import" subcommand and returns the exit code.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	in := fs.String("i", "", "input file (default stdin)")
+	dryRun := fs.Bool("dry-run", false, "verify only, write nothing")
+	resume := fs.Bool("resume", false, "finish an import that failed partway")
+	var tenants tenantFlags
+	fs.Var(&tenants, "tenant", "tenant to import (repeatable)")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, importUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Import reads the backup twice, so stdin is spooled to a file first
+	var r *os.File
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+			return 1
+		}
+		r = f
+	} else {
+		f, err := spool(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: reading stdin: %v\n", err)
+			return 1
+		}
+		defer os.Remove(f.Name())
+		r = f
+	}
+	defer r.Close()
+
+	ctx := context.Background()
+	s, closeStore, err := openCLIStore(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	defer closeStore()
+
+	opts := backup.ImportOptions{DryRun: *dryRun, Resume: *resume}
+	if len(tenants) > 0 {
+		opts.Tenants = tenants
+	}
+	report, err := backup.Import(ctx, s, r, opts)
+	if report != nil {
+		for _, c := range report.Conflicts {
+			fmt.Fprintf(os.Stderr, "exists: %s\n", c)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		if report != nil && !*dryRun && !errors.Is(err, backup.ErrConflict) {
+			fmt.Fprintf(os.Stderr, "Imported before the failure: %s; re-run with -resume to finish\n", report.Imported)
+		}
+		return 1
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Dry run: would import"
+	}
+	fmt.Fprintf(os.Stderr, "%s %s (%d records of other tenants skipped, %d already imported)\n", verb, report.Imported, report.Skipped, report.Resumed)
+	return 0
+}
+
+// spool copies r to a temporary file and rewinds it.
+func spool(r io.Reader) (_ *os.File, err error) {
+	f, err := os.CreateTemp("", "fluxforge-import-*.ndjson")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := io.Copy(f, r); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// openCLIStore opens the STORE_BACKEND store (see configureBackends)
+// without starting any background work.
+func openCLIStore(ctx context.Context) (store.Store, func(), error) {
+	switch backend := getEnvOrDefault("STORE_BACKEND", "redis"); backend {
+	case "redis":
+		rs, err := store.NewRedisStore(getEnvOrDefault("REDIS_ADDR", "localhost:6379"), "", 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rs, func() { rs.Client().Close() }, nil
+	case "postgres":
+		pg, err := openPostgres(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pg, pg.Close, nil
+	case "bolt":
+		bs, err := store.NewBoltStore(getEnvOrDefault("BOLT_PATH", "fluxforge.db"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return bs, func() { bs.Close() }, nil
+	case "memory":
+		return nil, nil, errors.New("the memory store is not persistent (set STORE_BACKEND)")
+	default:
+		return nil, nil, fmt.Errorf("unknown STORE_BACKEND %q (want redis, postgres or bolt)", backend)
+	}
+}
+
+// epochResources are the leader election epochs of this deployment: the
+// global one and, when POD_COUNT shards elect their own leaders, each
+// shard's.
+func epochResources() []string {
+	resources := []string{"leader_election"}
+	count, _ := strconv.Atoi(os.Getenv("POD_COUNT"))
+	for i := 0; count > 1 && i < count; i++ {
+		resources = append(resources, fmt.Sprintf("leader_election:shard-%d", i))
+	}
+	return resources
+}
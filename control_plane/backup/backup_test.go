@@ -0,0 +1,252 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// seed fills a store with two tenants' records and an epoch.
+func seed(t *testing.T, s store.Store) {
+	t.Helper()
+	ctx := context.Background()
+	for _, tid := range []string{"acme", "globex"} {
+		if err := s.UpsertAgent(ctx, tid, &store.Agent{NodeID: tid + "-n1", Hostname: "h1", Status: "active", Metadata: map[string]string{"zone": "a"}}); err != nil {
+			t.Fatalf("UpsertAgent: %v", err)
+		}
+		st := &store.DesiredState{StateID: tid + "-s1", NodeID: tid + "-n1", CheckCmd: "check", ApplyCmd: "apply v1"}
+		if err := s.UpsertState(store.WithChangeInfo(ctx, store.ChangeInfo{Author: "alice", Reason: "create"}), tid, st); err != nil {
+			t.Fatalf("UpsertState: %v", err)
+		}
+		st.ApplyCmd = "apply v2"
+		if err := s.UpsertState(store.WithChangeInfo(ctx, store.ChangeInfo{Author: "bob", Reason: "bump"}), tid, st); err != nil {
+			t.Fatalf("UpsertState: %v", err)
+		}
+		if err := s.UpdateStateStatus(ctx, tid, st.StateID, "compliant", "", time.Now(), 2); err != nil {
+			t.Fatalf("UpdateStateStatus: %v", err)
+		}
+		finished := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		job := &store.Job{JobID: tid + "-j1", NodeID: tid + "-n1", StateID: st.StateID, Command: "apply v2", Status: "completed", Stdout: "ok", FinishedAt: &finished}
+		if err := s.CreateJob(ctx, tid, job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.IncrementDurableEpoch(ctx, "leader_election"); err != nil {
+			t.Fatalf("IncrementDurableEpoch: %v", err)
+		}
+	}
+}
+
+func export(t *testing.T, s store.Store, opts ExportOptions) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := Export(context.Background(), s, &buf, opts); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewMemoryStore()
+	seed(t, src)
+
+	data := export(t, src, ExportOptions{EpochResources: []string{"leader_election", "leader_election:shard-1"}})
+	header, counts, err := Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	want := Counts{Agents: 2, States: 2, Revisions: 4, Jobs: 2, Epochs: 1}
+	if counts != want || len(header.Tenants) != 2 || !header.Epochs {
+		t.Fatalf("Verify = %+v, %s; want 2 tenants with epochs, %s", header, counts, want)
+	}
+
+	dst := store.NewMemoryStore()
+	report, err := Import(ctx, dst, bytes.NewReader(data), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Imported != want {
+		t.Errorf("imported %s, want %s", report.Imported, want)
+	}
+
+	st, _ := dst.GetState(ctx, "acme", "acme-s1")
+	if st == nil || st.Version != 2 || st.ApplyCmd != "apply v2" || st.Status != "compliant" {
+		t.Errorf("restored state = %+v", st)
+	}
+	if orig, _ := src.GetState(ctx, "acme", "acme-s1"); st != nil && !st.CreatedAt.Equal(orig.CreatedAt) {
+		t.Errorf("restored state CreatedAt = %v, want the exported %v", st.CreatedAt, orig.CreatedAt)
+	}
+	revs, _ := dst.ListStateRevisions(ctx, "acme", "acme-s1")
+	if len(revs) != 2 || revs[0].State.ApplyCmd != "apply v1" || revs[0].Author != "alice" || revs[1].Reason != "bump" {
+		t.Errorf("restored revisions = %+v", revs)
+	}
+	job, _ := dst.GetJob(ctx, "globex", "globex-j1")
+	if job == nil || job.Status != "completed" || job.FinishedAt == nil || job.Stdout != "ok" {
+		t.Errorf("restored job = %+v", job)
+	}
+	orig, _ := src.GetAgent(ctx, "globex", "globex-n1")
+	if agent, _ := dst.GetAgent(ctx, "globex", "globex-n1"); agent == nil || agent.Metadata["zone"] != "a" || !agent.CreatedAt.Equal(orig.CreatedAt) {
+		t.Errorf("restored agent = %+v, want CreatedAt %v", agent, orig.CreatedAt)
+	}
+	if epoch, _ := dst.GetDurableEpoch(ctx, "leader_election"); epoch != 3 {
+		t.Errorf("epoch = %d, want 3", epoch)
+	}
+}
+
+func TestImportTenantSelectionAndConflicts(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewMemoryStore()
+	seed(t, src)
+	data := export(t, src, ExportOptions{EpochResources: []string{"leader_election"}})
+
+	dst := store.NewMemoryStore()
+	report, err := Import(ctx, dst, bytes.NewReader(data), ImportOptions{Tenants: []string{"globex"}})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Imported.States != 1 || report.Skipped != 4 {
+		t.Errorf("report = %+v; want globex only, acme and the epoch skipped", report)
+	}
+	if st, _ := dst.GetState(ctx, "acme", "acme-s1"); st != nil {
+		t.Errorf("unselected tenant imported: %+v", st)
+	}
+	if epoch, _ := dst.GetDurableEpoch(ctx, "leader_election"); epoch != 0 {
+		t.Errorf("epoch = %d; a tenant import must not touch epochs", epoch)
+	}
+
+	// Importing everything now conflicts on globex; a dry run reports the
+	// same without writing
+	for _, dryRun := range []bool{true, false} {
+		report, err = Import(ctx, dst, bytes.NewReader(data), ImportOptions{DryRun: dryRun})
+		if !errors.Is(err, ErrConflict) || len(report.Conflicts) != 3 {
+			t.Fatalf("Import(dryRun=%v) = %+v, %v; want 3 conflicts", dryRun, report, err)
+		}
+		if st, _ := dst.GetState(ctx, "acme", "acme-s1"); st != nil {
+			t.Fatalf("conflicting import wrote acme: %+v", st)
+		}
+	}
+
+	if _, err := Import(ctx, dst, bytes.NewReader(data), ImportOptions{Tenants: []string{"initech"}}); err == nil {
+		t.Error("import of a tenant not in the backup succeeded")
+	}
+}
+
+func TestImportRejectsDamagedBackups(t *testing.T) {
+	src := store.NewMemoryStore()
+	seed(t, src)
+	data := string(export(t, src, ExportOptions{}))
+	lines := strings.SplitAfter(data, "\n")
+
+	cases := map[string]string{
+		"empty":     "",
+		"truncated": strings.Join(lines[:len(lines)-2], ""),
+		"tampered":  strings.Replace(data, "apply v2", "rm -rf /", 1),
+		"reordered": lines[0] + lines[2] + lines[1] + strings.Join(lines[3:], ""),
+		"newer":     strings.Replace(data, `"version":1`, `"version":2`, 1),
+		"trailing":  data + data,
+	}
+	for name, damaged := range cases {
+		dst := store.NewMemoryStore()
+		_, err := Import(context.Background(), dst, strings.NewReader(damaged), ImportOptions{})
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+		}
+		if tenants, _ := dst.ListTenants(context.Background()); len(tenants) != 0 {
+			t.Errorf("%s: wrote %v", name, tenants)
+		}
+	}
+}
+
+func TestImportFillsMissingRevisions(t *testing.T) {
+	ctx := context.Background()
+	st := &store.DesiredState{StateID: "s1", NodeID: "n1", CheckCmd: "check", ApplyCmd: "apply v4", Version: 4, Status: "drifted"}
+	rev3 := &store.StateRevision{StateID: "s1", Revision: 3, Author: "carol", State: store.DesiredState{StateID: "s1", NodeID: "n1", ApplyCmd: "apply v3"}}
+
+	var buf bytes.Buffer
+	enc := newEncoder(&buf)
+	enc.line(Header{Kind: KindHeader, Format: Format, Version: FormatVersion, Tenants: []string{"acme"}})
+	enc.record(&Record{Kind: KindState, TenantID: "acme", State: st, Revisions: []*store.StateRevision{rev3}})
+	if err := enc.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := store.NewMemoryStore()
+	if _, err := Import(ctx, dst, bytes.NewReader(buf.Bytes()), ImportOptions{}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	got, _ := dst.GetState(ctx, "acme", "s1")
+	if got == nil || got.Version != 4 || got.ApplyCmd != "apply v4" || got.Status != "drifted" {
+		t.Errorf("restored state = %+v", got)
+	}
+	revs, _ := dst.ListStateRevisions(ctx, "acme", "s1")
+	if len(revs) != 4 {
+		t.Fatalf("restored %d revisions, want 4", len(revs))
+	}
+	// Revisions 1 and 2 were not exported: they take the spec of 3
+	if revs[0].State.ApplyCmd != "apply v3" || !strings.Contains(revs[1].Reason, "not in the backup") ||
+		revs[2].Author != "carol" || revs[3].State.ApplyCmd != "apply v4" {
+		t.Errorf("restored revisions = %+v", revs)
+	}
+}
+
+// failingStore fails UpsertState after the given number of calls.
+type failingStore struct {
+	store.Store
+	upserts int
+}
+
+func (f *failingStore) UpsertState(ctx context.Context, tenantID string, state *store.DesiredState) error {
+	if f.upserts == 0 {
+		return errors.New("store unavailable")
+	}
+	f.upserts--
+	return f.Store.UpsertState(ctx, tenantID, state)
+}
+
+func TestImportResumesAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewMemoryStore()
+	seed(t, src)
+	data := export(t, src, ExportOptions{})
+
+	// acme's state gets its first version only
+	dst := store.NewMemoryStore()
+	_, err := Import(ctx, &failingStore{Store: dst, upserts: 1}, bytes.NewReader(data), ImportOptions{})
+	if err == nil {
+		t.Fatal("Import into a failing store succeeded")
+	}
+	if _, err := Import(ctx, dst, bytes.NewReader(data), ImportOptions{}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("re-run without Resume = %v, want ErrConflict", err)
+	}
+
+	report, err := Import(ctx, dst, bytes.NewReader(data), ImportOptions{Resume: true})
+	if err != nil {
+		t.Fatalf("Import with Resume: %v", err)
+	}
+	if report.Resumed != 1 || report.Imported.States != 2 || report.Imported.Agents != 1 {
+		t.Errorf("report = %+v; want acme's agent resumed, both states and globex imported", report)
+	}
+	st, _ := dst.GetState(ctx, "acme", "acme-s1")
+	if st == nil || st.Version != 2 || st.Status != "compliant" {
+		t.Errorf("resumed state = %+v", st)
+	}
+	if revs, _ := dst.ListStateRevisions(ctx, "acme", "acme-s1"); len(revs) != 2 || revs[1].Author != "bob" {
+		t.Errorf("resumed revisions = %+v", revs)
+	}
+
+	// A record that is not from the backup still conflicts
+	other := store.NewMemoryStore()
+	if err := other.UpsertAgent(ctx, "acme", &store.Agent{NodeID: "acme-n1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(ctx, other, bytes.NewReader(data), ImportOptions{Resume: true}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Resume over a foreign agent = %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// ExportOptions selects what Export writes.
+type ExportOptions struct {
+	Tenants []string // nil = every tenant (store.Store.ListTenants)
+	// EpochResources are the durable epochs to include; epochs still at 0
+	// are left out. Epochs are global, so only full backups include them.
+	EpochResources []string
+}
+
+// Export writes a backup of s to w and returns what it wrote. Records are
+// read page by page from the live store, not from a snapshot: stop the
+// control plane first for a backup that is consistent across records.
+func Export(ctx context.Context, s store.Store, w io.Writer, opts ExportOptions) (Counts, error) {
+	tenants := opts.Tenants
+	if tenants == nil {
+		var err error
+		if tenants, err = s.ListTenants(ctx); err != nil {
+			return Counts{}, fmt.Errorf("list tenants: %w", err)
+		}
+	}
+	tenants = append([]string{}, tenants...)
+	sort.Strings(tenants)
+
+	enc := newEncoder(w)
+	header := Header{
+		Kind:      KindHeader,
+		Format:    Format,
+		Version:   FormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Tenants:   tenants,
+		Epochs:    len(opts.EpochResources) > 0,
+	}
+	if err := enc.line(header); err != nil {
+		return enc.counts, err
+	}
+
+	for _, tid := range tenants {
+		if err := exportTenant(ctx, s, enc, tid); err != nil {
+			return enc.counts, fmt.Errorf("tenant %s: %w", tid, err)
+		}
+	}
+	for _, res := range opts.EpochResources {
+		value, err := s.GetDurableEpoch(ctx, res)
+		if err != nil {
+			return enc.counts, fmt.Errorf("epoch %s: %w", res, err)
+		}
+		if value == 0 {
+			continue
+		}
+		if err := enc.record(&Record{Kind: KindEpoch, Epoch: &Epoch{Resource: res, Value: value}}); err != nil {
+			return enc.counts, err
+		}
+	}
+	return enc.counts, enc.close()
+}
+
+func exportTenant(ctx context.Context, s store.Store, enc *encoder, tenantID string) error {
+	opts := store.ListOptions{Limit: store.MaxPageSize, Order: store.SortAsc}
+	for opts.Cursor = ""; ; {
+		page, err := s.QueryAgents(ctx, tenantID, opts)
+		if err != nil {
+			return fmt.Errorf("agents: %w", err)
+		}
+		for _, agent := range page.Agents {
+			if err := enc.record(&Record{Kind: KindAgent, TenantID: tenantID, Agent: agent}); err != nil {
+				return err
+			}
+		}
+		if opts.Cursor = page.NextCursor; opts.Cursor == "" {
+			break
+		}
+	}
+
+	for opts.Cursor = ""; ; {
+		page, err := s.QueryStates(ctx, tenantID, opts)
+		if err != nil {
+			return fmt.Errorf("states: %w", err)
+		}
+		for _, st := range page.States {
+			revs, err := s.ListStateRevisions(ctx, tenantID, st.StateID)
+			if err != nil {
+				return fmt.Errorf("state %s revisions: %w", st.StateID, err)
+			}
+			// An upsert after QueryStates may have added newer revisions
+			kept := revs[:0]
+			for _, rev := range revs {
+				if rev.Revision <= st.Version {
+					kept = append(kept, rev)
+				}
+			}
+			rec := &Record{Kind: KindState, TenantID: tenantID, State: st, Revisions: kept}
+			if err := enc.record(rec); err != nil {
+				return err
+			}
+		}
+		if opts.Cursor = page.NextCursor; opts.Cursor == "" {
+			break
+		}
+	}
+
+	for opts.Cursor = ""; ; {
+		page, err := s.QueryJobs(ctx, tenantID, opts)
+		if err != nil {
+			return fmt.Errorf("jobs: %w", err)
+		}
+		for _, job := range page.Jobs {
+			if err := enc.record(&Record{Kind: KindJob, TenantID: tenantID, Job: job}); err != nil {
+				return err
+			}
+		}
+		if opts.Cursor = page.NextCursor; opts.Cursor == "" {
+			break
+		}
+	}
+	return nil
+}
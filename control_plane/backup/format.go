@@ -0,0 +1,287 @@
+// Package backup writes and restores logical backups of a store.Store:
+// agents, desired states with their revisions, jobs and durable epochs as
+// versioned NDJSON. Backups only use the Store interface, so restoring one
+// into another backend is also how a deployment moves between backends.
+package backup
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// A backup is one JSON object per line: a header, the records of each
+// tenant (agents, then states, then jobs), the epochs, and a trailer with
+// the record counts and a checksum of every line before it. A backup
+// without its trailer is truncated and is rejected.
+const (
+	Format        = "fluxforge-export"
+	FormatVersion = 1
+)
+
+// Line kinds.
+const (
+	KindHeader  = "header"
+	KindAgent   = "agent"
+	KindState   = "state"
+	KindJob     = "job"
+	KindEpoch   = "epoch"
+	KindTrailer = "trailer"
+)
+
+// ErrInvalid is returned for a backup that is malformed, truncated,
+// inconsistent or fails its checksum.
+var ErrInvalid = errors.New("invalid backup")
+
+// Header is the first line of a backup.
+type Header struct {
+	Kind      string    `json:"kind"`
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Tenants   []string  `json:"tenants"` // Every tenant whose records follow
+	Epochs    bool      `json:"epochs"`  // Whether durable epochs follow
+}
+
+// Record is one exported record; exactly one of the pointers is set.
+type Record struct {
+	Kind     string              `json:"kind"`
+	TenantID string              `json:"tenant_id,omitempty"` // Not set for epochs
+	Agent    *store.Agent        `json:"agent,omitempty"`
+	State    *store.DesiredState `json:"state,omitempty"`
+	// Revisions of State, oldest first
+	Revisions []*store.StateRevision `json:"revisions,omitempty"`
+	Job       *store.Job             `json:"job,omitempty"`
+	Epoch     *Epoch                 `json:"epoch,omitempty"`
+}
+
+// Epoch is a durable epoch (store.Store.GetDurableEpoch).
+type Epoch struct {
+	Resource string `json:"resource"`
+	Value    int64  `json:"value"`
+}
+
+// Trailer is the last line of a backup.
+type Trailer struct {
+	Kind   string `json:"kind"`
+	Counts Counts `json:"counts"`
+	SHA256 string `json:"sha256"` // Hex digest of every line before the trailer
+}
+
+// Counts tallies the records of a backup.
+type Counts struct {
+	Agents    int `json:"agents"`
+	States    int `json:"states"`
+	Revisions int `json:"revisions"`
+	Jobs      int `json:"jobs"`
+	Epochs    int `json:"epochs"`
+}
+
+func (c *Counts) add(rec *Record) {
+	switch rec.Kind {
+	case KindAgent:
+		c.Agents++
+	case KindState:
+		c.States++
+		c.Revisions += len(rec.Revisions)
+	case KindJob:
+		c.Jobs++
+	case KindEpoch:
+		c.Epochs++
+	}
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d agents, %d states (%d revisions), %d jobs, %d epochs",
+		c.Agents, c.States, c.Revisions, c.Jobs, c.Epochs)
+}
+
+// encoder writes lines and hashes them for the trailer.
+type encoder struct {
+	w      *bufio.Writer
+	sum    hash.Hash
+	counts Counts
+}
+
+func newEncoder(w io.Writer) *encoder {
+	return &encoder{w: bufio.NewWriter(w), sum: sha256.New()}
+}
+
+func (e *encoder) line(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	e.sum.Write(data)
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *encoder) record(rec *Record) error {
+	e.counts.add(rec)
+	return e.line(rec)
+}
+
+// close writes the trailer and flushes.
+func (e *encoder) close() error {
+	trailer := Trailer{Kind: KindTrailer, Counts: e.counts, SHA256: hex.EncodeToString(e.sum.Sum(nil))}
+	if err := json.NewEncoder(e.w).Encode(trailer); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// scan reads a backup, checks that it is well formed and self-consistent,
+// and calls fn for each record in order. fn sees records before the
+// trailer is verified, so callers that write must scan twice.
+func scan(r io.Reader, fn func(rec *Record) error) (*Header, Counts, error) {
+	br := bufio.NewReader(r)
+	sum := sha256.New()
+	var counts Counts
+	lineNo := 0
+
+	next := func() ([]byte, error) {
+		data, err := br.ReadBytes('\n')
+		if err == io.EOF && len(data) > 0 {
+			return nil, fmt.Errorf("%w: line %d is not terminated", ErrInvalid, lineNo+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		lineNo++
+		return data, nil
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: line %d: %s", ErrInvalid, lineNo, fmt.Sprintf(format, args...))
+	}
+
+	data, err := next()
+	if err == io.EOF {
+		return nil, counts, fmt.Errorf("%w: empty", ErrInvalid)
+	}
+	if err != nil {
+		return nil, counts, err
+	}
+	var header Header
+	if err := json.Unmarshal(data, &header); err != nil || header.Kind != KindHeader || header.Format != Format {
+		return nil, counts, invalid("not a %s header", Format)
+	}
+	if header.Version < 1 || header.Version > FormatVersion {
+		return nil, counts, invalid("format version %d is not supported (max %d)", header.Version, FormatVersion)
+	}
+	sum.Write(data)
+
+	tenants := make(map[string]bool, len(header.Tenants))
+	for _, tid := range header.Tenants {
+		tenants[tid] = true
+	}
+	seen := make(map[string]bool)
+
+	for {
+		data, err := next()
+		if err == io.EOF {
+			return nil, counts, fmt.Errorf("%w: truncated (no trailer after line %d)", ErrInvalid, lineNo)
+		}
+		if err != nil {
+			return nil, counts, err
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, counts, invalid("%v", err)
+		}
+
+		if rec.Kind == KindTrailer {
+			var trailer Trailer
+			if err := json.Unmarshal(data, &trailer); err != nil {
+				return nil, counts, invalid("%v", err)
+			}
+			if trailer.Counts != counts {
+				return nil, counts, invalid("trailer counts %s, read %s", trailer.Counts, counts)
+			}
+			if got := hex.EncodeToString(sum.Sum(nil)); trailer.SHA256 != got {
+				return nil, counts, invalid("checksum mismatch")
+			}
+			if _, err := next(); err != io.EOF {
+				return nil, counts, fmt.Errorf("%w: data after the trailer", ErrInvalid)
+			}
+			return &header, counts, nil
+		}
+		sum.Write(data)
+
+		key, err := check(&rec, tenants, header.Epochs)
+		if err != nil {
+			return nil, counts, invalid("%v", err)
+		}
+		if seen[key] {
+			return nil, counts, invalid("duplicate %s", key)
+		}
+		seen[key] = true
+		counts.add(&rec)
+
+		if fn != nil {
+			if err := fn(&rec); err != nil {
+				return nil, counts, err
+			}
+		}
+	}
+}
+
+// check validates one record against the header and returns a key that
+// identifies it within the backup.
+func check(rec *Record, tenants map[string]bool, epochs bool) (string, error) {
+	if rec.Kind == KindEpoch {
+		if !epochs {
+			return "", errors.New("epoch in a backup without epochs")
+		}
+		if rec.Epoch == nil || rec.Epoch.Resource == "" || rec.Epoch.Value < 1 {
+			return "", errors.New("malformed epoch")
+		}
+		return "epoch " + rec.Epoch.Resource, nil
+	}
+	if !tenants[rec.TenantID] {
+		return "", fmt.Errorf("%s of tenant %q, which the header does not list", rec.Kind, rec.TenantID)
+	}
+
+	switch rec.Kind {
+	case KindAgent:
+		if rec.Agent == nil || rec.Agent.NodeID == "" {
+			return "", errors.New("malformed agent")
+		}
+		return fmt.Sprintf("agent %s/%s", rec.TenantID, rec.Agent.NodeID), nil
+	case KindState:
+		st := rec.State
+		if st == nil || st.StateID == "" || st.Version < 1 {
+			return "", errors.New("malformed state")
+		}
+		last := 0
+		for _, rev := range rec.Revisions {
+			if rev == nil || rev.StateID != st.StateID || rev.Revision <= last || rev.Revision > st.Version {
+				return "", fmt.Errorf("state %s: revisions must be ascending and at most version %d", st.StateID, st.Version)
+			}
+			last = rev.Revision
+		}
+		return fmt.Sprintf("state %s/%s", rec.TenantID, st.StateID), nil
+	case KindJob:
+		if rec.Job == nil || rec.Job.JobID == "" {
+			return "", errors.New("malformed job")
+		}
+		return fmt.Sprintf("job %s/%s", rec.TenantID, rec.Job.JobID), nil
+	default:
+		return "", fmt.Errorf("unknown record kind %q", rec.Kind)
+	}
+}
+
+// Verify checks a backup without restoring it and returns its header and
+// record counts.
+func Verify(r io.Reader) (*Header, Counts, error) {
+	return scan(r, nil)
+}
@@ -0,0 +1,224 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// ErrConflict is returned by Import when records of the backup already
+// exist in the target store (and, with Resume, were not restored from
+// it); nothing is written.
+var ErrConflict = errors.New("records already exist in the target store")
+
+// ImportOptions selects what Import restores.
+type ImportOptions struct {
+	Tenants []string // nil = every tenant in the backup, and its epochs
+	DryRun  bool     // Check everything, write nothing
+	// Resume continues an Import of the same backup that failed partway:
+	// records it already restored are skipped, or for a state, finished
+	Resume bool
+}
+
+// Report is the outcome of an Import.
+type Report struct {
+	Header    *Header
+	Imported  Counts   // Records restored, or that a dry run would restore
+	Skipped   int      // Records of tenants not selected
+	Resumed   int      // Records found already restored (with Resume)
+	Conflicts []string // Records that already exist in the target
+}
+
+// maxConflicts bounds Report.Conflicts; the check stops at the first ones.
+const maxConflicts = 100
+
+// Import restores a backup into s. It reads r twice: once to verify the
+// whole backup and check that none of its agents, states or jobs exist in
+// s, and once to write them. A backup that fails either check is not
+// restored at all.
+//
+// The write pass can still fail partway, on a store error, leaving some
+// records restored. Running Import again with Resume and the same backup
+// finishes it: a record in s with the CreatedAt and ID of the backup's
+// counts as restored by the earlier run, and any other conflicts as
+// before.
+//
+// Restored states get the versions and revision history they were
+// exported with: each exported revision is replayed through UpsertState
+// with its author and reason, and revisions missing from the backup (from
+// before revisions were recorded) are filled with the next known spec.
+// Epochs are raised to their exported value, never lowered. Agents and
+// states keep their exported CreatedAt; revisions are dated at the import.
+func Import(ctx context.Context, s store.Store, r io.ReadSeeker, opts ImportOptions) (*Report, error) {
+	header, _, err := Verify(r)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectTenants(header, opts.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Header: header}
+
+	want := func(rec *Record) bool {
+		if rec.Kind == KindEpoch {
+			return opts.Tenants == nil
+		}
+		return selected[rec.TenantID]
+	}
+
+	// Check pass: find records that would overwrite existing ones
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	_, _, err = scan(r, func(rec *Record) error {
+		if !want(rec) {
+			report.Skipped++
+			return nil
+		}
+		report.Imported.add(rec)
+		if len(report.Conflicts) >= maxConflicts {
+			return nil
+		}
+		exists, done, err := restored(ctx, s, rec)
+		if err != nil {
+			return err
+		}
+		if exists && !(opts.Resume && done >= 0) {
+			key, _ := check(rec, selected, true)
+			report.Conflicts = append(report.Conflicts, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Conflicts) > 0 {
+		return report, fmt.Errorf("%w (%d found)", ErrConflict, len(report.Conflicts))
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	// Write pass
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	report.Imported = Counts{}
+	_, _, err = scan(r, func(rec *Record) error {
+		if !want(rec) {
+			return nil
+		}
+		done := 0
+		if opts.Resume {
+			exists, n, err := restored(ctx, s, rec)
+			if err != nil {
+				return err
+			}
+			if exists && n < 0 {
+				// Written since the check pass
+				key, _ := check(rec, selected, true)
+				return fmt.Errorf("restore %s: %w", key, ErrConflict)
+			}
+			if exists && (rec.Kind != KindState || n == rec.State.Version) {
+				report.Resumed++
+				return nil
+			}
+			done = n
+		}
+		if err := restore(ctx, s, rec, done); err != nil {
+			key, _ := check(rec, selected, true)
+			return fmt.Errorf("restore %s: %w", key, err)
+		}
+		report.Imported.add(rec)
+		return nil
+	})
+	return report, err
+}
+
+// selectTenants returns the tenants to import, all of which must be in
+// the backup.
+func selectTenants(header *Header, tenants []string) (map[string]bool, error) {
+	inBackup := make(map[string]bool, len(header.Tenants))
+	for _, tid := range header.Tenants {
+		inBackup[tid] = true
+	}
+	if tenants == nil {
+		return inBackup, nil
+	}
+	selected := make(map[string]bool, len(tenants))
+	for _, tid := range tenants {
+		if !inBackup[tid] {
+			return nil, fmt.Errorf("tenant %q is not in the backup", tid)
+		}
+		selected[tid] = true
+	}
+	return selected, nil
+}
+
+// restored reports whether rec's agent, state or job is already in s and,
+// if so, how much of it an earlier Import restored: for a state the
+// version it reached, otherwise 1, and -1 if the record in s has another
+// CreatedAt or, for a state, a later version, so it is not from the
+// backup. Epochs never conflict: Import only raises them.
+func restored(ctx context.Context, s store.Store, rec *Record) (exists bool, done int, err error) {
+	var created, exported time.Time
+	switch rec.Kind {
+	case KindAgent:
+		agent, err := s.GetAgent(ctx, rec.TenantID, rec.Agent.NodeID)
+		if agent == nil || err != nil {
+			return false, 0, err
+		}
+		created, exported, done = agent.CreatedAt, rec.Agent.CreatedAt, 1
+	case KindState:
+		st, err := s.GetState(ctx, rec.TenantID, rec.State.StateID)
+		if st == nil || err != nil {
+			return false, 0, err
+		}
+		if st.Version > rec.State.Version {
+			return true, -1, nil
+		}
+		created, exported, done = st.CreatedAt, rec.State.CreatedAt, st.Version
+	case KindJob:
+		job, err := s.GetJob(ctx, rec.TenantID, rec.Job.JobID)
+		if job == nil || err != nil {
+			return false, 0, err
+		}
+		created, exported, done = job.CreatedAt, rec.Job.CreatedAt, 1
+	default:
+		return false, 0, nil
+	}
+	if !sameTime(created, exported) {
+		return true, -1, nil
+	}
+	return true, done, nil
+}
+
+// sameTime compares timestamps at the microsecond precision of the
+// coarsest backend (Postgres).
+func sameTime(a, b time.Time) bool {
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+// restore writes rec, or for a state the versions after done.
+func restore(ctx context.Context, s store.Store, rec *Record, done int) error {
+	switch rec.Kind {
+	case KindAgent:
+		agent := *rec.Agent
+		return s.UpsertAgent(store.WithCreatedAt(ctx, agent.CreatedAt), rec.TenantID, &agent)
+	case KindState:
+		return store.CopyState(ctx, s, rec.TenantID, rec.State, rec.Revisions, done, func(v int) string {
+			return fmt.Sprintf("import: revision %d is not in the backup", v)
+		})
+	case KindJob:
+		job := *rec.Job
+		return s.CreateJob(ctx, rec.TenantID, &job)
+	case KindEpoch:
+		return store.RaiseEpoch(ctx, s, rec.Epoch.Resource, rec.Epoch.Value)
+	}
+	return nil
+}
@@ -42,18 +42,21 @@ func (j *LockJanitor) loop(ctx context.Context) {
 }
 
 func (j *LockJanitor) clean(ctx context.Context) {
-	// 1. Get Current Durable Epoch
-	// We assume "leader_election" resource for now, or we iterate distinct resources?
-	// For now, FluxForge uses a single global leader election resource: "leader_election".
-	// If we support multiple, we might need to know which resource a lock belongs to.
-	// But locks are named "fluxforge:lock:leader".
-	// The resource ID in Postgres is "leader_election".
-	// We'll hardcode "leader_election" for the global epoch check.
-	// If we have localized locks, we'd need mapping.
-	currentEpoch, err := j.store.GetDurableEpoch(ctx, "leader_election")
-	if err != nil {
-		log.Printf("Janitor: Failed to get durable epoch: %v", err)
-		return
+	// 1. Durable epochs, looked up once per resource.
+	// The global leader lock "fluxforge:lock:leader" uses "leader_election";
+	// per-shard leader locks use "leader_election:shard-N".
+	epochs := make(map[string]int64)
+	currentEpochFor := func(key string) (int64, error) {
+		res := epochResourceForLock(key)
+		if epoch, ok := epochs[res]; ok {
+			return epoch, nil
+		}
+		epoch, err := j.store.GetDurableEpoch(ctx, res)
+		if err != nil {
+			return 0, err
+		}
+		epochs[res] = epoch
+		return epoch, nil
 	}
 
 	keys, err := j.coordinator.ScanLocks(ctx, "fluxforge:lock:*")
@@ -83,6 +86,12 @@ func (j *LockJanitor) clean(ctx context.Context) {
 			continue
 		}
 
+		currentEpoch, err := currentEpochFor(key)
+		if err != nil {
+			log.Printf("Janitor: Failed to get durable epoch: %v", err)
+			return
+		}
+
 		// Check 1: Fencing (Epoch Mismatch)
 		if meta.Epoch < currentEpoch {
 			log.Printf("Janitor: FENCING lock %s (Epoch %d < Current %d). Force releasing.", key, meta.Epoch, currentEpoch)
@@ -3,7 +3,9 @@ package coordination
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +26,7 @@ type LeaderElector struct {
 	store        store.Store // Durable store for Epochs
 	nodeID       string
 	lockKey      string
+	epochRes     string // Durable epoch resource for fencing tokens
 	ttl          time.Duration
 	leaderCtx    context.Context // Context valid only while leader
 	leaderCancel context.CancelFunc
@@ -93,12 +96,33 @@ func NewLeaderElector(c store.Coordinator, s store.Store, nodeID string, ttl tim
 		store:       s,
 		nodeID:      nodeID,
 		lockKey:     "fluxforge:lock:leader",
+		epochRes:    "leader_election",
 		ttl:         ttl,
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 }
 
+// NewShardLeaderElector elects a leader for one shard, so every shard's
+// scheduler runs on its own pod instead of one global leader serving only
+// its shard. Lock key and fencing epoch are scoped to the shard.
+func NewShardLeaderElector(c store.Coordinator, s store.Store, nodeID string, shardIndex int, ttl time.Duration) *LeaderElector {
+	l := NewLeaderElector(c, s, nodeID, ttl)
+	l.lockKey = fmt.Sprintf("%s:shard-%d", l.lockKey, shardIndex)
+	l.epochRes = epochResourceForLock(l.lockKey)
+	return l
+}
+
+// epochResourceForLock maps a leader lock key to its durable epoch resource:
+// "fluxforge:lock:leader" -> "leader_election",
+// "fluxforge:lock:leader:shard-1" -> "leader_election:shard-1".
+func epochResourceForLock(key string) string {
+	if suffix, ok := strings.CutPrefix(key, "fluxforge:lock:leader:"); ok {
+		return "leader_election:" + suffix
+	}
+	return "leader_election"
+}
+
 func (l *LeaderElector) SetCallbacks(onElected func(ctx context.Context), onLost func()) {
 	l.onElected = onElected
 	l.onLost = onLost
@@ -191,7 +215,7 @@ func (l *LeaderElector) IsLeader() bool {
 func (l *LeaderElector) acquire(ctx context.Context) (bool, error) {
 	// 1. Get Epoch from Durable Store (Postgres)
 	// This ensures monotonic fencing tokens even if Redis is flushed.
-	epoch, err := l.store.IncrementDurableEpoch(ctx, "leader_election")
+	epoch, err := l.store.IncrementDurableEpoch(ctx, l.epochRes)
 	if err != nil {
 		log.Printf("LeaderElector: Failed to increment durable epoch: %v", err)
 		return false, err
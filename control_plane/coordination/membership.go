@@ -0,0 +1,250 @@
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/sharding"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// memberKeyPrefix is outside "fluxforge:lock:*" so the LockJanitor,
+// which fences leader locks by epoch, never touches membership leases.
+const memberKeyPrefix = "fluxforge:shards:member:"
+
+// Member is a control-plane pod serving one shard.
+type Member struct {
+	PodID      string    `json:"pod_id"`
+	ShardIndex int       `json:"shard_index"`
+	Address    string    `json:"address"` // Base URL peers use for forwarding, e.g. http://pod-1:8080
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// Membership announces this pod's shard under a lease in the Coordinator and
+// tracks the live members, so requests for another shard can be forwarded to
+// the pod that owns it. Ownership is decided by a consistent-hash ring over
+// the live shard indexes: when a pod joins or its lease lapses, only the keys
+// of that shard move.
+type Membership struct {
+	coordinator store.Coordinator
+	self        Member
+	ttl         time.Duration
+
+	mu       sync.RWMutex
+	value    string // Exact lease value we hold; empty if not announced
+	members  map[int]Member
+	ring     *sharding.Ring
+	onChange func(*sharding.Ring)
+}
+
+// NewMembership creates a registry for self. Until the first refresh the ring
+// assumes the static layout 0..expectedShards-1, matching POD_COUNT.
+func NewMembership(c store.Coordinator, self Member, expectedShards int, ttl time.Duration) *Membership {
+	if expectedShards < 1 {
+		expectedShards = 1
+	}
+	shards := make([]int, expectedShards)
+	for i := range shards {
+		shards[i] = i
+	}
+	if self.StartedAt.IsZero() {
+		self.StartedAt = time.Now()
+	}
+	return &Membership{
+		coordinator: c,
+		self:        self,
+		ttl:         ttl,
+		members:     map[int]Member{self.ShardIndex: self},
+		ring:        sharding.NewRing(sharding.DefaultReplicas, shards...),
+	}
+}
+
+// OnChange registers a callback invoked with the new ring whenever the set
+// of live shards changes. It is called once from Start with the initial ring.
+func (m *Membership) OnChange(fn func(*sharding.Ring)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Start announces this pod and refreshes membership every ttl/3.
+func (m *Membership) Start(ctx context.Context) {
+	m.mu.RLock()
+	fn, ring := m.onChange, m.ring
+	m.mu.RUnlock()
+	if fn != nil {
+		fn(ring)
+	}
+
+	m.refresh(ctx)
+	go m.loop(ctx)
+}
+
+func (m *Membership) loop(ctx context.Context) {
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.leave()
+			return
+		case <-ticker.C:
+			m.refresh(ctx)
+		}
+	}
+}
+
+// refresh renews (or takes) our lease, then reloads the live members.
+func (m *Membership) refresh(ctx context.Context) {
+	if err := m.announce(ctx); err != nil {
+		log.Printf("Membership: announce failed: %v", err)
+	}
+
+	members, err := m.load(ctx)
+	if err != nil {
+		// Keep routing on the last known ring rather than dropping peers
+		log.Printf("Membership: refresh failed: %v", err)
+		return
+	}
+	// We always serve our own shard, even if our lease write was lost
+	members[m.self.ShardIndex] = m.self
+	m.apply(members)
+}
+
+func (m *Membership) announce(ctx context.Context) error {
+	m.mu.RLock()
+	val := m.value
+	m.mu.RUnlock()
+	key := m.key(m.self.ShardIndex)
+
+	if val != "" {
+		renewed, err := m.coordinator.RenewLease(ctx, key, val, m.ttl)
+		if err != nil || renewed {
+			return err
+		}
+		log.Printf("Membership: lease for shard %d lost, re-announcing", m.self.ShardIndex)
+	}
+
+	b, _ := json.Marshal(m.self)
+	acquired, err := m.coordinator.AcquireLease(ctx, key, string(b), m.ttl)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		owner, _ := m.coordinator.GetLockOwner(ctx, key)
+		m.mu.Lock()
+		m.value = ""
+		m.mu.Unlock()
+		return fmt.Errorf("shard %d already announced by another pod: %s", m.self.ShardIndex, owner)
+	}
+
+	m.mu.Lock()
+	m.value = string(b)
+	m.mu.Unlock()
+	log.Printf("Membership: announced shard %d at %s", m.self.ShardIndex, m.self.Address)
+	return nil
+}
+
+func (m *Membership) load(ctx context.Context) (map[int]Member, error) {
+	keys, err := m.coordinator.ScanLocks(ctx, memberKeyPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[int]Member, len(keys))
+	for _, key := range keys {
+		val, err := m.coordinator.GetLockOwner(ctx, key)
+		if err != nil || val == "" {
+			continue // Expired between scan and read
+		}
+		var member Member
+		if err := json.Unmarshal([]byte(val), &member); err != nil {
+			log.Printf("Membership: ignoring malformed member %s: %v", key, err)
+			continue
+		}
+		if key != m.key(member.ShardIndex) {
+			continue
+		}
+		members[member.ShardIndex] = member
+	}
+	return members, nil
+}
+
+func (m *Membership) apply(members map[int]Member) {
+	shards := make([]int, 0, len(members))
+	for idx := range members {
+		shards = append(shards, idx)
+	}
+	ring := sharding.NewRing(sharding.DefaultReplicas, shards...)
+
+	m.mu.Lock()
+	changed := !ring.Equal(m.ring)
+	m.members = members
+	if changed {
+		m.ring = ring
+	}
+	fn := m.onChange
+	m.mu.Unlock()
+
+	if changed {
+		log.Printf("Membership: live shards now %v", ring.Shards())
+		if fn != nil {
+			fn(ring)
+		}
+	}
+}
+
+func (m *Membership) leave() {
+	m.mu.RLock()
+	val := m.value
+	m.mu.RUnlock()
+	if val == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	m.coordinator.ReleaseLease(ctx, m.key(m.self.ShardIndex), val)
+}
+
+func (m *Membership) key(shard int) string {
+	return fmt.Sprintf("%s%d", memberKeyPrefix, shard)
+}
+
+// Self returns this pod's member record.
+func (m *Membership) Self() Member {
+	return m.self
+}
+
+// Ring returns the current ownership ring.
+func (m *Membership) Ring() *sharding.Ring {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ring
+}
+
+// Owner returns the member that owns key. ok is false if the owning shard
+// has no known address (e.g. before the first refresh).
+func (m *Membership) Owner(key string) (Member, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	member, ok := m.members[m.ring.Owner(key)]
+	return member, ok
+}
+
+// Members returns the live members ordered by shard index.
+func (m *Membership) Members() []Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Member, 0, len(m.members))
+	for _, member := range m.members {
+		out = append(out, member)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ShardIndex < out[j].ShardIndex })
+	return out
+}
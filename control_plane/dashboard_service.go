@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/itskum47/FluxForge/control_plane/coordination"
+	"github.com/itskum47/FluxForge/control_plane/resilience"
 	"github.com/itskum47/FluxForge/control_plane/scheduler"
 	"github.com/itskum47/FluxForge/control_plane/store"
 )
@@ -15,6 +16,7 @@ type DashboardService struct {
 	store     store.Store
 	scheduler *scheduler.Scheduler
 	elector   *coordination.LeaderElector
+	degraded  *resilience.DegradedMode // nil = not reported
 }
 
 // NewDashboardService creates a new DashboardService.
@@ -26,6 +28,11 @@ func NewDashboardService(store store.Store, scheduler *scheduler.Scheduler, elec
 	}
 }
 
+// SetDegradedMode reports dm's state in the dashboard metrics.
+func (s *DashboardService) SetDegradedMode(dm *resilience.DegradedMode) {
+	s.degraded = dm
+}
+
 // GetDashboardMetrics collects and aggregates all metrics for a specific tenant.
 func (s *DashboardService) GetDashboardMetrics(ctx context.Context, tenantID string) (DashboardMetrics, error) {
 	// 1. Scheduler Metrics
@@ -54,7 +61,7 @@ func (s *DashboardService) GetDashboardMetrics(ctx context.Context, tenantID str
 	}
 
 	// 4. Construct Response
-	return DashboardMetrics{
+	metrics := DashboardMetrics{
 		// Scheduler
 		QueueDepth:          schedMetrics.QueueDepth,
 		ActiveTasks:         schedMetrics.ActiveTasks,
@@ -80,7 +87,15 @@ func (s *DashboardService) GetDashboardMetrics(ctx context.Context, tenantID str
 		ClusterRole: getClusterRole(leaderState.IsLeader),
 		Region:      "us-east-1",
 		Timestamp:   time.Now().Unix(),
-	}, nil
+	}
+
+	// 5. Degraded Mode
+	if s.degraded != nil {
+		metrics.Dependencies = s.degraded.HealthCheck(ctx)
+		metrics.Degraded = metrics.Dependencies["degraded"]
+		metrics.PendingWrites = s.degraded.GetPendingWriteCount()
+	}
+	return metrics, nil
 }
 
 func getClusterRole(isLeader bool) string {
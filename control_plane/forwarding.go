@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/auth"
+	"github.com/itskum47/FluxForge/control_plane/coordination"
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/scheduler"
+)
+
+// internalSubmitPath is the pod-to-pod RPC that queues a task on the
+// scheduler that owns its shard. It is never forwarded again.
+const internalSubmitPath = "/internal/shards/submit"
+
+// internalCancelPath is the pod-to-pod RPC that cancels a state's task on
+// the scheduler that owns its shard, e.g. after the state was deleted.
+const internalCancelPath = "/internal/shards/cancel"
+
+// peerRole is the role of the tokens pods mint for each other. The
+// internal routes accept only it, so a tenant's own token cannot reach
+// another pod's scheduler directly.
+const peerRole = "peer"
+
+// errMisdirected means the peer does not own the task's shard either
+// (its membership view differs from ours, e.g. mid-rebalance).
+var errMisdirected = errors.New("peer does not own shard")
+
+// shardDirectory resolves which pod owns a node (coordination.Membership).
+type shardDirectory interface {
+	Owner(key string) (coordination.Member, bool)
+	Self() coordination.Member
+}
+
+// ShardForwarder relays submits for other shards to the owning pod, so a
+// reconcile request succeeds whichever pod the load balancer picked.
+type ShardForwarder struct {
+	directory shardDirectory
+	client    *http.Client
+}
+
+// NewShardForwarder creates a forwarder over the shard directory.
+func NewShardForwarder(directory shardDirectory) *ShardForwarder {
+	return &ShardForwarder{
+		directory: directory,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// forwardResponse is the body of a successful internal submit.
+type forwardResponse struct {
+	Status string `json:"status"`
+	TaskID string `json:"task_id,omitempty"`
+	Shard  int    `json:"shard"`
+}
+
+// cancelRequest is the body of an internal cancel.
+type cancelRequest struct {
+	NodeID  string `json:"node_id"`
+	StateID string `json:"state_id"`
+}
+
+// cancelResponse is the body of a successful internal cancel.
+type cancelResponse struct {
+	Cancelled bool `json:"cancelled"`
+	Shard     int  `json:"shard"`
+}
+
+// Forward submits task on the pod that owns task.NodeID, for the task's
+// tenant.
+func (f *ShardForwarder) Forward(ctx context.Context, task *scheduler.ReconciliationTask) (*forwardResponse, error) {
+	resp, owner, err := f.post(ctx, task.TenantID, task.NodeID, internalSubmitPath, task)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		var out forwardResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("invalid response from shard %d: %w", owner.ShardIndex, err)
+		}
+		return &out, nil
+	case http.StatusMisdirectedRequest:
+		return nil, errMisdirected
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("shard %d rejected task: %d %s", owner.ShardIndex, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+}
+
+// ForwardCancel cancels the tenant's task of stateID on the pod that owns
+// nodeID and reports whether there was one.
+func (f *ShardForwarder) ForwardCancel(ctx context.Context, tenantID, nodeID, stateID string) (bool, error) {
+	resp, owner, err := f.post(ctx, tenantID, nodeID, internalCancelPath, cancelRequest{NodeID: nodeID, StateID: stateID})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var out cancelResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return false, fmt.Errorf("invalid response from shard %d: %w", owner.ShardIndex, err)
+		}
+		return out.Cancelled, nil
+	case http.StatusMisdirectedRequest:
+		return false, errMisdirected
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("shard %d rejected cancel: %d %s", owner.ShardIndex, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+}
+
+// post sends body as JSON to path on the pod that owns nodeID, with a
+// peer token for tenantID.
+func (f *ShardForwarder) post(ctx context.Context, tenantID, nodeID, path string, body interface{}) (*http.Response, coordination.Member, error) {
+	owner, ok := f.directory.Owner(nodeID)
+	if !ok {
+		return nil, owner, fmt.Errorf("no live pod owns node %s", nodeID)
+	}
+	if owner.PodID == f.directory.Self().PodID {
+		return nil, owner, errMisdirected // Our own ring says it's ours; nothing to forward to
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, owner, err
+	}
+	token, err := auth.GenerateToken(tenantID, peerRole)
+	if err != nil {
+		return nil, owner, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, owner.Address+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, owner, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, owner, fmt.Errorf("forward to shard %d (%s) failed: %w", owner.ShardIndex, owner.Address, err)
+	}
+	return resp, owner, nil
+}
+
+// SetShardForwarder enables forwarding of reconcile requests to the pod that
+// owns the node's shard (nil = reject with 503, the single-shard behaviour).
+func (a *API) SetShardForwarder(f *ShardForwarder) {
+	a.forwarder = f
+}
+
+// handleInternalSubmit queues a task forwarded by a peer pod. It is served
+// behind RequireRole(peerRole).
+func (a *API) handleInternalSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var task scheduler.ReconciliationTask
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&task); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if task.StateID == "" || task.NodeID == "" {
+		http.Error(w, "state_id and node_id are required", http.StatusBadRequest)
+		return
+	}
+	// The peer token names the tenant the task is for
+	task.TenantID = tenantID
+
+	status := "reconciliation_queued"
+	if err := a.scheduler.Submit(&task); err != nil {
+		var wrongShard *scheduler.WrongShardError
+		switch {
+		case errors.As(err, &wrongShard):
+			http.Error(w, err.Error(), http.StatusMisdirectedRequest)
+			return
+		case errors.Is(err, scheduler.ErrDuplicateTask):
+			status = "reconciliation_already_queued"
+		default:
+			log.Printf("Scheduler rejected forwarded task: %v", err)
+			http.Error(w, "Service Overloaded", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(forwardResponse{Status: status, TaskID: task.ReqID, Shard: a.scheduler.ShardIndex()})
+}
+
+// handleInternalCancel cancels a tenant's task on behalf of a peer pod.
+// It is served behind RequireRole(peerRole).
+func (a *API) handleInternalCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantID, err := middleware.GetTenantFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req cancelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.StateID == "" || req.NodeID == "" {
+		http.Error(w, "state_id and node_id are required", http.StatusBadRequest)
+		return
+	}
+	if owner := a.scheduler.OwnerOf(req.NodeID); owner != a.scheduler.ShardIndex() {
+		http.Error(w, fmt.Sprintf("node_id %s belongs to shard %d", req.NodeID, owner), http.StatusMisdirectedRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cancelResponse{Cancelled: a.scheduler.Cancel(tenantID, req.StateID), Shard: a.scheduler.ShardIndex()})
+}
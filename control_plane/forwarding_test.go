@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/itskum47/FluxForge/control_plane/auth"
+	"github.com/itskum47/FluxForge/control_plane/coordination"
+	"github.com/itskum47/FluxForge/control_plane/idempotency"
+	"github.com/itskum47/FluxForge/control_plane/middleware"
+	"github.com/itskum47/FluxForge/control_plane/scheduler"
+	"github.com/itskum47/FluxForge/control_plane/sharding"
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/streaming"
+)
+
+// staticDirectory is a fixed two-pod membership view.
+type staticDirectory struct {
+	ring    *sharding.Ring
+	members map[int]coordination.Member
+	self    int
+}
+
+func (d *staticDirectory) Owner(key string) (coordination.Member, bool) {
+	m, ok := d.members[d.ring.Owner(key)]
+	return m, ok
+}
+
+func (d *staticDirectory) Self() coordination.Member { return d.members[d.self] }
+
+func TestReconcileForwardedToOwningShard(t *testing.T) {
+	s := store.NewMemoryStore()
+	ring := sharding.NewRing(sharding.DefaultReplicas, 0, 1)
+
+	newPod := func(shard int) (*API, *scheduler.Scheduler) {
+		dispatcher := NewDispatcher(s)
+		reconciler := NewReconciler(s, dispatcher, streaming.NewLogPublisher())
+		sched := scheduler.NewScheduler(s, reconciler, shard, 2, scheduler.DefaultSchedulerConfig())
+		sched.SetPartitioner(ring)
+		sched.RehydrateQueue(context.Background()) // Activate without starting workers
+		return NewAPI(s, dispatcher, reconciler, sched, nil, idempotency.NewStore(nil)), sched
+	}
+	api0, sched0 := newPod(0)
+	api1, sched1 := newPod(1)
+
+	peerMux := http.NewServeMux()
+	peerMux.Handle(internalSubmitPath, middleware.RequireRole(http.HandlerFunc(api1.handleInternalSubmit), peerRole))
+	peerMux.Handle(internalCancelPath, middleware.RequireRole(http.HandlerFunc(api1.handleInternalCancel), peerRole))
+	peer := httptest.NewServer(middleware.AuthMiddleware(peerMux))
+	defer peer.Close()
+
+	members := map[int]coordination.Member{
+		0: {PodID: "pod-0", ShardIndex: 0, Address: "http://unused"},
+		1: {PodID: "pod-1", ShardIndex: 1, Address: peer.URL},
+	}
+	api0.SetShardForwarder(NewShardForwarder(&staticDirectory{ring: ring, members: members, self: 0}))
+
+	// Find a node that shard 1 owns
+	nodeID := ""
+	for i := 0; nodeID == ""; i++ {
+		if candidate := fmt.Sprintf("node-%d", i); ring.Owner(candidate) == 1 {
+			nodeID = candidate
+		}
+	}
+	s.UpsertState(context.Background(), "default", &store.DesiredState{StateID: "st-1", NodeID: nodeID, TenantID: "default", Status: "compliant"})
+
+	token, err := auth.GenerateToken("default", "admin")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/states/st-1/reconcile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.TenantKey, "default"))
+	rec := httptest.NewRecorder()
+	api0.handleReconcileState(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body["shard"] != float64(1) {
+		t.Errorf("expected task queued on shard 1, got %v", body)
+	}
+	if got := sched1.GetMetrics().QueueDepth; got != 1 {
+		t.Errorf("expected owning shard to queue the task, depth %d", got)
+	}
+	if got := sched0.GetMetrics().QueueDepth; got != 0 {
+		t.Errorf("expected receiving shard to queue nothing, depth %d", got)
+	}
+
+	// A tenant token cannot reach the internal routes, and a peer cancel
+	// for another tenant leaves the task alone
+	body0, _ := json.Marshal(cancelRequest{NodeID: nodeID, StateID: "st-1"})
+	preq, _ := http.NewRequest(http.MethodPost, peer.URL+internalCancelPath, bytes.NewReader(body0))
+	preq.Header.Set("Authorization", "Bearer "+token)
+	presp, err := http.DefaultClient.Do(preq)
+	if err != nil {
+		t.Fatalf("internal cancel: %v", err)
+	}
+	presp.Body.Close()
+	if presp.StatusCode != http.StatusForbidden {
+		t.Errorf("internal cancel with a tenant token: expected 403, got %d", presp.StatusCode)
+	}
+	forwarder := NewShardForwarder(&staticDirectory{ring: ring, members: members, self: 0})
+	if cancelled, err := forwarder.ForwardCancel(context.Background(), "other", nodeID, "st-1"); err != nil || cancelled {
+		t.Errorf("ForwardCancel for another tenant = %v, %v; want false", cancelled, err)
+	}
+	if got := sched1.GetMetrics().QueueDepth; got != 1 {
+		t.Errorf("expected the task to survive another tenant's cancel, depth %d", got)
+	}
+
+	// Deleting the state cancels the task on the owning shard too
+	req = httptest.NewRequest(http.MethodDelete, "/states/st-1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.TenantKey, "default"))
+	rec = httptest.NewRecorder()
+	api0.handleDeleteState(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := sched1.GetMetrics().QueueDepth; got != 0 {
+		t.Errorf("expected the owning shard's task cancelled, depth %d", got)
+	}
+
+	// The owner refuses tasks it does not own instead of forwarding again
+	other := ""
+	for i := 0; other == ""; i++ {
+		if candidate := fmt.Sprintf("node-%d", i); ring.Owner(candidate) == 0 {
+			other = candidate
+		}
+	}
+	_, err = NewShardForwarder(&staticDirectory{ring: sharding.NewRing(sharding.DefaultReplicas, 1), members: members, self: 0}).
+		Forward(context.Background(), &scheduler.ReconciliationTask{ReqID: "r2", TenantID: "default", StateID: "st-2", NodeID: other})
+	if err != errMisdirected {
+		t.Errorf("expected errMisdirected from peer, got %v", err)
+	}
+}
@@ -28,7 +28,7 @@ type StoreInterface interface {
 
 // TimelineInterface defines timeline dependencies.
 type TimelineInterface interface {
-	GetEventsByStateID(stateID string) []timeline.ReconcileEvent
+	Query(ctx context.Context, q timeline.Query) ([]timeline.ReconcileEvent, error)
 }
 
 // CaptureIncident gathers all relevant data for a state failure.
@@ -63,8 +63,11 @@ func CaptureIncident(ctx context.Context, s StoreInterface, tl TimelineInterface
 		}
 	}
 
-	// Fetch timeline events
-	events := tl.GetEventsByStateID(stateID)
+	// Fetch timeline events (persisted, so this works after a failover)
+	events, err := tl.Query(ctx, timeline.Query{TenantID: tenantID, StateID: stateID})
+	if err != nil {
+		return nil, err
+	}
 
 	report := &IncidentReport{
 		StateID:      stateID,
@@ -0,0 +1,127 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/timeline"
+)
+
+func TestMemoryRepository_TenantScopingAndPagination(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	base := time.Now().Unix()
+
+	for i := 0; i < 7; i++ {
+		repo.Save(ctx, &Snapshot{
+			IncidentID: fmt.Sprintf("inc-%d", i),
+			TenantID:   "t1",
+			StateID:    fmt.Sprintf("state-%d", i%2),
+			Timestamp:  base + int64(i/2), // Shared timestamps exercise the tie-break
+		})
+	}
+	repo.Save(ctx, &Snapshot{IncidentID: "other", TenantID: "t2", Timestamp: base})
+
+	if _, err := repo.Get(ctx, "t2", "inc-1"); err != ErrNotFound {
+		t.Fatalf("expected cross-tenant Get to miss, got %v", err)
+	}
+
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, next, err := repo.List(ctx, "t1", ListFilter{Limit: 3, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		for _, s := range page {
+			seen = append(seen, s.IncidentID)
+		}
+		if next == "" {
+			break
+		}
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		cursor = next
+	}
+
+	if len(seen) != 7 {
+		t.Fatalf("expected 7 incidents across pages, got %d: %v", len(seen), seen)
+	}
+	if seen[0] != "inc-6" || seen[6] != "inc-0" {
+		t.Errorf("expected newest first, got %v", seen)
+	}
+
+	filtered, _, _ := repo.List(ctx, "t1", ListFilter{StateID: "state-1"})
+	if len(filtered) != 3 {
+		t.Errorf("expected 3 incidents for state-1, got %d", len(filtered))
+	}
+}
+
+func TestBuildReplay_FromPersistedData(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	tl := timeline.NewStore()
+	now := time.Now()
+
+	tl.Record(timeline.ReconcileEvent{ReqID: "r1", Stage: "QUEUED", Timestamp: now.Add(-3 * time.Minute), NodeID: "n1", TenantID: "t1",
+		Metadata: map[string]string{"state_id": "s1"}})
+	tl.Record(timeline.ReconcileEvent{Stage: StageStateTransition, Timestamp: now.Add(-1 * time.Minute), NodeID: "n1", TenantID: "t1",
+		Metadata: map[string]string{"state_id": "s1", "from": "drifted", "to": "failed", "reason": "apply failed"}})
+	// Other tenant, same state ID: must not leak into the replay
+	tl.Record(timeline.ReconcileEvent{ReqID: "x", Stage: "QUEUED", Timestamp: now.Add(-2 * time.Minute), TenantID: "t2",
+		Metadata: map[string]string{"state_id": "s1"}})
+
+	s.CreateJob(ctx, "t1", &store.Job{JobID: "j1", NodeID: "n1", StateID: "s1", Command: "check", Status: "queued", CreatedAt: now.Add(-2 * time.Minute)})
+	s.UpdateJobStatus(ctx, "t1", "j1", "completed", 1, "", "")
+	s.CreateJob(ctx, "t1", &store.Job{JobID: "j2", NodeID: "n1", StateID: "other", Command: "x", Status: "queued", CreatedAt: now})
+
+	snap := &Snapshot{IncidentID: "inc-1", TenantID: "t1", StateID: "s1", NodeID: "n1", Timestamp: now.Unix()}
+	replay, err := BuildReplay(ctx, s, tl, snap, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	if replay.Status != "replay_complete" {
+		t.Errorf("expected complete replay, got %s", replay.Status)
+	}
+	// QUEUED, job created, transition, job completed
+	var sources []string
+	for _, e := range replay.Timeline {
+		sources = append(sources, e.Source+":"+e.Event)
+	}
+	if len(replay.Timeline) != 4 {
+		t.Fatalf("expected 4 entries, got %d: %v", len(replay.Timeline), sources)
+	}
+	if replay.Timeline[0].Event != "Queued for reconciliation" || replay.Timeline[1].JobID != "j1" {
+		t.Errorf("unexpected ordering: %v", sources)
+	}
+	for i := 1; i < len(replay.Timeline); i++ {
+		if replay.Timeline[i].Time.Before(replay.Timeline[i-1].Time) {
+			t.Fatalf("replay not ordered: %v", sources)
+		}
+	}
+}
+
+func TestBuildReplay_FallsBackToCapturedTimeline(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	snap := &Snapshot{
+		IncidentID: "inc-2", TenantID: "t1", StateID: "s1", Timestamp: now.Unix(),
+		Timeline: []timeline.ReconcileEvent{
+			{ReqID: "r1", Stage: "FAILED", Timestamp: now.Add(-time.Minute), Metadata: map[string]string{"state_id": "s1", "error": "boom"}},
+		},
+	}
+
+	// Empty timeline backend: history was pruned
+	replay, err := BuildReplay(ctx, store.NewMemoryStore(), timeline.NewStore(), snap, 0)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if replay.Status != "replay_partial" || len(replay.Timeline) != 1 || replay.Timeline[0].Details != "boom" {
+		t.Errorf("unexpected fallback replay: %+v", replay)
+	}
+}
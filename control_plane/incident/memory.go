@@ -0,0 +1,73 @@
+package incident
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryRepository keeps incidents in process (tests, single-node dev).
+type MemoryRepository struct {
+	mu        sync.RWMutex
+	incidents map[string]map[string]*Snapshot // tenant -> id -> snapshot
+}
+
+// NewMemoryRepository creates a MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		incidents: make(map[string]map[string]*Snapshot),
+	}
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, snap *Snapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.incidents[snap.TenantID] == nil {
+		r.incidents[snap.TenantID] = make(map[string]*Snapshot)
+	}
+	c := *snap
+	r.incidents[snap.TenantID][snap.IncidentID] = &c
+	return nil
+}
+
+func (r *MemoryRepository) Get(ctx context.Context, tenantID string, incidentID string) (*Snapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snap, ok := r.incidents[tenantID][incidentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *snap
+	return &c, nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, tenantID string, filter ListFilter) ([]*Snapshot, string, error) {
+	cur, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	r.mu.RLock()
+	all := make([]*Snapshot, 0, len(r.incidents[tenantID]))
+	for _, snap := range r.incidents[tenantID] {
+		if filter.matches(snap) && cur.after(snap) {
+			c := *snap
+			all = append(all, &c)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Timestamp != all[j].Timestamp {
+			return all[i].Timestamp > all[j].Timestamp
+		}
+		return all[i].IncidentID > all[j].IncidentID
+	})
+
+	limit := filter.limit()
+	if len(all) <= limit {
+		return all, "", nil
+	}
+	page := all[:limit]
+	return page, encodeCursor(page[len(page)-1]), nil
+}
@@ -0,0 +1,123 @@
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresRepository stores incidents in the incidents table, which the
+// store's Postgres migrations create.
+type PostgresRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresRepository creates a PostgresRepository.
+func NewPostgresRepository(pool *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{pool: pool}
+}
+
+func (r *PostgresRepository) Save(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal incident: %w", err)
+	}
+	_, err = r.pool.Exec(ctx, `
+		INSERT INTO incidents (tenant_id, incident_id, state_id, node_id, captured_at, snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, incident_id) DO UPDATE SET snapshot = EXCLUDED.snapshot
+	`, snap.TenantID, snap.IncidentID, snap.StateID, snap.NodeID, snap.Timestamp, data)
+	return err
+}
+
+func (r *PostgresRepository) Get(ctx context.Context, tenantID string, incidentID string) (*Snapshot, error) {
+	var data []byte
+	err := r.pool.QueryRow(ctx,
+		`SELECT snapshot FROM incidents WHERE tenant_id = $1 AND incident_id = $2`,
+		tenantID, incidentID,
+	).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal incident: %w", err)
+	}
+	return &snap, nil
+}
+
+func (r *PostgresRepository) List(ctx context.Context, tenantID string, filter ListFilter) ([]*Snapshot, string, error) {
+	cur, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	where := []string{"tenant_id = $1"}
+	args := []interface{}{tenantID}
+	add := func(cond string, vals ...interface{}) {
+		placeholders := make([]interface{}, len(vals))
+		for i, v := range vals {
+			args = append(args, v)
+			placeholders[i] = len(args)
+		}
+		where = append(where, fmt.Sprintf(cond, placeholders...))
+	}
+
+	if filter.StateID != "" {
+		add("state_id = $%d", filter.StateID)
+	}
+	if filter.NodeID != "" {
+		add("node_id = $%d", filter.NodeID)
+	}
+	if filter.Since > 0 {
+		add("captured_at >= $%d", filter.Since)
+	}
+	if filter.Until > 0 {
+		add("captured_at <= $%d", filter.Until)
+	}
+	if cur != nil {
+		add("(captured_at, incident_id) < ($%d, $%d)", cur.Timestamp, cur.ID)
+	}
+
+	limit := filter.limit()
+	query := fmt.Sprintf(
+		`SELECT snapshot FROM incidents WHERE %s ORDER BY captured_at DESC, incident_id DESC LIMIT %d`,
+		strings.Join(where, " AND "), limit+1,
+	)
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	page := make([]*Snapshot, 0, limit)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, "", err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal incident: %w", err)
+		}
+		page = append(page, &snap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(page) <= limit {
+		return page, "", nil
+	}
+	page = page[:limit]
+	return page, encodeCursor(page[len(page)-1]), nil
+}
@@ -0,0 +1,125 @@
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRepository stores incidents per tenant:
+//
+//	fluxforge:tenants:{tid}:incidents:{id}   snapshot JSON
+//	fluxforge:tenants:{tid}:incident_index   sorted set of IDs scored by timestamp
+type RedisRepository struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRepository creates a RedisRepository.
+func NewRedisRepository(client redis.UniversalClient) *RedisRepository {
+	return &RedisRepository{client: client}
+}
+
+func incidentIndexKey(tenantID string) string {
+	return fmt.Sprintf("fluxforge:tenants:%s:incident_index", tenantID)
+}
+
+func (r *RedisRepository) Save(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal incident: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, store.TenantKey(snap.TenantID, store.ResourceIncident, snap.IncidentID), data, 0)
+		pipe.ZAdd(ctx, incidentIndexKey(snap.TenantID), redis.Z{Score: float64(snap.Timestamp), Member: snap.IncidentID})
+		return nil
+	})
+	return err
+}
+
+func (r *RedisRepository) Get(ctx context.Context, tenantID string, incidentID string) (*Snapshot, error) {
+	data, err := r.client.Get(ctx, store.TenantKey(tenantID, store.ResourceIncident, incidentID)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal incident: %w", err)
+	}
+	return &snap, nil
+}
+
+func (r *RedisRepository) List(ctx context.Context, tenantID string, filter ListFilter) ([]*Snapshot, string, error) {
+	cur, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	max := "+inf"
+	if filter.Until > 0 {
+		max = strconv.FormatInt(filter.Until, 10)
+	}
+	if cur != nil && (filter.Until == 0 || cur.Timestamp < filter.Until) {
+		max = strconv.FormatInt(cur.Timestamp, 10)
+	}
+	min := "-inf"
+	if filter.Since > 0 {
+		min = strconv.FormatInt(filter.Since, 10)
+	}
+
+	limit := filter.limit()
+	page := make([]*Snapshot, 0, limit)
+	const batch = 200
+	var offset int64
+
+	// Walk the index newest first, filtering until the page is full (+1 to
+	// know whether another page exists).
+	for len(page) <= limit {
+		ids, err := r.client.ZRevRangeByScoreWithScores(ctx, incidentIndexKey(tenantID), &redis.ZRangeBy{
+			Min: min, Max: max, Offset: offset, Count: batch,
+		}).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		offset += int64(len(ids))
+
+		for _, z := range ids {
+			id, _ := z.Member.(string)
+			// Cheap cursor check before fetching the snapshot
+			if !cur.after(&Snapshot{IncidentID: id, Timestamp: int64(z.Score)}) {
+				continue
+			}
+			snap, err := r.Get(ctx, tenantID, id)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			if filter.matches(snap) {
+				page = append(page, snap)
+				if len(page) > limit {
+					break
+				}
+			}
+		}
+		if len(ids) < batch {
+			break
+		}
+	}
+
+	if len(page) <= limit {
+		return page, "", nil
+	}
+	page = page[:limit]
+	return page, encodeCursor(page[len(page)-1]), nil
+}
@@ -0,0 +1,157 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/timeline"
+)
+
+// StageStateTransition is the timeline stage the reconciler records for
+// every persisted status change.
+const StageStateTransition = "STATE_TRANSITION"
+
+// DefaultReplayWindow is how far before capture a replay looks back.
+const DefaultReplayWindow = 30 * time.Minute
+
+// replayJobScan bounds how many node jobs are scanned for the window.
+const replayJobScan = 500
+
+// ReplayEntry is one reconstructed step of an incident.
+type ReplayEntry struct {
+	Timestamp int64     `json:"timestamp"` // Unix seconds (dashboard)
+	Time      time.Time `json:"time"`      // Full precision, used for ordering
+	Source    string    `json:"source"`    // "timeline", "transition", "job"
+	Event     string    `json:"event"`
+	Details   string    `json:"details,omitempty"`
+	ReqID     string    `json:"req_id,omitempty"`
+	JobID     string    `json:"job_id,omitempty"`
+}
+
+// Replay is the ordered history of an incident rebuilt from persisted data.
+type Replay struct {
+	IncidentID  string        `json:"incident_id"`
+	StateID     string        `json:"state_id"`
+	Status      string        `json:"status"` // "replay_complete", or "replay_partial" when the timeline was pruned
+	WindowStart time.Time     `json:"window_start"`
+	WindowEnd   time.Time     `json:"window_end"`
+	Timeline    []ReplayEntry `json:"timeline"`
+}
+
+// BuildReplay rebuilds the sequence of timeline events, state transitions
+// and jobs for the incident window from the timeline backend and the store.
+// If the timeline has been pruned, the events captured with the snapshot
+// are used instead and the replay is marked partial.
+func BuildReplay(ctx context.Context, s StoreInterface, tl TimelineInterface, snap *Snapshot, window time.Duration) (*Replay, error) {
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+	end := time.Unix(snap.Timestamp, 0).Add(time.Second) // Include the capture second
+	start := end.Add(-window)
+
+	replay := &Replay{
+		IncidentID:  snap.IncidentID,
+		StateID:     snap.StateID,
+		Status:      "replay_complete",
+		WindowStart: start,
+		WindowEnd:   end,
+		Timeline:    make([]ReplayEntry, 0),
+	}
+
+	events, err := tl.Query(ctx, timeline.Query{
+		TenantID: snap.TenantID,
+		StateID:  snap.StateID,
+		Since:    start,
+		Until:    end,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("timeline query failed: %w", err)
+	}
+	if len(events) == 0 && len(snap.Timeline) > 0 {
+		q := timeline.Query{StateID: snap.StateID, Since: start, Until: end}
+		for _, e := range snap.Timeline {
+			if q.Matches(e) {
+				events = append(events, e)
+			}
+		}
+		replay.Status = "replay_partial"
+	}
+	for _, e := range events {
+		replay.Timeline = append(replay.Timeline, timelineEntry(e))
+	}
+
+	if snap.NodeID != "" {
+		jobs, err := s.ListJobs(ctx, snap.TenantID, snap.NodeID, replayJobScan)
+		if err != nil {
+			return nil, fmt.Errorf("job query failed: %w", err)
+		}
+		for _, j := range jobs {
+			if j.StateID != snap.StateID {
+				continue
+			}
+			replay.Timeline = append(replay.Timeline, jobEntries(j, start, end)...)
+		}
+	}
+
+	sort.SliceStable(replay.Timeline, func(i, k int) bool {
+		return replay.Timeline[i].Time.Before(replay.Timeline[k].Time)
+	})
+	return replay, nil
+}
+
+func timelineEntry(e timeline.ReconcileEvent) ReplayEntry {
+	entry := ReplayEntry{
+		Timestamp: e.Timestamp.Unix(),
+		Time:      e.Timestamp,
+		Source:    "timeline",
+		Event:     e.Stage,
+		ReqID:     e.ReqID,
+	}
+	switch e.Stage {
+	case StageStateTransition:
+		entry.Source = "transition"
+		entry.Event = fmt.Sprintf("State %s → %s", e.Metadata["from"], e.Metadata["to"])
+		entry.Details = e.Metadata["reason"]
+	case "QUEUED":
+		entry.Event = "Queued for reconciliation"
+	case "FINISHED":
+		entry.Event = "Reconciliation finished"
+	case "FAILED":
+		entry.Event = "Reconciliation failed"
+		entry.Details = e.Metadata["error"]
+	}
+	return entry
+}
+
+// jobEntries expands a job into its lifecycle steps inside the window.
+func jobEntries(j *store.Job, start, end time.Time) []ReplayEntry {
+	in := func(t time.Time) bool { return !t.Before(start) && !t.After(end) }
+	var entries []ReplayEntry
+
+	if in(j.CreatedAt) {
+		entries = append(entries, ReplayEntry{
+			Timestamp: j.CreatedAt.Unix(), Time: j.CreatedAt, Source: "job", JobID: j.JobID,
+			Event: "Job created", Details: j.Command,
+		})
+	}
+	if j.StartedAt != nil && in(*j.StartedAt) {
+		entries = append(entries, ReplayEntry{
+			Timestamp: j.StartedAt.Unix(), Time: *j.StartedAt, Source: "job", JobID: j.JobID,
+			Event: "Job started",
+		})
+	}
+	if j.FinishedAt != nil && in(*j.FinishedAt) {
+		details := fmt.Sprintf("exit code %d", j.ExitCode)
+		if j.Stderr != "" {
+			details += ": " + j.Stderr
+		}
+		entries = append(entries, ReplayEntry{
+			Timestamp: j.FinishedAt.Unix(), Time: *j.FinishedAt, Source: "job", JobID: j.JobID,
+			Event: "Job " + j.Status, Details: details,
+		})
+	}
+	return entries
+}
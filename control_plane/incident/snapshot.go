@@ -0,0 +1,131 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/itskum47/FluxForge/control_plane/timeline"
+)
+
+// Snapshot represents a captured incident for replay.
+type Snapshot struct {
+	IncidentID    string `json:"incident_id"`
+	StateID       string `json:"state_id"`
+	NodeID        string `json:"node_id"`
+	TenantID      string `json:"tenant_id"`
+	FailureReason string `json:"failure_reason"`
+	Timestamp     int64  `json:"timestamp"` // Unix seconds
+
+	// Snapshots
+	SchedulerSnapshot SchedulerSnapshot         `json:"scheduler_snapshot"`
+	LeaderSnapshot    LeaderSnapshot            `json:"leader_snapshot"`
+	Timeline          []timeline.ReconcileEvent `json:"timeline"`
+}
+
+type SchedulerSnapshot struct {
+	QueueDepth          int     `json:"queue_depth"`
+	ActiveTasks         int     `json:"active_tasks"`
+	WorkerSaturation    float64 `json:"worker_saturation"`
+	CircuitBreakerState string  `json:"circuit_breaker_state"`
+	RuntimeMode         string  `json:"runtime_mode"`
+}
+
+type LeaderSnapshot struct {
+	IsLeader     bool   `json:"is_leader"`
+	CurrentEpoch int64  `json:"current_epoch"`
+	NodeID       string `json:"node_id"`
+}
+
+// ListFilter selects incidents for a tenant. Results are newest first.
+type ListFilter struct {
+	StateID string
+	NodeID  string
+	Since   int64 // Unix seconds, inclusive (0 = unbounded)
+	Until   int64 // Unix seconds, inclusive (0 = unbounded)
+	Limit   int
+	// Cursor resumes after the last incident of a previous page.
+	Cursor string
+}
+
+// DefaultListLimit applies when ListFilter.Limit is unset.
+const DefaultListLimit = 50
+
+// MaxListLimit caps a single page.
+const MaxListLimit = 500
+
+func (f ListFilter) matches(s *Snapshot) bool {
+	if f.StateID != "" && s.StateID != f.StateID {
+		return false
+	}
+	if f.NodeID != "" && s.NodeID != f.NodeID {
+		return false
+	}
+	if f.Since > 0 && s.Timestamp < f.Since {
+		return false
+	}
+	if f.Until > 0 && s.Timestamp > f.Until {
+		return false
+	}
+	return true
+}
+
+func (f ListFilter) limit() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultListLimit
+	case f.Limit > MaxListLimit:
+		return MaxListLimit
+	}
+	return f.Limit
+}
+
+// ErrNotFound is returned when an incident does not exist for the tenant.
+var ErrNotFound = errors.New("incident not found")
+
+// Repository persists incident snapshots, scoped by tenant.
+type Repository interface {
+	Save(ctx context.Context, snap *Snapshot) error
+	Get(ctx context.Context, tenantID string, incidentID string) (*Snapshot, error)
+	// List returns one page and the cursor for the next ("" when done).
+	List(ctx context.Context, tenantID string, filter ListFilter) ([]*Snapshot, string, error)
+}
+
+// cursor is the position of the last returned incident: "<timestamp>:<id>".
+// Ordering is (timestamp DESC, id DESC), so ties on the second are stable.
+type cursor struct {
+	Timestamp int64
+	ID        string
+}
+
+func encodeCursor(s *Snapshot) string {
+	return fmt.Sprintf("%d:%s", s.Timestamp, s.IncidentID)
+}
+
+func decodeCursor(raw string) (*cursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	ts, id, ok := strings.Cut(raw, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor{Timestamp: n, ID: id}, nil
+}
+
+// after reports whether s sorts after the cursor in (timestamp DESC, id DESC).
+func (c *cursor) after(s *Snapshot) bool {
+	if c == nil {
+		return true
+	}
+	if s.Timestamp != c.Timestamp {
+		return s.Timestamp < c.Timestamp
+	}
+	return s.IncidentID < c.ID
+}
@@ -0,0 +1,165 @@
+package jobnotify
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Channel is the pub/sub channel used to fan completions out to other replicas.
+const Channel = "fluxforge:jobs:completed"
+
+// Completion announces that a job reached a terminal status.
+// It carries no result data: the store remains the source of truth.
+type Completion struct {
+	TenantID string `json:"tenant_id"`
+	JobID    string `json:"job_id"`
+	Status   string `json:"status"`
+}
+
+// Bus delivers completions between control plane replicas.
+// /jobs/result may land on any replica while the leader is the one waiting.
+type Bus interface {
+	Publish(ctx context.Context, channel string, message string) error
+	// Subscribe blocks, invoking handler per message, until ctx is done or the
+	// subscription fails.
+	Subscribe(ctx context.Context, channel string, handler func(message string)) error
+}
+
+// Registry wakes goroutines waiting on a job as soon as its result is recorded.
+// IMPORTANT:
+// - Notifications are best-effort (pub/sub is fire-and-forget).
+// - Waiters must still re-read the job from the store and keep a slow fallback poll.
+type Registry struct {
+	mu      sync.Mutex
+	waiters map[string][]chan Completion
+	bus     Bus // nil = single replica, in-process delivery only
+}
+
+// NewRegistry creates a new Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		waiters: make(map[string][]chan Completion),
+	}
+}
+
+// SetBus enables cross-replica delivery.
+func (r *Registry) SetBus(bus Bus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bus = bus
+}
+
+func waiterKey(tenantID, jobID string) string {
+	return tenantID + "/" + jobID
+}
+
+// Wait registers interest in a job. Register BEFORE dispatching the job,
+// otherwise a fast agent can report back before anyone is listening.
+// The returned cancel func must be called once the caller stops waiting.
+func (r *Registry) Wait(tenantID, jobID string) (<-chan Completion, func()) {
+	key := waiterKey(tenantID, jobID)
+	ch := make(chan Completion, 1) // Buffered: Deliver never blocks
+
+	r.mu.Lock()
+	r.waiters[key] = append(r.waiters[key], ch)
+	r.mu.Unlock()
+
+	cancel := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		chans := r.waiters[key]
+		for i, c := range chans {
+			if c == ch {
+				chans = append(chans[:i], chans[i+1:]...)
+				break
+			}
+		}
+		if len(chans) == 0 {
+			delete(r.waiters, key)
+		} else {
+			r.waiters[key] = chans
+		}
+	}
+	return ch, cancel
+}
+
+// Deliver wakes local waiters for the job. Safe to call more than once.
+func (r *Registry) Deliver(c Completion) {
+	key := waiterKey(c.TenantID, c.JobID)
+
+	r.mu.Lock()
+	chans := r.waiters[key]
+	delete(r.waiters, key)
+	r.mu.Unlock()
+
+	for _, ch := range chans {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+}
+
+// Notify wakes local waiters and publishes the completion to other replicas.
+// Publish failures are logged, not returned: waiters fall back to polling.
+func (r *Registry) Notify(ctx context.Context, c Completion) {
+	r.Deliver(c)
+
+	r.mu.Lock()
+	bus := r.bus
+	r.mu.Unlock()
+	if bus == nil {
+		return
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		log.Printf("⚠️ Failed to marshal job completion %s: %v", c.JobID, err)
+		return
+	}
+	if err := bus.Publish(ctx, Channel, string(data)); err != nil {
+		log.Printf("⚠️ Failed to publish job completion %s (waiters will poll): %v", c.JobID, err)
+	}
+}
+
+// Pending returns the number of jobs with at least one waiter.
+func (r *Registry) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.waiters)
+}
+
+// Run consumes completions published by other replicas until ctx is done.
+// It resubscribes after bus failures. No-op when no bus is configured.
+func (r *Registry) Run(ctx context.Context) {
+	r.mu.Lock()
+	bus := r.bus
+	r.mu.Unlock()
+	if bus == nil {
+		return
+	}
+
+	for {
+		err := bus.Subscribe(ctx, Channel, func(message string) {
+			var c Completion
+			if err := json.Unmarshal([]byte(message), &c); err != nil {
+				log.Printf("⚠️ Ignoring malformed job completion: %v", err)
+				return
+			}
+			r.Deliver(c)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("⚠️ Job completion subscription lost: %v (resubscribing)", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
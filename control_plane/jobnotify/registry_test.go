@@ -0,0 +1,99 @@
+package jobnotify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memBus is an in-process Bus connecting registries like separate replicas.
+type memBus struct {
+	mu       sync.Mutex
+	handlers []func(string)
+	ready    chan struct{}
+}
+
+func (b *memBus) Publish(ctx context.Context, channel string, message string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, h := range b.handlers {
+		h(message)
+	}
+	return nil
+}
+
+func (b *memBus) Subscribe(ctx context.Context, channel string, handler func(string)) error {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+	b.ready <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRegistry_DeliverWakesWaiter(t *testing.T) {
+	r := NewRegistry()
+	done, cancel := r.Wait("t1", "job-1")
+	defer cancel()
+
+	// Same job ID in another tenant must not wake us
+	r.Deliver(Completion{TenantID: "t2", JobID: "job-1", Status: "completed"})
+	select {
+	case <-done:
+		t.Fatal("woken by another tenant's job")
+	default:
+	}
+
+	r.Deliver(Completion{TenantID: "t1", JobID: "job-1", Status: "completed"})
+	select {
+	case c := <-done:
+		if c.Status != "completed" {
+			t.Fatalf("unexpected status %s", c.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken")
+	}
+
+	if r.Pending() != 0 {
+		t.Fatalf("expected no pending waiters, got %d", r.Pending())
+	}
+}
+
+func TestRegistry_CancelRemovesWaiter(t *testing.T) {
+	r := NewRegistry()
+	_, cancel := r.Wait("t1", "job-1")
+	cancel()
+
+	if r.Pending() != 0 {
+		t.Fatalf("expected no pending waiters, got %d", r.Pending())
+	}
+	// Delivering after cancel must not panic or block
+	r.Deliver(Completion{TenantID: "t1", JobID: "job-1", Status: "failed"})
+}
+
+func TestRegistry_CrossReplicaDelivery(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	bus := &memBus{ready: make(chan struct{}, 1)}
+	leader := NewRegistry()
+	leader.SetBus(bus)
+	follower := NewRegistry()
+	follower.SetBus(bus)
+
+	go leader.Run(ctx)
+	<-bus.ready
+
+	done, cancel := leader.Wait("t1", "job-1")
+	defer cancel()
+
+	// Result lands on the follower replica
+	follower.Notify(ctx, Completion{TenantID: "t1", JobID: "job-1", Status: "completed"})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("leader not woken by follower's completion")
+	}
+}
@@ -1,31 +1,63 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
-	"time"
 
 	"github.com/itskum47/FluxForge/control_plane/store"
 )
 
 // Dispatcher is responsible for sending jobs to agents.
+// The Transport is chosen per agent from its registration Metadata.
 type Dispatcher struct {
-	store store.Store
+	store      store.Store
+	transports map[string]Transport
+	pull       *PullTransport
 }
 
-// NewDispatcher creates a new Dispatcher.
+// NewDispatcher creates a new Dispatcher with the push and pull transports.
 func NewDispatcher(store store.Store) *Dispatcher {
-	return &Dispatcher{store: store}
+	pull := NewPullTransport(store)
+	return &Dispatcher{
+		store: store,
+		transports: map[string]Transport{
+			TransportPush: NewHTTPPushTransport(store),
+			TransportPull: pull,
+		},
+		pull: pull,
+	}
+}
+
+// RegisterTransport adds or replaces a transport by name.
+func (d *Dispatcher) RegisterTransport(name string, t Transport) {
+	d.transports[name] = t
 }
 
-// DispatchJob sends a job to the target agent for execution.
+// Pull returns the pull transport backing /agent/jobs/next.
+func (d *Dispatcher) Pull() *PullTransport {
+	return d.pull
+}
+
+// transportFor resolves the transport an agent registered with.
+// Agents without a "transport" metadata key get push (legacy behaviour).
+func (d *Dispatcher) transportFor(agent *store.Agent) (Transport, error) {
+	name := agent.Metadata[TransportMetadataKey]
+	if name == "" {
+		name = TransportPush
+	}
+	t, ok := d.transports[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transport %q for agent %s", name, agent.NodeID)
+	}
+	return t, nil
+}
+
+// DispatchJob hands a job to the target agent for execution.
 // IMPORTANT:
-// - HTTP 202 Accepted = success (async execution)
+// - Success means the agent has (or will) take the job, not that it finished
 // - Job completion is reported later via /jobs/result
+// - Failures are recorded on the job; the job state is the source of truth
 func (d *Dispatcher) DispatchJob(ctx context.Context, agent *store.Agent, job *store.Job) {
 	// Check context before starting
 	if ctx.Err() != nil {
@@ -34,44 +66,16 @@ func (d *Dispatcher) DispatchJob(ctx context.Context, agent *store.Agent, job *s
 		return
 	}
 
-	url := fmt.Sprintf("http://%s:%d/execute", agent.IPAddress, agent.Port)
-
-	payload := map[string]string{
-		"job_id":  job.JobID,
-		"command": job.Command,
-	}
-
-	data, err := json.Marshal(payload)
-	if err != nil {
-		// Use UpdateJobStatus interface method
-		d.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "failed", 0, "", fmt.Sprintf("failed to marshal payload: %v", err))
-		return
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
+	t, err := d.transportFor(agent)
 	if err != nil {
-		d.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "failed", 0, "", fmt.Sprintf("failed to create request: %v", err))
+		d.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "failed", 0, "", err.Error())
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	// Execute Request
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		d.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "failed", 0, "", fmt.Sprintf("failed to contact agent: %v", err))
+	if err := t.Deliver(ctx, agent, job); err != nil {
+		d.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "failed", 0, "", err.Error())
 		return
 	}
-	defer resp.Body.Close()
-
-	// ✅ CORRECT SEMANTICS
-	if resp.StatusCode != http.StatusAccepted {
-		d.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "failed", 0, "", fmt.Sprintf("agent returned status %d", resp.StatusCode))
-		return
-	}
-
-	// Job accepted for execution
-	d.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "running", 0, "", "")
 
-	log.Printf("Job %s dispatched to agent %s", job.JobID, agent.NodeID)
+	log.Printf("Job %s dispatched to agent %s via %s", job.JobID, agent.NodeID, t.Name())
 }
@@ -2,20 +2,22 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/itskum47/FluxForge/control_plane/attestation"
 	"github.com/itskum47/FluxForge/control_plane/coordination"
 	"github.com/itskum47/FluxForge/control_plane/idempotency"
 	"github.com/itskum47/FluxForge/control_plane/middleware"
 	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/resilience"
 	"github.com/itskum47/FluxForge/control_plane/scheduler"
+	"github.com/itskum47/FluxForge/control_plane/sharding"
 	"github.com/itskum47/FluxForge/control_plane/store"
-	"github.com/itskum47/FluxForge/control_plane/streaming"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -26,35 +28,42 @@ func generateNodeID() string {
 }
 
 func main() {
-	var s store.Store
-	var err error
-
-	// Phase 5: DB Connection
-	// CRITICAL: Leader election requires shared coordination backend (Redis)
-	// MemoryStore only works for single-node operation
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "localhost:6379"
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
 	}
-
-	// Use RedisStore for both coordination AND durable epochs
-	// RedisStore implements both Store and Coordinator interfaces
-	redisStore, err := store.NewRedisStore(redisAddr, "", 0)
-	if err != nil {
-		log.Fatalf("Failed to connect to Redis (required for leader election): %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
 	}
-	log.Printf("✅ Connected to Redis at %s for coordination and storage", redisAddr)
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
+	ctx := context.Background()
 
-	// Use RedisStore as the primary store
-	s = redisStore
+	// Phase 5: DB Connection
+	// CRITICAL: Leader election requires a shared coordination backend
+	// (Redis or Postgres); without one this replica runs standalone
+	b := configureBackends(ctx)
+	s := b.store
+
+	// Degraded Mode: ride out a store outage on cached reads and queued
+	// writes; the leader replays them on recovery
+	degraded := resilience.NewDegradedMode()
+	degradedStore := configureDegradedMode(b, degraded)
+	if degradedStore != nil {
+		s = degradedStore
+	}
 
 	// Phase 5: Event Streaming
-	// Use LogPublisher until NATS is available
-	publisher := streaming.NewLogPublisher()
+	publisher := configureEventStream(ctx, b)
 	defer publisher.Close()
 
 	dispatcher := NewDispatcher(s)
 	reconciler := NewReconciler(s, dispatcher, publisher)
+	// Event outbox: every replica commits transition events with their
+	// status update; the relay publishes them (2.6)
+	eventRelay := configureOutboxRelay(s, publisher)
+	reconciler.SetOutbox(eventRelay != nil)
 
 	// Phase 5: Sharding Config
 	shardIndex := 0
@@ -84,13 +93,32 @@ func main() {
 			schedConfig.CircuitBreakerThreshold = cb
 		}
 	}
+	// Drift detection: DRIFT_SWEEP_INTERVAL=0 disables the sweeper,
+	// DRIFT_CHECK_INTERVAL re-checks states that don't set their own interval
+	if v := os.Getenv("DRIFT_SWEEP_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid DRIFT_SWEEP_INTERVAL %q: %v", v, err)
+		}
+		schedConfig.DriftSweepInterval = d
+	}
+	if v := os.Getenv("DRIFT_CHECK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid DRIFT_CHECK_INTERVAL %q: %v", v, err)
+		}
+		schedConfig.DefaultCheckInterval = d
+	}
 
 	// Pass store for polling/rehydration
 	sched := scheduler.NewScheduler(s, reconciler, shardIndex, shardCount, schedConfig)
-	ctx := context.Background()
+
+	// Phase 6: Durable Timeline (survives restarts and leader changes)
+	configureTimeline(ctx, sched.GetTimeline(), b)
+	reconciler.SetTimeline(sched.GetTimeline())
 
 	// Phase 5: Distributed Coordination
-	// Redis is already initialized above for coordination
+	// The coordination backend is initialized above
 
 	// CRITICAL: Configure reconciliation interval based on mode
 	// 5 seconds for certification (maximum race exposure)
@@ -103,14 +131,32 @@ func main() {
 		reconcileInterval, os.Getenv("PRODUCTION_MODE"))
 
 	// 2. Initialize Leader Elector
-	// We use Postgres (s) for Durable Epochs and Redis (redisStore) for leases.
+	// The store (s) holds Durable Epochs; the coordinator holds leases.
 	var elector *coordination.LeaderElector
-	if redisStore != nil {
-		elector = coordination.NewLeaderElector(redisStore, s, "node-"+generateNodeID(), 30*time.Second)
+	var membership *coordination.Membership
+	if b.coordinator != nil {
+		podID := "node-" + generateNodeID()
+		if shardCount > 1 {
+			// Sharded: each shard elects its own leader, and pods announce
+			// their shard so reconciles for other shards can be forwarded.
+			// POD_ADDRESS is the base URL peers reach this pod on.
+			hostname, _ := os.Hostname()
+			membership = coordination.NewMembership(b.coordinator, coordination.Member{
+				PodID:      podID,
+				ShardIndex: shardIndex,
+				Address:    getEnvOrDefault("POD_ADDRESS", "http://"+hostname+":8080"),
+			}, shardCount, 15*time.Second)
+			membership.OnChange(func(ring *sharding.Ring) { sched.SetPartitioner(ring) })
+			membership.Start(ctx)
+
+			elector = coordination.NewShardLeaderElector(b.coordinator, s, podID, shardIndex, 30*time.Second)
+		} else {
+			elector = coordination.NewLeaderElector(b.coordinator, s, podID, 30*time.Second)
+		}
 
 		// 2.1 Initialize Lock Janitor (Background Worker)
 		// Cleans up stale locks and enforces fencing safety
-		janitor := coordination.NewLockJanitor(redisStore, s, 60*time.Second)
+		janitor := coordination.NewLockJanitor(b.coordinator, s, 60*time.Second)
 		janitor.Start(ctx)
 
 		// 2.2 Initialize Agent Liveness Monitor
@@ -119,19 +165,72 @@ func main() {
 		agentMonitor.Start(ctx)
 	}
 
+	// 2.3 Degraded-mode replay follows leadership: only the leader queues
+	// writes during an outage, so only it has any to replay
+	var replay *resilience.ReconciliationCoordinator
+	if degradedStore != nil {
+		leaderInfo := func() (*resilience.LeaderEpoch, error) {
+			if elector == nil {
+				return &resilience.LeaderEpoch{}, nil
+			}
+			state := elector.GetState()
+			return &resilience.LeaderEpoch{Epoch: state.CurrentEpoch, LeaderID: state.NodeID}, nil
+		}
+		replay = resilience.NewReconciliationCoordinator(degraded, degradedStore.Replayer(), leaderInfo, "node-"+generateNodeID())
+		degradedStore.SetCoordinator(replay)
+		degradedStore.Start(ctx)
+	}
+
+	// 2.4 Job Retention: leader-only, and with sharding only shard 0's
+	// leader, since it spans every tenant
+	jobGC := configureJobRetention(s)
+	if shardIndex != 0 {
+		jobGC = nil
+	}
+	// 2.5 Store Sync: likewise, backfills a live migration's secondary
+	storeSync := configureStoreSync(b)
+	if shardIndex != 0 {
+		storeSync = nil
+	}
+	// 2.6 Event Outbox relay: likewise, the outbox is shared by all shards
+	if shardIndex != 0 {
+		eventRelay = nil
+	}
+	// 2.7 Store Routing: every replica follows a migration's cut-over
+	storeRouter := configureStoreRouter(b)
+	if storeRouter != nil {
+		storeRouter.Start(ctx)
+	}
+
 	// 3. Start Scheduler via Leader Election
 	if elector != nil {
 		elector.SetCallbacks(
 			func(ctx context.Context) {
 				log.Println("✅ Elected as LEADER. Starting Scheduler...")
+				if replay != nil {
+					state := elector.GetState()
+					replay.UpdateLeadershipStatus(state.CurrentEpoch, state.NodeID, true)
+				}
 				// Rehydrate pending work from DB
 				if err := sched.RehydrateQueue(ctx); err != nil {
 					log.Printf("⚠️ Failed to rehydrate queue: %v", err)
 				}
 				sched.Start(ctx)
+				if jobGC != nil {
+					jobGC.Start(ctx) // Stops when ctx is fenced off
+				}
+				if storeSync != nil {
+					storeSync.Start(ctx)
+				}
+				if eventRelay != nil {
+					eventRelay.Start(ctx)
+				}
 			},
 			func() {
 				log.Println("⚠️ Lost LEADERSHIP. Scheduler stopping...")
+				if replay != nil {
+					replay.UpdateLeadershipStatus(elector.GetState().CurrentEpoch, "", false)
+				}
 				sched.Stop()
 			},
 		)
@@ -141,38 +240,78 @@ func main() {
 	} else {
 		// Fallback for no-redis/dev mode: Just start scheduler?
 		// Or disable scheduler?
-		log.Println("❌ Redis unavailable. Starting Scheduler in STANDALONE mode (Unsafe for HA).")
+		log.Println("❌ No coordination backend. Starting Scheduler in STANDALONE mode (Unsafe for HA).")
+		if replay != nil {
+			replay.UpdateLeadershipStatus(0, "standalone", true)
+		}
 		// Rehydrate in standalone mode too
 		if err := sched.RehydrateQueue(ctx); err != nil {
 			log.Printf("⚠️ Failed to rehydrate queue: %v", err)
 		}
 		sched.Start(ctx)
+		if jobGC != nil {
+			jobGC.Start(ctx)
+		}
+		if storeSync != nil {
+			storeSync.Start(ctx)
+		}
+		if eventRelay != nil {
+			eventRelay.Start(ctx)
+		}
 	}
 
 	// 4. Initialize Idempotency Store
-	// Use Redis if available, otherwise Memory
+	// Use Redis or bolt if available, otherwise Memory
 	var idemStore *idempotency.Store
-	if redisStore != nil {
-		idemStore = idempotency.NewStore(redisStore)
-		log.Println("Using Redis for Idempotency Store")
+	if backend := b.idempotencyBackend(); backend != nil {
+		idemStore = idempotency.NewStore(backend)
+		log.Println("Using persistent Idempotency Store")
 	} else {
 		idemStore = idempotency.NewStore(nil)
 		log.Println("Using In-Memory Idempotency Store (Ephemeral)")
 	}
 
+	// Job Completion Notifications: /jobs/result may land on any replica
+	if b.bus != nil {
+		reconciler.Completions().SetBus(b.bus)
+		go reconciler.Completions().Run(ctx)
+	}
+
 	api := NewAPI(s, dispatcher, reconciler, sched, elector, idemStore)
+	if membership != nil {
+		api.SetShardForwarder(NewShardForwarder(membership))
+	}
+
+	// Phase 6.3: Incident persistence for /api/incidents and replay
+	configureIncidents(ctx, api, b)
+
+	// Agent Attestation (optional): verify X-Agent-Signature on registration
+	if keyPath := os.Getenv("AGENT_ATTESTATION_PUBLIC_KEY"); keyPath != "" {
+		keyPEM, err := os.ReadFile(keyPath)
+		if err != nil {
+			log.Fatalf("Failed to read attestation public key: %v", err)
+		}
+		verifier, err := attestation.NewVerifier(string(keyPEM), true)
+		if err != nil {
+			log.Fatalf("Failed to initialize attestation verifier: %v", err)
+		}
+		api.SetAttestationVerifier(verifier)
+		log.Println("Agent attestation ENFORCED on /agent/register")
+	}
 
 	// Start WebSocket hub (Phase 6: Critical Fix)
 	go api.wsHub.Run(ctx)
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
-	})
+	api.SetDegradedMode(degraded)
+	http.HandleFunc("/health", healthHandler(degraded))
 
 	http.Handle("/agent/register", middleware.AuthMiddleware(http.HandlerFunc(api.handleRegister)))
 	http.Handle("/agent/heartbeat", middleware.AuthMiddleware(http.HandlerFunc(api.handleHeartbeat)))
+	http.Handle("/agent/jobs/next", middleware.AuthMiddleware(http.HandlerFunc(api.handleNextJob)))
 	http.Handle("/agents", middleware.AuthMiddleware(http.HandlerFunc(api.handleListAgents)))
+	http.Handle("/agents/", middleware.AuthMiddleware(http.HandlerFunc(api.handleDeleteAgent)))
+	http.Handle(internalSubmitPath, middleware.AuthMiddleware(middleware.RequireRole(http.HandlerFunc(api.handleInternalSubmit), peerRole)))
+	http.Handle(internalCancelPath, middleware.AuthMiddleware(middleware.RequireRole(http.HandlerFunc(api.handleInternalCancel), peerRole)))
 
 	http.Handle("/jobs", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
@@ -199,10 +338,22 @@ func main() {
 			api.withIdempotency(api.handleReconcileState)(w, r)
 			return
 		}
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/rollback") {
+			api.withIdempotency(api.handleRollbackState)(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/revisions") {
+			api.handleStateRevisions(w, r)
+			return
+		}
 		if r.Method == http.MethodGet {
 			api.handleGetState(w, r)
 			return
 		}
+		if r.Method == http.MethodDelete {
+			api.handleDeleteState(w, r)
+			return
+		}
 		http.Error(w, "Not found", http.StatusNotFound)
 	})))
 
@@ -212,15 +363,16 @@ func main() {
 	// Metrics Endpoint
 	http.Handle("/metrics", promhttp.Handler())
 
-	// Debug Snapshot Endpoint
-	http.HandleFunc("/scheduler/debug/snapshot", func(w http.ResponseWriter, r *http.Request) {
-		snapshot := sched.GetSnapshot()
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(snapshot)
-	})
+	// Debug Snapshot Endpoint, scoped to the caller's tenant
+	// Optional filters: req_id, state_id, node_id, since, until (RFC3339), limit
+	http.Handle("/scheduler/debug/snapshot", middleware.AuthMiddleware(http.HandlerFunc(api.handleDebugSnapshot)))
 
 	// Admin Endpoints
 	http.HandleFunc("/admin/admission-mode", api.handleSetAdmissionMode)
+	if b.dual != nil {
+		http.Handle("/admin/store-migration", middleware.AuthMiddleware(middleware.RequireRole(
+			storeMigrationHandler(b.dual, storeRouter, storeSync), "admin")))
+	}
 
 	// Phase 6: Dashboard API
 	http.Handle("/api/dashboard", middleware.AuthMiddleware(http.HandlerFunc(api.handleGetDashboard)))
@@ -64,3 +64,31 @@ func GetRoleFromContext(ctx context.Context) (string, error) {
 	}
 	return role, nil
 }
+
+// GetClaimsFromContext retrieves the validated token claims from the context.
+func GetClaimsFromContext(ctx context.Context) (*auth.Claims, error) {
+	claims, ok := ctx.Value(ClaimsContextKey).(*auth.Claims)
+	if !ok || claims == nil {
+		return nil, fmt.Errorf("claims not found in context")
+	}
+	return claims, nil
+}
+
+// RequireRole rejects requests whose role is not one of roles. It reads
+// the role AuthMiddleware put in the context, so wrap it in AuthMiddleware.
+func RequireRole(next http.Handler, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, err := GetRoleFromContext(r.Context())
+		if err != nil {
+			http.Error(w, "Forbidden: no role", http.StatusForbidden)
+			return
+		}
+		for _, allowed := range roles {
+			if role == allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, fmt.Sprintf("Forbidden: role %q may not do this", role), http.StatusForbidden)
+	})
+}
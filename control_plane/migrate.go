@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+const migrateUsage = `usage: fluxforge-control-plane migrate <command> [flags]
+
+Manages the PostgresStore schema in DATABASE_URL.
+
+commands:
+  up       apply all pending migrations
+  down     revert the newest migrations (-steps, default 1)
+  status   list migrations and when they were applied
+`
+
+// runMigrate implements the "migrate" subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to revert (down only)")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if os.Getenv("DATABASE_URL") == "" {
+		fmt.Fprintln(os.Stderr, "migrate: DATABASE_URL is not set")
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	pg, err := store.NewPostgresStore(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: failed to connect: %v\n", err)
+		return 1
+	}
+	defer pg.Close()
+
+	switch command {
+	case "up":
+		n, err := pg.MigrateUp(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate up: %v (%d applied)\n", err, n)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", n)
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(os.Stderr, "migrate down: -steps must be at least 1")
+			return 2
+		}
+		n, err := pg.MigrateDown(ctx, *steps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate down: %v (%d reverted)\n", err, n)
+			return 1
+		}
+		fmt.Printf("Reverted %d migration(s)\n", n)
+	case "status":
+		states, err := pg.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, st := range states {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "migrate: unknown command %q\n\n", command)
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
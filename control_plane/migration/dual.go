@@ -0,0 +1,419 @@
+// Package migration moves a live control plane from one store backend to
+// another: DualStore writes to both, and the Syncer backfills and verifies
+// the secondary until reads, and then writes, can be cut over to it.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// DualStore is a store.Store over two backends. Every write goes to the
+// primary first; its result (and the version it assigns) is what callers
+// see, and it is then mirrored to the secondary. A mirror failure is
+// logged and counted, never returned: the Syncer repairs the record.
+// Reads are served by one side, chosen with SetReadFrom.
+//
+// Cut-over is switched at runtime: read from the secondary once the
+// Syncer reports no mismatches, then promote it with SetPrimary so the old
+// backend becomes the mirror, ready for a rollback until it is removed.
+// Both apply to this DualStore only; a Router applies them on every replica.
+type DualStore struct {
+	stores [2]store.Store
+	names  [2]string
+
+	primary atomic.Int32 // Index into stores
+	read    atomic.Int32
+}
+
+var _ store.Store = (*DualStore)(nil)
+
+// NewDualStore writes to primary and mirrors to secondary, reading from
+// the primary. The names identify the backends in SetPrimary, SetReadFrom,
+// logs and metrics.
+func NewDualStore(primaryName string, primary store.Store, secondaryName string, secondary store.Store) *DualStore {
+	return &DualStore{
+		stores: [2]store.Store{primary, secondary},
+		names:  [2]string{primaryName, secondaryName},
+	}
+}
+
+// Status is the current routing of a DualStore.
+type Status struct {
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary"`
+	ReadFrom  string `json:"read_from"`
+}
+
+func (d *DualStore) Status() Status {
+	p := d.primary.Load()
+	return Status{
+		Primary:   d.names[p],
+		Secondary: d.names[1-p],
+		ReadFrom:  d.names[d.read.Load()],
+	}
+}
+
+// SetPrimary makes the named backend the one written first.
+func (d *DualStore) SetPrimary(name string) error {
+	i, err := d.index(name)
+	if err != nil {
+		return err
+	}
+	if d.primary.Swap(i) != i {
+		log.Printf("Store migration: %s is now the primary", name)
+	}
+	return nil
+}
+
+// SetReadFrom makes the named backend serve reads.
+func (d *DualStore) SetReadFrom(name string) error {
+	i, err := d.index(name)
+	if err != nil {
+		return err
+	}
+	if d.read.Swap(i) != i {
+		log.Printf("Store migration: reading from %s", name)
+	}
+	return nil
+}
+
+func (d *DualStore) index(name string) (int32, error) {
+	for i, n := range d.names {
+		if n == name {
+			return int32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown backend %q (want %s or %s)", name, d.names[0], d.names[1])
+}
+
+// Primary and Secondary return the backends in their current roles.
+func (d *DualStore) Primary() store.Store {
+	return d.stores[d.primary.Load()]
+}
+
+func (d *DualStore) Secondary() store.Store {
+	return d.stores[1-d.primary.Load()]
+}
+
+func (d *DualStore) reader() store.Store {
+	return d.stores[d.read.Load()]
+}
+
+// sides returns both backends for one write, so a concurrent SetPrimary
+// does not mirror it back to where it came from.
+func (d *DualStore) sides() (primary, secondary store.Store, secondaryName string) {
+	p := d.primary.Load()
+	return d.stores[p], d.stores[1-p], d.names[1-p]
+}
+
+// mirrored records the outcome of a write to the secondary.
+func mirrored(name, op string, err error) {
+	if err == nil {
+		return
+	}
+	observability.StoreMirrorErrors.WithLabelValues(name, op).Inc()
+	log.Printf("Store migration: mirroring %s to %s failed: %v", op, name, err)
+}
+
+// --- Agent Operations ---
+
+func (d *DualStore) UpsertAgent(ctx context.Context, tenantID string, agent *store.Agent) error {
+	primary, secondary, name := d.sides()
+	if err := primary.UpsertAgent(ctx, tenantID, agent); err != nil {
+		return err
+	}
+	mirror := *agent
+	mirrored(name, "UpsertAgent", secondary.UpsertAgent(store.WithCreatedAt(ctx, agent.CreatedAt), tenantID, &mirror))
+	return nil
+}
+
+func (d *DualStore) GetAgent(ctx context.Context, tenantID string, nodeID string) (*store.Agent, error) {
+	return d.reader().GetAgent(ctx, tenantID, nodeID)
+}
+
+func (d *DualStore) ListAgents(ctx context.Context, tenantID string) ([]*store.Agent, error) {
+	return d.reader().ListAgents(ctx, tenantID)
+}
+
+func (d *DualStore) QueryAgents(ctx context.Context, tenantID string, opts store.ListOptions) (*store.AgentPage, error) {
+	return d.reader().QueryAgents(ctx, tenantID, opts)
+}
+
+func (d *DualStore) UpdateAgentHeartbeat(ctx context.Context, tenantID string, nodeID string, t time.Time) error {
+	primary, secondary, name := d.sides()
+	if err := primary.UpdateAgentHeartbeat(ctx, tenantID, nodeID, t); err != nil {
+		return err
+	}
+	mirrored(name, "UpdateAgentHeartbeat", secondary.UpdateAgentHeartbeat(ctx, tenantID, nodeID, t))
+	return nil
+}
+
+func (d *DualStore) DeleteAgent(ctx context.Context, tenantID string, nodeID string) error {
+	primary, secondary, name := d.sides()
+	if err := primary.DeleteAgent(ctx, tenantID, nodeID); err != nil {
+		return err
+	}
+	mirrored(name, "DeleteAgent", ignoreNotFound(secondary.DeleteAgent(ctx, tenantID, nodeID)))
+	return nil
+}
+
+// --- State Operations ---
+
+// UpsertState mirrors the upsert, with the CreatedAt the primary assigned,
+// and checks that the secondary assigned the same version; a secondary
+// that is behind is left for the Syncer.
+func (d *DualStore) UpsertState(ctx context.Context, tenantID string, state *store.DesiredState) error {
+	primary, secondary, name := d.sides()
+	if err := primary.UpsertState(ctx, tenantID, state); err != nil {
+		return err
+	}
+	mirror := *state
+	err := secondary.UpsertState(store.WithCreatedAt(ctx, state.CreatedAt), tenantID, &mirror)
+	if err == nil && mirror.Version != state.Version {
+		err = fmt.Errorf("state %s is at version %d, primary at %d", state.StateID, mirror.Version, state.Version)
+	}
+	mirrored(name, "UpsertState", err)
+	return nil
+}
+
+func (d *DualStore) UpdateStateStatus(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int) error {
+	return d.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, nil)
+}
+
+// UpdateStateStatusWithEvent commits the event with the primary's write
+// only; the secondary gets the status alone, so the event is relayed once.
+func (d *DualStore) UpdateStateStatusWithEvent(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int, event *store.OutboxEvent) error {
+	primary, secondary, name := d.sides()
+	if err := primary.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, event); err != nil {
+		return err
+	}
+	mirrored(name, "UpdateStateStatus", secondary.UpdateStateStatus(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion))
+	return nil
+}
+
+func (d *DualStore) GetState(ctx context.Context, tenantID string, stateID string) (*store.DesiredState, error) {
+	return d.reader().GetState(ctx, tenantID, stateID)
+}
+
+func (d *DualStore) GetStateByNode(ctx context.Context, tenantID string, nodeID string) (*store.DesiredState, error) {
+	return d.reader().GetStateByNode(ctx, tenantID, nodeID)
+}
+
+func (d *DualStore) ListStates(ctx context.Context, tenantID string) ([]*store.DesiredState, error) {
+	return d.reader().ListStates(ctx, tenantID)
+}
+
+func (d *DualStore) QueryStates(ctx context.Context, tenantID string, opts store.ListOptions) (*store.StatePage, error) {
+	return d.reader().QueryStates(ctx, tenantID, opts)
+}
+
+func (d *DualStore) DeleteState(ctx context.Context, tenantID string, stateID string) error {
+	primary, secondary, name := d.sides()
+	if err := primary.DeleteState(ctx, tenantID, stateID); err != nil {
+		return err
+	}
+	mirrored(name, "DeleteState", ignoreNotFound(secondary.DeleteState(ctx, tenantID, stateID)))
+	return nil
+}
+
+func (d *DualStore) ListStateRevisions(ctx context.Context, tenantID string, stateID string) ([]*store.StateRevision, error) {
+	return d.reader().ListStateRevisions(ctx, tenantID, stateID)
+}
+
+func (d *DualStore) GetStateRevision(ctx context.Context, tenantID string, stateID string, revision int) (*store.StateRevision, error) {
+	return d.reader().GetStateRevision(ctx, tenantID, stateID, revision)
+}
+
+func (d *DualStore) ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*store.DesiredState, error) {
+	return d.reader().ListStatesByStatus(ctx, status, shardIndex, shardCount)
+}
+
+func (d *DualStore) CountStatesByStatus(ctx context.Context, tenantID string, status string) (int, error) {
+	return d.reader().CountStatesByStatus(ctx, tenantID, status)
+}
+
+// --- Job Operations ---
+
+func (d *DualStore) CreateJob(ctx context.Context, tenantID string, job *store.Job) error {
+	primary, secondary, name := d.sides()
+	if err := primary.CreateJob(ctx, tenantID, job); err != nil {
+		return err
+	}
+	mirror := *job
+	mirrored(name, "CreateJob", secondary.CreateJob(ctx, tenantID, &mirror))
+	return nil
+}
+
+func (d *DualStore) UpdateJobStatus(ctx context.Context, tenantID string, jobID string, status string, exitCode int, stdout, stderr string) error {
+	primary, secondary, name := d.sides()
+	if err := primary.UpdateJobStatus(ctx, tenantID, jobID, status, exitCode, stdout, stderr); err != nil {
+		return err
+	}
+	mirrored(name, "UpdateJobStatus", secondary.UpdateJobStatus(ctx, tenantID, jobID, status, exitCode, stdout, stderr))
+	return nil
+}
+
+func (d *DualStore) GetJob(ctx context.Context, tenantID string, jobID string) (*store.Job, error) {
+	return d.reader().GetJob(ctx, tenantID, jobID)
+}
+
+func (d *DualStore) ListJobs(ctx context.Context, tenantID string, nodeID string, limit int) ([]*store.Job, error) {
+	return d.reader().ListJobs(ctx, tenantID, nodeID, limit)
+}
+
+func (d *DualStore) ListJobsByTenant(ctx context.Context, tenantID string, limit int) ([]*store.Job, error) {
+	return d.reader().ListJobsByTenant(ctx, tenantID, limit)
+}
+
+func (d *DualStore) QueryJobs(ctx context.Context, tenantID string, opts store.ListOptions) (*store.JobPage, error) {
+	return d.reader().QueryJobs(ctx, tenantID, opts)
+}
+
+func (d *DualStore) PurgeJobs(ctx context.Context, tenantID string, purge store.JobPurge) (int, error) {
+	primary, secondary, name := d.sides()
+	n, err := primary.PurgeJobs(ctx, tenantID, purge)
+	if err != nil {
+		return n, err
+	}
+	_, err = secondary.PurgeJobs(ctx, tenantID, purge)
+	mirrored(name, "PurgeJobs", err)
+	return n, nil
+}
+
+// ClaimNextJob claims on the primary, which alone decides which job is
+// handed out, and marks the same job running on the secondary.
+func (d *DualStore) ClaimNextJob(ctx context.Context, tenantID string, nodeID string) (*store.Job, error) {
+	primary, secondary, name := d.sides()
+	job, err := primary.ClaimNextJob(ctx, tenantID, nodeID)
+	if err != nil || job == nil {
+		return job, err
+	}
+	mirrored(name, "ClaimNextJob", secondary.UpdateJobStatus(ctx, tenantID, job.JobID, job.Status, job.ExitCode, job.Stdout, job.Stderr))
+	return job, nil
+}
+
+// --- Tombstones ---
+
+func (d *DualStore) GetTombstone(ctx context.Context, tenantID string, resource store.Resource, id string) (*store.Tombstone, error) {
+	return d.reader().GetTombstone(ctx, tenantID, resource, id)
+}
+
+func (d *DualStore) PurgeTombstones(ctx context.Context, before time.Time) (int, error) {
+	primary, secondary, name := d.sides()
+	n, err := primary.PurgeTombstones(ctx, before)
+	if err != nil {
+		return n, err
+	}
+	_, err = secondary.PurgeTombstones(ctx, before)
+	mirrored(name, "PurgeTombstones", err)
+	return n, nil
+}
+
+func (d *DualStore) ListTenants(ctx context.Context) ([]string, error) {
+	return d.reader().ListTenants(ctx)
+}
+
+// --- Outbox ---
+
+// ReadOutbox drains the secondary's outbox before the primary's: events
+// left there were written before the last SetPrimary. Positions carry the
+// backend name, so AckOutbox reaches the outbox each event came from.
+func (d *DualStore) ReadOutbox(ctx context.Context, limit int) ([]*store.OutboxEvent, error) {
+	p := d.primary.Load()
+	var events []*store.OutboxEvent
+	for _, i := range []int32{1 - p, p} {
+		remaining := 0
+		if limit > 0 {
+			if remaining = limit - len(events); remaining <= 0 {
+				break
+			}
+		}
+		batch, err := d.stores[i].ReadOutbox(ctx, remaining)
+		if err != nil {
+			return events, err
+		}
+		for _, e := range batch {
+			e.Position = d.names[i] + "/" + e.Position
+		}
+		events = append(events, batch...)
+	}
+	return events, nil
+}
+
+func (d *DualStore) AckOutbox(ctx context.Context, positions []string) error {
+	byStore := make(map[int32][]string)
+	for _, pos := range positions {
+		name, rest, ok := strings.Cut(pos, "/")
+		i, err := d.index(name)
+		if !ok || err != nil {
+			return fmt.Errorf("outbox position %q has no backend", pos)
+		}
+		byStore[i] = append(byStore[i], rest)
+	}
+	for i, ps := range byStore {
+		if err := d.stores[i].AckOutbox(ctx, ps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Coordination Operations ---
+
+// IncrementDurableEpoch raises the secondary's epoch to the primary's, so
+// fencing tokens keep increasing across the cut-over.
+func (d *DualStore) IncrementDurableEpoch(ctx context.Context, resourceID string) (int64, error) {
+	primary, secondary, name := d.sides()
+	epoch, err := primary.IncrementDurableEpoch(ctx, resourceID)
+	if err != nil {
+		return epoch, err
+	}
+	mirrored(name, "IncrementDurableEpoch", store.RaiseEpoch(ctx, secondary, resourceID, epoch))
+	return epoch, nil
+}
+
+func (d *DualStore) GetDurableEpoch(ctx context.Context, resourceID string) (int64, error) {
+	return d.reader().GetDurableEpoch(ctx, resourceID)
+}
+
+// --- Idempotency Operations ---
+
+func (d *DualStore) GetIdempotencyRecord(key string) (string, error) {
+	return d.reader().GetIdempotencyRecord(key)
+}
+
+func (d *DualStore) SetIdempotencyRecord(key string, value string, ttl time.Duration) error {
+	primary, secondary, name := d.sides()
+	if err := primary.SetIdempotencyRecord(key, value, ttl); err != nil {
+		return err
+	}
+	mirrored(name, "SetIdempotencyRecord", secondary.SetIdempotencyRecord(key, value, ttl))
+	return nil
+}
+
+// SetIdempotencyRecordNX lets the primary decide; the secondary gets the
+// record unconditionally.
+func (d *DualStore) SetIdempotencyRecordNX(key string, value string, ttl time.Duration) error {
+	primary, secondary, name := d.sides()
+	if err := primary.SetIdempotencyRecordNX(key, value, ttl); err != nil {
+		return err
+	}
+	mirrored(name, "SetIdempotencyRecordNX", secondary.SetIdempotencyRecord(key, value, ttl))
+	return nil
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,223 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/store/storetest"
+)
+
+func TestDualStoreContract(t *testing.T) {
+	for _, readFrom := range []string{"a", "b"} {
+		t.Run("read-"+readFrom, func(t *testing.T) {
+			d := NewDualStore("a", store.NewMemoryStore(), "b", store.NewMemoryStore())
+			d.SetReadFrom(readFrom)
+			storetest.Run(t, func(t *testing.T) store.Store { return d })
+		})
+	}
+}
+
+// flakyStore fails state upserts while failing is set.
+type flakyStore struct {
+	*store.MemoryStore
+	failing bool
+}
+
+func (f *flakyStore) UpsertState(ctx context.Context, tenantID string, state *store.DesiredState) error {
+	if f.failing {
+		return errors.New("connection refused")
+	}
+	return f.MemoryStore.UpsertState(ctx, tenantID, state)
+}
+
+func TestSyncerBackfillsAndRepairs(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewMemoryStore()
+
+	// Written before dual writes began
+	if err := src.UpsertAgent(ctx, "acme", &store.Agent{NodeID: "n1", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	st := &store.DesiredState{StateID: "s1", NodeID: "n1", CheckCmd: "check", ApplyCmd: "apply v1"}
+	for i := 0; i < 3; i++ {
+		if err := src.UpsertState(ctx, "acme", st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.CreateJob(ctx, "acme", &store.Job{JobID: "j1", NodeID: "n1", Status: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+	src.IncrementDurableEpoch(ctx, "leader_election")
+
+	dst := &flakyStore{MemoryStore: store.NewMemoryStore()}
+	d := NewDualStore("redis", src, "postgres", dst)
+
+	// Mirrored while the secondary lags: the write succeeds on the primary
+	dst.failing = true
+	st.ApplyCmd = "apply v4"
+	if err := d.UpsertState(store.WithChangeInfo(ctx, store.ChangeInfo{Author: "alice"}), "acme", st); err != nil {
+		t.Fatalf("UpsertState with a failing secondary: %v", err)
+	}
+	dst.failing = false
+	// The job is not on the secondary yet: only the primary records this
+	if err := d.UpdateJobStatus(ctx, "acme", "j1", "completed", 0, "ok", ""); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+
+	syncer := NewSyncer(d, time.Minute)
+	syncer.SetEpochResources([]string{"leader_election"})
+	syncer.SetRepair(false)
+	report, err := syncer.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if report.Missing != 3 || report.Mismatched != 1 || report.Repaired != 0 {
+		t.Fatalf("report-only pass = %+v; want 3 missing and the epoch behind", report)
+	}
+	if got, _ := dst.GetState(ctx, "acme", "s1"); got != nil {
+		t.Fatalf("report-only pass wrote %+v", got)
+	}
+
+	syncer.SetRepair(true)
+	if report, err = syncer.RunOnce(ctx); err != nil || report.Repaired != 4 || report.Failed != 0 {
+		t.Fatalf("repair pass = %+v, %v; want 4 repaired", report, err)
+	}
+	if report, err = syncer.RunOnce(ctx); err != nil || !report.InSync() {
+		t.Fatalf("pass after repair = %+v, %v; want in sync", report, err)
+	}
+
+	got, _ := dst.GetState(ctx, "acme", "s1")
+	want, _ := src.GetState(ctx, "acme", "s1")
+	if got == nil || got.Version != 4 || got.ApplyCmd != "apply v4" || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("secondary state = %+v; want version 4 created at %v", got, want.CreatedAt)
+	}
+	agent, _ := src.GetAgent(ctx, "acme", "n1")
+	if got, _ := dst.GetAgent(ctx, "acme", "n1"); got == nil || !got.CreatedAt.Equal(agent.CreatedAt) {
+		t.Errorf("secondary agent = %+v; want created at %v", got, agent.CreatedAt)
+	}
+
+	// An agent the secondary created at another time is a difference
+	if err := dst.DeleteAgent(ctx, "acme", "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.UpsertAgent(store.WithCreatedAt(ctx, agent.CreatedAt.Add(-time.Hour)), "acme", &store.Agent{NodeID: "n1", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = syncer.RunOnce(ctx); err != nil || report.Mismatched != 1 || report.Repaired != 1 {
+		t.Fatalf("pass with a recreated agent = %+v, %v; want it repaired", report, err)
+	}
+	if got, _ := dst.GetAgent(ctx, "acme", "n1"); got == nil || !got.CreatedAt.Equal(agent.CreatedAt) {
+		t.Errorf("repaired agent = %+v; want created at %v", got, agent.CreatedAt)
+	}
+	revs, _ := dst.ListStateRevisions(ctx, "acme", "s1")
+	if len(revs) != 4 || revs[3].Author != "alice" {
+		t.Errorf("secondary revisions = %+v", revs)
+	}
+	if job, _ := dst.GetJob(ctx, "acme", "j1"); job == nil || job.Status != "completed" || job.Stdout != "ok" {
+		t.Errorf("secondary job = %+v", job)
+	}
+	if epoch, _ := dst.GetDurableEpoch(ctx, "leader_election"); epoch != 1 {
+		t.Errorf("secondary epoch = %d, want 1", epoch)
+	}
+
+	// A state deleted on the primary while the mirror is down is removed
+	src.DeleteState(ctx, "acme", "s1")
+	if report, err = syncer.RunOnce(ctx); err != nil || report.Extra != 1 || report.Repaired != 1 {
+		t.Fatalf("pass after delete = %+v, %v; want the extra state removed", report, err)
+	}
+	if got, _ := dst.GetState(ctx, "acme", "s1"); got != nil {
+		t.Errorf("deleted state still on the secondary: %+v", got)
+	}
+}
+
+func TestDualStoreCutOver(t *testing.T) {
+	ctx := context.Background()
+	a, b := store.NewMemoryStore(), store.NewMemoryStore()
+	d := NewDualStore("a", a, "b", b)
+
+	st := &store.DesiredState{StateID: "s1", NodeID: "n1"}
+	if err := d.UpsertState(ctx, "acme", st); err != nil {
+		t.Fatal(err)
+	}
+	agent := &store.Agent{NodeID: "n1", Status: "active"}
+	if err := d.UpsertAgent(ctx, "acme", agent); err != nil {
+		t.Fatal(err)
+	}
+	// Mirrored with the primary's CreatedAt, so pages stay the same after
+	// cutting over
+	if got, _ := b.GetState(ctx, "acme", "s1"); got == nil || !got.CreatedAt.Equal(st.CreatedAt) {
+		t.Errorf("mirrored state = %+v; want created at %v", got, st.CreatedAt)
+	}
+	if got, _ := b.GetAgent(ctx, "acme", "n1"); got == nil || !got.CreatedAt.Equal(agent.CreatedAt) {
+		t.Errorf("mirrored agent = %+v; want created at %v", got, agent.CreatedAt)
+	}
+	if err := d.SetReadFrom("c"); err == nil {
+		t.Error("SetReadFrom accepted an unknown backend")
+	}
+
+	if err := d.SetReadFrom("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetPrimary("b"); err != nil {
+		t.Fatal(err)
+	}
+	if status := d.Status(); status != (Status{Primary: "b", Secondary: "a", ReadFrom: "b"}) {
+		t.Errorf("Status = %+v", status)
+	}
+
+	// The promoted store now decides: a version conflict there fails the
+	// write even though the old primary would accept it
+	b.UpsertState(ctx, "acme", &store.DesiredState{StateID: "s1", NodeID: "n1"})
+	err := d.UpdateStateStatus(ctx, "acme", "s1", "compliant", "", time.Now(), 1)
+	if !errors.Is(err, store.ErrVersionConflict) {
+		t.Errorf("UpdateStateStatus = %v, want ErrVersionConflict from the new primary", err)
+	}
+	if got, _ := a.GetState(ctx, "acme", "s1"); got.Status == "compliant" {
+		t.Error("a write rejected by the primary was mirrored")
+	}
+}
+
+func TestRouterSwitchesEveryReplica(t *testing.T) {
+	ctx := context.Background()
+	coord, err := store.NewBoltStore(filepath.Join(t.TempDir(), "coord.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coord.Close()
+
+	a, b := store.NewMemoryStore(), store.NewMemoryStore()
+	replicas := []*DualStore{NewDualStore("a", a, "b", b), NewDualStore("a", a, "b", b)}
+	routers := []*Router{NewRouter(replicas[0], coord, time.Minute), NewRouter(replicas[1], coord, time.Minute)}
+
+	if _, err := routers[0].Switch(ctx, "", "c"); err == nil {
+		t.Error("Switch accepted an unknown backend")
+	}
+	if _, err := routers[0].Switch(ctx, "", "b"); err != nil {
+		t.Fatal(err)
+	}
+	// A switch from another replica builds on the stored routing
+	if _, err := routers[1].Switch(ctx, "b", ""); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range routers {
+		if err := r.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if status := replicas[i].Status(); status != (Status{Primary: "b", Secondary: "a", ReadFrom: "b"}) {
+			t.Errorf("replica %d: Status = %+v", i, status)
+		}
+	}
+
+	// A restarted replica picks the routing up before serving
+	late := NewDualStore("a", a, "b", b)
+	lateCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	NewRouter(late, coord, time.Minute).Start(lateCtx)
+	if status := late.Status(); status.Primary != "b" || status.ReadFrom != "b" {
+		t.Errorf("restarted replica: Status = %+v", status)
+	}
+}
@@ -0,0 +1,184 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// routingKey holds the routing every replica follows, as JSON, under the
+// coordinator's lock API: GetLockOwner reads it, and a switch replaces it
+// by releasing the value it read and acquiring the new one, which fails if
+// another switch got there first.
+const routingKey = "fluxforge:store-migration:routing"
+
+// routingTTL keeps the routing for the life of the migration.
+const routingTTL = 10 * 365 * 24 * time.Hour
+
+// ErrConcurrentSwitch is returned by Switch when another replica switched
+// the routing between its read and its write.
+var ErrConcurrentSwitch = errors.New("migration: routing switched concurrently")
+
+// routing is the stored cut-over state. Generation increases with every
+// switch, so a replica applies each one once and never goes back.
+type routing struct {
+	Generation int64  `json:"generation"`
+	Primary    string `json:"primary"`
+	ReadFrom   string `json:"read_from"`
+}
+
+// Router keeps the cut-over routing of every replica's DualStore the same.
+// A switch is stored on the coordinator, and each replica polls it and
+// applies a newer generation, so replicas converge within one interval.
+// Without a coordinator (standalone) switches apply to this replica only.
+type Router struct {
+	dual        *DualStore
+	coordinator store.Coordinator
+	interval    time.Duration
+
+	mu      sync.Mutex // Serializes applying, so generations apply in order
+	applied int64
+}
+
+func NewRouter(d *DualStore, coordinator store.Coordinator, interval time.Duration) *Router {
+	return &Router{dual: d, coordinator: coordinator, interval: interval}
+}
+
+// Start applies the stored routing, then polls for switches every interval
+// until ctx is done. The first sync runs before Start returns, so a
+// restarted replica does not serve with its startup routing.
+func (r *Router) Start(ctx context.Context) {
+	if r.coordinator == nil {
+		return
+	}
+	if err := r.Sync(ctx); err != nil {
+		log.Printf("Store migration: reading routing failed: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Sync(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Store migration: reading routing failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Sync applies the stored routing if it is newer than the last applied.
+func (r *Router) Sync(ctx context.Context) error {
+	if r.coordinator == nil {
+		return nil
+	}
+	current, _, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+	return r.apply(current)
+}
+
+// Switch makes primary the backend written first and readFrom the one
+// read, on every replica; an empty name keeps the current one. It returns
+// once the switch is stored and applied here; other replicas follow on
+// their next poll.
+func (r *Router) Switch(ctx context.Context, primary, readFrom string) (Status, error) {
+	// Validate both before switching either
+	for _, name := range []string{primary, readFrom} {
+		if name == "" {
+			continue
+		}
+		if _, err := r.dual.index(name); err != nil {
+			return Status{}, err
+		}
+	}
+
+	if r.coordinator == nil {
+		if primary != "" {
+			r.dual.SetPrimary(primary)
+		}
+		if readFrom != "" {
+			r.dual.SetReadFrom(readFrom)
+		}
+		return r.dual.Status(), nil
+	}
+
+	current, raw, err := r.load(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	// Switches are relative to the stored routing, not to this replica's,
+	// which may not have caught up yet
+	next := routing{Generation: current.Generation + 1, Primary: current.Primary, ReadFrom: current.ReadFrom}
+	if primary != "" {
+		next.Primary = primary
+	}
+	if readFrom != "" {
+		next.ReadFrom = readFrom
+	}
+	value, err := json.Marshal(next)
+	if err != nil {
+		return Status{}, err
+	}
+
+	if raw != "" {
+		if err := r.coordinator.ReleaseLock(ctx, routingKey, raw); err != nil {
+			return Status{}, err
+		}
+	}
+	ok, err := r.coordinator.AcquireLock(ctx, routingKey, string(value), routingTTL)
+	if err != nil {
+		return Status{}, err
+	}
+	if !ok {
+		return Status{}, ErrConcurrentSwitch
+	}
+	if err := r.apply(next); err != nil {
+		return Status{}, err
+	}
+	return r.dual.Status(), nil
+}
+
+// load reads the stored routing and its raw value. With none stored yet it
+// returns generation 0 and this replica's routing.
+func (r *Router) load(ctx context.Context) (routing, string, error) {
+	raw, err := r.coordinator.GetLockOwner(ctx, routingKey)
+	if err != nil {
+		return routing{}, "", err
+	}
+	if raw == "" {
+		status := r.dual.Status()
+		return routing{Primary: status.Primary, ReadFrom: status.ReadFrom}, "", nil
+	}
+	var current routing
+	if err := json.Unmarshal([]byte(raw), &current); err != nil {
+		return routing{}, "", fmt.Errorf("decode routing: %w", err)
+	}
+	return current, raw, nil
+}
+
+func (r *Router) apply(next routing) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if next.Generation <= r.applied {
+		return nil
+	}
+	if err := r.dual.SetPrimary(next.Primary); err != nil {
+		return err
+	}
+	if err := r.dual.SetReadFrom(next.ReadFrom); err != nil {
+		return err
+	}
+	r.applied = next.Generation
+	return nil
+}
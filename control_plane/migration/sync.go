@@ -0,0 +1,417 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// maxSamples bounds Report.Samples.
+const maxSamples = 20
+
+// Report summarizes one Syncer pass.
+type Report struct {
+	FinishedAt time.Time `json:"finished_at"`
+	Tenants    int       `json:"tenants"`
+	Checked    int       `json:"checked"`    // Records compared
+	Missing    int       `json:"missing"`    // On the primary only
+	Extra      int       `json:"extra"`      // On the secondary only
+	Mismatched int       `json:"mismatched"` // On both, but different
+	Repaired   int       `json:"repaired"`
+	Failed     int       `json:"failed"`            // Differences left unrepaired
+	Samples    []string  `json:"samples,omitempty"` // The first differences found
+}
+
+// InSync reports whether the pass found the secondary identical to the
+// primary: the condition for cutting reads over.
+func (r *Report) InSync() bool {
+	return r.Missing == 0 && r.Extra == 0 && r.Mismatched == 0
+}
+
+func (r *Report) sample(format string, args ...interface{}) {
+	if len(r.Samples) < maxSamples {
+		r.Samples = append(r.Samples, fmt.Sprintf(format, args...))
+	}
+}
+
+// Syncer compares a DualStore's secondary with its primary and, unless
+// repair is off, backfills and fixes the secondary: records written before
+// dual writes began, and those whose mirror write failed. Run it on the
+// leader only.
+//
+// A difference is checked again, record by record, just before it is
+// repaired, so writes that land while a pass runs are not undone. States
+// are repaired with their versions and revision history.
+type Syncer struct {
+	dual     *DualStore
+	interval time.Duration
+
+	repair         bool
+	epochResources []string
+
+	mu   sync.Mutex
+	last *Report
+}
+
+func NewSyncer(d *DualStore, interval time.Duration) *Syncer {
+	return &Syncer{
+		dual:     d,
+		interval: interval,
+		repair:   true,
+	}
+}
+
+// SetRepair turns repairs on or off; with repair off a pass only reports.
+func (s *Syncer) SetRepair(repair bool) {
+	s.repair = repair
+}
+
+// SetEpochResources sets the durable epochs to compare. Epochs cannot be
+// listed, so only these are checked.
+func (s *Syncer) SetEpochResources(resources []string) {
+	s.epochResources = resources
+}
+
+// LastReport returns the report of the last completed pass, or nil.
+func (s *Syncer) LastReport() *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Start runs a pass immediately and then every interval until ctx is done.
+func (s *Syncer) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.runLogged(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Syncer) runLogged(ctx context.Context) {
+	report, err := s.RunOnce(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Store sync: pass failed: %v", err)
+		}
+		return
+	}
+	if report.InSync() {
+		status := s.dual.Status()
+		log.Printf("Store sync: %s in sync with %s (%d records)", status.Secondary, status.Primary, report.Checked)
+		return
+	}
+	log.Printf("Store sync: %d missing, %d extra, %d mismatched, %d repaired, %d failed; e.g. %v",
+		report.Missing, report.Extra, report.Mismatched, report.Repaired, report.Failed, report.Samples)
+}
+
+// RunOnce compares every tenant's agents, states and jobs, and the epochs,
+// on both sides.
+func (s *Syncer) RunOnce(ctx context.Context) (*Report, error) {
+	start := time.Now()
+	primary, secondary := s.dual.Primary(), s.dual.Secondary()
+	report := &Report{}
+
+	tenants, err := allTenants(ctx, primary, secondary)
+	if err != nil {
+		observability.StoreSyncRuns.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	report.Tenants = len(tenants)
+	p := &pass{ctx: ctx, primary: primary, secondary: secondary, repair: s.repair, report: report}
+	for _, tid := range tenants {
+		if err := p.tenant(tid); err != nil {
+			observability.StoreSyncRuns.WithLabelValues("error").Inc()
+			return nil, fmt.Errorf("tenant %s: %w", tid, err)
+		}
+	}
+	for _, res := range s.epochResources {
+		if err := p.epoch(res); err != nil {
+			observability.StoreSyncRuns.WithLabelValues("error").Inc()
+			return nil, fmt.Errorf("epoch %s: %w", res, err)
+		}
+	}
+	report.FinishedAt = time.Now()
+
+	observability.StoreSyncRuns.WithLabelValues("success").Inc()
+	observability.StoreSyncDuration.Observe(time.Since(start).Seconds())
+	observability.StoreSyncDifferences.WithLabelValues("missing").Set(float64(report.Missing))
+	observability.StoreSyncDifferences.WithLabelValues("extra").Set(float64(report.Extra))
+	observability.StoreSyncDifferences.WithLabelValues("mismatched").Set(float64(report.Mismatched))
+	observability.StoreSyncRepairs.Add(float64(report.Repaired))
+	if report.InSync() {
+		observability.StoreSyncLastInSync.SetToCurrentTime()
+	}
+
+	s.mu.Lock()
+	s.last = report
+	s.mu.Unlock()
+	return report, nil
+}
+
+func allTenants(ctx context.Context, primary, secondary store.Store) ([]string, error) {
+	set := make(map[string]bool)
+	for _, side := range []store.Store{primary, secondary} {
+		tenants, err := side.ListTenants(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, tid := range tenants {
+			set[tid] = true
+		}
+	}
+	tenants := make([]string, 0, len(set))
+	for tid := range set {
+		tenants = append(tenants, tid)
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// pass is the state of one RunOnce.
+type pass struct {
+	ctx                context.Context
+	primary, secondary store.Store
+	repair             bool
+	report             *Report
+}
+
+// difference counts one difference and, if repair is on, re-checks and
+// repairs it. fix returns false if the record turned out to be in sync.
+func (p *pass) difference(kind *int, what string, fix func() (bool, error)) {
+	if !p.repair {
+		*kind++
+		p.report.sample("%s", what)
+		return
+	}
+	differs, err := fix()
+	if !differs && err == nil {
+		return // Caught up by a concurrent write
+	}
+	*kind++
+	p.report.sample("%s", what)
+	if err != nil {
+		p.report.Failed++
+		log.Printf("Store sync: repairing %s failed: %v", what, err)
+		return
+	}
+	p.report.Repaired++
+}
+
+func (p *pass) tenant(tid string) error {
+	ctx := p.ctx
+
+	pAgents, err := p.primary.ListAgents(ctx, tid)
+	if err != nil {
+		return err
+	}
+	sAgents, err := p.secondary.ListAgents(ctx, tid)
+	if err != nil {
+		return err
+	}
+	compare(p, "agent", tid, byID(pAgents, agentID), byID(sAgents, agentID), sameAgent, p.repairAgent)
+
+	pStates, err := p.primary.ListStates(ctx, tid)
+	if err != nil {
+		return err
+	}
+	sStates, err := p.secondary.ListStates(ctx, tid)
+	if err != nil {
+		return err
+	}
+	compare(p, "state", tid, byID(pStates, stateID), byID(sStates, stateID), sameState, p.repairState)
+
+	pJobs, err := p.primary.ListJobsByTenant(ctx, tid, 0)
+	if err != nil {
+		return err
+	}
+	sJobs, err := p.secondary.ListJobsByTenant(ctx, tid, 0)
+	if err != nil {
+		return err
+	}
+	compare(p, "job", tid, byID(pJobs, jobID), byID(sJobs, jobID), sameJob, p.repairJob)
+	return nil
+}
+
+// compare diffs one resource of a tenant. repair re-reads the record on
+// both sides and fixes the secondary if they still differ.
+func compare[T any](p *pass, kind, tid string, primary, secondary map[string]T, same func(a, b T) bool, repair func(tid, id string) (bool, error)) {
+	for id, rec := range primary {
+		p.report.Checked++
+		other, ok := secondary[id]
+		switch {
+		case !ok:
+			p.difference(&p.report.Missing, fmt.Sprintf("%s %s/%s missing", kind, tid, id), func() (bool, error) { return repair(tid, id) })
+		case !same(rec, other):
+			p.difference(&p.report.Mismatched, fmt.Sprintf("%s %s/%s differs", kind, tid, id), func() (bool, error) { return repair(tid, id) })
+		}
+	}
+	for id := range secondary {
+		if _, ok := primary[id]; !ok {
+			p.report.Checked++
+			p.difference(&p.report.Extra, fmt.Sprintf("%s %s/%s not on the primary", kind, tid, id), func() (bool, error) { return repair(tid, id) })
+		}
+	}
+}
+
+func byID[T any](recs []T, id func(T) string) map[string]T {
+	m := make(map[string]T, len(recs))
+	for _, rec := range recs {
+		m[id(rec)] = rec
+	}
+	return m
+}
+
+func agentID(a *store.Agent) string         { return a.NodeID }
+func stateID(st *store.DesiredState) string { return st.StateID }
+func jobID(j *store.Job) string             { return j.JobID }
+
+// repairAgent copies the primary's agent to the secondary. An upsert keeps
+// the CreatedAt of an agent that exists, so one created at another time is
+// deleted first.
+func (p *pass) repairAgent(tid, id string) (bool, error) {
+	want, err := p.primary.GetAgent(p.ctx, tid, id)
+	if err != nil {
+		return true, err
+	}
+	got, err := p.secondary.GetAgent(p.ctx, tid, id)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case want == nil && got == nil:
+		return false, nil
+	case want == nil:
+		return true, ignoreNotFound(p.secondary.DeleteAgent(p.ctx, tid, id))
+	case got != nil && sameAgent(want, got):
+		return false, nil
+	}
+	if got != nil && !sameTime(got.CreatedAt, want.CreatedAt) {
+		if err := ignoreNotFound(p.secondary.DeleteAgent(p.ctx, tid, id)); err != nil {
+			return true, err
+		}
+	}
+	return true, p.secondary.UpsertAgent(store.WithCreatedAt(p.ctx, want.CreatedAt), tid, want)
+}
+
+// repairState re-creates the state on the secondary with the primary's
+// version and history; a difference in check status alone is fixed in
+// place.
+func (p *pass) repairState(tid, id string) (bool, error) {
+	want, err := p.primary.GetState(p.ctx, tid, id)
+	if err != nil {
+		return true, err
+	}
+	got, err := p.secondary.GetState(p.ctx, tid, id)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case want == nil && got == nil:
+		return false, nil
+	case want == nil:
+		return true, ignoreNotFound(p.secondary.DeleteState(p.ctx, tid, id))
+	case got != nil && sameState(want, got):
+		return false, nil
+	case got != nil && got.Version == want.Version && len(store.DiffStates(got, want)) == 0 && sameTime(got.CreatedAt, want.CreatedAt):
+		return true, p.secondary.UpdateStateStatus(p.ctx, tid, id, want.Status, want.LastError, want.LastChecked, want.Version)
+	}
+
+	revs, err := p.primary.ListStateRevisions(p.ctx, tid, id)
+	if err != nil {
+		return true, err
+	}
+	if got != nil {
+		if err := ignoreNotFound(p.secondary.DeleteState(p.ctx, tid, id)); err != nil {
+			return true, err
+		}
+	}
+	return true, store.CopyState(p.ctx, p.secondary, tid, want, revs, 0, func(v int) string {
+		return fmt.Sprintf("sync: revision %d is not on the primary", v)
+	})
+}
+
+// repairJob copies a missing job or brings its status up to date. Jobs
+// only on the secondary cannot be deleted one by one and are reported.
+func (p *pass) repairJob(tid, id string) (bool, error) {
+	want, err := p.primary.GetJob(p.ctx, tid, id)
+	if err != nil {
+		return true, err
+	}
+	got, err := p.secondary.GetJob(p.ctx, tid, id)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case want == nil && got == nil:
+		return false, nil
+	case want == nil:
+		return true, fmt.Errorf("job %s exists only on the secondary", id)
+	case got == nil:
+		return true, p.secondary.CreateJob(p.ctx, tid, want)
+	case sameJob(want, got):
+		return false, nil
+	}
+	return true, p.secondary.UpdateJobStatus(p.ctx, tid, id, want.Status, want.ExitCode, want.Stdout, want.Stderr)
+}
+
+func (p *pass) epoch(res string) error {
+	want, err := p.primary.GetDurableEpoch(p.ctx, res)
+	if err != nil {
+		return err
+	}
+	got, err := p.secondary.GetDurableEpoch(p.ctx, res)
+	if err != nil {
+		return err
+	}
+	if got >= want {
+		return nil
+	}
+	p.report.Checked++
+	p.difference(&p.report.Mismatched, fmt.Sprintf("epoch %s at %d, primary at %d", res, got, want), func() (bool, error) {
+		return true, store.RaiseEpoch(p.ctx, p.secondary, res, want)
+	})
+	return nil
+}
+
+// Records are compared on what both backends store exactly. UpdatedAt is
+// set by each backend, and times may be rounded differently (Postgres
+// keeps microseconds). CreatedAt is copied, and pages and created_since
+// filters depend on it, so it must match.
+
+func sameAgent(a, b *store.Agent) bool {
+	return a.Hostname == b.Hostname && a.IPAddress == b.IPAddress && a.Port == b.Port &&
+		a.Version == b.Version && a.Status == b.Status && a.Tier == b.Tier &&
+		maps.Equal(a.Metadata, b.Metadata) && sameTime(a.LastHeartbeat, b.LastHeartbeat) &&
+		sameTime(a.CreatedAt, b.CreatedAt)
+}
+
+func sameState(a, b *store.DesiredState) bool {
+	return a.Version == b.Version && len(store.DiffStates(a, b)) == 0 &&
+		a.Status == b.Status && a.LastError == b.LastError && sameTime(a.LastChecked, b.LastChecked) &&
+		sameTime(a.CreatedAt, b.CreatedAt)
+}
+
+func sameJob(a, b *store.Job) bool {
+	return a.NodeID == b.NodeID && a.StateID == b.StateID && a.Command == b.Command &&
+		a.Status == b.Status && a.ExitCode == b.ExitCode && a.Stdout == b.Stdout && a.Stderr == b.Stderr
+}
+
+func sameTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d < time.Millisecond && d > -time.Millisecond
+}
@@ -224,4 +224,130 @@ var (
 		Name: "flux_connected_agents",
 		Help: "Current number of connected agents",
 	})
+
+	// === Job Retention ===
+
+	// JobGCRuns counts retention passes by result ("success" or "error").
+	JobGCRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_job_gc_runs_total",
+		Help: "Total number of job retention passes",
+	}, []string{"result"})
+
+	// JobGCDeleted counts finished jobs deleted by retention.
+	JobGCDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_job_gc_jobs_deleted_total",
+		Help: "Total number of finished jobs deleted by retention",
+	}, []string{"tenant"})
+
+	// JobGCArchived counts jobs written to the archive before deletion.
+	JobGCArchived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_job_gc_jobs_archived_total",
+		Help: "Total number of jobs archived before deletion",
+	}, []string{"tenant"})
+
+	// TombstonesPurged counts agent and state tombstones dropped by retention.
+	TombstonesPurged = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "flux_job_gc_tombstones_purged_total",
+		Help: "Total number of tombstones purged by retention",
+	})
+
+	// JobGCDuration tracks how long a retention pass takes.
+	JobGCDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "flux_job_gc_duration_seconds",
+		Help:    "Duration of a job retention pass",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// JobGCLastSuccess is the Unix time of the last fully successful pass.
+	JobGCLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "flux_job_gc_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful job retention pass",
+	})
+
+	// === Store Migration ===
+
+	// StoreMirrorErrors counts writes DualStore failed to mirror, by the
+	// secondary backend and store operation.
+	StoreMirrorErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_store_mirror_errors_total",
+		Help: "Total number of writes that failed on the secondary store",
+	}, []string{"backend", "op"})
+
+	// StoreSyncRuns counts store sync passes by result ("success" or "error").
+	StoreSyncRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_store_sync_runs_total",
+		Help: "Total number of store sync passes",
+	}, []string{"result"})
+
+	// StoreSyncDifferences is what the last pass found, by kind
+	// ("missing", "extra" or "mismatched").
+	StoreSyncDifferences = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flux_store_sync_differences",
+		Help: "Records that differed between the stores in the last sync pass",
+	}, []string{"kind"})
+
+	// StoreSyncRepairs counts records the sync repaired on the secondary.
+	StoreSyncRepairs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "flux_store_sync_repairs_total",
+		Help: "Total number of records repaired on the secondary store",
+	})
+
+	// StoreSyncDuration tracks how long a sync pass takes.
+	StoreSyncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "flux_store_sync_duration_seconds",
+		Help:    "Duration of a store sync pass",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// StoreSyncLastInSync is the Unix time of the last pass that found no
+	// differences.
+	StoreSyncLastInSync = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "flux_store_sync_last_in_sync_timestamp_seconds",
+		Help: "Unix time of the last store sync pass that found the stores in sync",
+	})
+
+	// === Degraded Mode ===
+
+	// StoreDegraded is 1 while the store backend is down and requests are
+	// served from the degraded-mode cache.
+	StoreDegraded = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "flux_store_degraded",
+		Help: "Whether the store is degraded (1) or serving normally (0)",
+	})
+
+	// DegradedWrites counts writes taken while degraded, by result
+	// ("queued", "rejected", "replayed", "dead_lettered" or "failed").
+	DegradedWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_degraded_writes_total",
+		Help: "Total number of writes taken while the store was degraded",
+	}, []string{"result"})
+
+	// DegradedCacheReads counts reads served while degraded, by result
+	// ("hit" or "miss").
+	DegradedCacheReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_degraded_cache_reads_total",
+		Help: "Total number of reads served from the degraded-mode cache",
+	}, []string{"result"})
+
+	// === Event Outbox ===
+
+	// OutboxRelayed counts outbox events published by the relay, by topic.
+	OutboxRelayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_outbox_relayed_total",
+		Help: "Total number of outbox events published",
+	}, []string{"topic"})
+
+	// OutboxRelayErrors counts relay passes that stopped on an error, by
+	// stage ("read", "publish" or "ack").
+	OutboxRelayErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flux_outbox_relay_errors_total",
+		Help: "Total number of outbox relay failures",
+	}, []string{"stage"})
+
+	// OutboxOldestAgeSeconds is the age of the oldest undelivered event
+	// seen by the last relay pass (0 = outbox drained).
+	OutboxOldestAgeSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "flux_outbox_oldest_age_seconds",
+		Help: "Age of the oldest undelivered outbox event",
+	})
 )
@@ -0,0 +1,126 @@
+// Package outbox relays events committed to the store's outbox to a
+// streaming.Publisher. Writers commit an event in the same transaction as
+// the change it reports, so a crash can delay an event but not lose it.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/streaming"
+)
+
+// DefaultBatchSize is how many events a relay reads per store call.
+const DefaultBatchSize = 100
+
+// Relay publishes outbox events in commit order and acks each one only
+// after Publish returns, so delivery is at least once: an event published
+// just before a crash or a failed ack is published again. Every publish of
+// an event carries its outbox ID as the Event ID (see
+// streaming.WithEventID), which consumers dedupe on.
+//
+// Run it on the leader only: two relays would publish every event twice.
+type Relay struct {
+	store     store.Store
+	publisher streaming.Publisher
+	interval  time.Duration
+	batchSize int
+
+	// publishTimeout bounds one Publish, so a hung broker stalls the relay
+	// rather than hiding it
+	publishTimeout time.Duration
+}
+
+func NewRelay(s store.Store, publisher streaming.Publisher, interval time.Duration) *Relay {
+	return &Relay{
+		store:          s,
+		publisher:      publisher,
+		interval:       interval,
+		batchSize:      DefaultBatchSize,
+		publishTimeout: 5 * time.Second,
+	}
+}
+
+// SetBatchSize sets how many events are read and acked at a time.
+func (r *Relay) SetBatchSize(n int) {
+	if n > 0 {
+		r.batchSize = n
+	}
+}
+
+// Start runs RunOnce every interval until ctx is done; pass the leader's
+// fenced context so relaying stops with leadership.
+func (r *Relay) Start(ctx context.Context) {
+	go r.loop(ctx)
+}
+
+func (r *Relay) loop(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Outbox relay: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce publishes everything in the outbox, a batch at a time, and
+// returns how many events it delivered. It stops at the first event that
+// fails to publish, keeping it and everything after it for the next pass
+// so events are never reordered.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	relayed := 0
+	for {
+		events, err := r.store.ReadOutbox(ctx, r.batchSize)
+		if err != nil {
+			observability.OutboxRelayErrors.WithLabelValues("read").Inc()
+			return relayed, err
+		}
+		if len(events) == 0 {
+			observability.OutboxOldestAgeSeconds.Set(0)
+			return relayed, nil
+		}
+		observability.OutboxOldestAgeSeconds.Set(time.Since(events[0].CreatedAt).Seconds())
+
+		published := make([]string, 0, len(events))
+		var publishErr error
+		for _, e := range events {
+			if publishErr = r.publish(ctx, e); publishErr != nil {
+				observability.OutboxRelayErrors.WithLabelValues("publish").Inc()
+				break
+			}
+			published = append(published, e.Position)
+			observability.OutboxRelayed.WithLabelValues(e.Topic).Inc()
+		}
+		if err := r.store.AckOutbox(ctx, published); err != nil {
+			// Published but still in the outbox: the next pass sends
+			// them again
+			observability.OutboxRelayErrors.WithLabelValues("ack").Inc()
+			return relayed, err
+		}
+		relayed += len(published)
+		if publishErr != nil || len(events) < r.batchSize {
+			return relayed, publishErr
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, e *store.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(streaming.WithEventID(ctx, e.ID), r.publishTimeout)
+	defer cancel()
+	if err := r.publisher.Publish(ctx, e.Topic, json.RawMessage(e.Payload)); err != nil {
+		return fmt.Errorf("publish event %s: %w", e.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/streaming"
+)
+
+// recordingPublisher records payloads and fails from failAt on (0 = never).
+type recordingPublisher struct {
+	published []string
+	failAt    int
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, topic string, payload interface{}) error {
+	if p.failAt > 0 && len(p.published)+1 >= p.failAt {
+		return errors.New("broker unavailable")
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	p.published = append(p.published, string(data))
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+// failingAckStore loses acks while failAck is set, as if the relay died
+// between publishing and acking.
+type failingAckStore struct {
+	*store.MemoryStore
+	failAck bool
+}
+
+func (s *failingAckStore) AckOutbox(ctx context.Context, positions []string) error {
+	if s.failAck {
+		return errors.New("connection reset")
+	}
+	return s.MemoryStore.AckOutbox(ctx, positions)
+}
+
+func TestRelayDeliversInOrderAtLeastOnce(t *testing.T) {
+	ctx := context.Background()
+	s := &failingAckStore{MemoryStore: store.NewMemoryStore()}
+	st := &store.DesiredState{StateID: "s1", NodeID: "n1"}
+	if err := s.UpsertState(ctx, "acme", st); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		e := &store.OutboxEvent{
+			ID:        fmt.Sprintf("e%d", i),
+			Topic:     "fluxforge.events.state.transition",
+			TenantID:  "acme",
+			Payload:   json.RawMessage(fmt.Sprintf(`{"event_id":"e%d"}`, i)),
+			CreatedAt: time.Now(),
+		}
+		if err := s.UpdateStateStatusWithEvent(ctx, "acme", "s1", "compliant", "", time.Now(), st.Version, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pub := &recordingPublisher{failAt: 3}
+	relay := NewRelay(s, pub, time.Minute)
+	relay.SetBatchSize(2)
+
+	// The broker fails on the third event: the first two are acked, the
+	// rest wait behind it
+	n, err := relay.RunOnce(ctx)
+	if err == nil || n != 2 {
+		t.Fatalf("RunOnce = %d, %v; want 2 and the publish error", n, err)
+	}
+	if left, _ := s.ReadOutbox(ctx, 0); len(left) != 3 || left[0].ID != "e3" {
+		t.Fatalf("outbox after failure = %d events", len(left))
+	}
+
+	// Published but never acked: sent again on the next pass
+	pub.failAt = 0
+	s.failAck = true
+	if _, err := relay.RunOnce(ctx); err == nil {
+		t.Fatal("RunOnce succeeded with acks failing")
+	}
+	s.failAck = false
+	if n, err = relay.RunOnce(ctx); err != nil || n != 3 {
+		t.Fatalf("RunOnce = %d, %v; want the remaining 3", n, err)
+	}
+
+	want := []string{`{"event_id":"e1"}`, `{"event_id":"e2"}`, `{"event_id":"e3"}`, `{"event_id":"e4"}`, `{"event_id":"e3"}`, `{"event_id":"e4"}`, `{"event_id":"e5"}`}
+	if fmt.Sprint(pub.published) != fmt.Sprint(want) {
+		t.Errorf("published %v, want %v", pub.published, want)
+	}
+	if left, _ := s.ReadOutbox(ctx, 0); len(left) != 0 {
+		t.Errorf("%d events left in the outbox", len(left))
+	}
+}
+
+func TestRelayPublishesTheOutboxID(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	st := &store.DesiredState{StateID: "s1", NodeID: "n1"}
+	if err := s.UpsertState(ctx, "acme", st); err != nil {
+		t.Fatal(err)
+	}
+	e := &store.OutboxEvent{ID: "e1", Topic: "fluxforge.events.state.transition", TenantID: "acme", Payload: json.RawMessage(`{}`), CreatedAt: time.Now()}
+	if err := s.UpdateStateStatusWithEvent(ctx, "acme", "s1", "drifted", "", time.Now(), st.Version, e); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := streaming.NewBus()
+	defer bus.Close()
+	ids := make(chan string, 1)
+	bus.Subscribe("fluxforge.events.*", func(e streaming.Event) { ids <- e.ID })
+	if _, err := NewRelay(s, bus, time.Minute).RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-ids:
+		if id != "e1" {
+			t.Errorf("event ID %q, want the outbox ID", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event delivered")
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/incident"
+	"github.com/itskum47/FluxForge/control_plane/migration"
+	"github.com/itskum47/FluxForge/control_plane/outbox"
+	"github.com/itskum47/FluxForge/control_plane/resilience"
+	"github.com/itskum47/FluxForge/control_plane/retention"
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/streaming"
+	"github.com/itskum47/FluxForge/control_plane/timeline"
+	"github.com/redis/go-redis/v9"
+)
+
+// sharedPostgres lazily opens one pool for the optional Postgres-backed
+// components so they do not each hold 50 connections.
+var sharedPostgres struct {
+	once  sync.Once
+	store *store.PostgresStore
+	err   error
+}
+
+func postgresForAuxiliary(ctx context.Context) (*store.PostgresStore, error) {
+	sharedPostgres.once.Do(func() {
+		sharedPostgres.store, sharedPostgres.err = openPostgres(ctx)
+	})
+	return sharedPostgres.store, sharedPostgres.err
+}
+
+// openPostgres connects to DATABASE_URL and brings the schema up to date.
+//
+//	DB_MIGRATE   auto (default): apply pending migrations at startup
+//	             verify: refuse to start unless already migrated
+//	                     (run "fluxforge-control-plane migrate up" first)
+func openPostgres(ctx context.Context) (*store.PostgresStore, error) {
+	pg, err := store.NewPostgresStore(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode := getEnvOrDefault("DB_MIGRATE", "auto"); mode {
+	case "auto":
+		if _, err := pg.MigrateUp(ctx); err != nil {
+			pg.Close()
+			return nil, fmt.Errorf("schema migration failed: %w", err)
+		}
+	case "verify":
+		if err := pg.CheckSchema(ctx); err != nil {
+			pg.Close()
+			return nil, err
+		}
+	default:
+		pg.Close()
+		return nil, fmt.Errorf("unknown DB_MIGRATE %q (want auto or verify)", mode)
+	}
+	return pg, nil
+}
+
+// configureTimeline selects the timeline backend from the environment.
+//
+//	TIMELINE_BACKEND      memory | redis | postgres (default: see backends.persistenceDefault)
+//	TIMELINE_RETENTION    max event age (default 72h)
+//	TIMELINE_MAX_EVENTS   max events kept (default 100000)
+//	DATABASE_URL          required for the postgres backend
+func configureTimeline(ctx context.Context, tl *timeline.Store, b *backends) {
+	retention := timeline.DefaultRetention()
+	if v := os.Getenv("TIMELINE_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid TIMELINE_RETENTION %q: %v", v, err)
+		}
+		retention.MaxAge = d
+	}
+	if v := os.Getenv("TIMELINE_MAX_EVENTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("Invalid TIMELINE_MAX_EVENTS %q: %v", v, err)
+		}
+		retention.MaxEvents = n
+	}
+
+	backend := getEnvOrDefault("TIMELINE_BACKEND", b.persistenceDefault())
+	switch backend {
+	case "memory":
+		tl.SetBackend(timeline.NewMemoryBackend(retention.MaxEvents), retention)
+	case "redis":
+		if b.redis == nil {
+			log.Fatalf("TIMELINE_BACKEND=redis requires a Redis store or coordination backend")
+		}
+		tl.SetBackend(timeline.NewRedisBackend(b.redis.Client(), retention.MaxAge), retention)
+	case "postgres":
+		pg, err := postgresForAuxiliary(ctx)
+		if err != nil {
+			log.Fatalf("Failed to connect to Postgres for timeline: %v", err)
+		}
+		// openPostgres migrated (or verified) the schema, tables included
+		tl.SetBackend(timeline.NewPostgresBackend(pg.Pool()), retention)
+	default:
+		log.Fatalf("Unknown TIMELINE_BACKEND %q (want memory, redis or postgres)", backend)
+	}
+
+	tl.StartRetention(ctx, 5*time.Minute)
+	log.Printf("Timeline backend: %s (retention %v / %d events)", backend, retention.MaxAge, retention.MaxEvents)
+}
+
+// configureIncidents selects where captured incidents are persisted.
+//
+//	INCIDENT_BACKEND   memory | redis | postgres (default: see backends.persistenceDefault)
+func configureIncidents(ctx context.Context, api *API, b *backends) {
+	backend := getEnvOrDefault("INCIDENT_BACKEND", b.persistenceDefault())
+	switch backend {
+	case "memory":
+		api.SetIncidentRepository(incident.NewMemoryRepository())
+	case "redis":
+		if b.redis == nil {
+			log.Fatalf("INCIDENT_BACKEND=redis requires a Redis store or coordination backend")
+		}
+		api.SetIncidentRepository(incident.NewRedisRepository(b.redis.Client()))
+	case "postgres":
+		pg, err := postgresForAuxiliary(ctx)
+		if err != nil {
+			log.Fatalf("Failed to connect to Postgres for incidents: %v", err)
+		}
+		api.SetIncidentRepository(incident.NewPostgresRepository(pg.Pool()))
+	default:
+		log.Fatalf("Unknown INCIDENT_BACKEND %q (want memory, redis or postgres)", backend)
+	}
+	log.Printf("Incident backend: %s", backend)
+}
+
+// parseTimelineQuery reads timeline filters from the request.
+// ok is false when no filter was given.
+func parseTimelineQuery(r *http.Request) (q timeline.Query, ok bool, err error) {
+	v := r.URL.Query()
+	q.ReqID = v.Get("req_id")
+	q.StateID = v.Get("state_id")
+	q.NodeID = v.Get("node_id")
+	q.TenantID = v.Get("tenant_id")
+
+	if raw := v.Get("since"); raw != "" {
+		if q.Since, err = time.Parse(time.RFC3339, raw); err != nil {
+			return q, false, fmt.Errorf("invalid since: %v", err)
+		}
+	}
+	if raw := v.Get("until"); raw != "" {
+		if q.Until, err = time.Parse(time.RFC3339, raw); err != nil {
+			return q, false, fmt.Errorf("invalid until: %v", err)
+		}
+	}
+	if raw := v.Get("limit"); raw != "" {
+		if q.Limit, err = strconv.Atoi(raw); err != nil || q.Limit < 0 {
+			return q, false, fmt.Errorf("invalid limit: %s", raw)
+		}
+	}
+
+	ok = q.ReqID != "" || q.StateID != "" || q.NodeID != "" || q.TenantID != "" ||
+		!q.Since.IsZero() || !q.Until.IsZero() || q.Limit > 0
+	return q, ok, nil
+}
+
+// configureJobRetention builds the job garbage collector from the
+// environment, or returns nil when it is disabled. It deletes job history,
+// so it only runs once JOB_RETENTION_INTERVAL is set. main starts it on
+// the leader.
+//
+//	JOB_RETENTION_INTERVAL        pass interval, e.g. 10m (default "off": no retention)
+//	JOB_RETENTION_MAX_AGE         prune jobs finished longer ago (default 720h; 0 = no limit)
+//	JOB_RETENTION_MAX_PER_NODE    keep the newest N finished jobs per node (default 0 = no limit)
+//	JOB_RETENTION_KEEP_PER_STATE  keep the newest N finished jobs per state (default 0 = no limit)
+//	JOB_RETENTION_POLICIES        JSON file with "default" and per-tenant policies;
+//	                              replaces the three limits above (see retention.LoadPolicies)
+//	JOB_ARCHIVE_DIR               archive pruned jobs here as gzipped NDJSON (default: no archive)
+//	TOMBSTONE_RETENTION           purge agent/state tombstones older than this (default 168h; 0 = keep)
+func configureJobRetention(s store.Store) *retention.Collector {
+	intervalStr := getEnvOrDefault("JOB_RETENTION_INTERVAL", "off")
+	if intervalStr == "off" {
+		log.Println("Job retention: disabled")
+		return nil
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid JOB_RETENTION_INTERVAL %q: want a positive duration or \"off\"", intervalStr)
+	}
+
+	var policies retention.Policies
+	if path := os.Getenv("JOB_RETENTION_POLICIES"); path != "" {
+		if policies, err = retention.LoadPolicies(path); err != nil {
+			log.Fatalf("Invalid JOB_RETENTION_POLICIES: %v", err)
+		}
+	} else {
+		if policies.Default.MaxAge, err = time.ParseDuration(getEnvOrDefault("JOB_RETENTION_MAX_AGE", "720h")); err != nil {
+			log.Fatalf("Invalid JOB_RETENTION_MAX_AGE: %v", err)
+		}
+		for env, dst := range map[string]*int{
+			"JOB_RETENTION_MAX_PER_NODE":   &policies.Default.MaxJobsPerNode,
+			"JOB_RETENTION_KEEP_PER_STATE": &policies.Default.KeepPerState,
+		} {
+			if v := os.Getenv(env); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					log.Fatalf("Invalid %s %q: want a non-negative integer", env, v)
+				}
+				*dst = n
+			}
+		}
+	}
+
+	collector := retention.NewCollector(s, policies, interval)
+	if dir := os.Getenv("JOB_ARCHIVE_DIR"); dir != "" {
+		collector.SetArchiveDir(dir)
+	}
+	tombstoneAge, err := time.ParseDuration(getEnvOrDefault("TOMBSTONE_RETENTION", "168h"))
+	if err != nil {
+		log.Fatalf("Invalid TOMBSTONE_RETENTION: %v", err)
+	}
+	collector.SetTombstoneRetention(tombstoneAge)
+
+	log.Printf("Job retention: every %v, default policy %+v, %d tenant overrides, archive %q",
+		interval, policies.Default, len(policies.Tenants), os.Getenv("JOB_ARCHIVE_DIR"))
+	return collector
+}
+
+// configureStoreSync returns the Syncer that backfills and verifies the
+// secondary store of a live migration, or nil if there is none.
+//
+//	STORE_SYNC_INTERVAL   time between passes (default 5m; "off" = never)
+//	STORE_SYNC_REPAIR     true (default): fix the secondary; false: only report
+func configureStoreSync(b *backends) *migration.Syncer {
+	if b.dual == nil {
+		return nil
+	}
+	intervalStr := getEnvOrDefault("STORE_SYNC_INTERVAL", "5m")
+	if intervalStr == "off" {
+		log.Println("Store sync: disabled")
+		return nil
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid STORE_SYNC_INTERVAL %q: want a positive duration or \"off\"", intervalStr)
+	}
+	repair, err := strconv.ParseBool(getEnvOrDefault("STORE_SYNC_REPAIR", "true"))
+	if err != nil {
+		log.Fatalf("Invalid STORE_SYNC_REPAIR: %v", err)
+	}
+
+	syncer := migration.NewSyncer(b.dual, interval)
+	syncer.SetRepair(repair)
+	syncer.SetEpochResources(epochResources())
+	log.Printf("Store sync: every %v, repair %v", interval, repair)
+	return syncer
+}
+
+// configureStoreRouter returns the Router that keeps a live migration's
+// cut-over routing the same on every replica, or nil if there is none.
+//
+//	STORE_ROUTING_INTERVAL  how often replicas check for a switch (default 2s)
+func configureStoreRouter(b *backends) *migration.Router {
+	if b.dual == nil {
+		return nil
+	}
+	interval, err := time.ParseDuration(getEnvOrDefault("STORE_ROUTING_INTERVAL", "2s"))
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid STORE_ROUTING_INTERVAL: want a positive duration")
+	}
+	if b.coordinator == nil {
+		log.Println("Store migration: no coordinator, routing switches apply to this replica only")
+	}
+	return migration.NewRouter(b.dual, b.coordinator, interval)
+}
+
+// configureEventStream returns the Publisher for control plane events.
+// The memory and redis streams are also Subscribers, for in-process
+// consumers of fluxforge.events.*.
+//
+//	EVENT_STREAM          log (default): log events only | memory: in-process
+//	                      bus, single node | redis: Redis Streams, shared by replicas |
+//	                      nats: NATS JetStream, persistent and replayable
+//	EVENT_STREAM_MAXLEN   approximate events kept per topic stream (default 100000; 0 = all)
+//	REDIS_ADDR            Redis address when no store backend uses Redis (default localhost:6379)
+//	NATS_URL              NATS server URL(s) (default nats://localhost:4222)
+//	NATS_STREAM           JetStream stream name (default FLUXFORGE_EVENTS)
+//	NATS_STREAM_MAX_AGE   how long the stream keeps events (default 168h; 0 = no limit)
+//	NATS_STREAM_REPLICAS  stream replicas in a NATS cluster (default 1)
+func configureEventStream(ctx context.Context, b *backends) streaming.Publisher {
+	switch kind := getEnvOrDefault("EVENT_STREAM", "log"); kind {
+	case "log":
+		return streaming.NewLogPublisher()
+	case "memory":
+		log.Println("Event stream: in-process bus")
+		return streaming.NewBus()
+	case "redis":
+		var client *redis.Client
+		if b.redis != nil {
+			client = b.redis.Client()
+		} else {
+			addr := getEnvOrDefault("REDIS_ADDR", "localhost:6379")
+			client = redis.NewClient(&redis.Options{Addr: addr})
+			if err := client.Ping(ctx).Err(); err != nil {
+				log.Fatalf("Failed to connect to Redis at %s for the event stream: %v", addr, err)
+			}
+		}
+		maxLen, err := strconv.ParseInt(getEnvOrDefault("EVENT_STREAM_MAXLEN", strconv.Itoa(streaming.DefaultStreamMaxLen)), 10, 64)
+		if err != nil || maxLen < 0 {
+			log.Fatalf("Invalid EVENT_STREAM_MAXLEN: want a non-negative integer")
+		}
+		consumer, _ := os.Hostname()
+		rs := streaming.NewRedisStreams(client, "control-plane", consumer)
+		rs.SetMaxLen(maxLen)
+		log.Printf("Event stream: Redis Streams, about %d events per topic", maxLen)
+		return rs
+	case "nats":
+		cfg := streaming.JetStreamConfig{
+			URL:    getEnvOrDefault("NATS_URL", "nats://localhost:4222"),
+			Stream: getEnvOrDefault("NATS_STREAM", "FLUXFORGE_EVENTS"),
+		}
+		var err error
+		if cfg.MaxAge, err = time.ParseDuration(getEnvOrDefault("NATS_STREAM_MAX_AGE", "168h")); err != nil || cfg.MaxAge < 0 {
+			log.Fatalf("Invalid NATS_STREAM_MAX_AGE: want a non-negative duration")
+		}
+		if cfg.Replicas, err = strconv.Atoi(getEnvOrDefault("NATS_STREAM_REPLICAS", "1")); err != nil || cfg.Replicas < 1 {
+			log.Fatalf("Invalid NATS_STREAM_REPLICAS: want a positive integer")
+		}
+		connectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		js, err := streaming.NewJetStream(connectCtx, cfg)
+		if err != nil {
+			log.Fatalf("Failed to set up the NATS JetStream event stream: %v", err)
+		}
+		log.Printf("Event stream: NATS JetStream %s at %s, events kept %v", cfg.Stream, cfg.URL, cfg.MaxAge)
+		return js
+	default:
+		log.Fatalf("Invalid EVENT_STREAM %q: want log, memory, redis or nats", kind)
+		return nil
+	}
+}
+
+// configureOutboxRelay returns the relay that publishes state transition
+// events from the store's outbox, or nil when events are published
+// directly (best effort, lost on a crash or publish failure). main starts
+// it on the leader.
+//
+//	OUTBOX_RELAY_INTERVAL   time between relay passes (default 1s; "off" = publish directly)
+//	OUTBOX_RELAY_BATCH      events read and acked per store call (default 100)
+func configureOutboxRelay(s store.Store, publisher streaming.Publisher) *outbox.Relay {
+	intervalStr := getEnvOrDefault("OUTBOX_RELAY_INTERVAL", "1s")
+	if intervalStr == "off" {
+		log.Println("Event outbox: disabled, publishing events directly")
+		return nil
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid OUTBOX_RELAY_INTERVAL %q: want a positive duration or \"off\"", intervalStr)
+	}
+	batchStr := getEnvOrDefault("OUTBOX_RELAY_BATCH", strconv.Itoa(outbox.DefaultBatchSize))
+	batch, err := strconv.Atoi(batchStr)
+	if err != nil || batch <= 0 {
+		log.Fatalf("Invalid OUTBOX_RELAY_BATCH %q: want a positive integer", batchStr)
+	}
+
+	relay := outbox.NewRelay(s, publisher, interval)
+	relay.SetBatchSize(batch)
+	log.Printf("Event outbox: relaying every %v, %d events per batch", interval, batch)
+	return relay
+}
+
+// configureDegradedMode wraps the store so an outage of its backend serves
+// reads from cache and queues the leader's writes for replay (see
+// resilience.DegradedStore), or returns nil for the memory backend or if
+// disabled.
+//
+//	DEGRADED_MODE              true (default) | false
+//	DEGRADED_PROBE_INTERVAL    how often a down backend is probed (default 5s)
+//	DEGRADED_MAX_PENDING_AGE   queued writes older than this are dead-lettered
+//	                           on replay instead, except those recovered from
+//	                           the journal (default off: all are replayed)
+//	DEGRADED_WAL_DIR           directory queued writes are journaled to, so
+//	                           they survive a restart (default degraded-wal;
+//	                           "off" = kept in memory only)
+//	DEGRADED_DEAD_LETTER_FILE  file the writes replay gives up on are appended
+//	                           to (default degraded-dead-letters.jsonl; "off" =
+//	                           logged only)
+func configureDegradedMode(b *backends, mode *resilience.DegradedMode) *resilience.DegradedStore {
+	enabled, err := strconv.ParseBool(getEnvOrDefault("DEGRADED_MODE", "true"))
+	if err != nil {
+		log.Fatalf("Invalid DEGRADED_MODE: %v", err)
+	}
+	if !enabled || b.storeBackend == "memory" {
+		return nil
+	}
+	interval, err := time.ParseDuration(getEnvOrDefault("DEGRADED_PROBE_INTERVAL", "5s"))
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid DEGRADED_PROBE_INTERVAL: want a positive duration")
+	}
+	maxAgeStr := getEnvOrDefault("DEGRADED_MAX_PENDING_AGE", "off")
+	if maxAgeStr != "off" {
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil || maxAge <= 0 {
+			log.Fatalf("Invalid DEGRADED_MAX_PENDING_AGE %q: want a positive duration or \"off\"", maxAgeStr)
+		}
+		mode.SetMaxPendingAge(maxAge)
+	}
+	if path := getEnvOrDefault("DEGRADED_DEAD_LETTER_FILE", "degraded-dead-letters.jsonl"); path != "off" {
+		dl, err := resilience.OpenDeadLetterFile(path)
+		if err != nil {
+			log.Fatalf("Failed to open degraded-mode dead letter file %s: %v", path, err)
+		}
+		mode.SetDeadLetters(dl)
+	}
+
+	walDir := getEnvOrDefault("DEGRADED_WAL_DIR", "degraded-wal")
+	if walDir != "off" {
+		wal, err := resilience.OpenWAL(walDir, resilience.DecodePendingOp)
+		if err != nil {
+			log.Fatalf("Failed to open degraded-mode WAL %s: %v", walDir, err)
+		}
+		if n := mode.SetJournal(wal); n > 0 {
+			log.Printf("Degraded mode: %d writes from before the restart will be replayed", n)
+		}
+	}
+
+	dependency := resilience.DependencyDatabase
+	if b.storeBackend == "redis" {
+		dependency = resilience.DependencyRedis
+	}
+	log.Printf("Degraded mode: %s outages served from cache, probed every %v, max write age %s, journal %q", dependency, interval, maxAgeStr, walDir)
+	return resilience.NewDegradedStore(b.store, mode, dependency, interval)
+}
@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/itskum47/FluxForge/control_plane/incident"
+	"github.com/itskum47/FluxForge/control_plane/jobnotify"
 	"github.com/itskum47/FluxForge/control_plane/observability"
 	"github.com/itskum47/FluxForge/control_plane/store"
 	"github.com/itskum47/FluxForge/control_plane/streaming"
+	"github.com/itskum47/FluxForge/control_plane/timeline"
 )
 
 // Reconciler handles desired state reconciliation.
@@ -25,6 +30,17 @@ type Reconciler struct {
 
 	// maxTaskRuntime is the hard timeout for any single reconciliation task
 	maxTaskRuntime time.Duration
+	// defaultJobTimeout bounds a single job when the state does not set one
+	defaultJobTimeout time.Duration
+	// completions wakes waitForJob as soon as /jobs/result is recorded
+	completions *jobnotify.Registry
+	// jobResyncInterval is the fallback poll in case a notification is lost
+	jobResyncInterval time.Duration
+	// timeline persists state transitions for incident replay (optional)
+	timeline *timeline.Store
+	// outbox commits transition events with the status update, for the
+	// outbox relay to publish, instead of publishing them directly
+	outbox bool
 	// ShadowMode enables dry-run execution (log intentions but don't execute side effects)
 	ShadowMode bool
 }
@@ -32,15 +48,40 @@ type Reconciler struct {
 // NewReconciler creates a new Reconciler.
 func NewReconciler(store store.Store, dispatcher *Dispatcher, publisher streaming.Publisher) *Reconciler {
 	return &Reconciler{
-		store:            store,
-		dispatcher:       dispatcher,
-		publisher:        publisher,
-		activeReconciles: make(map[string]bool),
-		maxTaskRuntime:   5 * time.Minute, // Default: 5 minutes
-		ShadowMode:       false,
+		store:             store,
+		dispatcher:        dispatcher,
+		publisher:         publisher,
+		activeReconciles:  make(map[string]bool),
+		maxTaskRuntime:    5 * time.Minute, // Default: 5 minutes
+		defaultJobTimeout: 30 * time.Second,
+		completions:       jobnotify.NewRegistry(),
+		jobResyncInterval: 5 * time.Second,
+		ShadowMode:        false,
 	}
 }
 
+// SetDefaultJobTimeout configures the job timeout used when a state has none.
+func (r *Reconciler) SetDefaultJobTimeout(d time.Duration) {
+	r.defaultJobTimeout = d
+}
+
+// Completions returns the registry fed by /jobs/result.
+func (r *Reconciler) Completions() *jobnotify.Registry {
+	return r.completions
+}
+
+// SetTimeline records state transitions into the (durable) timeline.
+func (r *Reconciler) SetTimeline(tl *timeline.Store) {
+	r.timeline = tl
+}
+
+// SetOutbox makes status updates commit their transition event to the
+// store's outbox. Enable it only with an outbox relay running, which then
+// publishes the events instead of the reconciler.
+func (r *Reconciler) SetOutbox(enabled bool) {
+	r.outbox = enabled
+}
+
 // SetShadowMode enables/disables shadow mode.
 func (r *Reconciler) SetShadowMode(enabled bool) {
 	r.ShadowMode = enabled
@@ -152,6 +193,9 @@ func (r *Reconciler) reconcileWithContext(ctx context.Context, tenantID string,
 
 	// 1. Check phase
 	if !r.runCheck(ctx, agent, state) {
+		if state.Status == "compliant" {
+			return nil // No drift, nothing to apply
+		}
 		return fmt.Errorf("check phase failed")
 	}
 
@@ -186,7 +230,7 @@ func (r *Reconciler) runApply(ctx context.Context, agent *store.Agent, state *st
 		return true
 	}
 
-	exitCode, err := r.executeJob(ctx, agent, state.ApplyCmd)
+	exitCode, err := r.executeJob(ctx, agent, state, state.ApplyCmd)
 	if err != nil {
 		r.updateStatus(ctx, state, "failed", fmt.Sprintf("apply failed: %v", err))
 		return false
@@ -201,7 +245,7 @@ func (r *Reconciler) runApply(ctx context.Context, agent *store.Agent, state *st
 
 // runFinalCheck executes the final verification check.
 func (r *Reconciler) runFinalCheck(ctx context.Context, agent *store.Agent, state *store.DesiredState) {
-	exitCode, err := r.executeJob(ctx, agent, state.CheckCmd)
+	exitCode, err := r.executeJob(ctx, agent, state, state.CheckCmd)
 	if err != nil {
 		r.updateStatus(ctx, state, "failed", fmt.Sprintf("final check failed: %v", err))
 		return
@@ -223,7 +267,7 @@ func (r *Reconciler) runFinalCheck(ctx context.Context, agent *store.Agent, stat
 
 // runCheck executes the check command.
 func (r *Reconciler) runCheck(ctx context.Context, agent *store.Agent, state *store.DesiredState) bool {
-	exitCode, err := r.executeJob(ctx, agent, state.CheckCmd)
+	exitCode, err := r.executeJob(ctx, agent, state, state.CheckCmd)
 	if err != nil {
 		r.updateStatus(ctx, state, "failed", fmt.Sprintf("check failed: %v", err))
 		return false
@@ -232,7 +276,13 @@ func (r *Reconciler) runCheck(ctx context.Context, agent *store.Agent, state *st
 	state.LastChecked = time.Now()
 
 	if exitCode == state.DesiredExitCode {
-		r.updateStatus(ctx, state, "compliant", "")
+		// A drift recheck of a compliant state mostly finds it still
+		// compliant: that is not a transition, so only the check is recorded
+		if state.Status == "compliant" {
+			r.recordCheck(ctx, state)
+		} else {
+			r.updateStatus(ctx, state, "compliant", "")
+		}
 		return false // No apply needed
 	}
 
@@ -245,26 +295,30 @@ func (r *Reconciler) runCheck(ctx context.Context, agent *store.Agent, state *st
 	return true // Apply needed
 }
 
-// Placeholder to avoid syntax error while I fix types.go
-func (r *Reconciler) dummy() {}
-
 // executeJob creates a job, dispatches it, and waits for completion.
-func (r *Reconciler) executeJob(ctx context.Context, agent *store.Agent, command string) (int, error) {
+func (r *Reconciler) executeJob(ctx context.Context, agent *store.Agent, state *store.DesiredState, command string) (int, error) {
 	jobID := generateUUID()
+	timeout := r.jobTimeout(state)
 
 	job := &store.Job{
-		JobID:     jobID,
-		NodeID:    agent.NodeID,
-		TenantID:  agent.TenantID, // Ensure tenant ID is propagated
-		Command:   command,
-		Status:    "queued",
-		CreatedAt: time.Now(),
+		JobID:          jobID,
+		NodeID:         agent.NodeID,
+		TenantID:       agent.TenantID, // Ensure tenant ID is propagated
+		StateID:        state.StateID,
+		Command:        command,
+		Status:         "queued",
+		CreatedAt:      time.Now(),
+		TimeoutSeconds: int(timeout / time.Second),
 	}
 
 	if err := r.store.CreateJob(ctx, agent.TenantID, job); err != nil {
 		return -1, fmt.Errorf("failed to create job: %v", err)
 	}
 
+	// Register before dispatch so a fast result cannot be missed
+	done, cancel := r.completions.Wait(agent.TenantID, jobID)
+	defer cancel()
+
 	log.Printf("Dispatching job %s to agent %s: %s", jobID, agent.NodeID, command)
 
 	// IMPORTANT:
@@ -272,36 +326,68 @@ func (r *Reconciler) executeJob(ctx context.Context, agent *store.Agent, command
 	// Job state is the source of truth.
 	r.dispatcher.DispatchJob(ctx, agent, job)
 
-	return r.waitForJob(ctx, agent.TenantID, jobID)
+	exitCode, err := r.waitForJob(ctx, agent.TenantID, jobID, timeout, done)
+	if err != nil {
+		r.abandonQueuedJob(agent.TenantID, jobID)
+	}
+	return exitCode, err
+}
+
+// abandonQueuedJob fails a job nobody picked up (e.g. a pull agent that
+// stopped polling) so it is not leased after the reconciler gave up on it.
+// Only a job still queued is failed: one an agent claimed meanwhile runs.
+func (r *Reconciler) abandonQueuedJob(tenantID string, jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := r.store.UpdateJobStatus(store.WithExpectedJobStatus(ctx, "queued"), tenantID, jobID, "failed", -1, "", "abandoned: not picked up before timeout")
+	if err != nil && !errors.Is(err, store.ErrJobStatusConflict) && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to abandon job %s: %v", jobID, err)
+	}
 }
 
-// waitForJob polls until the job completes or fails.
-func (r *Reconciler) waitForJob(ctx context.Context, tenantID string, jobID string) (int, error) {
-	timeout := time.After(30 * time.Second)
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+// jobTimeout resolves the per-job timeout: state override, else reconciler default.
+func (r *Reconciler) jobTimeout(state *store.DesiredState) time.Duration {
+	if state != nil && state.JobTimeoutSeconds > 0 {
+		return time.Duration(state.JobTimeoutSeconds) * time.Second
+	}
+	return r.defaultJobTimeout
+}
+
+// waitForJob blocks until the job completes or fails.
+// It wakes on completion notifications and re-reads the job from the store;
+// a slow resync poll covers notifications lost between replicas.
+func (r *Reconciler) waitForJob(ctx context.Context, tenantID string, jobID string, timeout time.Duration, done <-chan jobnotify.Completion) (int, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	resync := time.NewTicker(r.jobResyncInterval)
+	defer resync.Stop()
 
 	for {
-		select {
-		case <-timeout:
-			return -1, fmt.Errorf("timeout waiting for job %s", jobID)
-
-		case <-ticker.C:
-			// Pass context
-			job, err := r.store.GetJob(ctx, tenantID, jobID)
-			if err != nil {
-				return -1, fmt.Errorf("error getting job %s: %v", jobID, err)
-			}
-			if job == nil {
-				return -1, fmt.Errorf("job %s lost", jobID)
-			}
+		// Dispatch failures are recorded synchronously, so check once up front
+		job, err := r.store.GetJob(ctx, tenantID, jobID)
+		if err != nil {
+			return -1, fmt.Errorf("error getting job %s: %v", jobID, err)
+		}
+		if job == nil {
+			return -1, fmt.Errorf("job %s lost", jobID)
+		}
 
-			switch job.Status {
-			case "completed":
-				return job.ExitCode, nil
-			case "failed":
-				return -1, fmt.Errorf("job execution failed: %s", job.Stderr)
-			}
+		switch job.Status {
+		case "completed":
+			return job.ExitCode, nil
+		case "failed":
+			return -1, fmt.Errorf("job execution failed: %s", job.Stderr)
+		}
+
+		select {
+		case <-ctx.Done():
+			return -1, fmt.Errorf("cancelled waiting for job %s: %w", jobID, ctx.Err())
+		case <-deadline.C:
+			return -1, fmt.Errorf("timeout waiting for job %s after %v", jobID, timeout)
+		case <-done:
+			done = nil // One-shot: later wake-ups come from resync
+		case <-resync.C:
 		}
 	}
 }
@@ -310,10 +396,18 @@ func (r *Reconciler) waitForJob(ctx context.Context, tenantID string, jobID stri
 func (r *Reconciler) updateStatus(ctx context.Context, state *store.DesiredState, status, lastError string) {
 	// Preserve local state update for subsequent logic usage if needed?
 	// But CAS failure means our state is stale.
+	previous := state.Status
 	state.Status = status
 	state.LastError = lastError
 
-	err := r.store.UpdateStateStatus(ctx, state.TenantID, state.StateID, status, lastError, state.LastChecked, state.Version)
+	var err error
+	if r.outbox && previous != status {
+		// Only a transition is an event; rechecks that keep the status
+		// would otherwise fill the outbox
+		err = r.updateStatusWithEvent(ctx, state, status, lastError)
+	} else {
+		err = r.store.UpdateStateStatus(ctx, state.TenantID, state.StateID, status, lastError, state.LastChecked, state.Version)
+	}
 	if err != nil {
 		log.Printf("Failed to update status for state %s: %v", state.StateID, err)
 		// If CAS failed, we should arguably stop reconciliation.
@@ -324,13 +418,37 @@ func (r *Reconciler) updateStatus(ctx context.Context, state *store.DesiredState
 	} else {
 		log.Printf("State %s transitioned to %s", state.StateID, status)
 
-		// Emit Event (Phase 5.1: Async, non-blocking, best-effort)
-		if r.publisher != nil {
+		if r.timeline != nil {
+			r.timeline.Record(timeline.ReconcileEvent{
+				Stage:    incident.StageStateTransition,
+				NodeID:   state.NodeID,
+				TenantID: state.TenantID,
+				Metadata: map[string]string{
+					"state_id": state.StateID,
+					"from":     previous,
+					"to":       status,
+					"reason":   lastError,
+				},
+			})
+		}
+
+		// Emit Event (Phase 5.1: Async, non-blocking, best-effort), unless
+		// it was committed to the outbox
+		if r.publisher != nil && !r.outbox {
 			go r.publishEventAsync(state, status, lastError)
 		}
 	}
 }
 
+// recordCheck persists state.LastChecked without changing the status, so
+// the check is visible and the drift sweeper does not find the state due
+// again. It records no transition and emits no event.
+func (r *Reconciler) recordCheck(ctx context.Context, state *store.DesiredState) {
+	if err := r.store.UpdateStateStatus(ctx, state.TenantID, state.StateID, state.Status, state.LastError, state.LastChecked, state.Version); err != nil {
+		log.Printf("Failed to record check for state %s: %v", state.StateID, err)
+	}
+}
+
 // publishEventAsync publishes state transition events asynchronously.
 // This is best-effort and non-blocking - failures are logged and metered but don't affect reconciliation.
 // Policy: Events are for observability, not control flow. NATS/Kafka outages should not block reconciliation.
@@ -339,15 +457,9 @@ func (r *Reconciler) publishEventAsync(state *store.DesiredState, status string,
 	publishCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	eventPayload := map[string]interface{}{
-		"state_id":   state.StateID,
-		"node_id":    state.NodeID,
-		"new_status": status,
-		"reason":     lastError,
-		"timestamp":  time.Now().Format(time.RFC3339),
-	}
+	eventPayload := transitionPayload(state, status, lastError, time.Now())
 
-	if err := r.publisher.Publish(publishCtx, "fluxforge.events.state.transition", eventPayload); err != nil {
+	if err := r.publisher.Publish(publishCtx, stateTransitionTopic, eventPayload); err != nil {
 		// Log error but DO NOT fail reconciliation
 		// Events are for observability, not control flow
 		log.Printf("⚠️ Event publish failed (non-critical): %v", err)
@@ -355,6 +467,41 @@ func (r *Reconciler) publishEventAsync(state *store.DesiredState, status string,
 	}
 }
 
+// updateStatusWithEvent persists the status update and its transition
+// event in one store write. The event ID lets consumers drop the
+// duplicates at-least-once delivery can produce.
+func (r *Reconciler) updateStatusWithEvent(ctx context.Context, state *store.DesiredState, status, lastError string) error {
+	now := time.Now()
+	eventID := generateUUID()
+	payload := transitionPayload(state, status, lastError, now)
+	payload["event_id"] = eventID
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	event := &store.OutboxEvent{
+		ID:        eventID,
+		Topic:     stateTransitionTopic,
+		TenantID:  state.TenantID,
+		Payload:   data,
+		CreatedAt: now,
+	}
+	return r.store.UpdateStateStatusWithEvent(ctx, state.TenantID, state.StateID, status, lastError, state.LastChecked, state.Version, event)
+}
+
+// stateTransitionTopic is where state transition events are published.
+const stateTransitionTopic = "fluxforge.events.state.transition"
+
+func transitionPayload(state *store.DesiredState, status, lastError string, at time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"state_id":   state.StateID,
+		"node_id":    state.NodeID,
+		"new_status": status,
+		"reason":     lastError,
+		"timestamp":  at.Format(time.RFC3339),
+	}
+}
+
 // acquireLock enforces per-agent exclusivity.
 func (r *Reconciler) acquireLock(nodeID string) bool {
 	r.mu.Lock()
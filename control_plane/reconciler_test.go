@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/timeline"
+)
+
+// exitTransport completes every job it is handed with exitCode.
+type exitTransport struct {
+	store    store.Store
+	exitCode int
+}
+
+func (e *exitTransport) Name() string { return "exit" }
+
+func (e *exitTransport) Deliver(ctx context.Context, agent *store.Agent, job *store.Job) error {
+	return e.store.UpdateJobStatus(ctx, job.TenantID, job.JobID, "completed", e.exitCode, "", "")
+}
+
+func TestRecheckOfCompliantStateOnlyRecordsCheck(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	dispatcher := NewDispatcher(s)
+	dispatcher.RegisterTransport("exit", &exitTransport{store: s})
+	reconciler := NewReconciler(s, dispatcher, nil)
+	tl := timeline.NewStore()
+	reconciler.SetTimeline(tl)
+
+	agent := &store.Agent{NodeID: "n1", Status: "active", Metadata: map[string]string{TransportMetadataKey: "exit"}}
+	if err := s.UpsertAgent(ctx, "t1", agent); err != nil {
+		t.Fatal(err)
+	}
+	checked := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, st := range []*store.DesiredState{
+		{StateID: "compliant", NodeID: "n1", CheckCmd: "true"},
+		{StateID: "pending", NodeID: "n1", CheckCmd: "true"},
+	} {
+		if err := s.UpsertState(ctx, "t1", st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.UpdateStateStatus(ctx, "t1", "compliant", "compliant", "", checked, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateStateStatus(ctx, "t1", "pending", "pending", "", checked, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"compliant", "pending"} {
+		if err := reconciler.Reconcile(ctx, "t1", id); err != nil {
+			t.Fatalf("Reconcile(%s): %v", id, err)
+		}
+	}
+
+	// Still compliant: the check is recorded, but it is not a transition
+	if st, _ := s.GetState(ctx, "t1", "compliant"); st == nil || st.Status != "compliant" || !st.LastChecked.After(checked) {
+		t.Errorf("compliant state after a passing recheck = %+v, want compliant and checked now", st)
+	}
+	if st, _ := s.GetState(ctx, "t1", "pending"); st == nil || st.Status != "compliant" || !st.LastChecked.After(checked) {
+		t.Errorf("pending state after a passing check = %+v, want compliant and checked now", st)
+	}
+	for _, event := range tl.GetEventsByStateID("compliant") {
+		t.Errorf("recheck of a compliant state recorded a transition: %+v", event)
+	}
+}
+
+func TestAbandonQueuedJobSparesClaimedJobs(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	reconciler := NewReconciler(s, NewDispatcher(s), nil)
+
+	for _, id := range []string{"queued", "claimed"} {
+		if err := s.CreateJob(ctx, "t1", &store.Job{JobID: id, NodeID: "n1", Status: "queued"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.UpdateJobStatus(ctx, "t1", "claimed", "running", 0, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	reconciler.abandonQueuedJob("t1", "queued")
+	reconciler.abandonQueuedJob("t1", "claimed")
+
+	if job, _ := s.GetJob(ctx, "t1", "queued"); job == nil || job.Status != "failed" {
+		t.Errorf("abandoned queued job = %+v, want failed", job)
+	}
+	if job, _ := s.GetJob(ctx, "t1", "claimed"); job == nil || job.Status != "running" {
+		t.Errorf("claimed job after abandon = %+v, want still running", job)
+	}
+}
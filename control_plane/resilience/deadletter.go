@@ -0,0 +1,80 @@
+package resilience
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetters records pending writes reconciliation gave up on, so an
+// operator can inspect them and re-apply what still matters.
+type DeadLetters interface {
+	Record(write PendingWrite, reason string) error
+}
+
+// deadLetterRecord is one line of a DeadLetterFile.
+type deadLetterRecord struct {
+	Version      int64       `json:"version"`
+	Key          string      `json:"key"`
+	Value        interface{} `json:"value"`
+	Timestamp    int64       `json:"timestamp"`
+	Reason       string      `json:"reason"`
+	DeadLettered time.Time   `json:"dead_lettered_at"`
+}
+
+// DeadLetterFile appends dead-lettered writes to a file, one JSON object
+// per line, syncing each before Record returns.
+type DeadLetterFile struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+var _ DeadLetters = (*DeadLetterFile)(nil)
+
+// OpenDeadLetterFile opens path for appending, creating it if needed.
+func OpenDeadLetterFile(path string) (*DeadLetterFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetterFile{f: f}, nil
+}
+
+func (d *DeadLetterFile) Record(write PendingWrite, reason string) error {
+	line, err := json.Marshal(deadLetterRecord{
+		Version:      write.Version,
+		Key:          write.Key,
+		Value:        write.Value,
+		Timestamp:    write.Timestamp,
+		Reason:       reason,
+		DeadLettered: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("encode dead letter %s: %w", write.Key, err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return d.f.Sync()
+}
+
+// Close closes the file.
+func (d *DeadLetterFile) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.f.Close()
+}
+
+// recordDeadLetter logs a write given up on and records it to dl, if set.
+func recordDeadLetter(dl DeadLetters, write PendingWrite, reason string) error {
+	log.Printf("[DEGRADED MODE] Dead-lettered write %s (version %d): %s", write.Key, write.Version, reason)
+	if dl == nil {
+		return nil
+	}
+	return dl.Record(write, reason)
+}
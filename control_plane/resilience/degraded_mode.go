@@ -17,6 +17,22 @@ type PendingWrite struct {
 	TTL        time.Duration
 	Version    int64 // CRITICAL: Version for conflict detection
 	Reconciled bool  // CRITICAL: Idempotent reconciliation
+	// Recovered writes were read back from the journal after a restart.
+	// Their age includes the downtime, so no max pending age applies
+	Recovered bool
+}
+
+// Journal durably records pending writes so they survive a restart (see
+// WAL).
+type Journal interface {
+	Append(write PendingWrite) error
+	// Ack records that writes were reconciled or dropped
+	Ack(versions []int64) error
+	// Pending returns the writes never acked, oldest first
+	Pending() []PendingWrite
+	LastVersion() int64
+	// Compact drops what is no longer needed
+	Compact() error
 }
 
 // CacheEntry tracks access time for proper LRU eviction
@@ -43,7 +59,10 @@ type DegradedMode struct {
 	// CRITICAL: Bounded pending writes with version tracking
 	pendingWrites    []PendingWrite
 	maxPendingWrites int
+	maxPendingAge    time.Duration // 0 = none; older writes are dead-lettered on reconcile
 	currentVersion   int64
+	journal          Journal     // nil = pending writes are lost on restart
+	deadLetters      DeadLetters // nil = writes given up on are only logged
 
 	// Metrics
 	degradedModeActive bool
@@ -116,6 +135,40 @@ func (d *DegradedMode) MarkDBAvailable() {
 	}
 }
 
+// SetMaxPendingAge sets how old a pending write may get before
+// reconciliation dead-letters it as stale (default 0: never).
+func (d *DegradedMode) SetMaxPendingAge(age time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxPendingAge = age
+}
+
+// SetDeadLetters records the pending writes reconciliation gives up on,
+// and those dropped when the queue is full, to dl.
+func (d *DegradedMode) SetDeadLetters(dl DeadLetters) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadLetters = dl
+}
+
+// SetJournal journals pending writes to j from now on, after queueing the
+// writes it holds from before a restart ahead of any already queued.
+func (d *DegradedMode) SetJournal(j Journal) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	recovered := j.Pending()
+	d.pendingWrites = append(recovered, d.pendingWrites...)
+	if v := j.LastVersion(); v > d.currentVersion {
+		d.currentVersion = v
+	}
+	d.journal = j
+	if len(recovered) > 0 {
+		log.Printf("[DEGRADED MODE] Recovered %d pending writes from the journal", len(recovered))
+	}
+	return len(recovered)
+}
+
 // IsRedisAvailable checks if Redis is available
 func (d *DegradedMode) IsRedisAvailable() bool {
 	d.mu.RLock()
@@ -173,22 +226,35 @@ func (d *DegradedMode) SetInCacheWithTTL(key string, value interface{}, ttl time
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// CRITICAL: Enforce bounded pending writes
-	if len(d.pendingWrites) >= d.maxPendingWrites {
-		log.Printf("[DEGRADED MODE] Pending writes full (%d entries), dropping oldest unreconciled",
-			d.maxPendingWrites)
-
-		// Remove oldest unreconciled write
-		for i := 0; i < len(d.pendingWrites); i++ {
-			if !d.pendingWrites[i].Reconciled {
-				d.pendingWrites = append(d.pendingWrites[:i], d.pendingWrites[i+1:]...)
-				break
-			}
-		}
+	d.cacheLocked(key, value)
+	if _, err := d.queueLocked(key, value, ttl); err != nil {
+		log.Printf("[DEGRADED MODE] Write %s not queued: %v", key, err)
 	}
+}
 
+// CacheValue stores value in the local cache without queueing a write, to
+// keep what the backend last returned for reads during an outage.
+func (d *DegradedMode) CacheValue(key string, value interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cacheLocked(key, value)
+}
+
+// QueueWrite tracks value for reconciliation without caching it and
+// returns the write's version. With a journal, the write is only queued
+// once journaled.
+func (d *DegradedMode) QueueWrite(key string, value interface{}) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.queueLocked(key, value, 0)
+}
+
+// cacheLocked stores value in the bounded LRU cache. d.mu must be held.
+func (d *DegradedMode) cacheLocked(key string, value interface{}) {
 	// Enforce bounded cache with PROPER LRU eviction
-	if d.cacheSize >= d.maxCacheSize {
+	if _, exists := d.localCache[key]; !exists && d.cacheSize >= d.maxCacheSize {
 		// Find oldest accessed entry (proper LRU)
 		var oldestKey string
 		var oldestTime time.Time
@@ -218,22 +284,54 @@ func (d *DegradedMode) SetInCacheWithTTL(key string, value interface{}, ttl time
 		Value:      value,
 		LastAccess: time.Now(),
 	}
+}
+
+// queueLocked appends a pending write and returns its version. d.mu must
+// be held.
+func (d *DegradedMode) queueLocked(key string, value interface{}, ttl time.Duration) (int64, error) {
+	write := PendingWrite{
+		Key:       key,
+		Value:     value,
+		Timestamp: time.Now().Unix(), // Unix timestamp
+		TTL:       ttl,
+		Version:   d.currentVersion + 1, // CRITICAL: Version for conflict detection
+	}
+	if d.journal != nil {
+		if err := d.journal.Append(write); err != nil {
+			return 0, err
+		}
+	}
 
-	// CRITICAL: Increment version for conflict detection
-	d.currentVersion++
+	// CRITICAL: Enforce bounded pending writes
+	if len(d.pendingWrites) >= d.maxPendingWrites {
+		log.Printf("[DEGRADED MODE] Pending writes full (%d entries), dropping oldest unreconciled",
+			d.maxPendingWrites)
+
+		// Remove oldest unreconciled write
+		for i := 0; i < len(d.pendingWrites); i++ {
+			if !d.pendingWrites[i].Reconciled {
+				if err := recordDeadLetter(d.deadLetters, d.pendingWrites[i], "pending writes full"); err != nil {
+					log.Printf("[DEGRADED MODE] Failed to dead-letter dropped write: %v", err)
+				}
+				if d.journal != nil {
+					if err := d.journal.Ack([]int64{d.pendingWrites[i].Version}); err != nil {
+						log.Printf("[DEGRADED MODE] Failed to journal dropped write: %v", err)
+					}
+				}
+				d.pendingWrites = append(d.pendingWrites[:i], d.pendingWrites[i+1:]...)
+				break
+			}
+		}
+	}
+
+	d.currentVersion = write.Version
 
 	// CRITICAL: Mark as DEGRADED_PENDING_SYNC with version
-	d.pendingWrites = append(d.pendingWrites, PendingWrite{
-		Key:        key,
-		Value:      value,
-		Timestamp:  time.Now().Unix(), // Unix timestamp
-		TTL:        ttl,
-		Version:    d.currentVersion,
-		Reconciled: false,
-	})
+	d.pendingWrites = append(d.pendingWrites, write)
 
 	log.Printf("[DEGRADED MODE] Write marked DEGRADED_PENDING_SYNC: %s (version: %d, total pending: %d)",
 		key, d.currentVersion, len(d.pendingWrites))
+	return d.currentVersion, nil
 }
 
 // ClearCache clears local cache
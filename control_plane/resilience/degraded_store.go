@@ -0,0 +1,842 @@
+package resilience
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// Dependencies a DegradedStore's backend is reported as in HealthCheck.
+const (
+	DependencyRedis    = "redis"
+	DependencyDatabase = "database"
+)
+
+// ErrStoreUnavailable is returned by a DegradedStore for what it can't
+// serve while the backend is down: a read that isn't cached, or a write
+// it can't queue.
+var ErrStoreUnavailable = errors.New("store unavailable")
+
+// Writes a DegradedStore queues while the backend is down.
+const (
+	OpUpsertAgent    = "upsert_agent"
+	OpAgentHeartbeat = "agent_heartbeat"
+	OpUpsertState    = "upsert_state"
+	OpStateStatus    = "state_status"
+	OpCreateJob      = "create_job"
+	OpJobStatus      = "job_status"
+)
+
+const (
+	// probeCooldown is how long a probe result is reused, so a burst of
+	// failing requests probes the backend once
+	probeCooldown = time.Second
+	probeTimeout  = 2 * time.Second
+	// probeResource is the epoch read to check the backend is up
+	probeResource = "leader_election"
+)
+
+// PendingOp is a store write queued while degraded, replayed on recovery.
+// The record carries what the write changes: the whole record for upserts
+// and creates, the ID and the new fields for status updates.
+type PendingOp struct {
+	Op       string              `json:"op"`
+	TenantID string              `json:"tenant_id"`
+	Agent    *store.Agent        `json:"agent,omitempty"`
+	State    *store.DesiredState `json:"state,omitempty"`
+	Job      *store.Job          `json:"job,omitempty"`
+	// BaseVersion is the state version the write was made against (0 =
+	// not cached, so a create): replay drops the write if the state has
+	// since moved on, or for a create, if it exists
+	BaseVersion int              `json:"base_version,omitempty"`
+	Change      store.ChangeInfo `json:"change"`
+	// ExpectedStatus is the job status a status update was made against
+	// (store.WithExpectedJobStatus): replay drops it if the job moved on
+	ExpectedStatus string `json:"expected_status,omitempty"`
+	// Event is the outbox event committed with a status update
+	Event *store.OutboxEvent `json:"event,omitempty"`
+}
+
+// DegradedStore keeps the control plane serving through a store outage.
+// While the backend is up it passes everything through and caches what
+// reads return. Once a call fails and a probe confirms the backend is
+// down, it serves agent, state and job reads from that cache and queues
+// upserts, creates and status updates as pending writes, which the leader
+// replays through the ReconciliationCoordinator when the backend recovers.
+// Everything else fails with ErrStoreUnavailable until then.
+//
+// Only the leader queues writes: it is the only node that replays them,
+// so a follower's write would be lost. Followers reject writes while
+// degraded. With a journal on the DegradedMode, a write is only accepted
+// once journaled, and survives a restart.
+type DegradedStore struct {
+	store.Store
+
+	mode        *DegradedMode
+	dependency  string
+	interval    time.Duration
+	coordinator *ReconciliationCoordinator
+
+	probeMu      sync.Mutex
+	lastProbe    time.Time
+	lastProbeErr error
+}
+
+// NewDegradedStore wraps s, reporting its backend to mode as dependency
+// (DependencyRedis or DependencyDatabase). Start probes a down backend
+// every interval.
+func NewDegradedStore(s store.Store, mode *DegradedMode, dependency string, interval time.Duration) *DegradedStore {
+	return &DegradedStore{
+		Store:      s,
+		mode:       mode,
+		dependency: dependency,
+		interval:   interval,
+	}
+}
+
+// SetCoordinator sets the coordinator that replays pending writes and
+// tells whether this node is the leader. Without one, writes are never
+// queued.
+func (d *DegradedStore) SetCoordinator(c *ReconciliationCoordinator) {
+	d.coordinator = c
+}
+
+// Replayer returns the writer the ReconciliationCoordinator replays
+// pending writes through.
+func (d *DegradedStore) Replayer() VersionedRedisWriter {
+	return storeReplayer{s: d.Store}
+}
+
+// Available reports whether the backend is up.
+func (d *DegradedStore) Available() bool {
+	if d.dependency == DependencyRedis {
+		return d.mode.IsRedisAvailable()
+	}
+	return d.mode.IsDBAvailable()
+}
+
+// Start probes the backend every interval while it is down. On recovery
+// it replays pending writes before leaving degraded mode, then once more
+// for writes queued during that replay; failed replays are retried on
+// later ticks.
+func (d *DegradedStore) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.check(ctx)
+			}
+		}
+	}()
+}
+
+func (d *DegradedStore) check(ctx context.Context) {
+	if !d.Available() {
+		if err := d.probe(); err != nil {
+			return
+		}
+		d.replay(ctx)
+		d.markAvailable()
+	}
+	if d.mode.GetPendingWriteCount() > 0 {
+		d.replay(ctx)
+	}
+}
+
+func (d *DegradedStore) replay(ctx context.Context) {
+	if d.coordinator == nil {
+		return
+	}
+	if err := d.coordinator.ReconcileIfLeader(ctx); err != nil {
+		log.Printf("[DEGRADED MODE] Replay of pending writes failed: %v", err)
+	}
+}
+
+// probe reads an epoch to check the backend is up, reusing a result less
+// than probeCooldown old.
+func (d *DegradedStore) probe() error {
+	d.probeMu.Lock()
+	defer d.probeMu.Unlock()
+
+	if time.Since(d.lastProbe) < probeCooldown {
+		return d.lastProbeErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	_, err := d.Store.GetDurableEpoch(ctx, probeResource)
+	d.lastProbe, d.lastProbeErr = time.Now(), err
+	return err
+}
+
+// failed reports whether err means the backend is down, entering degraded
+// mode if so. Errors the store returns by contract never do.
+func (d *DegradedStore) failed(ctx context.Context, err error) bool {
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrVersionConflict) ||
+		errors.Is(err, store.ErrJobStatusConflict) || errors.Is(err, store.ErrInvalidListOptions) || ctx.Err() != nil {
+		return false
+	}
+	if d.probe() == nil {
+		return false
+	}
+	log.Printf("[DEGRADED MODE] Store call failed and backend is down: %v", err)
+	d.markUnavailable()
+	return true
+}
+
+func (d *DegradedStore) markUnavailable() {
+	if d.dependency == DependencyRedis {
+		d.mode.MarkRedisUnavailable()
+	} else {
+		d.mode.MarkDBUnavailable()
+	}
+	observability.StoreDegraded.Set(1)
+}
+
+func (d *DegradedStore) markAvailable() {
+	if d.dependency == DependencyRedis {
+		d.mode.MarkRedisAvailable()
+	} else {
+		d.mode.MarkDBAvailable()
+	}
+	observability.StoreDegraded.Set(0)
+}
+
+// canQueue returns nil if this node may queue writes while degraded.
+func (d *DegradedStore) canQueue() error {
+	if d.coordinator == nil || !d.coordinator.IsLeader() {
+		observability.DegradedWrites.WithLabelValues("rejected").Inc()
+		return fmt.Errorf("writes are only queued on the leader: %w", ErrStoreUnavailable)
+	}
+	return nil
+}
+
+// queue queues op, failing the write if it can't be journaled.
+func (d *DegradedStore) queue(key string, op *PendingOp) error {
+	if _, err := d.mode.QueueWrite(key, op); err != nil {
+		observability.DegradedWrites.WithLabelValues("rejected").Inc()
+		return fmt.Errorf("queue %s: %v: %w", op.Op, err, ErrStoreUnavailable)
+	}
+	observability.DegradedWrites.WithLabelValues("queued").Inc()
+	return nil
+}
+
+// --- Cache ---
+
+func listKey(tenantID string, resource store.Resource) string {
+	return "list:" + store.TenantKey(tenantID, resource, "")
+}
+
+func stateByNodeKey(tenantID, nodeID string) string {
+	return "by-node:" + store.TenantKey(tenantID, store.ResourceState, nodeID)
+}
+
+// cacheRecord caches a copy of v under key.
+func cacheRecord[T any](d *DegradedStore, key string, v *T) {
+	c := *v
+	d.mode.CacheValue(key, &c)
+}
+
+// cachedRecord returns a copy of the record cached under key.
+func cachedRecord[T any](d *DegradedStore, key string) (*T, bool) {
+	v, ok := d.mode.GetFromCache(key)
+	if !ok {
+		return nil, false
+	}
+	c := *v.(*T)
+	return &c, true
+}
+
+// fromCache serves a read while degraded.
+func fromCache[T any](d *DegradedStore, key string) (*T, error) {
+	v, ok := cachedRecord[T](d, key)
+	if !ok {
+		observability.DegradedCacheReads.WithLabelValues("miss").Inc()
+		return nil, fmt.Errorf("%s is not cached: %w", key, ErrStoreUnavailable)
+	}
+	observability.DegradedCacheReads.WithLabelValues("hit").Inc()
+	return v, nil
+}
+
+func cacheList[T any](d *DegradedStore, key string, items []*T) {
+	c := make([]*T, len(items))
+	for i, item := range items {
+		v := *item
+		c[i] = &v
+	}
+	d.mode.CacheValue(key, c)
+}
+
+func listFromCache[T any](d *DegradedStore, key string) ([]*T, error) {
+	v, ok := d.mode.GetFromCache(key)
+	if !ok {
+		observability.DegradedCacheReads.WithLabelValues("miss").Inc()
+		return nil, fmt.Errorf("%s is not cached: %w", key, ErrStoreUnavailable)
+	}
+	observability.DegradedCacheReads.WithLabelValues("hit").Inc()
+	items := v.([]*T)
+	c := make([]*T, len(items))
+	for i, item := range items {
+		v := *item
+		c[i] = &v
+	}
+	return c, nil
+}
+
+// queryKey caches a Query* page by everything that selected it.
+func queryKey(tenantID string, resource store.Resource, opts store.ListOptions) string {
+	selected, _ := json.Marshal(opts)
+	return "query:" + store.TenantKey(tenantID, resource, "") + string(selected)
+}
+
+// cachedPage is a Query* page as cached.
+type cachedPage[T any] struct {
+	items []*T
+	next  string
+}
+
+func cachePage[T any](d *DegradedStore, key string, items []*T, next string) {
+	c := make([]*T, len(items))
+	for i, item := range items {
+		v := *item
+		c[i] = &v
+	}
+	d.mode.CacheValue(key, &cachedPage[T]{items: c, next: next})
+}
+
+// pageFromCache serves a Query* page while degraded: the same query must
+// have been answered before the outage. Queued writes don't show in it.
+func pageFromCache[T any](d *DegradedStore, key string) ([]*T, string, error) {
+	v, ok := d.mode.GetFromCache(key)
+	if !ok {
+		observability.DegradedCacheReads.WithLabelValues("miss").Inc()
+		return nil, "", fmt.Errorf("%s is not cached: %w", key, ErrStoreUnavailable)
+	}
+	observability.DegradedCacheReads.WithLabelValues("hit").Inc()
+	page := v.(*cachedPage[T])
+	c := make([]*T, len(page.items))
+	for i, item := range page.items {
+		v := *item
+		c[i] = &v
+	}
+	return c, page.next, nil
+}
+
+// patchList replaces or appends item in a cached list, so lists served
+// while degraded show queued writes. Uncached lists are left alone.
+func patchList[T any](d *DegradedStore, key string, item *T, same func(a, b *T) bool) {
+	items, ok := d.mode.GetFromCache(key)
+	if !ok {
+		return
+	}
+	old := items.([]*T)
+	patched := make([]*T, 0, len(old)+1)
+	found := false
+	for _, v := range old {
+		if same(v, item) {
+			v, found = item, true
+		}
+		patched = append(patched, v)
+	}
+	if !found {
+		patched = append(patched, item)
+	}
+	cacheList(d, key, patched)
+}
+
+func sameAgent(a, b *store.Agent) bool        { return a.NodeID == b.NodeID }
+func sameState(a, b *store.DesiredState) bool { return a.StateID == b.StateID }
+
+func (d *DegradedStore) cacheAgent(tenantID string, agent *store.Agent) {
+	cacheRecord(d, store.TenantKey(tenantID, store.ResourceAgent, agent.NodeID), agent)
+	patchList(d, listKey(tenantID, store.ResourceAgent), agent, sameAgent)
+}
+
+func (d *DegradedStore) cacheState(tenantID string, state *store.DesiredState) {
+	cacheRecord(d, store.TenantKey(tenantID, store.ResourceState, state.StateID), state)
+	cacheRecord(d, stateByNodeKey(tenantID, state.NodeID), state)
+	patchList(d, listKey(tenantID, store.ResourceState), state, sameState)
+}
+
+// --- Reads ---
+
+func (d *DegradedStore) GetAgent(ctx context.Context, tenantID string, nodeID string) (*store.Agent, error) {
+	key := store.TenantKey(tenantID, store.ResourceAgent, nodeID)
+	if d.Available() {
+		agent, err := d.Store.GetAgent(ctx, tenantID, nodeID)
+		if err == nil {
+			if agent != nil {
+				cacheRecord(d, key, agent)
+			}
+			return agent, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	return fromCache[store.Agent](d, key)
+}
+
+func (d *DegradedStore) ListAgents(ctx context.Context, tenantID string) ([]*store.Agent, error) {
+	key := listKey(tenantID, store.ResourceAgent)
+	if d.Available() {
+		agents, err := d.Store.ListAgents(ctx, tenantID)
+		if err == nil {
+			cacheList(d, key, agents)
+			return agents, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	return listFromCache[store.Agent](d, key)
+}
+
+func (d *DegradedStore) QueryAgents(ctx context.Context, tenantID string, opts store.ListOptions) (*store.AgentPage, error) {
+	key := queryKey(tenantID, store.ResourceAgent, opts)
+	if d.Available() {
+		page, err := d.Store.QueryAgents(ctx, tenantID, opts)
+		if err == nil {
+			cachePage(d, key, page.Agents, page.NextCursor)
+			return page, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	agents, next, err := pageFromCache[store.Agent](d, key)
+	if err != nil {
+		return nil, err
+	}
+	return &store.AgentPage{Agents: agents, NextCursor: next}, nil
+}
+
+func (d *DegradedStore) GetState(ctx context.Context, tenantID string, stateID string) (*store.DesiredState, error) {
+	key := store.TenantKey(tenantID, store.ResourceState, stateID)
+	if d.Available() {
+		state, err := d.Store.GetState(ctx, tenantID, stateID)
+		if err == nil {
+			if state != nil {
+				cacheRecord(d, key, state)
+			}
+			return state, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	return fromCache[store.DesiredState](d, key)
+}
+
+func (d *DegradedStore) GetStateByNode(ctx context.Context, tenantID string, nodeID string) (*store.DesiredState, error) {
+	key := stateByNodeKey(tenantID, nodeID)
+	if d.Available() {
+		state, err := d.Store.GetStateByNode(ctx, tenantID, nodeID)
+		if err == nil {
+			if state != nil {
+				cacheRecord(d, key, state)
+			}
+			return state, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	return fromCache[store.DesiredState](d, key)
+}
+
+func (d *DegradedStore) ListStates(ctx context.Context, tenantID string) ([]*store.DesiredState, error) {
+	key := listKey(tenantID, store.ResourceState)
+	if d.Available() {
+		states, err := d.Store.ListStates(ctx, tenantID)
+		if err == nil {
+			cacheList(d, key, states)
+			return states, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	return listFromCache[store.DesiredState](d, key)
+}
+
+func (d *DegradedStore) QueryStates(ctx context.Context, tenantID string, opts store.ListOptions) (*store.StatePage, error) {
+	key := queryKey(tenantID, store.ResourceState, opts)
+	if d.Available() {
+		page, err := d.Store.QueryStates(ctx, tenantID, opts)
+		if err == nil {
+			cachePage(d, key, page.States, page.NextCursor)
+			return page, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	states, next, err := pageFromCache[store.DesiredState](d, key)
+	if err != nil {
+		return nil, err
+	}
+	return &store.StatePage{States: states, NextCursor: next}, nil
+}
+
+// CountStatesByStatus counts the cached state list while degraded.
+func (d *DegradedStore) CountStatesByStatus(ctx context.Context, tenantID string, status string) (int, error) {
+	if d.Available() {
+		n, err := d.Store.CountStatesByStatus(ctx, tenantID, status)
+		if err == nil || !d.failed(ctx, err) {
+			return n, err
+		}
+	}
+	states, err := listFromCache[store.DesiredState](d, listKey(tenantID, store.ResourceState))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, st := range states {
+		if st.Status == status {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (d *DegradedStore) GetJob(ctx context.Context, tenantID string, jobID string) (*store.Job, error) {
+	key := store.TenantKey(tenantID, store.ResourceJob, jobID)
+	if d.Available() {
+		job, err := d.Store.GetJob(ctx, tenantID, jobID)
+		if err == nil {
+			if job != nil {
+				cacheRecord(d, key, job)
+			}
+			return job, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	return fromCache[store.Job](d, key)
+}
+
+func (d *DegradedStore) QueryJobs(ctx context.Context, tenantID string, opts store.ListOptions) (*store.JobPage, error) {
+	key := queryKey(tenantID, store.ResourceJob, opts)
+	if d.Available() {
+		page, err := d.Store.QueryJobs(ctx, tenantID, opts)
+		if err == nil {
+			cachePage(d, key, page.Jobs, page.NextCursor)
+			return page, nil
+		}
+		if !d.failed(ctx, err) {
+			return nil, err
+		}
+	}
+	jobs, next, err := pageFromCache[store.Job](d, key)
+	if err != nil {
+		return nil, err
+	}
+	return &store.JobPage{Jobs: jobs, NextCursor: next}, nil
+}
+
+// --- Writes ---
+
+func (d *DegradedStore) UpsertAgent(ctx context.Context, tenantID string, agent *store.Agent) error {
+	if d.Available() {
+		err := d.Store.UpsertAgent(ctx, tenantID, agent)
+		if err == nil {
+			d.cacheAgent(tenantID, agent)
+			return nil
+		}
+		if !d.failed(ctx, err) {
+			return err
+		}
+	}
+	if err := d.canQueue(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	agent.TenantID = tenantID
+	if prev, ok := cachedRecord[store.Agent](d, store.TenantKey(tenantID, store.ResourceAgent, agent.NodeID)); ok {
+		agent.CreatedAt = prev.CreatedAt
+	} else if agent.CreatedAt.IsZero() {
+		agent.CreatedAt = now
+	}
+	agent.UpdatedAt = now
+
+	a := *agent
+	if err := d.queue(store.TenantKey(tenantID, store.ResourceAgent, agent.NodeID), &PendingOp{Op: OpUpsertAgent, TenantID: tenantID, Agent: &a}); err != nil {
+		return err
+	}
+	d.cacheAgent(tenantID, &a)
+	return nil
+}
+
+func (d *DegradedStore) UpdateAgentHeartbeat(ctx context.Context, tenantID string, nodeID string, t time.Time) error {
+	if d.Available() {
+		err := d.Store.UpdateAgentHeartbeat(ctx, tenantID, nodeID, t)
+		if err == nil || !d.failed(ctx, err) {
+			return err
+		}
+	}
+	if err := d.canQueue(); err != nil {
+		return err
+	}
+
+	key := store.TenantKey(tenantID, store.ResourceAgent, nodeID)
+	if err := d.queue(key, &PendingOp{Op: OpAgentHeartbeat, TenantID: tenantID, Agent: &store.Agent{NodeID: nodeID, LastHeartbeat: t}}); err != nil {
+		return err
+	}
+	if agent, ok := cachedRecord[store.Agent](d, key); ok {
+		agent.LastHeartbeat = t
+		d.cacheAgent(tenantID, agent)
+	}
+	return nil
+}
+
+// UpsertState queued while degraded takes the next version after the
+// cached state's, or 1 if the state isn't cached. Replay skips it if the
+// stored state is no longer at the cached version. An expected version
+// (see store.WithExpectedVersion) is checked against the cached state.
+func (d *DegradedStore) UpsertState(ctx context.Context, tenantID string, state *store.DesiredState) error {
+	if d.Available() {
+		err := d.Store.UpsertState(ctx, tenantID, state)
+		if err == nil {
+			d.cacheState(tenantID, state)
+			return nil
+		}
+		if !d.failed(ctx, err) {
+			return err
+		}
+	}
+	if err := d.canQueue(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	base := 0
+	state.TenantID = tenantID
+	prev, cached := cachedRecord[store.DesiredState](d, store.TenantKey(tenantID, store.ResourceState, state.StateID))
+	if cached {
+		base = prev.Version
+		state.CreatedAt = prev.CreatedAt
+	} else if state.CreatedAt.IsZero() {
+		state.CreatedAt = now
+	}
+	if want, ok := store.ExpectedVersionFromContext(ctx); ok {
+		if !cached && want != 0 {
+			observability.DegradedWrites.WithLabelValues("rejected").Inc()
+			return fmt.Errorf("state %s is not cached: %w", state.StateID, ErrStoreUnavailable)
+		}
+		if want != base {
+			return fmt.Errorf("state %s is at version %d, not %d: %w", state.StateID, base, want, store.ErrVersionConflict)
+		}
+	}
+	state.UpdatedAt = now
+	state.Version = base + 1
+
+	st := *state
+	err := d.queue(store.TenantKey(tenantID, store.ResourceState, state.StateID), &PendingOp{
+		Op:          OpUpsertState,
+		TenantID:    tenantID,
+		State:       &st,
+		BaseVersion: base,
+		Change:      store.ChangeInfoFromContext(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	d.cacheState(tenantID, &st)
+	return nil
+}
+
+func (d *DegradedStore) UpdateStateStatus(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int) error {
+	return d.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, nil)
+}
+
+// UpdateStateStatusWithEvent queued while degraded needs the state cached,
+// to check expectedVersion against. The event is queued with the status
+// and reaches the outbox when the write is replayed.
+func (d *DegradedStore) UpdateStateStatusWithEvent(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int, event *store.OutboxEvent) error {
+	if d.Available() {
+		err := d.Store.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, event)
+		if err == nil || !d.failed(ctx, err) {
+			return err
+		}
+	}
+	if err := d.canQueue(); err != nil {
+		return err
+	}
+
+	key := store.TenantKey(tenantID, store.ResourceState, stateID)
+	state, ok := cachedRecord[store.DesiredState](d, key)
+	if !ok {
+		observability.DegradedWrites.WithLabelValues("rejected").Inc()
+		return fmt.Errorf("state %s is not cached: %w", stateID, ErrStoreUnavailable)
+	}
+	if state.Version != expectedVersion {
+		return fmt.Errorf("state %s is at version %d, not %d: %w", stateID, state.Version, expectedVersion, store.ErrVersionConflict)
+	}
+	err := d.queue(key, &PendingOp{
+		Op:          OpStateStatus,
+		TenantID:    tenantID,
+		State:       &store.DesiredState{StateID: stateID, Status: status, LastError: lastError, LastChecked: lastChecked},
+		BaseVersion: expectedVersion,
+		Event:       event,
+	})
+	if err != nil {
+		return err
+	}
+	state.Status, state.LastError, state.LastChecked = status, lastError, lastChecked
+	d.cacheState(tenantID, state)
+	return nil
+}
+
+func (d *DegradedStore) CreateJob(ctx context.Context, tenantID string, job *store.Job) error {
+	if d.Available() {
+		err := d.Store.CreateJob(ctx, tenantID, job)
+		if err == nil {
+			cacheRecord(d, store.TenantKey(tenantID, store.ResourceJob, job.JobID), job)
+			return nil
+		}
+		if !d.failed(ctx, err) {
+			return err
+		}
+	}
+	if err := d.canQueue(); err != nil {
+		return err
+	}
+
+	job.TenantID = tenantID
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	j := *job
+	key := store.TenantKey(tenantID, store.ResourceJob, job.JobID)
+	if err := d.queue(key, &PendingOp{Op: OpCreateJob, TenantID: tenantID, Job: &j}); err != nil {
+		return err
+	}
+	cacheRecord(d, key, &j)
+	return nil
+}
+
+// UpdateJobStatus queued while degraded is timestamped when replayed: the
+// store sets StartedAt and FinishedAt itself.
+func (d *DegradedStore) UpdateJobStatus(ctx context.Context, tenantID string, jobID string, status string, exitCode int, stdout, stderr string) error {
+	if d.Available() {
+		err := d.Store.UpdateJobStatus(ctx, tenantID, jobID, status, exitCode, stdout, stderr)
+		if err == nil || !d.failed(ctx, err) {
+			return err
+		}
+	}
+	if err := d.canQueue(); err != nil {
+		return err
+	}
+
+	key := store.TenantKey(tenantID, store.ResourceJob, jobID)
+	expected, _ := store.ExpectedJobStatusFromContext(ctx)
+	job, cached := cachedRecord[store.Job](d, key)
+	if cached && expected != "" && job.Status != expected {
+		return fmt.Errorf("job %s is %s, expected %s: %w", jobID, job.Status, expected, store.ErrJobStatusConflict)
+	}
+	err := d.queue(key, &PendingOp{
+		Op:             OpJobStatus,
+		TenantID:       tenantID,
+		Job:            &store.Job{JobID: jobID, Status: status, ExitCode: exitCode, Stdout: stdout, Stderr: stderr},
+		ExpectedStatus: expected,
+	})
+	if err != nil {
+		return err
+	}
+	if cached {
+		now := time.Now()
+		job.Status = status
+		switch status {
+		case "running":
+			job.StartedAt = &now
+		case "completed", "failed", "cancelled":
+			job.FinishedAt = &now
+			job.ExitCode, job.Stdout, job.Stderr = exitCode, stdout, stderr
+		}
+		cacheRecord(d, key, job)
+	}
+	return nil
+}
+
+// --- Replay ---
+
+// storeReplayer applies pending writes to the store. Pending write
+// versions are local to this node, so it is no VersionedReader: conflicts
+// are caught against the store's own state versions instead, and a write
+// whose target was deleted or moved on is rejected, to be dead-lettered
+// rather than retried.
+type storeReplayer struct {
+	s store.Store
+}
+
+func (r storeReplayer) SetVersioned(ctx context.Context, key string, value VersionedValue, ttl time.Duration) error {
+	op, ok := value.Value.(*PendingOp)
+	if !ok {
+		return fmt.Errorf("pending write %s: unexpected value %T", key, value.Value)
+	}
+	err := r.apply(ctx, op)
+	switch {
+	case err == nil:
+		observability.DegradedWrites.WithLabelValues("replayed").Inc()
+		return nil
+	case errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrVersionConflict) || errors.Is(err, store.ErrJobStatusConflict):
+		observability.DegradedWrites.WithLabelValues("dead_lettered").Inc()
+		return fmt.Errorf("%w: %s: %v", ErrWriteRejected, op.Op, err)
+	default:
+		observability.DegradedWrites.WithLabelValues("failed").Inc()
+		return err
+	}
+}
+
+func (r storeReplayer) apply(ctx context.Context, op *PendingOp) error {
+	switch op.Op {
+	case OpUpsertAgent:
+		a := *op.Agent
+		return r.s.UpsertAgent(ctx, op.TenantID, &a)
+	case OpAgentHeartbeat:
+		return r.s.UpdateAgentHeartbeat(ctx, op.TenantID, op.Agent.NodeID, op.Agent.LastHeartbeat)
+	case OpUpsertState:
+		// Checked by the store as part of the upsert; a write made without
+		// the state cached (base 0) only creates it
+		ctx = store.WithExpectedVersion(ctx, op.BaseVersion)
+		st := *op.State
+		return r.s.UpsertState(store.WithChangeInfo(ctx, op.Change), op.TenantID, &st)
+	case OpStateStatus:
+		return r.s.UpdateStateStatusWithEvent(ctx, op.TenantID, op.State.StateID, op.State.Status, op.State.LastError, op.State.LastChecked, op.BaseVersion, op.Event)
+	case OpCreateJob:
+		// Already created if a replay was cut short before its ack
+		if existing, err := r.s.GetJob(ctx, op.TenantID, op.Job.JobID); err != nil || existing != nil {
+			return err
+		}
+		j := *op.Job
+		return r.s.CreateJob(ctx, op.TenantID, &j)
+	case OpJobStatus:
+		if op.ExpectedStatus != "" {
+			ctx = store.WithExpectedJobStatus(ctx, op.ExpectedStatus)
+		}
+		return r.s.UpdateJobStatus(ctx, op.TenantID, op.Job.JobID, op.Job.Status, op.Job.ExitCode, op.Job.Stdout, op.Job.Stderr)
+	}
+	return fmt.Errorf("unknown pending write %q", op.Op)
+}
+
+// DecodePendingOp decodes a journaled PendingOp, for OpenWAL.
+func DecodePendingOp(raw json.RawMessage) (interface{}, error) {
+	var op PendingOp
+	if err := json.Unmarshal(raw, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
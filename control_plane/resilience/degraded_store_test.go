@@ -0,0 +1,250 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+var errConnRefused = errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+
+// outageStore fails every call the test makes while down is set.
+type outageStore struct {
+	*store.MemoryStore
+	down atomic.Bool
+}
+
+func (o *outageStore) err() error {
+	if o.down.Load() {
+		return errConnRefused
+	}
+	return nil
+}
+
+func (o *outageStore) GetDurableEpoch(ctx context.Context, resourceID string) (int64, error) {
+	if err := o.err(); err != nil {
+		return 0, err
+	}
+	return o.MemoryStore.GetDurableEpoch(ctx, resourceID)
+}
+
+func (o *outageStore) GetState(ctx context.Context, tenantID, stateID string) (*store.DesiredState, error) {
+	if err := o.err(); err != nil {
+		return nil, err
+	}
+	return o.MemoryStore.GetState(ctx, tenantID, stateID)
+}
+
+func (o *outageStore) ListStates(ctx context.Context, tenantID string) ([]*store.DesiredState, error) {
+	if err := o.err(); err != nil {
+		return nil, err
+	}
+	return o.MemoryStore.ListStates(ctx, tenantID)
+}
+
+func (o *outageStore) UpsertState(ctx context.Context, tenantID string, state *store.DesiredState) error {
+	if err := o.err(); err != nil {
+		return err
+	}
+	return o.MemoryStore.UpsertState(ctx, tenantID, state)
+}
+
+func (o *outageStore) DeleteState(ctx context.Context, tenantID, stateID string) error {
+	if err := o.err(); err != nil {
+		return err
+	}
+	return o.MemoryStore.DeleteState(ctx, tenantID, stateID)
+}
+
+func (o *outageStore) UpsertAgent(ctx context.Context, tenantID string, agent *store.Agent) error {
+	if err := o.err(); err != nil {
+		return err
+	}
+	return o.MemoryStore.UpsertAgent(ctx, tenantID, agent)
+}
+
+func (o *outageStore) UpdateAgentHeartbeat(ctx context.Context, tenantID, nodeID string, t time.Time) error {
+	if err := o.err(); err != nil {
+		return err
+	}
+	return o.MemoryStore.UpdateAgentHeartbeat(ctx, tenantID, nodeID, t)
+}
+
+func (o *outageStore) CreateJob(ctx context.Context, tenantID string, job *store.Job) error {
+	if err := o.err(); err != nil {
+		return err
+	}
+	return o.MemoryStore.CreateJob(ctx, tenantID, job)
+}
+
+func TestDegradedStoreQueuesAndReplays(t *testing.T) {
+	ctx := context.Background()
+	backend := &outageStore{MemoryStore: store.NewMemoryStore()}
+	mode := NewDegradedMode()
+	ds := NewDegradedStore(backend, mode, DependencyRedis, time.Minute)
+	coordinator := NewReconciliationCoordinator(mode, ds.Replayer(), func() (*LeaderEpoch, error) {
+		return &LeaderEpoch{Epoch: 1}, nil
+	}, "node-1")
+	coordinator.UpdateLeadershipStatus(1, "node-1", true)
+	ds.SetCoordinator(coordinator)
+
+	// Reads while the backend is up fill the cache
+	ds.UpsertAgent(ctx, "acme", &store.Agent{NodeID: "n1", Status: "active"})
+	for _, id := range []string{"s1", "s2"} {
+		if err := ds.UpsertState(ctx, "acme", &store.DesiredState{StateID: id, NodeID: "n1", ApplyCmd: "apply v1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ds.CreateJob(ctx, "acme", &store.Job{JobID: "j1", NodeID: "n1", Status: "queued"})
+	ds.GetJob(ctx, "acme", "j1")
+	if states, err := ds.ListStates(ctx, "acme"); err != nil || len(states) != 2 {
+		t.Fatalf("ListStates = %v, %v", states, err)
+	}
+	jobsQuery := store.ListOptions{Status: "queued", Limit: 10}
+	if page, err := ds.QueryJobs(ctx, "acme", jobsQuery); err != nil || len(page.Jobs) != 1 {
+		t.Fatalf("QueryJobs = %+v, %v", page, err)
+	}
+
+	backend.down.Store(true)
+	got, err := ds.GetState(ctx, "acme", "s1")
+	if err != nil || got.ApplyCmd != "apply v1" {
+		t.Fatalf("GetState during outage = %+v, %v; want the cached state", got, err)
+	}
+	if !mode.IsDegraded() || ds.Available() {
+		t.Fatal("a failed call with the backend down did not enter degraded mode")
+	}
+	if page, err := ds.QueryJobs(ctx, "acme", jobsQuery); err != nil || len(page.Jobs) != 1 || page.Jobs[0].JobID != "j1" {
+		t.Errorf("QueryJobs during outage = %+v, %v; want the cached page", page, err)
+	}
+	if _, err := ds.QueryJobs(ctx, "acme", store.ListOptions{Status: "failed"}); !errors.Is(err, ErrStoreUnavailable) {
+		t.Errorf("uncached QueryJobs = %v, want ErrStoreUnavailable", err)
+	}
+
+	// Writes are queued and show up in reads
+	st := &store.DesiredState{StateID: "s1", NodeID: "n1", ApplyCmd: "apply v2"}
+	if err := ds.UpsertState(store.WithChangeInfo(ctx, store.ChangeInfo{Author: "alice"}), "acme", st); err != nil || st.Version != 2 {
+		t.Fatalf("UpsertState = %v, version %d; want it queued as version 2", err, st.Version)
+	}
+	if err := ds.UpdateStateStatus(ctx, "acme", "s1", "drifted", "", time.Now(), 1); !errors.Is(err, store.ErrVersionConflict) {
+		t.Errorf("UpdateStateStatus at a stale version = %v, want ErrVersionConflict", err)
+	}
+	if err := ds.UpdateStateStatus(ctx, "acme", "s1", "compliant", "", time.Now(), 2); err != nil {
+		t.Fatal(err)
+	}
+	ds.UpsertState(ctx, "acme", &store.DesiredState{StateID: "s2", NodeID: "n1", ApplyCmd: "apply mine"})
+	// Not cached, so queued as a create
+	if err := ds.UpsertState(ctx, "acme", &store.DesiredState{StateID: "s3", NodeID: "n1", ApplyCmd: "apply mine"}); err != nil {
+		t.Fatal(err)
+	}
+	heartbeat := time.Now().Add(-time.Second).UTC().Truncate(time.Millisecond)
+	if err := ds.UpdateAgentHeartbeat(ctx, "acme", "n1", heartbeat); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.CreateJob(ctx, "acme", &store.Job{JobID: "j2", NodeID: "n1", Status: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.UpdateJobStatus(ctx, "acme", "j1", "completed", 0, "ok", ""); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ds.CountStatesByStatus(ctx, "acme", "compliant"); err != nil || n != 1 {
+		t.Errorf("CountStatesByStatus = %d, %v; want the queued status counted", n, err)
+	}
+	if job, err := ds.GetJob(ctx, "acme", "j1"); err != nil || job.Status != "completed" {
+		t.Errorf("GetJob = %+v, %v", job, err)
+	}
+
+	// What can't be served or queued fails
+	if _, err := ds.GetState(ctx, "acme", "s9"); !errors.Is(err, ErrStoreUnavailable) {
+		t.Errorf("uncached GetState = %v, want ErrStoreUnavailable", err)
+	}
+	if err := ds.DeleteState(ctx, "acme", "s2"); err == nil {
+		t.Error("DeleteState succeeded during the outage")
+	}
+	coordinator.UpdateLeadershipStatus(1, "node-2", false)
+	if err := ds.UpsertAgent(ctx, "acme", &store.Agent{NodeID: "n2"}); !errors.Is(err, ErrStoreUnavailable) {
+		t.Errorf("follower UpsertAgent = %v, want ErrStoreUnavailable", err)
+	}
+	coordinator.UpdateLeadershipStatus(1, "node-1", true)
+	if n := mode.GetPendingWriteCount(); n != 7 {
+		t.Fatalf("%d pending writes, want 7", n)
+	}
+
+	// Another writer reached s2 first, and created s3: the queued writes
+	// must not win
+	backend.MemoryStore.UpsertState(ctx, "acme", &store.DesiredState{StateID: "s2", NodeID: "n1", ApplyCmd: "apply theirs"})
+	backend.MemoryStore.UpsertState(ctx, "acme", &store.DesiredState{StateID: "s3", NodeID: "n1", ApplyCmd: "apply theirs"})
+
+	backend.down.Store(false)
+	ds.lastProbe = time.Time{}
+	ds.check(ctx)
+	if !ds.Available() || mode.IsDegraded() || mode.GetPendingWriteCount() != 0 {
+		t.Fatalf("after recovery: available %v, degraded %v, %d pending", ds.Available(), mode.IsDegraded(), mode.GetPendingWriteCount())
+	}
+
+	s1, _ := backend.MemoryStore.GetState(ctx, "acme", "s1")
+	if s1.Version != 2 || s1.ApplyCmd != "apply v2" || s1.Status != "compliant" {
+		t.Errorf("replayed s1 = %+v", s1)
+	}
+	if revs, _ := backend.MemoryStore.ListStateRevisions(ctx, "acme", "s1"); len(revs) != 2 || revs[1].Author != "alice" {
+		t.Errorf("s1 revisions = %+v; want the replayed upsert attributed", revs)
+	}
+	if s2, _ := backend.MemoryStore.GetState(ctx, "acme", "s2"); s2.ApplyCmd != "apply theirs" {
+		t.Errorf("s2 = %+v; the stale queued write overwrote a newer one", s2)
+	}
+	if s3, _ := backend.MemoryStore.GetState(ctx, "acme", "s3"); s3.ApplyCmd != "apply theirs" || s3.Version != 1 {
+		t.Errorf("s3 = %+v; the queued create overwrote a state created meanwhile", s3)
+	}
+	if agent, _ := backend.MemoryStore.GetAgent(ctx, "acme", "n1"); !agent.LastHeartbeat.Equal(heartbeat) {
+		t.Errorf("agent heartbeat = %v, want %v", agent.LastHeartbeat, heartbeat)
+	}
+	if job, _ := backend.MemoryStore.GetJob(ctx, "acme", "j1"); job.Status != "completed" || job.Stdout != "ok" {
+		t.Errorf("j1 = %+v", job)
+	}
+	if job, _ := backend.MemoryStore.GetJob(ctx, "acme", "j2"); job == nil {
+		t.Error("queued job j2 was not created")
+	}
+}
+
+func TestDegradedStoreQueuesOutboxEvent(t *testing.T) {
+	ctx := context.Background()
+	backend := &outageStore{MemoryStore: store.NewMemoryStore()}
+	mode := NewDegradedMode()
+	ds := NewDegradedStore(backend, mode, DependencyRedis, time.Minute)
+	coordinator := NewReconciliationCoordinator(mode, ds.Replayer(), func() (*LeaderEpoch, error) {
+		return &LeaderEpoch{Epoch: 1}, nil
+	}, "node-1")
+	coordinator.UpdateLeadershipStatus(1, "node-1", true)
+	ds.SetCoordinator(coordinator)
+
+	if err := ds.UpsertState(ctx, "acme", &store.DesiredState{StateID: "s1", NodeID: "n1"}); err != nil {
+		t.Fatal(err)
+	}
+	backend.down.Store(true)
+	ds.GetState(ctx, "acme", "s1")
+	if !mode.IsDegraded() {
+		t.Fatal("a failed call with the backend down did not enter degraded mode")
+	}
+
+	// The status and its event are queued as one write
+	event := &store.OutboxEvent{ID: "e1", Topic: "fluxforge.events.state.transition", TenantID: "acme", Payload: []byte(`{}`)}
+	if err := ds.UpdateStateStatusWithEvent(ctx, "acme", "s1", "drifted", "", time.Now(), 1, event); err != nil {
+		t.Fatal(err)
+	}
+	if n := mode.GetPendingWriteCount(); n != 1 {
+		t.Fatalf("%d pending writes, want 1", n)
+	}
+
+	backend.down.Store(false)
+	ds.lastProbe = time.Time{}
+	ds.check(ctx)
+	if s1, _ := backend.MemoryStore.GetState(ctx, "acme", "s1"); s1.Status != "drifted" {
+		t.Errorf("replayed s1 = %+v", s1)
+	}
+	if events, _ := backend.MemoryStore.ReadOutbox(ctx, 0); len(events) != 1 || events[0].ID != "e1" {
+		t.Errorf("outbox = %+v; want the event queued with the status", events)
+	}
+}
@@ -1,9 +1,15 @@
 package resilience
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrWriteRejected is returned by a VersionedRedisWriter for a pending
+// write the backend will never accept, such as one that lost a version
+// check. Reconciliation dead-letters it rather than retrying.
+var ErrWriteRejected = errors.New("pending write rejected")
+
 // ReconciliationError represents reconciliation failures
 type ReconciliationError struct {
 	Total   int
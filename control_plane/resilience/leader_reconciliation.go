@@ -61,6 +61,13 @@ func (c *ReconciliationCoordinator) UpdateLeadershipStatus(epoch int64, leaderID
 		epoch, leaderID, isLeader)
 }
 
+// IsLeader reports whether this node is the current leader
+func (c *ReconciliationCoordinator) IsLeader() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.isLeader
+}
+
 // ReconcileIfLeader reconciles only if this node is current leader
 // CRITICAL: Validates epoch throughout reconciliation to detect leadership changes
 func (c *ReconciliationCoordinator) ReconcileIfLeader(ctx context.Context) error {
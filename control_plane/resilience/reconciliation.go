@@ -2,7 +2,10 @@ package resilience
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"time"
 )
 
@@ -15,11 +18,17 @@ type VersionedValue struct {
 
 // ReconcilePendingWrites reconciles local cache writes to Redis after recovery
 // CRITICAL: Uses versioning to prevent stale overwrites
+//
+// Writes are replayed in version order. A write the backend rejects, or
+// one older than the max pending age if set, is dead-lettered, not lost.
 func (d *DegradedMode) ReconcilePendingWrites(ctx context.Context, redisStore VersionedRedisWriter) error {
 	d.mu.Lock()
 	pending := make([]PendingWrite, len(d.pendingWrites))
 	copy(pending, d.pendingWrites)
+	maxAge := d.maxPendingAge
+	deadLetters := d.deadLetters
 	d.mu.Unlock()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
 
 	if len(pending) == 0 {
 		log.Printf("[DEGRADED MODE] No pending writes to reconcile")
@@ -31,52 +40,56 @@ func (d *DegradedMode) ReconcilePendingWrites(ctx context.Context, redisStore Ve
 	successCount := 0
 	failCount := 0
 	skippedCount := 0
+	deadCount := 0
 
-	for i, write := range pending {
+	// giveUp dead-letters a write and marks it reconciled; one that can't
+	// be recorded stays pending and fails this pass
+	giveUp := func(write PendingWrite, reason string) {
+		if err := recordDeadLetter(deadLetters, write, reason); err != nil {
+			log.Printf("[DEGRADED MODE] Failed to dead-letter write %s: %v", write.Key, err)
+			failCount++
+			return
+		}
+		d.markReconciled(write.Version)
+		deadCount++
+	}
+
+	for _, write := range pending {
 		// Skip already reconciled (idempotent reconciliation)
 		if write.Reconciled {
 			skippedCount++
 			continue
 		}
 
-		// Check if write is too old (stale)
-		age := time.Since(time.Unix(write.Timestamp, 0))
-		if age > 5*time.Minute {
-			log.Printf("[DEGRADED MODE] Skipping stale write: %s (age: %v)", write.Key, age)
-
-			// Mark as reconciled to prevent retry
-			d.mu.Lock()
-			if i < len(d.pendingWrites) {
-				d.pendingWrites[i].Reconciled = true
-			}
-			d.mu.Unlock()
-
-			failCount++
+		// Check if write is too old (stale), if a max age is set. Journaled
+		// writes from before a restart are exempt: a long outage or
+		// downtime must not turn into lost writes
+		if age := time.Since(time.Unix(write.Timestamp, 0)); maxAge > 0 && age > maxAge && !write.Recovered {
+			giveUp(write, fmt.Sprintf("stale: queued %v ago", age.Round(time.Second)))
 			continue
 		}
 
-		// CRITICAL: Check existing value version before overwriting
-		existing, err := redisStore.GetVersioned(ctx, write.Key)
-		if err != nil && err.Error() != "not found" {
-			log.Printf("[DEGRADED MODE] Failed to get existing value for %s: %v", write.Key, err)
-			failCount++
-			continue
-		}
+		// CRITICAL: Check existing value version before overwriting, where
+		// the writer's versions are comparable with ours
+		if reader, ok := redisStore.(VersionedReader); ok {
+			existing, err := reader.GetVersioned(ctx, write.Key)
+			if err != nil && err.Error() != "not found" {
+				log.Printf("[DEGRADED MODE] Failed to get existing value for %s: %v", write.Key, err)
+				failCount++
+				continue
+			}
 
-		// CRITICAL: Only reconcile if our version is newer
-		if existing != nil && existing.Version >= write.Version {
-			log.Printf("[DEGRADED MODE] Skipping write %s: Redis has newer version (%d >= %d)",
-				write.Key, existing.Version, write.Version)
+			// CRITICAL: Only reconcile if our version is newer
+			if existing != nil && existing.Version >= write.Version {
+				log.Printf("[DEGRADED MODE] Skipping write %s: Redis has newer version (%d >= %d)",
+					write.Key, existing.Version, write.Version)
 
-			// Mark as reconciled (no need to retry)
-			d.mu.Lock()
-			if i < len(d.pendingWrites) {
-				d.pendingWrites[i].Reconciled = true
-			}
-			d.mu.Unlock()
+				// Mark as reconciled (no need to retry)
+				d.markReconciled(write.Version)
 
-			skippedCount++
-			continue
+				skippedCount++
+				continue
+			}
 		}
 
 		// Safe to reconcile: our version is newer
@@ -86,7 +99,11 @@ func (d *DegradedMode) ReconcilePendingWrites(ctx context.Context, redisStore Ve
 			Timestamp: write.Timestamp,
 		}
 
-		err = redisStore.SetVersioned(ctx, write.Key, versionedValue, write.TTL)
+		err := redisStore.SetVersioned(ctx, write.Key, versionedValue, write.TTL)
+		if errors.Is(err, ErrWriteRejected) {
+			giveUp(write, err.Error())
+			continue
+		}
 		if err != nil {
 			log.Printf("[DEGRADED MODE] Failed to reconcile write %s: %v", write.Key, err)
 			failCount++
@@ -94,29 +111,36 @@ func (d *DegradedMode) ReconcilePendingWrites(ctx context.Context, redisStore Ve
 		}
 
 		// Mark as reconciled atomically
-		d.mu.Lock()
-		if i < len(d.pendingWrites) {
-			d.pendingWrites[i].Reconciled = true
-		}
-		d.mu.Unlock()
+		d.markReconciled(write.Version)
 
 		successCount++
 		log.Printf("[DEGRADED MODE] Reconciled %s (version: %d)", write.Key, write.Version)
 	}
 
-	// Clean up reconciled writes
+	// Clean up reconciled writes, journaling that they are done first
 	d.mu.Lock()
 	unreconciled := make([]PendingWrite, 0)
+	var done []int64
 	for _, write := range d.pendingWrites {
 		if !write.Reconciled {
 			unreconciled = append(unreconciled, write)
+		} else {
+			done = append(done, write.Version)
+		}
+	}
+	if d.journal != nil && len(done) > 0 {
+		if err := d.journal.Ack(done); err != nil {
+			// Kept in the journal: replayed again after a restart
+			log.Printf("[DEGRADED MODE] Failed to journal reconciled writes: %v", err)
+		} else if err := d.journal.Compact(); err != nil {
+			log.Printf("[DEGRADED MODE] Failed to compact journal: %v", err)
 		}
 	}
 	d.pendingWrites = unreconciled
 	d.mu.Unlock()
 
-	log.Printf("[DEGRADED MODE] Reconciliation complete: %d succeeded, %d skipped (newer version), %d failed",
-		successCount, skippedCount, failCount)
+	log.Printf("[DEGRADED MODE] Reconciliation complete: %d succeeded, %d skipped (newer version), %d dead-lettered, %d failed",
+		successCount, skippedCount, deadCount, failCount)
 
 	if failCount > 0 {
 		return &ReconciliationError{
@@ -130,12 +154,31 @@ func (d *DegradedMode) ReconcilePendingWrites(ctx context.Context, redisStore Ve
 	return nil
 }
 
+// markReconciled marks the pending write with version reconciled. Writes
+// are found by version, as the slice may have changed since the copy.
+func (d *DegradedMode) markReconciled(version int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.pendingWrites {
+		if d.pendingWrites[i].Version == version {
+			d.pendingWrites[i].Reconciled = true
+			return
+		}
+	}
+}
+
 // VersionedRedisWriter interface for versioned reconciliation
 type VersionedRedisWriter interface {
-	GetVersioned(ctx context.Context, key string) (*VersionedValue, error)
 	SetVersioned(ctx context.Context, key string, value VersionedValue, ttl time.Duration) error
 }
 
+// VersionedReader is implemented by a VersionedRedisWriter whose stored
+// versions are pending write versions: reconciliation then skips writes
+// the backend already holds a newer version of.
+type VersionedReader interface {
+	GetVersioned(ctx context.Context, key string) (*VersionedValue, error)
+}
+
 // MarkRedisAvailableWithReconciliation marks Redis as available and triggers reconciliation
 func (d *DegradedMode) MarkRedisAvailableWithReconciliation(ctx context.Context, redisStore VersionedRedisWriter) error {
 	d.mu.Lock()
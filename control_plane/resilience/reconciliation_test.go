@@ -3,6 +3,9 @@ package resilience
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -174,6 +177,70 @@ func TestLeaderEpochValidation(t *testing.T) {
 	})
 }
 
+// TestReconcileDeadLettersWritesItGivesUpOn checks that no pending write
+// is acked without being applied or dead-lettered.
+func TestReconcileDeadLettersWritesItGivesUpOn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	dl, err := OpenDeadLetterFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+
+	mode := NewDegradedMode()
+	mode.SetDeadLetters(dl)
+	mode.SetInCacheWithTTL("old", "queued long ago", 0)
+	mode.SetInCacheWithTTL("conflict", "lost a version check", 0)
+	mode.SetInCacheWithTTL("ok", "applied", 0)
+	mode.mu.Lock()
+	mode.pendingWrites[0].Timestamp = time.Now().Add(-time.Hour).Unix()
+	mode.mu.Unlock()
+
+	// No age limit by default: an old write is still replayed
+	writer := &rejectingWriter{mockVersionedRedis: newMockVersionedRedis(), reject: "conflict"}
+	if err := mode.ReconcilePendingWrites(context.Background(), writer); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := writer.data["old"]; !ok {
+		t.Error("an old write was dropped with no max pending age set")
+	}
+	if _, ok := writer.data["ok"]; !ok || mode.GetPendingWriteCount() != 0 {
+		t.Fatalf("ok applied %v, %d pending", ok, mode.GetPendingWriteCount())
+	}
+
+	// With one, it is dead-lettered
+	mode.SetMaxPendingAge(time.Minute)
+	mode.SetInCacheWithTTL("stale", "queued long ago", 0)
+	mode.mu.Lock()
+	mode.pendingWrites[0].Timestamp = time.Now().Add(-time.Hour).Unix()
+	mode.mu.Unlock()
+	if err := mode.ReconcilePendingWrites(context.Background(), writer); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"key":"conflict"`) || !strings.Contains(lines[1], `"key":"stale"`) {
+		t.Errorf("dead letters = %q", lines)
+	}
+}
+
+// rejectingWriter rejects writes to one key as a lost version check.
+type rejectingWriter struct {
+	*mockVersionedRedis
+	reject string
+}
+
+func (w *rejectingWriter) SetVersioned(ctx context.Context, key string, value VersionedValue, ttl time.Duration) error {
+	if key == w.reject {
+		return fmt.Errorf("%w: version conflict", ErrWriteRejected)
+	}
+	return w.mockVersionedRedis.SetVersioned(ctx, key, value, ttl)
+}
+
 // Mock implementations for testing
 
 type mockVersionedRedis struct {
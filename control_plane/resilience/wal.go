@@ -0,0 +1,396 @@
+package resilience
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrWALCorrupt is returned by OpenWAL for a damaged record anywhere but
+// at the end of the last segment, which is where a crash mid-append
+// leaves one.
+var ErrWALCorrupt = errors.New("write-ahead log corrupt")
+
+const (
+	walSegmentPrefix = "wal-"
+	walSegmentSuffix = ".log"
+	walHeaderSize    = 8 // payload length, CRC-32C of the payload
+
+	// DefaultWALSegmentSize is the size a segment is rotated at.
+	DefaultWALSegmentSize = 4 << 20
+	// maxWALRecordSize bounds a record read back, so a damaged length
+	// can't allocate gigabytes
+	maxWALRecordSize = 64 << 20
+)
+
+var walCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// walRecord is one WAL entry: a pending write, or the versions of writes
+// that have been reconciled (or dropped) and must not be replayed.
+type walRecord struct {
+	Type      string          `json:"type"` // "write" or "ack"
+	Version   int64           `json:"version,omitempty"`
+	Key       string          `json:"key,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Timestamp int64           `json:"timestamp,omitempty"`
+	TTL       time.Duration   `json:"ttl,omitempty"`
+	Acked     []int64         `json:"acked,omitempty"`
+}
+
+type walSegment struct {
+	seq     uint64
+	path    string
+	pending int // writes in this segment not yet acked
+}
+
+// WAL journals DegradedMode's pending writes to an append-only log of
+// checksummed records in a directory, so they survive a crash. Records
+// are appended to the newest segment, which is rotated at a size limit;
+// every append is synced before it returns. Once reconciled, writes are
+// acked, and segments whose writes are all acked are deleted by Compact,
+// oldest first: an ack only ever follows its write, so deleting in order
+// never resurrects a write.
+type WAL struct {
+	mu sync.Mutex
+
+	dir         string
+	decode      func(json.RawMessage) (interface{}, error)
+	maxSize     int64
+	segments    []*walSegment // oldest first; the last is active
+	active      *os.File
+	activeSize  int64
+	writes      map[int64]PendingWrite // unacked, by version
+	segmentOf   map[int64]*walSegment
+	lastVersion int64
+}
+
+// OpenWAL opens the log in dir, creating it if needed, and loads the
+// writes it holds that were never acked. decode turns a journaled value
+// back into what was queued; nil decodes JSON into interface{}.
+func OpenWAL(dir string, decode func(json.RawMessage) (interface{}, error)) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if decode == nil {
+		decode = func(raw json.RawMessage) (interface{}, error) {
+			var v interface{}
+			err := json.Unmarshal(raw, &v)
+			return v, err
+		}
+	}
+	w := &WAL{
+		dir:       dir,
+		decode:    decode,
+		maxSize:   DefaultWALSegmentSize,
+		writes:    make(map[int64]PendingWrite),
+		segmentOf: make(map[int64]*walSegment),
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, walSegmentPrefix+"*"+walSegmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		var seq uint64
+		base := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), walSegmentPrefix), walSegmentSuffix)
+		if _, err := fmt.Sscanf(base, "%d", &seq); err != nil {
+			return nil, fmt.Errorf("%w: unexpected file %s", ErrWALCorrupt, name)
+		}
+		w.segments = append(w.segments, &walSegment{seq: seq, path: name})
+	}
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i].seq < w.segments[j].seq })
+
+	for i, seg := range w.segments {
+		if err := w.load(seg, i == len(w.segments)-1); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(w.segments) == 0 {
+		if err := w.rotate(); err != nil {
+			return nil, err
+		}
+	} else {
+		seg := w.segments[len(w.segments)-1]
+		f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		w.active, w.activeSize = f, info.Size()
+	}
+	return w, nil
+}
+
+// load replays one segment. A damaged tail of the last segment is a
+// crash mid-append: it is truncated away.
+func (w *WAL) load(seg *walSegment, last bool) error {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		rec, n, err := readWALRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if !last {
+				return fmt.Errorf("%w: %s at offset %d: %v", ErrWALCorrupt, seg.path, offset, err)
+			}
+			log.Printf("[DEGRADED MODE] WAL: truncating damaged tail of %s at offset %d: %v", seg.path, offset, err)
+			return os.Truncate(seg.path, offset)
+		}
+		if err := w.apply(seg, rec); err != nil {
+			return fmt.Errorf("%w: %s at offset %d: %v", ErrWALCorrupt, seg.path, offset, err)
+		}
+		offset += n
+	}
+}
+
+func readWALRecord(r io.Reader) (*walRecord, int64, error) {
+	var header [walHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, errors.New("truncated header")
+		}
+		return nil, 0, err // io.EOF: clean end
+	}
+	size := binary.LittleEndian.Uint32(header[:4])
+	if size > maxWALRecordSize {
+		return nil, 0, fmt.Errorf("record of %d bytes", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, errors.New("truncated record")
+	}
+	if crc32.Checksum(payload, walCRC) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+	var rec walRecord
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return nil, 0, err
+	}
+	return &rec, int64(walHeaderSize + size), nil
+}
+
+// apply folds a record into the in-memory view of the log.
+func (w *WAL) apply(seg *walSegment, rec *walRecord) error {
+	switch rec.Type {
+	case "write":
+		value, err := w.decode(rec.Value)
+		if err != nil {
+			return fmt.Errorf("write %d: %v", rec.Version, err)
+		}
+		w.writes[rec.Version] = PendingWrite{
+			Key:       rec.Key,
+			Value:     value,
+			Timestamp: rec.Timestamp,
+			TTL:       rec.TTL,
+			Version:   rec.Version,
+			Recovered: true,
+		}
+		w.segmentOf[rec.Version] = seg
+		seg.pending++
+		if rec.Version > w.lastVersion {
+			w.lastVersion = rec.Version
+		}
+	case "ack":
+		for _, v := range rec.Acked {
+			if s, ok := w.segmentOf[v]; ok {
+				s.pending--
+				delete(w.segmentOf, v)
+				delete(w.writes, v)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown record type %q", rec.Type)
+	}
+	return nil
+}
+
+// SetMaxSegmentSize sets the size segments are rotated at.
+func (w *WAL) SetMaxSegmentSize(size int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.maxSize = size
+}
+
+// Pending returns the writes not yet acked, oldest first.
+func (w *WAL) Pending() []PendingWrite {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pending := make([]PendingWrite, 0, len(w.writes))
+	for _, write := range w.writes {
+		pending = append(pending, write)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+	return pending
+}
+
+// LastVersion returns the highest write version the log has held, so
+// versions stay increasing across restarts.
+func (w *WAL) LastVersion() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastVersion
+}
+
+// Append journals a pending write.
+func (w *WAL) Append(write PendingWrite) error {
+	value, err := json.Marshal(write.Value)
+	if err != nil {
+		return fmt.Errorf("encode pending write %s: %w", write.Key, err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rec := &walRecord{Type: "write", Version: write.Version, Key: write.Key, Value: value, Timestamp: write.Timestamp, TTL: write.TTL}
+	if err := w.append(rec); err != nil {
+		return err
+	}
+	seg := w.segments[len(w.segments)-1]
+	write.Reconciled = false
+	w.writes[write.Version] = write
+	w.segmentOf[write.Version] = seg
+	seg.pending++
+	if write.Version > w.lastVersion {
+		w.lastVersion = write.Version
+	}
+	return nil
+}
+
+// Ack journals that writes were reconciled or dropped, so they are not
+// replayed after a restart.
+func (w *WAL) Ack(versions []int64) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.append(&walRecord{Type: "ack", Acked: versions}); err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if seg, ok := w.segmentOf[v]; ok {
+			seg.pending--
+			delete(w.segmentOf, v)
+			delete(w.writes, v)
+		}
+	}
+	return nil
+}
+
+// append writes and syncs one record, rotating first if the active
+// segment is full. w.mu must be held.
+func (w *WAL) append(rec *walRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	size := int64(walHeaderSize + len(payload))
+	if w.activeSize > 0 && w.activeSize+size > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	frame := make([]byte, walHeaderSize, size)
+	binary.LittleEndian.PutUint32(frame[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:], crc32.Checksum(payload, walCRC))
+	frame = append(frame, payload...)
+	if _, err := w.active.Write(frame); err != nil {
+		return fmt.Errorf("append to write-ahead log: %w", err)
+	}
+	if err := w.active.Sync(); err != nil {
+		return fmt.Errorf("sync write-ahead log: %w", err)
+	}
+	w.activeSize += size
+	return nil
+}
+
+// rotate starts a new active segment. w.mu must be held (or w unshared).
+func (w *WAL) rotate() error {
+	var seq uint64 = 1
+	if n := len(w.segments); n > 0 {
+		seq = w.segments[n-1].seq + 1
+	}
+	path := filepath.Join(w.dir, fmt.Sprintf("%s%020d%s", walSegmentPrefix, seq, walSegmentSuffix))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		f.Close()
+		return err
+	}
+	if w.active != nil {
+		w.active.Close()
+	}
+	w.segments = append(w.segments, &walSegment{seq: seq, path: path})
+	w.active, w.activeSize = f, 0
+	return nil
+}
+
+// Compact deletes segments, oldest first, whose writes are all acked.
+// With nothing pending, the active segment is rotated so it goes too.
+func (w *WAL) Compact() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.writes) == 0 && w.activeSize > 0 {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	removed := 0
+	for len(w.segments) > 1 && w.segments[0].pending == 0 {
+		if err := os.Remove(w.segments[0].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		w.segments = w.segments[1:]
+		removed++
+	}
+	if removed == 0 {
+		return nil
+	}
+	return syncDir(w.dir)
+}
+
+// Close closes the active segment.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.active.Close()
+}
+
+// syncDir makes file creations and removals in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
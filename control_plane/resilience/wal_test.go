@@ -0,0 +1,159 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func segments(t *testing.T, dir string) []string {
+	t.Helper()
+	names, err := filepath.Glob(filepath.Join(dir, walSegmentPrefix+"*"+walSegmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestWALSurvivesRestartAndTornTail(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := OpenWAL(dir, DecodePendingOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v := int64(1); v <= 3; v++ {
+		op := &PendingOp{Op: OpUpsertState, TenantID: "acme", State: &store.DesiredState{StateID: "s1", ApplyCmd: "apply"}, BaseVersion: int(v)}
+		if err := wal.Append(PendingWrite{Key: "k", Value: op, Version: v, Timestamp: time.Now().Unix()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wal.Ack([]int64{2}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	// A crash mid-append leaves half a record behind
+	names := segments(t, dir)
+	f, _ := os.OpenFile(names[len(names)-1], os.O_WRONLY|os.O_APPEND, 0o644)
+	f.Write([]byte{0x40, 0, 0, 0, 1, 2})
+	f.Close()
+
+	wal, err = OpenWAL(dir, DecodePendingOp)
+	if err != nil {
+		t.Fatalf("reopen with a torn tail: %v", err)
+	}
+	pending := wal.Pending()
+	if len(pending) != 2 || pending[0].Version != 1 || pending[1].Version != 3 || wal.LastVersion() != 3 {
+		t.Fatalf("pending after restart = %+v, last version %d", pending, wal.LastVersion())
+	}
+	if op, ok := pending[1].Value.(*PendingOp); !ok || op.State.StateID != "s1" || op.BaseVersion != 3 {
+		t.Errorf("decoded value = %#v", pending[1].Value)
+	}
+	// The tail was cut off: appends after it read back
+	if err := wal.Append(PendingWrite{Key: "k", Value: &PendingOp{Op: OpCreateJob}, Version: 4}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+	if wal, err = OpenWAL(dir, DecodePendingOp); err != nil || len(wal.Pending()) != 3 {
+		t.Fatalf("reopen after append = %v", err)
+	}
+	wal.Close()
+}
+
+func TestWALRejectsCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	wal, _ := OpenWAL(dir, nil)
+	wal.SetMaxSegmentSize(1)
+	for v := int64(1); v <= 3; v++ {
+		wal.Append(PendingWrite{Key: "k", Value: "value", Version: v})
+	}
+	wal.Close()
+
+	names := segments(t, dir)
+	if len(names) != 3 {
+		t.Fatalf("%d segments, want one per record", len(names))
+	}
+	data, _ := os.ReadFile(names[0])
+	data[len(data)-2] ^= 0xff
+	os.WriteFile(names[0], data, 0o644)
+
+	if _, err := OpenWAL(dir, nil); !errors.Is(err, ErrWALCorrupt) {
+		t.Errorf("OpenWAL = %v, want ErrWALCorrupt", err)
+	}
+}
+
+func TestWALCompactsReconciledSegments(t *testing.T) {
+	dir := t.TempDir()
+	wal, _ := OpenWAL(dir, nil)
+	wal.SetMaxSegmentSize(1)
+
+	mode := NewDegradedMode()
+	mode.SetJournal(wal)
+	for i := 0; i < 3; i++ {
+		if _, err := mode.QueueWrite("key", "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Restarted before reconciling: the writes come back, and new ones
+	// continue the versions
+	wal.Close()
+	wal, _ = OpenWAL(dir, nil)
+	wal.SetMaxSegmentSize(1)
+	mode = NewDegradedMode()
+	if n := mode.SetJournal(wal); n != 3 {
+		t.Fatalf("recovered %d writes, want 3", n)
+	}
+	if v, _ := mode.QueueWrite("other", "value"); v != 4 {
+		t.Errorf("version after restart = %d, want 4", v)
+	}
+
+	if err := mode.ReconcilePendingWrites(context.Background(), newMockVersionedRedis()); err != nil {
+		t.Fatal(err)
+	}
+	if mode.GetPendingWriteCount() != 0 || len(wal.Pending()) != 0 {
+		t.Fatalf("after reconcile: %d pending, %d journaled", mode.GetPendingWriteCount(), len(wal.Pending()))
+	}
+	if names := segments(t, dir); len(names) != 1 {
+		t.Errorf("%d segments after compaction, want only the empty active one", len(names))
+	}
+	wal.Close()
+	if wal, _ = OpenWAL(dir, nil); len(wal.Pending()) != 0 {
+		t.Errorf("reconciled writes replayed after restart: %+v", wal.Pending())
+	}
+	wal.Close()
+}
+
+func TestWALRecoveredWritesIgnoreMaxPendingAge(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := OpenWAL(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Queued an hour before the restart
+	if err := wal.Append(PendingWrite{Key: "k", Value: "v", Version: 1, Timestamp: time.Now().Add(-time.Hour).Unix()}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	if wal, err = OpenWAL(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	mode := NewDegradedMode()
+	mode.SetMaxPendingAge(time.Minute)
+	mode.SetJournal(wal)
+
+	writer := newMockVersionedRedis()
+	if err := mode.ReconcilePendingWrites(context.Background(), writer); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := writer.data["k"]; !ok || got.Value != "v" {
+		t.Errorf("recovered write = %+v, %v; want it replayed despite its age", got, ok)
+	}
+}
@@ -0,0 +1,72 @@
+package retention
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// archive writes jobs as gzip-compressed NDJSON (one store.Job per line)
+// to {dir}/{tenant}/jobs-{timestamp}.ndjson.gz. Close syncs the file, so
+// jobs are only deleted once their archive is durable; Abort removes a
+// partial file.
+type archive struct {
+	path  string
+	f     *os.File
+	zw    *gzip.Writer
+	enc   *json.Encoder
+	count int
+}
+
+func createArchive(dir, tenantID string, now time.Time) (*archive, error) {
+	if tenantID == "" || tenantID == "." || tenantID == ".." || filepath.Base(tenantID) != tenantID {
+		return nil, fmt.Errorf("tenant %q is not usable as a directory name", tenantID)
+	}
+	tenantDir := filepath.Join(dir, tenantID)
+	if err := os.MkdirAll(tenantDir, 0o750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(tenantDir, fmt.Sprintf("jobs-%s.ndjson.gz", now.UTC().Format("20060102T150405.000000000Z")))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	zw := gzip.NewWriter(f)
+	return &archive{path: path, f: f, zw: zw, enc: json.NewEncoder(zw)}, nil
+}
+
+func (a *archive) Write(job *store.Job) error {
+	if err := a.enc.Encode(job); err != nil {
+		return err
+	}
+	a.count++
+	return nil
+}
+
+// Close flushes and syncs the archive, removing it on failure.
+func (a *archive) Close() error {
+	if err := a.zw.Close(); err != nil {
+		a.Abort()
+		return err
+	}
+	if err := a.f.Sync(); err != nil {
+		a.Abort()
+		return err
+	}
+	if err := a.f.Close(); err != nil {
+		os.Remove(a.path)
+		return err
+	}
+	return nil
+}
+
+// Abort discards the archive.
+func (a *archive) Abort() {
+	a.f.Close()
+	os.Remove(a.path)
+}
@@ -0,0 +1,330 @@
+// Package retention garbage-collects finished jobs under per-tenant
+// policies, optionally archiving them first, and purges old tombstones.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// Collector applies retention Policies on an interval. Run it on the
+// leader only: replicas pruning the same tenant would archive jobs twice.
+type Collector struct {
+	store    store.Store
+	policies Policies
+	interval time.Duration
+
+	archiveDir      string        // "" = delete without archiving
+	tombstoneMaxAge time.Duration // 0 = keep tombstones
+	pageSize        int           // Jobs read per QueryJobs call
+}
+
+// Pass summarizes one RunOnce.
+type Pass struct {
+	Tenants    int // Tenants examined
+	Deleted    int // Jobs deleted
+	Archived   int // Jobs archived before deletion
+	Tombstones int // Tombstones purged
+}
+
+func NewCollector(s store.Store, policies Policies, interval time.Duration) *Collector {
+	return &Collector{
+		store:    s,
+		policies: policies,
+		interval: interval,
+		pageSize: store.MaxPageSize,
+	}
+}
+
+// SetArchiveDir makes the collector write pruned jobs to dir as gzipped
+// NDJSON before deleting them. A tenant whose archive cannot be written is
+// skipped for that pass rather than losing jobs.
+func (c *Collector) SetArchiveDir(dir string) {
+	c.archiveDir = dir
+}
+
+// SetTombstoneRetention purges agent and state tombstones older than maxAge.
+func (c *Collector) SetTombstoneRetention(maxAge time.Duration) {
+	c.tombstoneMaxAge = maxAge
+}
+
+// Start runs RunOnce every interval until ctx is done; pass the leader's
+// fenced context so collection stops with leadership.
+func (c *Collector) Start(ctx context.Context) {
+	go c.loop(ctx)
+}
+
+func (c *Collector) loop(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pass, err := c.RunOnce(ctx)
+			if err != nil {
+				log.Printf("Retention: pass failed: %v", err)
+			} else if pass.Deleted > 0 || pass.Tombstones > 0 {
+				log.Printf("Retention: %d jobs deleted (%d archived) across %d tenants, %d tombstones purged",
+					pass.Deleted, pass.Archived, pass.Tenants, pass.Tombstones)
+			}
+		}
+	}
+}
+
+// RunOnce applies every tenant's policy once. Tenants are those the store
+// has agents, states or jobs for, plus those named in the policies. A failing tenant
+// does not stop the others; the first error is returned.
+func (c *Collector) RunOnce(ctx context.Context) (Pass, error) {
+	start := time.Now()
+	defer func() { observability.JobGCDuration.Observe(time.Since(start).Seconds()) }()
+
+	var pass Pass
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	tenants, err := c.tenants(ctx)
+	if err != nil {
+		observability.JobGCRuns.WithLabelValues("error").Inc()
+		return pass, fmt.Errorf("list tenants: %w", err)
+	}
+	for _, tenantID := range tenants {
+		if ctx.Err() != nil {
+			fail(ctx.Err())
+			break
+		}
+		pass.Tenants++
+		deleted, archived, err := c.collectTenant(ctx, tenantID, c.policies.For(tenantID), start)
+		pass.Deleted += deleted
+		pass.Archived += archived
+		if err != nil {
+			fail(fmt.Errorf("tenant %s: %w", tenantID, err))
+		}
+	}
+
+	if c.tombstoneMaxAge > 0 {
+		n, err := c.store.PurgeTombstones(ctx, start.Add(-c.tombstoneMaxAge))
+		pass.Tombstones = n
+		observability.TombstonesPurged.Add(float64(n))
+		if err != nil {
+			fail(fmt.Errorf("purge tombstones: %w", err))
+		}
+	}
+
+	if firstErr != nil {
+		observability.JobGCRuns.WithLabelValues("error").Inc()
+		return pass, firstErr
+	}
+	observability.JobGCRuns.WithLabelValues("success").Inc()
+	observability.JobGCLastSuccess.Set(float64(time.Now().Unix()))
+	return pass, nil
+}
+
+func (c *Collector) tenants(ctx context.Context) ([]string, error) {
+	stored, err := c.store.ListTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(stored))
+	for _, tenantID := range stored {
+		seen[tenantID] = true
+	}
+	tenants := stored
+	for tenantID := range c.policies.Tenants {
+		if !seen[tenantID] {
+			tenants = append(tenants, tenantID)
+		}
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// eachJob calls fn for every job of the tenant, a page at a time.
+func (c *Collector) eachJob(ctx context.Context, tenantID string, fn func(*store.Job) error) error {
+	opts := store.ListOptions{Limit: c.pageSize}
+	for {
+		page, err := c.store.QueryJobs(ctx, tenantID, opts)
+		if err != nil {
+			return err
+		}
+		for _, job := range page.Jobs {
+			if err := fn(job); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// collectTenant archives and deletes the tenant's jobs that policy prunes.
+// One pass over the jobs plans the purges and, with an archive directory,
+// a second writes the jobs they select to the archive; neither holds more
+// than a page of jobs.
+func (c *Collector) collectTenant(ctx context.Context, tenantID string, policy Policy, now time.Time) (deleted, archived int, err error) {
+	p := newPlanner(policy)
+	if err := c.eachJob(ctx, tenantID, func(job *store.Job) error {
+		p.add(job)
+		return nil
+	}); err != nil {
+		return 0, 0, err
+	}
+	purges := p.purges(now)
+	if len(purges) == 0 {
+		return 0, 0, nil
+	}
+
+	if c.archiveDir != "" {
+		if archived, err = c.archiveTenant(ctx, tenantID, purges, now); err != nil {
+			return 0, 0, fmt.Errorf("archive: %w", err)
+		}
+	}
+
+	// Each purge selects by FinishedAt cutoffs computed from the listing, so
+	// jobs finishing meanwhile are never deleted unarchived.
+	for _, p := range purges {
+		n, err := c.store.PurgeJobs(ctx, tenantID, p)
+		deleted += n
+		if err != nil {
+			observability.JobGCDeleted.WithLabelValues(tenantID).Add(float64(deleted))
+			return deleted, archived, err
+		}
+	}
+	observability.JobGCDeleted.WithLabelValues(tenantID).Add(float64(deleted))
+	return deleted, archived, nil
+}
+
+// archiveTenant writes the tenant's jobs that purges select to a new
+// archive and returns how many it wrote. No file is left if none match.
+func (c *Collector) archiveTenant(ctx context.Context, tenantID string, purges []store.JobPurge, now time.Time) (int, error) {
+	var a *archive
+	err := c.eachJob(ctx, tenantID, func(job *store.Job) error {
+		for _, p := range purges {
+			if !p.Matches(job) {
+				continue
+			}
+			if a == nil {
+				var err error
+				if a, err = createArchive(c.archiveDir, tenantID, now); err != nil {
+					return err
+				}
+			}
+			return a.Write(job)
+		}
+		return nil
+	})
+	if a == nil {
+		return 0, err
+	}
+	if err != nil {
+		a.Abort()
+		return 0, err
+	}
+	if err := a.Close(); err != nil {
+		return 0, err
+	}
+	observability.JobGCArchived.WithLabelValues(tenantID).Add(float64(a.count))
+	log.Printf("Retention: archived %d jobs of tenant %s to %s", a.count, tenantID, a.path)
+	return a.count, nil
+}
+
+// planner turns a policy into JobPurge selections as it is shown the
+// tenant's jobs: one for MaxAge, and one per node or state over its limit,
+// cutting at the FinishedAt of the oldest job it keeps.
+type planner struct {
+	policy  Policy
+	byNode  map[string]*newest
+	byState map[string]*newest
+}
+
+func newPlanner(policy Policy) *planner {
+	return &planner{
+		policy:  policy,
+		byNode:  make(map[string]*newest),
+		byState: make(map[string]*newest),
+	}
+}
+
+func (p *planner) add(job *store.Job) {
+	if job.FinishedAt == nil {
+		return
+	}
+	if p.policy.MaxJobsPerNode > 0 {
+		track(p.byNode, job.NodeID, p.policy.MaxJobsPerNode).add(*job.FinishedAt)
+	}
+	if p.policy.KeepPerState > 0 && job.StateID != "" {
+		track(p.byState, job.StateID, p.policy.KeepPerState).add(*job.FinishedAt)
+	}
+}
+
+func (p *planner) purges(now time.Time) []store.JobPurge {
+	var purges []store.JobPurge
+	if p.policy.MaxAge > 0 {
+		purges = append(purges, store.JobPurge{FinishedBefore: now.Add(-p.policy.MaxAge)})
+	}
+	for nodeID, n := range p.byNode {
+		if cutoff, ok := n.cutoff(); ok {
+			purges = append(purges, store.JobPurge{NodeID: nodeID, FinishedBefore: cutoff})
+		}
+	}
+	for stateID, n := range p.byState {
+		if cutoff, ok := n.cutoff(); ok {
+			purges = append(purges, store.JobPurge{StateID: stateID, FinishedBefore: cutoff})
+		}
+	}
+	return purges
+}
+
+func track(m map[string]*newest, key string, keep int) *newest {
+	n := m[key]
+	if n == nil {
+		n = &newest{keep: keep}
+		m[key] = n
+	}
+	return n
+}
+
+// newest keeps the keep newest FinishedAt times of a node's or state's
+// finished jobs, and how many it was shown.
+type newest struct {
+	keep  int
+	seen  int
+	times []time.Time // Newest first
+}
+
+func (n *newest) add(t time.Time) {
+	n.seen++
+	i := sort.Search(len(n.times), func(i int) bool { return n.times[i].Before(t) })
+	if i >= n.keep {
+		return
+	}
+	if len(n.times) < n.keep {
+		n.times = append(n.times, time.Time{})
+	}
+	copy(n.times[i+1:], n.times[i:])
+	n.times[i] = t
+}
+
+// cutoff returns the FinishedAt of the keep-th newest finished job, if
+// there were more than keep. Jobs that finished at the same instant as the
+// cutoff are kept.
+func (n *newest) cutoff() (time.Time, bool) {
+	if n.seen <= n.keep {
+		return time.Time{}, false
+	}
+	return n.times[n.keep-1], true
+}
@@ -0,0 +1,173 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func finishedJob(id, node, state string, age time.Duration) *store.Job {
+	finished := time.Now().Add(-age)
+	return &store.Job{
+		JobID: id, NodeID: node, StateID: state, Status: "completed",
+		Stdout: "output of " + id, CreatedAt: finished.Add(-time.Second), FinishedAt: &finished,
+	}
+}
+
+func TestCollectorAppliesPolicies(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	for _, tenant := range []string{"t1", "t2"} {
+		if err := s.UpsertAgent(ctx, tenant, &store.Agent{NodeID: "n1"}); err != nil {
+			t.Fatalf("UpsertAgent: %v", err)
+		}
+	}
+
+	jobs := map[string][]*store.Job{
+		"t1": {
+			finishedJob("old", "n1", "s1", 48*time.Hour),
+			finishedJob("s1-a", "n1", "s1", 3*time.Hour),
+			finishedJob("s1-b", "n1", "s1", 2*time.Hour),
+			finishedJob("s1-c", "n1", "s1", time.Hour),
+			finishedJob("n2-a", "n2", "", 3*time.Hour),
+			finishedJob("n2-b", "n2", "", 2*time.Hour),
+			finishedJob("n2-c", "n2", "", time.Hour),
+			{JobID: "queued", NodeID: "n1", StateID: "s1", Status: "queued", CreatedAt: time.Now().Add(-72 * time.Hour)},
+		},
+		"t2": {finishedJob("t2-old", "n1", "s9", 48*time.Hour)},
+	}
+	for tenant, list := range jobs {
+		for _, job := range list {
+			if err := s.CreateJob(ctx, tenant, job); err != nil {
+				t.Fatalf("CreateJob: %v", err)
+			}
+		}
+	}
+
+	archive := t.TempDir()
+	c := NewCollector(s, Policies{
+		Default: Policy{MaxAge: 24 * time.Hour, MaxJobsPerNode: 2, KeepPerState: 1},
+		Tenants: map[string]Policy{"t2": {MaxAge: 72 * time.Hour}},
+	}, time.Hour)
+	c.SetArchiveDir(archive)
+	c.pageSize = 3 // Several pages per tenant
+
+	pass, err := c.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	// t1: "old" by age, "s1-a" and "s1-b" by state, "n2-a" by node.
+	// t2's override keeps its job.
+	if pass.Tenants != 2 || pass.Deleted != 4 || pass.Archived != 4 {
+		t.Errorf("pass = %+v; want 2 tenants, 4 deleted, 4 archived", pass)
+	}
+	left, _ := s.ListJobsByTenant(ctx, "t1", 0)
+	ids := map[string]bool{}
+	for _, j := range left {
+		ids[j.JobID] = true
+	}
+	for _, id := range []string{"s1-c", "n2-b", "n2-c", "queued"} {
+		if !ids[id] {
+			t.Errorf("job %s was pruned", id)
+		}
+	}
+	if len(ids) != 4 {
+		t.Errorf("jobs left = %v", ids)
+	}
+	if j, _ := s.GetJob(ctx, "t2", "t2-old"); j == nil {
+		t.Error("tenant override ignored: t2-old was pruned")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(archive, "t1", "jobs-*.ndjson.gz"))
+	if len(files) != 1 {
+		t.Fatalf("archives = %v; want one for t1", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive is not gzip: %v", err)
+	}
+	archived := map[string]bool{}
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var job store.Job
+		if err := json.Unmarshal(scanner.Bytes(), &job); err != nil {
+			t.Fatalf("archive line %q: %v", scanner.Text(), err)
+		}
+		if !strings.HasPrefix(job.Stdout, "output of ") {
+			t.Errorf("archived job lost its output: %+v", job)
+		}
+		archived[job.JobID] = true
+	}
+	if len(archived) != 4 || !archived["old"] || !archived["s1-a"] || !archived["s1-b"] || !archived["n2-a"] {
+		t.Errorf("archived = %v", archived)
+	}
+
+	// Nothing left to prune: no new archive
+	if pass, err := c.RunOnce(ctx); err != nil || pass.Deleted != 0 {
+		t.Errorf("second pass = %+v, %v; want nothing deleted", pass, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(archive, "t1", "*")); len(files) != 1 {
+		t.Errorf("second pass wrote another archive: %v", files)
+	}
+}
+
+func TestCollectorKeepsJobsWhenArchiveFails(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	s.UpsertAgent(ctx, "t1", &store.Agent{NodeID: "n1"})
+	s.CreateJob(ctx, "t1", finishedJob("old", "n1", "", 48*time.Hour))
+
+	// A file where the archive directory should be
+	blocked := filepath.Join(t.TempDir(), "archive")
+	if err := os.WriteFile(blocked, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollector(s, Policies{Default: Policy{MaxAge: time.Hour}}, time.Hour)
+	c.SetArchiveDir(blocked)
+
+	if _, err := c.RunOnce(ctx); err == nil {
+		t.Error("RunOnce succeeded with an unwritable archive")
+	}
+	if j, _ := s.GetJob(ctx, "t1", "old"); j == nil {
+		t.Error("job deleted although it could not be archived")
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	data := `{"default": {"max_age": "720h", "max_jobs_per_node": 100},
+	          "tenants": {"acme": {"keep_per_state": 5}}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicies(path)
+	if err != nil {
+		t.Fatalf("LoadPolicies: %v", err)
+	}
+	if got := p.For("other"); got.MaxAge != 720*time.Hour || got.MaxJobsPerNode != 100 {
+		t.Errorf("default = %+v", got)
+	}
+	if got := p.For("acme"); got.KeepPerState != 5 || got.MaxAge != 0 {
+		t.Errorf("acme = %+v; want override to replace the default", got)
+	}
+
+	for _, bad := range []string{`{"default": {"max_age": "a month"}}`, `{"default": {"keep_per_state": -1}}`} {
+		os.WriteFile(path, []byte(bad), 0o600)
+		if _, err := LoadPolicies(path); err == nil {
+			t.Errorf("LoadPolicies(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Policy bounds the finished jobs a tenant keeps. A finished job is pruned
+// as soon as any limit excludes it; a zero limit is no limit. Queued and
+// running jobs are never pruned.
+type Policy struct {
+	MaxAge         time.Duration // Since the job finished
+	MaxJobsPerNode int           // Newest finished jobs kept per node
+	KeepPerState   int           // Newest finished jobs kept per desired state
+}
+
+// policyJSON is Policy as written in a policies file, with MaxAge as a
+// Go duration string ("720h").
+type policyJSON struct {
+	MaxAge         string `json:"max_age,omitempty"`
+	MaxJobsPerNode int    `json:"max_jobs_per_node,omitempty"`
+	KeepPerState   int    `json:"keep_per_state,omitempty"`
+}
+
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var raw policyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Policy{MaxJobsPerNode: raw.MaxJobsPerNode, KeepPerState: raw.KeepPerState}
+	if raw.MaxAge != "" {
+		d, err := time.ParseDuration(raw.MaxAge)
+		if err != nil {
+			return fmt.Errorf("max_age: %w", err)
+		}
+		p.MaxAge = d
+	}
+	if p.MaxAge < 0 || p.MaxJobsPerNode < 0 || p.KeepPerState < 0 {
+		return fmt.Errorf("retention limits must not be negative")
+	}
+	return nil
+}
+
+func (p Policy) MarshalJSON() ([]byte, error) {
+	raw := policyJSON{MaxJobsPerNode: p.MaxJobsPerNode, KeepPerState: p.KeepPerState}
+	if p.MaxAge > 0 {
+		raw.MaxAge = p.MaxAge.String()
+	}
+	return json.Marshal(raw)
+}
+
+// Policies holds the default policy and per-tenant overrides. A tenant
+// override replaces the default as a whole.
+type Policies struct {
+	Default Policy            `json:"default"`
+	Tenants map[string]Policy `json:"tenants,omitempty"`
+}
+
+// For returns the policy that applies to tenantID.
+func (p Policies) For(tenantID string) Policy {
+	if policy, ok := p.Tenants[tenantID]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// LoadPolicies reads policies from a JSON file:
+//
+//	{"default": {"max_age": "720h", "max_jobs_per_node": 1000},
+//	 "tenants": {"acme": {"max_age": "2160h", "keep_per_state": 50}}}
+func LoadPolicies(path string) (Policies, error) {
+	var p Policies
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// DriftCheckPriority is the priority of sweeper-submitted tasks.
+// Background work: aging still lets it through under sustained load,
+// and degraded mode sheds it first.
+const DriftCheckPriority = 10
+
+// driftSweepStatuses are the settled statuses the sweeper re-checks.
+// pending/drifted states are already picked up by the poller.
+var driftSweepStatuses = []string{"compliant", "failed"}
+
+// driftSweeper periodically submits low-priority reconciliation tasks for
+// states in this shard whose LastChecked is older than their check interval,
+// so drift on a node is detected without anyone calling /reconcile.
+func (s *Scheduler) driftSweeper(ctx context.Context) {
+	interval := s.config.DriftSweepInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			mode := s.mode
+			active := s.active
+			s.mu.RUnlock()
+			if !active || mode != ModeNormal {
+				continue
+			}
+
+			submitted, err := s.sweepDrift(ctx, time.Now())
+			if err != nil {
+				log.Printf("Drift sweeper: %v", err)
+				continue
+			}
+			if submitted > 0 {
+				log.Printf("Drift sweeper: submitted %d checks (Shard %d/%d)", submitted, s.shardIndex, s.shardCount)
+			}
+		}
+	}
+}
+
+// sweepDrift runs one drift sweep as of now and returns how many checks were
+// submitted. States skipped because their tenant is over its drift-check
+// rate stay due and are picked up on a later sweep.
+func (s *Scheduler) sweepDrift(ctx context.Context, now time.Time) (int, error) {
+	submitted := 0
+	swept := make(map[stateKey]bool)
+	for _, status := range driftSweepStatuses {
+		states, err := s.listStates(ctx, status)
+		if err != nil {
+			return submitted, fmt.Errorf("failed to list %s states: %w", status, err)
+		}
+		for _, state := range states {
+			key := stateKey{TenantID: state.TenantID, StateID: state.StateID}
+			swept[key] = true
+			interval := s.checkInterval(state)
+			if interval <= 0 || now.Sub(state.LastChecked) < interval || s.queue.Contains(state.TenantID, state.StateID) {
+				continue
+			}
+			// A recheck that fails before reaching the agent does not
+			// advance LastChecked; don't resubmit it more than once per
+			// interval.
+			if at, ok := s.driftSubmitted[key]; ok && now.Sub(at) < interval {
+				continue
+			}
+			if !s.driftLimiters.Allow(state.TenantID) {
+				observability.SchedulerRejections.WithLabelValues("drift_tenant_rate").Inc()
+				continue
+			}
+
+			task := &ReconciliationTask{
+				ReqID:      fmt.Sprintf("drift-%s-%d", state.StateID, now.Unix()),
+				NodeID:     state.NodeID,
+				TenantID:   state.TenantID,
+				StateID:    state.StateID,
+				Priority:   DriftCheckPriority,
+				SubmitTime: now,
+			}
+			if err := s.Submit(task); err != nil {
+				// Queue full, breaker open or wrong shard: retry next sweep
+				continue
+			}
+			s.driftSubmitted[key] = now
+			submitted++
+		}
+	}
+
+	// Forget states no longer settled in this shard
+	for key := range s.driftSubmitted {
+		if !swept[key] {
+			delete(s.driftSubmitted, key)
+		}
+	}
+	return submitted, nil
+}
+
+// checkInterval returns how often the state should be re-checked,
+// or 0 if it should not be swept.
+func (s *Scheduler) checkInterval(state *store.DesiredState) time.Duration {
+	switch {
+	case state.CheckInterval > 0:
+		return time.Duration(state.CheckInterval) * time.Second
+	case state.CheckInterval < 0:
+		return 0
+	default:
+		return s.config.DefaultCheckInterval
+	}
+}
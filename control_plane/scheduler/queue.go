@@ -2,10 +2,17 @@ package scheduler
 
 import (
 	"container/heap"
+	"errors"
 	"sync"
 	"time"
 )
 
+// ErrDuplicateTask is returned by Push when a task for the same tenant and
+// StateID is already queued or being reconciled. A state being reconciled
+// is reconciled once more when the run finishes, so the newer spec is
+// still picked up.
+var ErrDuplicateTask = errors.New("task for state is already queued or in flight")
+
 // TaskQueue implements heap.Interface and holds ReconciliationTasks.
 type TaskQueue []*ReconciliationTask
 
@@ -48,31 +55,73 @@ func (pq *TaskQueue) Pop() interface{} {
 	return item
 }
 
+// stateKey identifies a state across tenants; StateIDs are chosen by
+// clients, so two tenants may use the same one.
+type stateKey struct {
+	TenantID string
+	StateID  string
+}
+
+func taskKey(task *ReconciliationTask) stateKey {
+	return stateKey{TenantID: task.TenantID, StateID: task.StateID}
+}
+
+// trackedTask is the task a queue owns for a state.
+type trackedTask struct {
+	task     *ReconciliationTask
+	inFlight bool                // Popped and not requeued: a worker may have read the state
+	rerun    *ReconciliationTask // Submitted while in flight; queued by Done
+}
+
 // ThreadSafeQueue wraps TaskQueue with a mutex for safe concurrent access.
+// It also tracks the states it owns, from Push until Done, so the same
+// state is never queued twice or queued while a worker is reconciling it.
 type ThreadSafeQueue struct {
-	pq TaskQueue
-	mu sync.Mutex
+	pq      TaskQueue
+	tracked map[stateKey]*trackedTask // Task queued, delayed or in flight
+	mu      sync.Mutex
 }
 
 func NewThreadSafeQueue() *ThreadSafeQueue {
 	return &ThreadSafeQueue{
-		pq: make(TaskQueue, 0),
+		pq:      make(TaskQueue, 0),
+		tracked: make(map[stateKey]*trackedTask),
 	}
 }
 
-func (q *ThreadSafeQueue) Push(task *ReconciliationTask) {
+// Push enqueues a new task. It returns ErrDuplicateTask if a task for the
+// same tenant and StateID is already tracked; if that task is in flight,
+// the new one is kept and queued when it is Done. Tasks without a StateID
+// are never deduplicated.
+func (q *ThreadSafeQueue) Push(task *ReconciliationTask) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	if task.StateID != "" {
+		if t := q.tracked[taskKey(task)]; t != nil {
+			if t.inFlight {
+				t.rerun = task
+			}
+			return ErrDuplicateTask
+		}
+		q.tracked[taskKey(task)] = &trackedTask{task: task}
+	}
 	heap.Push(&q.pq, task)
+	return nil
 }
 
+// Pop removes the most urgent task. Its state stays tracked (in flight)
+// until Done is called.
 func (q *ThreadSafeQueue) Pop() *ReconciliationTask {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 	if len(q.pq) == 0 {
 		return nil
 	}
-	return heap.Pop(&q.pq).(*ReconciliationTask)
+	task := heap.Pop(&q.pq).(*ReconciliationTask)
+	if t := q.tracked[taskKey(task)]; t != nil && t.task == task {
+		t.inFlight = true
+	}
+	return task
 }
 
 func (q *ThreadSafeQueue) Peek() *ReconciliationTask {
@@ -91,10 +140,85 @@ func (q *ThreadSafeQueue) Len() int {
 	return len(q.pq)
 }
 
-// PushDelayed pushes a task to the queue after a delay.
+// Contains reports whether a task for the tenant's stateID is queued or in
+// flight.
+func (q *ThreadSafeQueue) Contains(tenantID, stateID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tracked[stateKey{TenantID: tenantID, StateID: stateID}] != nil
+}
+
+// Done releases a popped task's state so it can be queued again, or, if a
+// task for it was pushed while this one was in flight, queues that one in
+// its place. It is a no-op for a task that was removed, so it never
+// releases a newer task.
+func (q *ThreadSafeQueue) Done(task *ReconciliationTask) {
+	if task.StateID == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.tracked[taskKey(task)]
+	if t == nil || t.task != task {
+		return
+	}
+	if t.rerun == nil {
+		delete(q.tracked, taskKey(task))
+		return
+	}
+	next := t.rerun
+	next.EnqueuedAt = time.Now()
+	q.tracked[taskKey(task)] = &trackedTask{task: next}
+	heap.Push(&q.pq, next)
+}
+
+// Remove drops the tenant's task for stateID, whether queued, delayed or
+// in flight, and reports whether there was one. A delayed task is
+// discarded when its delay ends; an in-flight one runs to completion but
+// is not requeued.
+func (q *ThreadSafeQueue) Remove(tenantID, stateID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := stateKey{TenantID: tenantID, StateID: stateID}
+	t := q.tracked[key]
+	if t == nil {
+		return false
+	}
+	delete(q.tracked, key)
+	for i, queued := range q.pq {
+		if queued == t.task {
+			heap.Remove(&q.pq, i)
+			break
+		}
+	}
+	return true
+}
+
+// requeue puts a popped task back without the duplicate check; its state
+// is still tracked from the original Push, unless it was removed since.
+func (q *ThreadSafeQueue) requeue(task *ReconciliationTask) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if task.StateID != "" {
+		t := q.tracked[taskKey(task)]
+		if t == nil || t.task != task {
+			return
+		}
+		t.inFlight = false
+	}
+	heap.Push(&q.pq, task)
+}
+
+// PushDelayed requeues a popped task after a delay. The task has not run,
+// so until then it counts as queued, not in flight.
 // This is non-blocking.
 func (q *ThreadSafeQueue) PushDelayed(task *ReconciliationTask, delay time.Duration) {
+	q.mu.Lock()
+	if t := q.tracked[taskKey(task)]; t != nil && t.task == task {
+		t.inFlight = false
+	}
+	q.mu.Unlock()
 	time.AfterFunc(delay, func() {
-		q.Push(task)
+		q.requeue(task)
 	})
 }
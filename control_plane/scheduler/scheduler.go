@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"sync"
 	"time"
 
 	"errors"
 
 	"github.com/itskum47/FluxForge/control_plane/observability"
+	"github.com/itskum47/FluxForge/control_plane/sharding"
 	"github.com/itskum47/FluxForge/control_plane/store"
 	"github.com/itskum47/FluxForge/control_plane/timeline"
 )
@@ -33,10 +35,13 @@ type Scheduler struct {
 	queue          *ThreadSafeQueue
 	nodeLimiters   *TokenBucketLimiter
 	tenantLimiters *TokenBucketLimiter
+	driftLimiters  *TokenBucketLimiter    // Per-tenant cap on drift-check submissions
+	driftSubmitted map[stateKey]time.Time // Last drift check submitted per state. Owned by driftSweeper.
 	reconciler     ReconcilerInterface
 	store          StoreInterface // Injected store for polling
 	shardIndex     int
 	shardCount     int
+	partitioner    sharding.Partitioner // Protected by mu. Decides which NodeIDs this shard owns
 
 	nodeHealth     map[string]*NodeHealth
 	domainFailures map[string]int
@@ -59,15 +64,22 @@ func NewScheduler(store StoreInterface, reconciler ReconcilerInterface, shardInd
 	if shardCount < 1 {
 		shardCount = 1
 	}
+	driftRate := config.DriftChecksPerTenant
+	if driftRate <= 0 {
+		driftRate = 5
+	}
 
 	return &Scheduler{
 		queue:          NewThreadSafeQueue(),
 		nodeLimiters:   NewTokenBucketLimiter(5, 1),
 		tenantLimiters: NewTokenBucketLimiter(50, 10),
+		driftLimiters:  NewTokenBucketLimiter(driftRate, int(math.Ceil(driftRate))),
+		driftSubmitted: make(map[stateKey]time.Time),
 		reconciler:     reconciler,
 		store:          store,
 		shardIndex:     shardIndex,
 		shardCount:     shardCount,
+		partitioner:    sharding.Modulo(shardCount),
 		nodeHealth:     make(map[string]*NodeHealth),
 		domainFailures: make(map[string]int),
 		domainTasks:    make(map[string]int),
@@ -117,6 +129,13 @@ func (s *Scheduler) Submit(task *ReconciliationTask) error {
 	saturation := float64(s.activeTasks) / float64(s.maxConcurrency)
 	s.mu.RUnlock()
 
+	// Sharding Check: first, so a pod that is not leading this node's
+	// shard still reports where the task belongs and the API can forward it
+	if owner := s.OwnerOf(task.NodeID); owner != s.shardIndex {
+		observability.SchedulerRejections.WithLabelValues("wrong_shard").Inc()
+		return &WrongShardError{NodeID: task.NodeID, Owner: owner, Shard: s.shardIndex}
+	}
+
 	// 0. Leadership/Active Check
 	if !isActive {
 		observability.SchedulerRejections.WithLabelValues("not_leader").Inc()
@@ -169,31 +188,10 @@ func (s *Scheduler) Submit(task *ReconciliationTask) error {
 	}
 	task.EnqueuedAt = time.Now() // Set enqueue time for backpressure tracking
 
-	// Sharding Check
-	if s.shardCount > 1 {
-		// Use same hash as MemoryStore for consistency
-		h := fnvHash(task.NodeID)
-		if int(h%uint32(s.shardCount)) != s.shardIndex {
-			// Not my shard.
-			// In a real distributed system, we might forward.
-			// Here, we reject (assuming API handles rerouting or we rely on DB polling/rehydrate).
-			// But wait, if API Submits and we reject, task is LOST from memory queue.
-			// But it IS in DB (API calls UpsertState first).
-			// So Rehydrate (or Poller) would pick it up.
-			// Since I didn't implement Poller, this means tasks for other shards are IGNORED until Restart?
-			// This suggests I NEED Poller.
-			// OR I need API to Submit to *correct* queue.
-			// Given I just implemented Rehydrate with Sharding, restarting picks it up.
-			// Poller is needed for "Live" sharding if API hits wrong pod.
-			// I will add a simple Poller that Rehydrates every 10s?
-			// And for Submit, I'll log warning but ALLOW it?
-			// No, that breaks strict sharding.
-			// I will REJECT it. And I will uncomment/fix the Poller.
-			return fmt.Errorf("task node_id %s belongs to shard %d (my shard: %d)", task.NodeID, int(h%uint32(s.shardCount)), s.shardIndex)
-		}
+	if err := s.queue.Push(task); err != nil {
+		observability.SchedulerRejections.WithLabelValues("duplicate").Inc()
+		return err
 	}
-
-	s.queue.Push(task)
 	s.timeline.Record(timeline.ReconcileEvent{
 		ReqID:    task.ReqID,
 		Stage:    "QUEUED",
@@ -204,14 +202,60 @@ func (s *Scheduler) Submit(task *ReconciliationTask) error {
 	return nil
 }
 
-// Simple hash (same as in store/memory.go to match logic)
-func fnvHash(s string) uint32 {
-	h := uint32(2166136261)
-	for i := 0; i < len(s); i++ {
-		h *= 16777619
-		h ^= uint32(s[i])
+// WrongShardError is returned by Submit for a task whose node is owned by
+// another shard. The task is not queued; callers forward it to Owner.
+type WrongShardError struct {
+	NodeID string
+	Owner  int // Shard that owns NodeID
+	Shard  int // This scheduler's shard
+}
+
+func (e *WrongShardError) Error() string {
+	return fmt.Sprintf("task node_id %s belongs to shard %d (my shard: %d)", e.NodeID, e.Owner, e.Shard)
+}
+
+// SetPartitioner changes how NodeIDs map to shards, e.g. to a consistent-hash
+// ring over the live shards (see coordination.Membership). The default is
+// sharding.Modulo(shardCount), the same layout the stores filter on.
+func (s *Scheduler) SetPartitioner(p sharding.Partitioner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partitioner = p
+}
+
+// ShardIndex returns the shard this scheduler serves.
+func (s *Scheduler) ShardIndex() int {
+	return s.shardIndex
+}
+
+// OwnerOf returns the shard that owns nodeID.
+func (s *Scheduler) OwnerOf(nodeID string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.partitioner.Owner(nodeID)
+}
+
+// listStates returns this shard's states with the given status. The stores
+// shard with sharding.Modulo; for any other partitioner list all and filter.
+func (s *Scheduler) listStates(ctx context.Context, status string) ([]*store.DesiredState, error) {
+	s.mu.RLock()
+	p := s.partitioner
+	s.mu.RUnlock()
+
+	if m, ok := p.(sharding.Modulo); ok && int(m) == s.shardCount {
+		return s.store.ListStatesByStatus(ctx, status, s.shardIndex, s.shardCount)
+	}
+	states, err := s.store.ListStatesByStatus(ctx, status, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	owned := states[:0:0]
+	for _, state := range states {
+		if sharding.Owns(p, state.NodeID, s.shardIndex) {
+			owned = append(owned, state)
+		}
 	}
-	return h
+	return owned, nil
 }
 
 // Stop halts the scheduler and clears the queue.
@@ -224,6 +268,12 @@ func (s *Scheduler) Stop() {
 	s.queue = NewThreadSafeQueue()
 }
 
+// Cancel drops any queued, delayed or in-flight task for a tenant's state,
+// e.g. because the state was deleted. It reports whether there was one.
+func (s *Scheduler) Cancel(tenantID, stateID string) bool {
+	return s.queue.Remove(tenantID, stateID)
+}
+
 // RehydrateQueue pulls pending tasks from the store.
 func (s *Scheduler) RehydrateQueue(ctx context.Context) error {
 	log.Printf("Rehydrating Scheduler Queue (Shard %d/%d)...", s.shardIndex, s.shardCount)
@@ -253,14 +303,25 @@ func (s *Scheduler) RehydrateQueue(ctx context.Context) error {
 	s.active = true
 	s.mu.Unlock()
 
+	_, err := s.enqueueFromStore(ctx, "rehydrate")
+	return err
+}
+
+// enqueueFromStore submits a task for every pending or drifted state in this
+// shard that is not already queued or in flight. It returns how many were added.
+func (s *Scheduler) enqueueFromStore(ctx context.Context, reqPrefix string) (int, error) {
+	added := 0
 	for _, status := range []string{"pending", "drifted"} {
-		states, err := s.store.ListStatesByStatus(ctx, status, s.shardIndex, s.shardCount)
+		states, err := s.listStates(ctx, status)
 		if err != nil {
-			return fmt.Errorf("failed to list %s states: %w", status, err)
+			return added, fmt.Errorf("failed to list %s states: %w", status, err)
 		}
 		for _, state := range states {
+			if s.queue.Contains(state.TenantID, state.StateID) {
+				continue
+			}
 			task := &ReconciliationTask{
-				ReqID:      fmt.Sprintf("rehydrate-%s", state.StateID),
+				ReqID:      fmt.Sprintf("%s-%s", reqPrefix, state.StateID),
 				NodeID:     state.NodeID,
 				TenantID:   state.TenantID,
 				StateID:    state.StateID,
@@ -269,11 +330,15 @@ func (s *Scheduler) RehydrateQueue(ctx context.Context) error {
 				Attempt:    0,
 			}
 			if err := s.Submit(task); err != nil {
-				log.Printf("Failed to rehydrate task %s: %v", state.StateID, err)
+				if !errors.Is(err, ErrDuplicateTask) {
+					log.Printf("Failed to %s task %s: %v", reqPrefix, state.StateID, err)
+				}
+				continue
 			}
+			added++
 		}
 	}
-	return nil
+	return added, nil
 }
 
 // Start begins the scheduling loop.
@@ -284,11 +349,19 @@ func (s *Scheduler) Start(ctx context.Context) {
 	s.mu.Unlock()
 	go s.worker(ctx)
 	go s.poller(ctx)
+	go s.driftSweeper(ctx)
 }
 
-// poller periodically fetches pending tasks from DB (Sharded).
+// poller periodically fetches pending/drifted states from the DB (Sharded).
+// This picks up states written by API pods that hit the wrong shard, or whose
+// Submit was rejected, without waiting for a restart. Deduplication in the
+// queue makes re-polling states that are already queued a no-op.
 func (s *Scheduler) poller(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
+	interval := s.config.PollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -296,45 +369,22 @@ func (s *Scheduler) poller(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			// Check mode
 			s.mu.RLock()
 			mode := s.mode
+			active := s.active
 			s.mu.RUnlock()
-			if mode != ModeNormal && mode != ModeDegraded {
+			if !active || (mode != ModeNormal && mode != ModeDegraded) {
 				continue
 			}
 
-			// Reuse Rehydrate logic essentially, but maybe optimized?
-			// For now, just call Rehydrate to pull in everything pending.
-			// s.queue.Push() handles dups? No.
-			// RehydrateQueue implementation blindly Submits.
-			// s.Submit checks queue limit.
-			// But we don't have deduplication in Queue!
-			// If we re-submit pending task that is already in queue...
-			// We need Deduplication in Submit or Queue.
-			// Currently Queue is just a list.
-			// Let's implement Deduplication in Submit?
-			// Or just assume polling is slow enough?
-			// If we poll every 5s, and queue size is large, we might duplicate.
-			// Ideally, Queue should check "Contains(task.StateID)".
-			// For Phase 5, let's assume RehydrateQueue is enough for "Startup" and "Recovery".
-			// But for "Active-Active", polling is the MAIN way to get tasks if API doesn't submit to us?
-			// If API submits to us, we don't need polling except for recovery.
-			// But if API is sharded...
-			// Let's stick to: Rehydrate on Start.
-			// And Polling?
-			// If we implement Sharding, API -> Submit (Local) -> Scheduler (Local).
-			// If Request for Node X comes to Pod B (Shard 1), but Node X is Shard 0.
-			// Pod B Scheduler (Shard 1) will ignore it?
-			// We haven't implemented "Ignore" in Submit yet.
-			// We should!
-
-			// So Polling is needed ONLY if API writes to DB but doesn't Submit.
-			// Currently API calls Submit.
-			// Let's implement Polling as a safety net.
-			// But to avoid duplicates, we need Deduplication.
-			// I'll skip Polling for now to avoid complexity, relying on Rehydrate + API Submit.
-			// But I MUST implement Sharding Check in Submit!
+			added, err := s.enqueueFromStore(ctx, "poll")
+			if err != nil {
+				log.Printf("Scheduler poller: %v", err)
+				continue
+			}
+			if added > 0 {
+				log.Printf("Scheduler poller: enqueued %d states (Shard %d/%d)", added, s.shardIndex, s.shardCount)
+			}
 		}
 	}
 }
@@ -427,11 +477,14 @@ func (s *Scheduler) UpdateNodeHealth(nodeID, signal string, score float64, tier
 }
 
 func (s *Scheduler) processNextTask(ctx context.Context) {
-	if s.queue.Len() == 0 {
+	// Hold on to this queue: Stop() swaps in a fresh one, and the task's
+	// state must be released on the queue that tracked it.
+	queue := s.queue
+	if queue.Len() == 0 {
 		return
 	}
 
-	task := s.queue.Pop()
+	task := queue.Pop()
 	if task == nil {
 		return
 	}
@@ -453,6 +506,7 @@ func (s *Scheduler) processNextTask(ctx context.Context) {
 				Reason:    "Node quarantined due to low health score",
 				Metadata:  map[string]float64{"score": health.CompositeScore},
 			})
+			queue.Done(task)
 			return // Drop task
 		}
 	}
@@ -481,7 +535,7 @@ func (s *Scheduler) processNextTask(ctx context.Context) {
 				Reason:    "Failure domain saturation",
 				Metadata:  map[string]int{"failures": failures, "active": active, "limit": limit},
 			})
-			s.queue.PushDelayed(task, 2*time.Second)
+			queue.PushDelayed(task, 2*time.Second)
 			return
 		}
 	}
@@ -489,7 +543,7 @@ func (s *Scheduler) processNextTask(ctx context.Context) {
 	// 2. Check Rate Limits (Node)
 	if allowed, delay := s.nodeLimiters.Reserve(task.NodeID); !allowed {
 		// Node limit: Requeue with backoff
-		s.queue.PushDelayed(task, delay)
+		queue.PushDelayed(task, delay)
 		return
 	}
 
@@ -503,7 +557,7 @@ func (s *Scheduler) processNextTask(ctx context.Context) {
 			Reason:    "Tenant rate limit exceeded",
 		})
 		// Requeue with penalty (delay)
-		s.queue.PushDelayed(task, delay)
+		queue.PushDelayed(task, delay)
 		return
 	}
 
@@ -524,7 +578,7 @@ func (s *Scheduler) processNextTask(ctx context.Context) {
 	if s.activeTasks >= 100 { // Global Budget: 100 concurrent tasks
 		s.mu.Unlock()
 		// Requeue if full
-		s.queue.PushDelayed(task, 1*time.Second)
+		queue.PushDelayed(task, 1*time.Second)
 		return
 	}
 	s.activeTasks++
@@ -563,6 +617,7 @@ func (s *Scheduler) processNextTask(ctx context.Context) {
 				}
 			}
 			s.mu.Unlock()
+			queue.Done(task)
 		}()
 
 		// Task Execution Fence Check
@@ -576,7 +631,7 @@ func (s *Scheduler) processNextTask(ctx context.Context) {
 		err = s.reconciler.Reconcile(ctx, task.TenantID, task.StateID)
 
 		stage := "FINISHED"
-		meta := make(map[string]string)
+		meta := map[string]string{"state_id": task.StateID}
 		if err != nil {
 			stage = "FAILED"
 			meta["error"] = err.Error()
@@ -602,13 +657,13 @@ func logDecision(d SchedulingDecision) {
 	observability.SchedulerDecisions.WithLabelValues(d.Decision, d.Reason).Inc()
 }
 
-// GetSnapshot returns the internal state for debugging.
+// GetSnapshot returns the internal state for debugging. Timeline events
+// are left to the caller, which queries them for its tenant.
 func (s *Scheduler) GetSnapshot() map[string]interface{} {
 	return map[string]interface{}{
 		"queue_depth":     s.queue.Len(),
 		"domain_failures": s.domainFailures,
 		"domain_active":   s.domainTasks,
-		"timeline_events": s.timeline.GetAllEvents(),
 		"mode":            s.mode,
 	}
 }
@@ -3,11 +3,12 @@ package scheduler
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/itskum47/FluxForge/control_plane/store"
-	"github.com/itskum47/FluxForge/control_plane/timeline"
 )
 
 type MockStore struct{}
@@ -137,7 +138,11 @@ func TestGetSnapshot(t *testing.T) {
 		t.Errorf("Expected queue_depth 1, got %v", val)
 	}
 
-	events := snap["timeline_events"].([]timeline.ReconcileEvent)
+	if _, ok := snap["timeline_events"]; ok {
+		t.Error("snapshot carries timeline events of every tenant")
+	}
+
+	events := sched.GetTimeline().GetEvents("snap-1")
 	if len(events) != 1 {
 		t.Errorf("Expected 1 timeline event (QUEUED), got %d", len(events))
 	}
@@ -176,3 +181,214 @@ func TestSchedulerModes(t *testing.T) {
 		t.Error("ReadOnly mode accepted task")
 	}
 }
+
+func TestQueueDeduplication(t *testing.T) {
+	q := NewThreadSafeQueue()
+
+	if err := q.Push(&ReconciliationTask{TenantID: "t1", StateID: "s1"}); err != nil {
+		t.Fatalf("first push failed: %v", err)
+	}
+	if err := q.Push(&ReconciliationTask{TenantID: "t1", StateID: "s1"}); !errors.Is(err, ErrDuplicateTask) {
+		t.Fatalf("expected ErrDuplicateTask for queued state, got %v", err)
+	}
+	// Another tenant's state of the same ID is its own
+	if err := q.Push(&ReconciliationTask{TenantID: "t2", StateID: "s1"}); err != nil {
+		t.Fatalf("push for another tenant failed: %v", err)
+	}
+	if q.Len() != 2 {
+		t.Fatalf("len = %d, want 2", q.Len())
+	}
+	q.Remove("t2", "s1")
+
+	// Popped task is in flight: refused until Done, which then queues the
+	// task pushed meanwhile so the run after it sees the newer state
+	task := q.Pop()
+	rerun := &ReconciliationTask{ReqID: "rerun", TenantID: "t1", StateID: "s1"}
+	if err := q.Push(rerun); !errors.Is(err, ErrDuplicateTask) {
+		t.Fatalf("expected ErrDuplicateTask for in-flight state, got %v", err)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("len = %d while in flight, want 0", q.Len())
+	}
+	q.Done(task)
+	if got := q.Pop(); got != rerun {
+		t.Fatalf("expected the task pushed while in flight to be queued by Done, got %+v", got)
+	}
+	q.Done(rerun)
+	if q.Contains("t1", "s1") {
+		t.Fatal("state still tracked after Done")
+	}
+	if err := q.Push(&ReconciliationTask{TenantID: "t1", StateID: "s1"}); err != nil {
+		t.Fatalf("push after Done failed: %v", err)
+	}
+}
+
+func TestQueueRemove(t *testing.T) {
+	q := NewThreadSafeQueue()
+	for _, id := range []string{"s1", "s2", "s3"} {
+		if err := q.Push(&ReconciliationTask{TenantID: "t1", StateID: id, Priority: 1}); err != nil {
+			t.Fatalf("push %s: %v", id, err)
+		}
+	}
+
+	// Another tenant's state of the same ID is left alone
+	if q.Remove("t2", "s2") {
+		t.Fatal("Remove(t2, s2) = true for another tenant's task")
+	}
+
+	// Queued task: gone from the heap and released
+	if !q.Remove("t1", "s2") {
+		t.Fatal("Remove(s2) = false for a queued task")
+	}
+	if q.Len() != 2 || q.Contains("t1", "s2") {
+		t.Fatalf("after Remove: len %d, contains s2 %v", q.Len(), q.Contains("t1", "s2"))
+	}
+	if q.Remove("t1", "s2") {
+		t.Error("second Remove(s2) = true")
+	}
+
+	// In-flight task: its Done and requeue must not touch a newer task
+	inFlight := q.Pop()
+	if !q.Remove("t1", inFlight.StateID) {
+		t.Fatal("Remove = false for an in-flight task")
+	}
+	fresh := &ReconciliationTask{TenantID: "t1", StateID: inFlight.StateID}
+	if err := q.Push(fresh); err != nil {
+		t.Fatalf("push after Remove: %v", err)
+	}
+	q.requeue(inFlight)
+	q.Done(inFlight)
+	if !q.Contains("t1", fresh.StateID) {
+		t.Error("Done of the removed task released the new one")
+	}
+	if q.Len() != 2 {
+		t.Errorf("len = %d, want 2; removed task was requeued", q.Len())
+	}
+}
+
+// pollStore returns a fixed set of states per status.
+type pollStore struct {
+	mu     sync.Mutex
+	states map[string][]*store.DesiredState
+	calls  int
+}
+
+func (p *pollStore) ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*store.DesiredState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return p.states[status], nil
+}
+
+func (p *pollStore) add(status string, state *store.DesiredState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states[status] = append(p.states[status], state)
+}
+
+func (p *pollStore) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func TestPollerEnqueuesOnlyNewStates(t *testing.T) {
+	ps := &pollStore{states: map[string][]*store.DesiredState{
+		"pending": {{StateID: "s1", NodeID: "node-1", TenantID: "t1"}},
+		"drifted": {{StateID: "s2", NodeID: "node-1", TenantID: "t1"}},
+	}}
+	cfg := DefaultSchedulerConfig()
+	cfg.PollInterval = 20 * time.Millisecond
+	sched := NewScheduler(ps, &MockReconciler{}, 0, 1, cfg)
+	if err := sched.RehydrateQueue(context.Background()); err != nil {
+		t.Fatalf("rehydrate failed: %v", err)
+	}
+
+	// The worker's freeze window keeps tasks queued while the poller ticks
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+
+	ps.add("pending", &store.DesiredState{StateID: "s3", NodeID: "node-1", TenantID: "t1"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ps.callCount() < 10 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if depth := sched.GetMetrics().QueueDepth; depth != 3 {
+		t.Errorf("expected 3 queued tasks after repeated polls, got %d", depth)
+	}
+	if !sched.queue.Contains("t1", "s3") {
+		t.Error("poller did not enqueue newly pending state")
+	}
+}
+
+func TestDriftSweepSubmitsOverdueStates(t *testing.T) {
+	now := time.Now()
+	ps := &pollStore{states: map[string][]*store.DesiredState{
+		"compliant": {
+			{StateID: "due", NodeID: "node-1", TenantID: "t1", CheckInterval: 60, LastChecked: now.Add(-2 * time.Minute)},
+			{StateID: "fresh", NodeID: "node-1", TenantID: "t1", CheckInterval: 60, LastChecked: now.Add(-10 * time.Second)},
+			{StateID: "no-interval", NodeID: "node-1", TenantID: "t1", LastChecked: now.Add(-24 * time.Hour)},
+		},
+		"failed": {
+			{StateID: "failed-due", NodeID: "node-2", TenantID: "t1", CheckInterval: 30, LastChecked: now.Add(-time.Minute)},
+		},
+	}}
+	sched := NewScheduler(ps, &MockReconciler{}, 0, 1, DefaultSchedulerConfig())
+	sched.RehydrateQueue(context.Background()) // Activate scheduler
+
+	n, err := sched.sweepDrift(context.Background(), now)
+	if err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 drift checks, got %d", n)
+	}
+	for _, id := range []string{"due", "failed-due"} {
+		if !sched.queue.Contains("t1", id) {
+			t.Errorf("expected %s to be queued", id)
+		}
+	}
+	if task := sched.queue.Peek(); task.Priority != DriftCheckPriority {
+		t.Errorf("expected drift priority %d, got %d", DriftCheckPriority, task.Priority)
+	}
+
+	// Once drained, a check that never advanced LastChecked is not resubmitted within its interval
+	for task := sched.queue.Pop(); task != nil; task = sched.queue.Pop() {
+		sched.queue.Done(task)
+	}
+	if n, _ := sched.sweepDrift(context.Background(), now.Add(10*time.Second)); n != 0 {
+		t.Errorf("expected no resubmission within interval, got %d", n)
+	}
+	// "fresh" is due by now too
+	if n, _ := sched.sweepDrift(context.Background(), now.Add(2*time.Minute)); n != 3 {
+		t.Errorf("expected resubmission after interval, got %d", n)
+	}
+}
+
+func TestDriftSweepRespectsTenantRate(t *testing.T) {
+	ps := &pollStore{states: map[string][]*store.DesiredState{}}
+	for i := 0; i < 10; i++ {
+		ps.add("compliant", &store.DesiredState{StateID: fmt.Sprintf("s%d", i), NodeID: "node-1", TenantID: "noisy"})
+	}
+	ps.add("compliant", &store.DesiredState{StateID: "quiet-1", NodeID: "node-1", TenantID: "quiet"})
+
+	cfg := DefaultSchedulerConfig()
+	cfg.DefaultCheckInterval = time.Minute
+	cfg.DriftChecksPerTenant = 3
+	sched := NewScheduler(ps, &MockReconciler{}, 0, 1, cfg)
+	sched.RehydrateQueue(context.Background()) // Activate scheduler
+
+	n, err := sched.sweepDrift(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("expected 3 noisy + 1 quiet checks, got %d", n)
+	}
+	if !sched.queue.Contains("quiet", "quiet-1") {
+		t.Error("quiet tenant starved by noisy tenant")
+	}
+}
@@ -58,6 +58,23 @@ type SchedulerConfig struct {
 
 	// CircuitBreakerThreshold is the queue depth that triggers circuit open
 	CircuitBreakerThreshold int // Default: 1000
+
+	// PollInterval is how often the poller pulls pending/drifted states
+	// for this shard from the store and enqueues any not already queued
+	PollInterval time.Duration // Default: 5 seconds
+
+	// DriftSweepInterval is how often the drift sweeper looks for
+	// compliant/failed states whose last check is older than their
+	// check interval. Zero or negative disables the sweeper.
+	DriftSweepInterval time.Duration // Default: 30 seconds
+
+	// DefaultCheckInterval applies to states without CheckInterval.
+	// Zero means only states with an explicit interval are re-checked.
+	DefaultCheckInterval time.Duration // Default: 0 (opt-in per state)
+
+	// DriftChecksPerTenant caps drift-check submissions per tenant per
+	// second so one large tenant cannot flood the queue with background work
+	DriftChecksPerTenant float64 // Default: 5
 }
 
 // DefaultSchedulerConfig returns sensible production defaults.
@@ -66,6 +83,9 @@ func DefaultSchedulerConfig() SchedulerConfig {
 		MaxTaskExecutionTime:    5 * time.Minute,
 		MaxConcurrency:          10,
 		CircuitBreakerThreshold: 1000,
+		PollInterval:            5 * time.Second,
+		DriftSweepInterval:      30 * time.Second,
+		DriftChecksPerTenant:    5,
 	}
 }
 
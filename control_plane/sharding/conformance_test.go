@@ -0,0 +1,159 @@
+package sharding_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/scheduler"
+	"github.com/itskum47/FluxForge/control_plane/sharding"
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// shardedStore is the part of store.Store the conformance test exercises.
+type shardedStore interface {
+	UpsertState(ctx context.Context, tenantID string, state *store.DesiredState) error
+	ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*store.DesiredState, error)
+}
+
+// backends returns every store reachable from this environment. Redis and
+// Postgres join when FLUXFORGE_TEST_REDIS_ADDR / FLUXFORGE_TEST_DATABASE_URL
+// are set (Postgres is migrated to the latest schema first).
+func backends(t *testing.T) map[string]shardedStore {
+	out := map[string]shardedStore{"memory": store.NewMemoryStore()}
+
+	if addr := os.Getenv("FLUXFORGE_TEST_REDIS_ADDR"); addr != "" {
+		rs, err := store.NewRedisStore(addr, "", 0)
+		if err != nil {
+			t.Fatalf("redis: %v", err)
+		}
+		t.Cleanup(func() { rs.Client().Close() })
+		out["redis"] = rs
+	} else {
+		t.Log("FLUXFORGE_TEST_REDIS_ADDR not set, skipping redis")
+	}
+
+	if url := os.Getenv("FLUXFORGE_TEST_DATABASE_URL"); url != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ps, err := store.NewPostgresStore(ctx, url)
+		if err != nil {
+			t.Fatalf("postgres: %v", err)
+		}
+		t.Cleanup(ps.Close)
+		if _, err := ps.MigrateUp(ctx); err != nil {
+			t.Fatalf("postgres migrate: %v", err)
+		}
+		out["postgres"] = ps
+	} else {
+		t.Log("FLUXFORGE_TEST_DATABASE_URL not set, skipping postgres")
+	}
+	return out
+}
+
+// TestShardOwnershipConformance proves every store and the scheduler assign
+// each state to the same shard, and that shards partition the states.
+func TestShardOwnershipConformance(t *testing.T) {
+	const shardCount = 3
+	const numStates = 60
+	ctx := context.Background()
+	run := fmt.Sprintf("conf-%d", time.Now().UnixNano()) // Isolate from data in shared backends
+
+	schedulers := make([]*scheduler.Scheduler, shardCount)
+	for i := range schedulers {
+		schedulers[i] = scheduler.NewScheduler(nil, nil, i, shardCount, scheduler.DefaultSchedulerConfig())
+	}
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			upsertStates(t, s, run, numStates)
+
+			seen := make(map[string]int)
+			for shard := 0; shard < shardCount; shard++ {
+				states, err := s.ListStatesByStatus(ctx, "pending", shard, shardCount)
+				if err != nil {
+					t.Fatalf("ListStatesByStatus: %v", err)
+				}
+				for _, st := range states {
+					if len(st.StateID) <= len(run) || st.StateID[:len(run)] != run {
+						continue
+					}
+					if prev, dup := seen[st.StateID]; dup {
+						t.Errorf("%s listed by shards %d and %d", st.StateID, prev, shard)
+					}
+					seen[st.StateID] = shard
+
+					if want := sharding.Modulo(shardCount).Owner(st.NodeID); want != shard {
+						t.Errorf("%s: store put node %s on shard %d, partitioner says %d", name, st.NodeID, shard, want)
+					}
+					if got := schedulers[shard].OwnerOf(st.NodeID); got != shard {
+						t.Errorf("%s: store put node %s on shard %d, scheduler says %d", name, st.NodeID, shard, got)
+					}
+					if st.TenantID != "conformance" {
+						t.Errorf("%s: expected tenant on listed state, got %q", name, st.TenantID)
+					}
+				}
+			}
+			if len(seen) != numStates {
+				t.Errorf("expected %d states across all shards, got %d", numStates, len(seen))
+			}
+		})
+	}
+}
+
+// TestRingOwnershipConformance proves that with a consistent-hash ring,
+// which the stores cannot filter on, each scheduler still rehydrates
+// exactly the states the ring gives its shard.
+func TestRingOwnershipConformance(t *testing.T) {
+	const numStates = 60
+	ctx := context.Background()
+	run := fmt.Sprintf("ring-%d", time.Now().UnixNano())
+	ring := sharding.NewRing(sharding.DefaultReplicas, 0, 1, 2)
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			upsertStates(t, s, run, numStates)
+
+			schedulers := make([]*scheduler.Scheduler, len(ring.Shards()))
+			for i := range schedulers {
+				schedulers[i] = scheduler.NewScheduler(s, nil, i, len(schedulers), scheduler.DefaultSchedulerConfig())
+				schedulers[i].SetPartitioner(ring)
+				if err := schedulers[i].RehydrateQueue(ctx); err != nil {
+					t.Fatalf("RehydrateQueue(shard %d): %v", i, err)
+				}
+			}
+
+			for i := 0; i < numStates; i++ {
+				stateID, nodeID := fmt.Sprintf("%s-state-%d", run, i), fmt.Sprintf("%s-node-%d", run, i)
+				var queuedOn []int
+				for shard, sched := range schedulers {
+					if sched.Cancel("conformance", stateID) {
+						queuedOn = append(queuedOn, shard)
+					}
+				}
+				if want := ring.Owner(nodeID); len(queuedOn) != 1 || queuedOn[0] != want {
+					t.Errorf("%s queued on shards %v, ring owner is %d", stateID, queuedOn, want)
+				}
+			}
+		})
+	}
+}
+
+// upsertStates writes n pending states, each on its own node, with IDs
+// prefixed by run.
+func upsertStates(t *testing.T, s shardedStore, run string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		state := &store.DesiredState{
+			StateID:  fmt.Sprintf("%s-state-%d", run, i),
+			NodeID:   fmt.Sprintf("%s-node-%d", run, i),
+			TenantID: "conformance",
+			Status:   "pending",
+		}
+		if err := s.UpsertState(context.Background(), "conformance", state); err != nil {
+			t.Fatalf("UpsertState: %v", err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package sharding
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Partitioner maps a node ID to the shard that owns it.
+//
+// Work is sharded by NodeID everywhere: the scheduler decides which tasks it
+// accepts, and every store filters ListStatesByStatus, through the same
+// Partitioner so that rehydrate, polling and submit agree on ownership.
+type Partitioner interface {
+	Owner(nodeID string) int
+}
+
+// Hash is the shard hash shared by all partitioners. It is PostgreSQL's
+// hashtext (Bob Jenkins' lookup3, as hash_bytes computes it on a
+// little-endian server) read as unsigned, so PostgresStore can shard in SQL
+// and still agree with the other stores and the scheduler.
+func Hash(key string) uint32 {
+	k := []byte(key)
+	a := 0x9e3779b9 + uint32(len(k)) + 3923095
+	b, c := a, a
+	for ; len(k) >= 12; k = k[12:] {
+		a += binary.LittleEndian.Uint32(k)
+		b += binary.LittleEndian.Uint32(k[4:])
+		c += binary.LittleEndian.Uint32(k[8:])
+		a, b, c = mix(a, b, c)
+	}
+	// The lowest byte of c is left for the length, as in hash_bytes
+	switch len(k) {
+	case 11:
+		c += uint32(k[10]) << 24
+		fallthrough
+	case 10:
+		c += uint32(k[9]) << 16
+		fallthrough
+	case 9:
+		c += uint32(k[8]) << 8
+		fallthrough
+	case 8:
+		b += uint32(k[7]) << 24
+		fallthrough
+	case 7:
+		b += uint32(k[6]) << 16
+		fallthrough
+	case 6:
+		b += uint32(k[5]) << 8
+		fallthrough
+	case 5:
+		b += uint32(k[4])
+		fallthrough
+	case 4:
+		a += uint32(k[3]) << 24
+		fallthrough
+	case 3:
+		a += uint32(k[2]) << 16
+		fallthrough
+	case 2:
+		a += uint32(k[1]) << 8
+		fallthrough
+	case 1:
+		a += uint32(k[0])
+	}
+	return final(a, b, c)
+}
+
+func mix(a, b, c uint32) (uint32, uint32, uint32) {
+	a -= c
+	a ^= bits.RotateLeft32(c, 4)
+	c += b
+	b -= a
+	b ^= bits.RotateLeft32(a, 6)
+	a += c
+	c -= b
+	c ^= bits.RotateLeft32(b, 8)
+	b += a
+	a -= c
+	a ^= bits.RotateLeft32(c, 16)
+	c += b
+	b -= a
+	b ^= bits.RotateLeft32(a, 19)
+	a += c
+	c -= b
+	c ^= bits.RotateLeft32(b, 4)
+	b += a
+	return a, b, c
+}
+
+func final(a, b, c uint32) uint32 {
+	c ^= b
+	c -= bits.RotateLeft32(b, 14)
+	a ^= c
+	a -= bits.RotateLeft32(c, 11)
+	b ^= a
+	b -= bits.RotateLeft32(a, 25)
+	c ^= b
+	c -= bits.RotateLeft32(b, 16)
+	a ^= c
+	a -= bits.RotateLeft32(c, 4)
+	b ^= a
+	b -= bits.RotateLeft32(a, 14)
+	c ^= b
+	c -= bits.RotateLeft32(b, 24)
+	return c
+}
+
+// Modulo is the static layout for a fixed POD_COUNT: hash(nodeID) mod the
+// shard count. Changing the count reassigns most nodes; use a Ring when
+// shards come and go.
+type Modulo int
+
+// Owner returns the shard that owns nodeID.
+func (m Modulo) Owner(nodeID string) int {
+	if m <= 1 {
+		return 0
+	}
+	return int(Hash(nodeID) % uint32(m))
+}
+
+// Owns reports whether shard owns nodeID under p.
+func Owns(p Partitioner, nodeID string, shard int) bool {
+	return p.Owner(nodeID) == shard
+}
@@ -0,0 +1,90 @@
+// Package sharding decides which control-plane shard owns a node's work.
+package sharding
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultReplicas is the number of virtual points each shard places on the
+// ring. More points spread keys more evenly at the cost of a larger ring.
+const DefaultReplicas = 128
+
+// Ring is an immutable consistent-hash ring over shard indexes. It implements
+// Partitioner.
+// Adding or removing a shard only moves the keys that hashed to it,
+// so a POD_COUNT change does not reshuffle every node.
+type Ring struct {
+	points []uint32
+	owners map[uint32]int
+	shards []int
+}
+
+// NewRing builds a ring with replicas virtual points per shard.
+func NewRing(replicas int, shards ...int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	r := &Ring{owners: make(map[uint32]int)}
+
+	seen := make(map[int]bool)
+	for _, shard := range shards {
+		if seen[shard] {
+			continue
+		}
+		seen[shard] = true
+		r.shards = append(r.shards, shard)
+
+		for i := 0; i < replicas; i++ {
+			p := Hash(fmt.Sprintf("shard-%d#%d", shard, i))
+			// On the (unlikely) collision the lower shard index wins,
+			// so every pod builds the same ring regardless of input order.
+			if owner, ok := r.owners[p]; ok {
+				if shard < owner {
+					r.owners[p] = shard
+				}
+				continue
+			}
+			r.owners[p] = shard
+			r.points = append(r.points, p)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	sort.Ints(r.shards)
+	return r
+}
+
+// Owner returns the shard that owns key, or -1 if the ring is empty.
+func (r *Ring) Owner(key string) int {
+	if r == nil || len(r.points) == 0 {
+		return -1
+	}
+	h := Hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0 // Wrap around
+	}
+	return r.owners[r.points[i]]
+}
+
+// Shards returns the shard indexes on the ring in ascending order.
+func (r *Ring) Shards() []int {
+	if r == nil {
+		return nil
+	}
+	return append([]int(nil), r.shards...)
+}
+
+// Equal reports whether both rings hold the same shards.
+func (r *Ring) Equal(other *Ring) bool {
+	a, b := r.Shards(), other.Shards()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
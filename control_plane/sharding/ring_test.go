@@ -0,0 +1,51 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingOwnerIsDeterministic(t *testing.T) {
+	a := NewRing(DefaultReplicas, 0, 1, 2)
+	b := NewRing(DefaultReplicas, 2, 0, 1, 1)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("node-%d", i)
+		if a.Owner(key) != b.Owner(key) {
+			t.Fatalf("rings built in different order disagree on %s", key)
+		}
+	}
+	if NewRing(DefaultReplicas).Owner("node-1") != -1 {
+		t.Error("expected -1 from empty ring")
+	}
+}
+
+func TestRingAddingShardMovesOnlyItsKeys(t *testing.T) {
+	before := NewRing(DefaultReplicas, 0, 1, 2)
+	after := NewRing(DefaultReplicas, 0, 1, 2, 3)
+
+	const keys = 10000
+	moved := 0
+	counts := make(map[int]int)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("node-%d", i)
+		o1, o2 := before.Owner(key), after.Owner(key)
+		counts[o2]++
+		if o1 != o2 {
+			moved++
+			if o2 != 3 {
+				t.Fatalf("key %s moved from %d to %d, expected only moves to the new shard", key, o1, o2)
+			}
+		}
+	}
+
+	// Ideal is 1/4 of the keys; allow generous slack for hash variance
+	if moved < keys/8 || moved > keys/2 {
+		t.Errorf("expected about %d keys to move, moved %d", keys/4, moved)
+	}
+	for shard := 0; shard < 4; shard++ {
+		if counts[shard] < keys/8 {
+			t.Errorf("shard %d owns only %d of %d keys", shard, counts[shard], keys)
+		}
+	}
+}
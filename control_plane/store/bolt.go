@@ -0,0 +1,1087 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/itskum47/FluxForge/control_plane/sharding"
+)
+
+// Bucket names. Records in agents, states and jobs are keyed by TenantKey,
+// so a tenant's records are one contiguous prefix.
+var (
+	boltAgents = []byte("agents")
+	boltStates = []byte("states")
+	boltJobs   = []byte("jobs")
+	boltEpochs = []byte("epochs")
+	boltKV     = []byte("kv")     // Idempotency records and Set/Get values
+	boltLeases = []byte("leases") // Coordinator locks and leases
+
+	boltTombstones = []byte("tombstones") // Keyed by TombstoneKey
+	boltRevisions  = []byte("revisions")  // Keyed by RevisionKey
+	boltOutbox     = []byte("outbox")     // Keyed by big-endian sequence
+
+	// Created-order indexes for the Query* listings, keyed by boltIndexKey
+	// with empty values
+	boltAgentsByCreated = []byte("agents_by_created")
+	boltJobsByCreated   = []byte("jobs_by_created")
+)
+
+// BoltStore implements Store and Coordinator in a single bbolt file for
+// edge and development deployments without Redis or Postgres.
+//
+// Every write is an fsync'd transaction. bbolt takes an exclusive file
+// lock, so exactly one control plane process can use a file: leases exist
+// for single-node leader election and the janitor, not to coordinate
+// replicas.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// expiringValue is how kv and leases entries are stored.
+type expiringValue struct {
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // Zero = never
+}
+
+func (e expiringValue) live(now time.Time) bool {
+	return e.ExpiresAt.IsZero() || now.Before(e.ExpiresAt)
+}
+
+// NewBoltStore opens (creating if needed) the store file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 2 * time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is locked by another process", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltAgents, boltStates, boltJobs, boltEpochs, boltKV, boltLeases, boltTombstones, boltRevisions, boltOutbox} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		// Index records written before the indexes existed
+		if err := createIndex(tx, boltAgentsByCreated, boltAgents, ResourceAgent); err != nil {
+			return err
+		}
+		return createIndex(tx, boltJobsByCreated, boltJobs, ResourceJob)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// StartExpiry periodically deletes expired idempotency records and leases,
+// which are otherwise only skipped on read.
+func (s *BoltStore) StartExpiry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.purgeExpired(time.Now()); err != nil {
+					log.Printf("⚠️ BoltStore: expiry sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (s *BoltStore) purgeExpired(now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltKV, boltLeases} {
+			b := tx.Bucket(name)
+			var expired [][]byte
+			err := b.ForEach(func(k, v []byte) error {
+				var e expiringValue
+				if json.Unmarshal(v, &e) == nil && !e.live(now) {
+					expired = append(expired, k)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range expired {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// --- helpers ---
+
+func getJSON(b *bolt.Bucket, key string, out interface{}) (bool, error) {
+	data := b.Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, out)
+}
+
+func putJSON(b *bolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
+
+// scanPrefix calls fn for each value whose key starts with prefix
+// ("" = the whole bucket).
+func scanPrefix(b *bolt.Bucket, prefix string, fn func(v []byte) error) error {
+	p := []byte(prefix)
+	c := b.Cursor()
+	for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltIndexKey is a record's key in a created-order index: its tenant's
+// prefix, then its createdKey, so a tenant's entries are contiguous and in
+// listing order.
+func boltIndexKey(tenantID string, resource Resource, pos pagePosition) []byte {
+	return []byte(TenantPrefix(tenantID, resource) + pos.createdKey())
+}
+
+// createIndex creates the index bucket for records unless it exists, and
+// fills it from them.
+func createIndex(tx *bolt.Tx, index, records []byte, resource Resource) error {
+	if tx.Bucket(index) != nil {
+		return nil
+	}
+	b, err := tx.CreateBucket(index)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(records).ForEach(func(k, v []byte) error {
+		tenantID, pos, err := recordPosition(resource, v)
+		if err != nil {
+			return fmt.Errorf("index %s: %w", k, err)
+		}
+		return b.Put(boltIndexKey(tenantID, resource, pos), []byte{})
+	})
+}
+
+// boltIndexScan reads a tenant's entries of a created-order index, within
+// the createdBounds of opts, as the createdKeys keysetPage pages through.
+func boltIndexScan(b *bolt.Bucket, tenantID string, resource Resource, opts ListOptions) indexScan {
+	prefix := TenantPrefix(tenantID, resource)
+	low, high := opts.createdBounds()
+	return func(from string, n int) ([]string, error) {
+		c := b.Cursor()
+		var k []byte
+		if opts.Order == SortAsc {
+			start := low
+			if from != "" {
+				start = from
+			}
+			k, _ = c.Seek([]byte(prefix + start))
+			if from != "" && string(k) == prefix+from {
+				k, _ = c.Next()
+			}
+		} else {
+			// The entry before the first one at or after start is the
+			// newest in range
+			start := high
+			if from != "" {
+				start = from
+			} else if start == "" {
+				start = "\xff"
+			}
+			if k, _ = c.Seek([]byte(prefix + start)); k == nil {
+				k, _ = c.Last()
+			} else {
+				k, _ = c.Prev()
+			}
+		}
+
+		var keys []string
+		for k != nil && strings.HasPrefix(string(k), prefix) && len(keys) < n {
+			key := string(k[len(prefix):])
+			if opts.Order == SortAsc {
+				if high != "" && key >= high {
+					break
+				}
+				k, _ = c.Next()
+			} else {
+				if key < low {
+					break
+				}
+				k, _ = c.Prev()
+			}
+			keys = append(keys, key)
+		}
+		return keys, nil
+	}
+}
+
+// boltLoad loads the tenant's records for index entries, nil where one is
+// gone.
+func boltLoad[T any](b *bolt.Bucket, tenantID string, resource Resource, keys []string) ([]*T, error) {
+	records := make([]*T, len(keys))
+	for i, key := range keys {
+		var record T
+		found, err := getJSON(b, TenantKey(tenantID, resource, createdKeyID(key)), &record)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			records[i] = &record
+		}
+	}
+	return records, nil
+}
+
+// --- Agent Operations ---
+
+func (s *BoltStore) UpsertAgent(ctx context.Context, tenantID string, agent *Agent) error {
+	agent.TenantID = tenantID
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltAgents)
+		key := TenantKey(tenantID, ResourceAgent, agent.NodeID)
+
+		now := time.Now()
+		var prev Agent
+		found, err := getJSON(b, key, &prev)
+		if err != nil {
+			return err
+		}
+		agent.CreatedAt = createdAt(ctx, now)
+		if found {
+			agent.CreatedAt = prev.CreatedAt
+		}
+		agent.UpdatedAt = now
+		if err := tx.Bucket(boltTombstones).Delete([]byte(TombstoneKey(tenantID, ResourceAgent, agent.NodeID))); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltAgentsByCreated).Put(boltIndexKey(tenantID, ResourceAgent, agentPosition(agent)), []byte{}); err != nil {
+			return err
+		}
+		return putJSON(b, key, agent)
+	})
+}
+
+func (s *BoltStore) GetAgent(ctx context.Context, tenantID string, nodeID string) (*Agent, error) {
+	var agent Agent
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		found, err = getJSON(tx.Bucket(boltAgents), TenantKey(tenantID, ResourceAgent, nodeID), &agent)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+func (s *BoltStore) ListAgents(ctx context.Context, tenantID string) ([]*Agent, error) {
+	prefix := ""
+	if tenantID != "" {
+		prefix = TenantPrefix(tenantID, ResourceAgent)
+	}
+	var agents []*Agent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scanPrefix(tx.Bucket(boltAgents), prefix, func(v []byte) error {
+			var agent Agent
+			if err := json.Unmarshal(v, &agent); err != nil {
+				return err
+			}
+			agents = append(agents, &agent)
+			return nil
+		})
+	})
+	return agents, err
+}
+
+// QueryAgents seeks the created-order index to the cursor and reads one
+// page from there.
+func (s *BoltStore) QueryAgents(ctx context.Context, tenantID string, opts ListOptions) (*AgentPage, error) {
+	opts, after, err := opts.normalize(ResourceAgent)
+	if err != nil {
+		return nil, err
+	}
+	page := &AgentPage{}
+	err = s.db.View(func(tx *bolt.Tx) (err error) {
+		agents := tx.Bucket(boltAgents)
+		page.Agents, page.NextCursor, err = keysetPage(opts, after,
+			boltIndexScan(tx.Bucket(boltAgentsByCreated), tenantID, ResourceAgent, opts),
+			func(keys []string) ([]*Agent, error) { return boltLoad[Agent](agents, tenantID, ResourceAgent, keys) },
+			agentPosition, opts.matchesAgent)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *BoltStore) UpdateAgentHeartbeat(ctx context.Context, tenantID string, nodeID string, t time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltAgents)
+		key := TenantKey(tenantID, ResourceAgent, nodeID)
+		var agent Agent
+		found, err := getJSON(b, key, &agent)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("agent %s: %w", nodeID, ErrNotFound)
+		}
+		agent.LastHeartbeat = t
+		return putJSON(b, key, &agent)
+	})
+}
+
+func (s *BoltStore) DeleteAgent(ctx context.Context, tenantID string, nodeID string) error {
+	var agent Agent
+	return s.deleteWithTombstone(boltAgents, ResourceAgent, tenantID, nodeID, &agent, func(tx *bolt.Tx) error {
+		return tx.Bucket(boltAgentsByCreated).Delete(boltIndexKey(tenantID, ResourceAgent, agentPosition(&agent)))
+	})
+}
+
+// --- State Operations ---
+
+func (s *BoltStore) UpsertState(ctx context.Context, tenantID string, state *DesiredState) error {
+	state.TenantID = tenantID
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltStates)
+		key := TenantKey(tenantID, ResourceState, state.StateID)
+
+		now := time.Now()
+		var existing DesiredState
+		found, err := getJSON(b, key, &existing)
+		if err != nil {
+			return err
+		}
+		if err := checkExpectedVersion(ctx, state.StateID, existing.Version); err != nil {
+			return err
+		}
+		state.Version = 1
+		state.CreatedAt = createdAt(ctx, now)
+		if found {
+			state.Version = existing.Version + 1
+			state.CreatedAt = existing.CreatedAt
+		}
+		state.UpdatedAt = now
+		if err := tx.Bucket(boltTombstones).Delete([]byte(TombstoneKey(tenantID, ResourceState, state.StateID))); err != nil {
+			return err
+		}
+		rev := newStateRevision(ctx, state)
+		if err := putJSON(tx.Bucket(boltRevisions), RevisionKey(tenantID, state.StateID, rev.Revision), rev); err != nil {
+			return err
+		}
+		return putJSON(b, key, state)
+	})
+}
+
+func (s *BoltStore) UpdateStateStatus(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int) error {
+	return s.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, nil)
+}
+
+func (s *BoltStore) UpdateStateStatusWithEvent(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int, event *OutboxEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltStates)
+		key := TenantKey(tenantID, ResourceState, stateID)
+
+		var state DesiredState
+		found, err := getJSON(b, key, &state)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("state %s: %w", stateID, ErrNotFound)
+		}
+		if state.Version != expectedVersion {
+			return fmt.Errorf("state %s at version %d, expected %d: %w", stateID, state.Version, expectedVersion, ErrVersionConflict)
+		}
+
+		state.Status = status
+		state.LastError = lastError
+		state.LastChecked = lastChecked
+		state.UpdatedAt = time.Now()
+		if err := putJSON(b, key, &state); err != nil {
+			return err
+		}
+		if event == nil {
+			return nil
+		}
+		outbox := tx.Bucket(boltOutbox)
+		seq, err := outbox.NextSequence()
+		if err != nil {
+			return err
+		}
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, seq)
+		v, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return outbox.Put(k, v)
+	})
+}
+
+func (s *BoltStore) GetState(ctx context.Context, tenantID string, stateID string) (*DesiredState, error) {
+	var state DesiredState
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		found, err = getJSON(tx.Bucket(boltStates), TenantKey(tenantID, ResourceState, stateID), &state)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetStateByNode returns the node's most recently created state.
+func (s *BoltStore) GetStateByNode(ctx context.Context, tenantID string, nodeID string) (*DesiredState, error) {
+	states, err := s.ListStates(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var newest *DesiredState
+	for _, state := range states {
+		if state.NodeID == nodeID && (newest == nil || state.CreatedAt.After(newest.CreatedAt)) {
+			newest = state
+		}
+	}
+	return newest, nil
+}
+
+func (s *BoltStore) ListStates(ctx context.Context, tenantID string) ([]*DesiredState, error) {
+	return s.scanStates(TenantPrefix(tenantID, ResourceState), func(*DesiredState) bool { return true })
+}
+
+func (s *BoltStore) QueryStates(ctx context.Context, tenantID string, opts ListOptions) (*StatePage, error) {
+	states, err := s.ListStates(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return pageStates(states, opts)
+}
+
+func (s *BoltStore) DeleteState(ctx context.Context, tenantID string, stateID string) error {
+	var state DesiredState
+	return s.deleteWithTombstone(boltStates, ResourceState, tenantID, stateID, &state, func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltRevisions)
+		var keys [][]byte
+		p := []byte(RevisionPrefix(tenantID, stateID))
+		c := b.Cursor()
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) ListStateRevisions(ctx context.Context, tenantID string, stateID string) ([]*StateRevision, error) {
+	revs := []*StateRevision{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scanPrefix(tx.Bucket(boltRevisions), RevisionPrefix(tenantID, stateID), func(v []byte) error {
+			var rev StateRevision
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return err
+			}
+			revs = append(revs, &rev)
+			return nil
+		})
+	})
+	return revs, err
+}
+
+func (s *BoltStore) GetStateRevision(ctx context.Context, tenantID string, stateID string, revision int) (*StateRevision, error) {
+	var rev StateRevision
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		found, err = getJSON(tx.Bucket(boltRevisions), RevisionKey(tenantID, stateID, revision), &rev)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// deleteWithTombstone swaps a record for its tombstone in one transaction.
+// record receives the stored value and is what the tombstone keeps;
+// cleanup, if set, removes data that goes with the record.
+func (s *BoltStore) deleteWithTombstone(bucket []byte, resource Resource, tenantID, id string, record interface{}, cleanup func(tx *bolt.Tx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		key := TenantKey(tenantID, resource, id)
+		found, err := getJSON(b, key, record)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%s %s: %w", resource, id, ErrNotFound)
+		}
+		tomb, err := newTombstone(resource, tenantID, id, record)
+		if err != nil {
+			return err
+		}
+		if err := b.Delete([]byte(key)); err != nil {
+			return err
+		}
+		if cleanup != nil {
+			if err := cleanup(tx); err != nil {
+				return err
+			}
+		}
+		return putJSON(tx.Bucket(boltTombstones), TombstoneKey(tenantID, resource, id), tomb)
+	})
+}
+
+func (s *BoltStore) ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*DesiredState, error) {
+	partitioner := sharding.Modulo(shardCount)
+	return s.scanStates("", func(state *DesiredState) bool {
+		return state.Status == status && sharding.Owns(partitioner, state.NodeID, shardIndex)
+	})
+}
+
+func (s *BoltStore) CountStatesByStatus(ctx context.Context, tenantID string, status string) (int, error) {
+	states, err := s.scanStates(TenantPrefix(tenantID, ResourceState), func(state *DesiredState) bool {
+		return state.Status == status
+	})
+	return len(states), err
+}
+
+func (s *BoltStore) scanStates(prefix string, keep func(*DesiredState) bool) ([]*DesiredState, error) {
+	var states []*DesiredState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scanPrefix(tx.Bucket(boltStates), prefix, func(v []byte) error {
+			var state DesiredState
+			if err := json.Unmarshal(v, &state); err != nil {
+				return err
+			}
+			if keep(&state) {
+				states = append(states, &state)
+			}
+			return nil
+		})
+	})
+	return states, err
+}
+
+// --- Job Operations ---
+
+func (s *BoltStore) CreateJob(ctx context.Context, tenantID string, job *Job) error {
+	job.TenantID = tenantID
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltJobs)
+		key := TenantKey(tenantID, ResourceJob, job.JobID)
+		index := tx.Bucket(boltJobsByCreated)
+		var prev Job
+		found, err := getJSON(b, key, &prev)
+		if err != nil {
+			return err
+		}
+		if found {
+			if err := index.Delete(boltIndexKey(tenantID, ResourceJob, jobPosition(&prev))); err != nil {
+				return err
+			}
+		}
+		if err := index.Put(boltIndexKey(tenantID, ResourceJob, jobPosition(job)), []byte{}); err != nil {
+			return err
+		}
+		return putJSON(b, key, job)
+	})
+}
+
+func (s *BoltStore) UpdateJobStatus(ctx context.Context, tenantID string, jobID string, status string, exitCode int, stdout, stderr string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltJobs)
+		key := TenantKey(tenantID, ResourceJob, jobID)
+
+		var job Job
+		found, err := getJSON(b, key, &job)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("job %s: %w", jobID, ErrNotFound)
+		}
+		if err := checkExpectedJobStatus(ctx, jobID, job.Status); err != nil {
+			return err
+		}
+
+		job.Status = status
+		now := time.Now()
+		if status == "running" {
+			job.StartedAt = &now
+		} else if jobFinished(status) {
+			job.FinishedAt = &now
+			job.ExitCode = exitCode
+			job.Stdout = stdout
+			job.Stderr = stderr
+		}
+		return putJSON(b, key, &job)
+	})
+}
+
+func (s *BoltStore) GetJob(ctx context.Context, tenantID string, jobID string) (*Job, error) {
+	var job Job
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		found, err = getJSON(tx.Bucket(boltJobs), TenantKey(tenantID, ResourceJob, jobID), &job)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *BoltStore) ListJobs(ctx context.Context, tenantID string, nodeID string, limit int) ([]*Job, error) {
+	jobs, err := s.scanJobs(tenantID, func(job *Job) bool { return job.NodeID == nodeID })
+	if err != nil {
+		return nil, err
+	}
+	return newestJobs(jobs, limit), nil
+}
+
+func (s *BoltStore) ListJobsByTenant(ctx context.Context, tenantID string, limit int) ([]*Job, error) {
+	jobs, err := s.scanJobs(tenantID, func(*Job) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	return newestJobs(jobs, limit), nil
+}
+
+// QueryJobs seeks the created-order index to the cursor and reads one page
+// from there.
+func (s *BoltStore) QueryJobs(ctx context.Context, tenantID string, opts ListOptions) (*JobPage, error) {
+	opts, after, err := opts.normalize(ResourceJob)
+	if err != nil {
+		return nil, err
+	}
+	page := &JobPage{}
+	err = s.db.View(func(tx *bolt.Tx) (err error) {
+		jobs := tx.Bucket(boltJobs)
+		page.Jobs, page.NextCursor, err = keysetPage(opts, after,
+			boltIndexScan(tx.Bucket(boltJobsByCreated), tenantID, ResourceJob, opts),
+			func(keys []string) ([]*Job, error) { return boltLoad[Job](jobs, tenantID, ResourceJob, keys) },
+			jobPosition, func(j *Job) bool { return opts.matches(j.Status, j.NodeID, j.CreatedAt, time.Time{}) })
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *BoltStore) PurgeJobs(ctx context.Context, tenantID string, purge JobPurge) (int, error) {
+	purged := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltJobs)
+		var keys, indexKeys [][]byte
+		p := []byte(TenantPrefix(tenantID, ResourceJob))
+		c := b.Cursor()
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			var job Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			if purge.Matches(&job) {
+				keys = append(keys, k)
+				indexKeys = append(indexKeys, boltIndexKey(tenantID, ResourceJob, jobPosition(&job)))
+			}
+		}
+		for i, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltJobsByCreated).Delete(indexKeys[i]); err != nil {
+				return err
+			}
+		}
+		purged = len(keys)
+		return nil
+	})
+	return purged, err
+}
+
+func (s *BoltStore) ListTenants(ctx context.Context) ([]string, error) {
+	set := make(map[string]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		buckets := map[Resource][]byte{ResourceAgent: boltAgents, ResourceState: boltStates, ResourceJob: boltJobs}
+		for resource, name := range buckets {
+			err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+				if tid, ok := tenantFromKey(string(k), resource); ok {
+					set[tid] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return sortedTenants(set), err
+}
+
+func (s *BoltStore) scanJobs(tenantID string, keep func(*Job) bool) ([]*Job, error) {
+	var jobs []*Job
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scanPrefix(tx.Bucket(boltJobs), TenantPrefix(tenantID, ResourceJob), func(v []byte) error {
+			var job Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			if keep(&job) {
+				jobs = append(jobs, &job)
+			}
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// ClaimNextJob scans and claims in one write transaction, so the same job
+// is never handed out twice.
+func (s *BoltStore) ClaimNextJob(ctx context.Context, tenantID string, nodeID string) (*Job, error) {
+	var next *Job
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltJobs)
+		err := scanPrefix(b, TenantPrefix(tenantID, ResourceJob), func(v []byte) error {
+			var job Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			if job.NodeID == nodeID && job.Status == "queued" &&
+				(next == nil || job.CreatedAt.Before(next.CreatedAt)) {
+				next = &job
+			}
+			return nil
+		})
+		if err != nil || next == nil {
+			return err
+		}
+
+		now := time.Now()
+		next.Status = "running"
+		next.StartedAt = &now
+		return putJSON(b, TenantKey(tenantID, ResourceJob, next.JobID), next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// --- Tombstones ---
+
+func (s *BoltStore) GetTombstone(ctx context.Context, tenantID string, resource Resource, id string) (*Tombstone, error) {
+	var tomb Tombstone
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		found, err = getJSON(tx.Bucket(boltTombstones), TombstoneKey(tenantID, resource, id), &tomb)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &tomb, nil
+}
+
+func (s *BoltStore) PurgeTombstones(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltTombstones)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var tomb Tombstone
+			if err := json.Unmarshal(v, &tomb); err != nil {
+				return err
+			}
+			if tomb.DeletedAt.Before(before) {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(keys)
+		return nil
+	})
+	return purged, err
+}
+
+// --- Outbox ---
+
+func (s *BoltStore) ReadOutbox(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	var events []*OutboxEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltOutbox).Cursor()
+		for k, v := c.First(); k != nil && (limit <= 0 || len(events) < limit); k, v = c.Next() {
+			var e OutboxEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			e.Position = strconv.FormatUint(binary.BigEndian.Uint64(k), 10)
+			events = append(events, &e)
+		}
+		return nil
+	})
+	return events, err
+}
+
+func (s *BoltStore) AckOutbox(ctx context.Context, positions []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltOutbox)
+		k := make([]byte, 8)
+		for _, p := range positions {
+			seq, err := strconv.ParseUint(p, 10, 64)
+			if err != nil {
+				return fmt.Errorf("outbox position %q: %w", p, err)
+			}
+			binary.BigEndian.PutUint64(k, seq)
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// --- Coordination Operations ---
+
+func (s *BoltStore) IncrementDurableEpoch(ctx context.Context, resourceID string) (int64, error) {
+	var epoch int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltEpochs)
+		if v := b.Get([]byte(resourceID)); v != nil {
+			epoch = int64(binary.BigEndian.Uint64(v))
+		}
+		epoch++
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(epoch))
+		return b.Put([]byte(resourceID), buf)
+	})
+	return epoch, err
+}
+
+func (s *BoltStore) GetDurableEpoch(ctx context.Context, resourceID string) (int64, error) {
+	var epoch int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltEpochs).Get([]byte(resourceID)); v != nil {
+			epoch = int64(binary.BigEndian.Uint64(v))
+		}
+		return nil
+	})
+	return epoch, err
+}
+
+// --- Idempotency Operations ---
+
+func (s *BoltStore) getLive(bucket []byte, key string) (string, bool, error) {
+	var e expiringValue
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		found, err = getJSON(tx.Bucket(bucket), key, &e)
+		return err
+	})
+	if err != nil || !found || !e.live(time.Now()) {
+		return "", false, err
+	}
+	return e.Value, true, nil
+}
+
+func newExpiringValue(value string, ttl time.Duration) expiringValue {
+	e := expiringValue{Value: value}
+	if ttl > 0 {
+		e.ExpiresAt = time.Now().Add(ttl)
+	}
+	return e
+}
+
+// Set and Get implement idempotency.Backend.
+func (s *BoltStore) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(boltKV), key, newExpiringValue(value, ttl))
+	})
+}
+
+func (s *BoltStore) Get(ctx context.Context, key string) (string, error) {
+	val, _, err := s.getLive(boltKV, key)
+	return val, err
+}
+
+func (s *BoltStore) GetIdempotencyRecord(key string) (string, error) {
+	val, found, err := s.getLive(boltKV, "idempotency:"+key)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", errors.New("not found")
+	}
+	return val, nil
+}
+
+func (s *BoltStore) SetIdempotencyRecord(key string, value string, ttl time.Duration) error {
+	return s.Set(context.Background(), "idempotency:"+key, value, ttl)
+}
+
+func (s *BoltStore) SetIdempotencyRecordNX(key string, value string, ttl time.Duration) error {
+	acquired, err := s.putIfFree(boltKV, "idempotency:"+key, value, ttl)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return errors.New("key exists")
+	}
+	return nil
+}
+
+// putIfFree writes the entry unless a live one exists under key.
+func (s *BoltStore) putIfFree(bucket []byte, key string, value string, ttl time.Duration) (bool, error) {
+	acquired := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		var current expiringValue
+		found, err := getJSON(b, key, &current)
+		if err != nil {
+			return err
+		}
+		if found && current.live(time.Now()) {
+			return nil
+		}
+		acquired = true
+		return putJSON(b, key, newExpiringValue(value, ttl))
+	})
+	return acquired, err
+}
+
+// --- Coordinator ---
+
+// AcquireLock takes the lock if it is free or its lease expired.
+func (s *BoltStore) AcquireLock(ctx context.Context, key string, ownerID string, ttl time.Duration) (bool, error) {
+	return s.putIfFree(boltLeases, key, ownerID, ttl)
+}
+
+// RenewLock extends the TTL if the lock is still held by ownerID.
+func (s *BoltStore) RenewLock(ctx context.Context, key string, ownerID string, ttl time.Duration) (bool, error) {
+	renewed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltLeases)
+		var current expiringValue
+		found, err := getJSON(b, key, &current)
+		if err != nil || !found || !current.live(time.Now()) || current.Value != ownerID {
+			return err
+		}
+		renewed = true
+		return putJSON(b, key, newExpiringValue(ownerID, ttl))
+	})
+	return renewed, err
+}
+
+// ReleaseLock releases the lock if held by ownerID.
+func (s *BoltStore) ReleaseLock(ctx context.Context, key string, ownerID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltLeases)
+		var current expiringValue
+		found, err := getJSON(b, key, &current)
+		if err != nil || !found || current.Value != ownerID {
+			return err
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// GetLockOwner returns the current owner, or "" if the lock is free.
+func (s *BoltStore) GetLockOwner(ctx context.Context, key string) (string, error) {
+	owner, _, err := s.getLive(boltLeases, key)
+	return owner, err
+}
+
+func (s *BoltStore) AcquireLease(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return s.AcquireLock(ctx, key, value, ttl)
+}
+
+func (s *BoltStore) RenewLease(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return s.RenewLock(ctx, key, value, ttl)
+}
+
+func (s *BoltStore) ReleaseLease(ctx context.Context, key string, value string) error {
+	return s.ReleaseLock(ctx, key, value)
+}
+
+func (s *BoltStore) IsLeaseOwner(ctx context.Context, key string, value string) (bool, error) {
+	owner, err := s.GetLockOwner(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return owner == value, nil
+}
+
+// IncrementEpoch increments the epoch counter for the given key, stored
+// like RedisStore's under key + ":epoch".
+func (s *BoltStore) IncrementEpoch(ctx context.Context, key string) (int64, error) {
+	return s.IncrementDurableEpoch(ctx, key+":epoch")
+}
+
+// ScanLocks returns held locks whose key matches the glob pattern.
+func (s *BoltStore) ScanLocks(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	now := time.Now()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltLeases).ForEach(func(k, v []byte) error {
+			if ok, _ := path.Match(pattern, string(k)); !ok {
+				return nil
+			}
+			var e expiringValue
+			if json.Unmarshal(v, &e) == nil && e.live(now) {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	return keys, err
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// CopyState writes st into dst with its versions, history and CreatedAt by
+// upserting it once per version after done, so dst assigns the same
+// versions and records a revision for each. done is the version dst
+// already holds (0 for a state dst does not have). A version missing from
+// revs takes the spec of the next one recorded, and missing(version) as
+// its reason.
+func CopyState(ctx context.Context, dst Store, tenantID string, st *DesiredState, revs []*StateRevision, done int, missing func(version int) string) error {
+	byRevision := make(map[int]*StateRevision, len(revs))
+	for _, rev := range revs {
+		byRevision[rev.Revision] = rev
+	}
+	ctx = WithCreatedAt(ctx, st.CreatedAt)
+
+	for v := done + 1; v <= st.Version; v++ {
+		var next DesiredState
+		var info ChangeInfo
+		if rev, ok := byRevision[v]; ok {
+			info = ChangeInfo{Author: rev.Author, Reason: rev.Reason}
+			next = rev.State
+		} else {
+			info = ChangeInfo{Reason: missing(v)}
+			next = *st
+			for w := v + 1; w < st.Version; w++ {
+				if rev, ok := byRevision[w]; ok {
+					next = rev.State
+					break
+				}
+			}
+		}
+		if v == st.Version {
+			// The state itself, with its current status
+			next = *st
+		}
+		next.StateID, next.TenantID = st.StateID, tenantID
+
+		if err := dst.UpsertState(WithChangeInfo(ctx, info), tenantID, &next); err != nil {
+			return err
+		}
+		if next.Version != v {
+			return fmt.Errorf("stored as version %d, want %d", next.Version, v)
+		}
+	}
+	return nil
+}
+
+// RaiseEpoch increments dst's epoch for resource until it reaches value.
+// Fencing tokens must never go back, so a higher epoch is kept.
+func RaiseEpoch(ctx context.Context, dst Store, resource string, value int64) error {
+	epoch, err := dst.GetDurableEpoch(ctx, resource)
+	for err == nil && epoch < value {
+		epoch, err = dst.IncrementDurableEpoch(ctx, resource)
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+package store
+
+import "errors"
+
+// ErrNotFound is returned by updates to an agent, state or job that does
+// not exist in the tenant. Get* methods return (nil, nil) instead.
+var ErrNotFound = errors.New("not found")
+
+// ErrVersionConflict is returned by UpdateStateStatus when the state's
+// Version no longer matches expectedVersion: the desired state was changed
+// (or another writer won) since the caller read it.
+var ErrVersionConflict = errors.New("state version conflict")
+
+// ErrJobStatusConflict is returned by UpdateJobStatus under
+// WithExpectedJobStatus when the job has moved on from the expected status.
+var ErrJobStatusConflict = errors.New("job status conflict")
@@ -9,26 +9,85 @@ import (
 // It abstracts over Postgres (durable) and Redis (ephemeral/fast).
 type Store interface {
 	// Agent Operations
+	// UpsertAgent sets CreatedAt when the agent is first stored (to the
+	// time from WithCreatedAt, if set) and keeps it on later upserts;
+	// UpdatedAt is the time of the last upsert.
 	UpsertAgent(ctx context.Context, tenantID string, agent *Agent) error
 	GetAgent(ctx context.Context, tenantID string, nodeID string) (*Agent, error)
+	// ListAgents lists a tenant's agents; "" lists agents of every tenant.
 	ListAgents(ctx context.Context, tenantID string) ([]*Agent, error)
+	// QueryAgents returns one page of ListAgents, filtered and ordered by
+	// opts (ErrInvalidListOptions for a bad cursor or filter).
+	QueryAgents(ctx context.Context, tenantID string, opts ListOptions) (*AgentPage, error)
 	UpdateAgentHeartbeat(ctx context.Context, tenantID string, nodeID string, t time.Time) error
+	// DeleteAgent removes an agent and leaves a tombstone (ErrNotFound if
+	// missing). The agent's states and jobs are untouched.
+	DeleteAgent(ctx context.Context, tenantID string, nodeID string) error
 
 	// State Operations
+	// UpsertState assigns state.Version: 1 on create, previous+1 on update.
+	// CreatedAt and UpdatedAt are kept as for UpsertAgent. With a context
+	// from WithExpectedVersion it is a CAS on the version.
 	UpsertState(ctx context.Context, tenantID string, state *DesiredState) error
+	// UpdateStateStatus records a check result only if the state is still at
+	// expectedVersion (ErrVersionConflict otherwise; ErrNotFound if missing).
+	// It does not change the version.
 	UpdateStateStatus(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int) error
 	GetState(ctx context.Context, tenantID string, stateID string) (*DesiredState, error)
 	GetStateByNode(ctx context.Context, tenantID string, nodeID string) (*DesiredState, error)
 	ListStates(ctx context.Context, tenantID string) ([]*DesiredState, error)
-	ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*DesiredState, error) // Global scan?
+	QueryStates(ctx context.Context, tenantID string, opts ListOptions) (*StatePage, error)
+	// DeleteState removes a state and leaves a tombstone (ErrNotFound if
+	// missing), so it is no longer listed, counted or rehydrated.
+	DeleteState(ctx context.Context, tenantID string, stateID string) error
+	// ListStateRevisions returns a state's revisions, oldest first.
+	ListStateRevisions(ctx context.Context, tenantID string, stateID string) ([]*StateRevision, error)
+	GetStateRevision(ctx context.Context, tenantID string, stateID string, revision int) (*StateRevision, error)
+	// ListStatesByStatus scans all tenants for states with status whose NodeID
+	// shard is shardIndex under sharding.Modulo(shardCount).
+	ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*DesiredState, error)
 	CountStatesByStatus(ctx context.Context, tenantID string, status string) (int, error)
 
 	// Job Operations
+	// CreateJob stores the job as given, including its status and
+	// timestamps; CreatedAt defaults to now.
 	CreateJob(ctx context.Context, tenantID string, job *Job) error
 	UpdateJobStatus(ctx context.Context, tenantID string, jobID string, status string, exitCode int, stdout, stderr string) error
 	GetJob(ctx context.Context, tenantID string, jobID string) (*Job, error)
+	// ListJobs and ListJobsByTenant return the newest jobs first; limit <= 0
+	// returns all of them.
 	ListJobs(ctx context.Context, tenantID string, nodeID string, limit int) ([]*Job, error)
 	ListJobsByTenant(ctx context.Context, tenantID string, limit int) ([]*Job, error)
+	QueryJobs(ctx context.Context, tenantID string, opts ListOptions) (*JobPage, error)
+	// PurgeJobs permanently deletes the tenant's finished jobs selected by
+	// purge and returns how many were deleted.
+	PurgeJobs(ctx context.Context, tenantID string, purge JobPurge) (int, error)
+	// ClaimNextJob atomically moves the oldest "queued" job for a node to
+	// "running" and returns it (nil if none). Used by pull-mode agents.
+	ClaimNextJob(ctx context.Context, tenantID string, nodeID string) (*Job, error)
+
+	// Tombstones
+	// GetTombstone returns the tombstone of a deleted agent or state, or nil
+	// if the record was never deleted, was re-created, or the tombstone was
+	// purged.
+	GetTombstone(ctx context.Context, tenantID string, resource Resource, id string) (*Tombstone, error)
+	// PurgeTombstones drops tombstones deleted before the given time and
+	// returns how many were dropped.
+	PurgeTombstones(ctx context.Context, before time.Time) (int, error)
+
+	// ListTenants returns every tenant with at least one agent, state or
+	// job, sorted.
+	ListTenants(ctx context.Context) ([]string, error)
+
+	// Outbox
+	// UpdateStateStatusWithEvent is UpdateStateStatus that also appends
+	// event to the outbox, atomically: both are committed or neither is.
+	// A nil event makes it UpdateStateStatus.
+	UpdateStateStatusWithEvent(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int, event *OutboxEvent) error
+	// ReadOutbox returns up to limit undelivered events, oldest first.
+	ReadOutbox(ctx context.Context, limit int) ([]*OutboxEvent, error)
+	// AckOutbox removes delivered events by Position.
+	AckOutbox(ctx context.Context, positions []string) error
 
 	// Coordination Operations
 	// IncrementDurableEpoch increments the epoch for a given resource (e.g. "leader_election")
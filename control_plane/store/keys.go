@@ -2,6 +2,8 @@ package store
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // Resource type for Redis keys
@@ -11,6 +13,8 @@ const (
 	ResourceAgent Resource = "agents"
 	ResourceJob   Resource = "jobs"
 	ResourceState Resource = "states"
+	// ResourceIncident holds captured incident snapshots
+	ResourceIncident Resource = "incidents"
 )
 
 // TenantKey constructs a fully qualified Redis key for a tenant resource.
@@ -31,3 +35,26 @@ func TenantPrefix(tenantID string, resource Resource) string {
 func TenantWildcardPrefix(resource Resource) string {
 	return fmt.Sprintf("fluxforge:tenants:*:%s:*", resource)
 }
+
+// tenantFromKey returns the tenant of a TenantKey for resource.
+func tenantFromKey(key string, resource Resource) (string, bool) {
+	rest, ok := strings.CutPrefix(key, "fluxforge:tenants:")
+	if !ok {
+		return "", false
+	}
+	i := strings.Index(rest, ":"+string(resource)+":")
+	if i <= 0 {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// sortedTenants returns the tenants in set in order.
+func sortedTenants(set map[string]bool) []string {
+	tenants := make([]string, 0, len(set))
+	for tid := range set {
+		tenants = append(tenants, tid)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
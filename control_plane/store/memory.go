@@ -3,8 +3,14 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/sharding"
 )
 
 // MemoryStore holds the in-memory state of registered agents and jobs.
@@ -15,6 +21,12 @@ type MemoryStore struct {
 	jobs   map[string]*Job
 	states map[string]*DesiredState
 	epochs map[string]int64
+
+	tombstones map[string]*Tombstone       // By TombstoneKey
+	revisions  map[string][]*StateRevision // By state key, oldest first
+
+	outbox    []*OutboxEvent // Oldest first
+	outboxSeq int64
 }
 
 // NewMemoryStore initializes a new MemoryStore.
@@ -24,6 +36,9 @@ func NewMemoryStore() *MemoryStore {
 		jobs:   make(map[string]*Job),
 		states: make(map[string]*DesiredState),
 		epochs: make(map[string]int64),
+
+		tombstones: make(map[string]*Tombstone),
+		revisions:  make(map[string][]*StateRevision),
 	}
 }
 
@@ -34,7 +49,15 @@ func (s *MemoryStore) UpsertAgent(ctx context.Context, tenantID string, a *Agent
 	defer s.mu.Unlock()
 	a.TenantID = tenantID
 	key := TenantKey(tenantID, ResourceAgent, a.NodeID)
-	s.agents[key] = a
+	now := time.Now()
+	a.CreatedAt = createdAt(ctx, now)
+	if prev, ok := s.agents[key]; ok {
+		a.CreatedAt = prev.CreatedAt
+	}
+	a.UpdatedAt = now
+	agentCopy := *a
+	s.agents[key] = &agentCopy
+	delete(s.tombstones, TombstoneKey(tenantID, ResourceAgent, a.NodeID))
 	return nil
 }
 
@@ -60,8 +83,8 @@ func (s *MemoryStore) ListAgents(ctx context.Context, tenantID string) ([]*Agent
 	prefix := TenantPrefix(tenantID, ResourceAgent)
 
 	for key, a := range s.agents {
-		// Filter by key prefix to ensure isolation
-		if len(key) >= len(prefix) && key[0:len(prefix)] == prefix {
+		// Filter by key prefix to ensure isolation; "" lists every tenant
+		if tenantID == "" || strings.HasPrefix(key, prefix) {
 			agentCopy := *a
 			result = append(result, &agentCopy)
 		}
@@ -69,6 +92,14 @@ func (s *MemoryStore) ListAgents(ctx context.Context, tenantID string) ([]*Agent
 	return result, nil
 }
 
+func (s *MemoryStore) QueryAgents(ctx context.Context, tenantID string, opts ListOptions) (*AgentPage, error) {
+	agents, err := s.ListAgents(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return pageAgents(agents, opts)
+}
+
 func (s *MemoryStore) UpdateAgentHeartbeat(ctx context.Context, tenantID string, nodeID string, t time.Time) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -76,12 +107,30 @@ func (s *MemoryStore) UpdateAgentHeartbeat(ctx context.Context, tenantID string,
 	key := TenantKey(tenantID, ResourceAgent, nodeID)
 	agent, ok := s.agents[key]
 	if !ok {
-		return errors.New("agent not found")
+		return fmt.Errorf("agent %s: %w", nodeID, ErrNotFound)
 	}
 	agent.LastHeartbeat = t
 	return nil
 }
 
+func (s *MemoryStore) DeleteAgent(ctx context.Context, tenantID string, nodeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := TenantKey(tenantID, ResourceAgent, nodeID)
+	agent, ok := s.agents[key]
+	if !ok {
+		return fmt.Errorf("agent %s: %w", nodeID, ErrNotFound)
+	}
+	tomb, err := newTombstone(ResourceAgent, tenantID, nodeID, agent)
+	if err != nil {
+		return err
+	}
+	delete(s.agents, key)
+	s.tombstones[TombstoneKey(tenantID, ResourceAgent, nodeID)] = tomb
+	return nil
+}
+
 // --- State Operations ---
 
 func (s *MemoryStore) UpsertState(ctx context.Context, tenantID string, st *DesiredState) error {
@@ -89,26 +138,55 @@ func (s *MemoryStore) UpsertState(ctx context.Context, tenantID string, st *Desi
 	defer s.mu.Unlock()
 	st.TenantID = tenantID
 	key := TenantKey(tenantID, ResourceState, st.StateID)
-	s.states[key] = st
+	current := 0
+	if existing, ok := s.states[key]; ok {
+		current = existing.Version
+	}
+	if err := checkExpectedVersion(ctx, st.StateID, current); err != nil {
+		return err
+	}
+	now := time.Now()
+	st.Version = 1
+	st.CreatedAt = createdAt(ctx, now)
+	if existing, ok := s.states[key]; ok {
+		st.Version = existing.Version + 1
+		st.CreatedAt = existing.CreatedAt
+	}
+	st.UpdatedAt = now
+	stateCopy := *st
+	s.states[key] = &stateCopy
+	delete(s.tombstones, TombstoneKey(tenantID, ResourceState, st.StateID))
+	s.revisions[key] = append(s.revisions[key], newStateRevision(ctx, &stateCopy))
 	return nil
 }
 
 func (s *MemoryStore) UpdateStateStatus(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int) error {
+	return s.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, nil)
+}
+
+func (s *MemoryStore) UpdateStateStatusWithEvent(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int, event *OutboxEvent) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	key := TenantKey(tenantID, ResourceState, stateID)
 	state, exists := s.states[key]
 	if !exists {
-		return errors.New("state not found")
+		return fmt.Errorf("state %s: %w", stateID, ErrNotFound)
 	}
 	if state.Version != expectedVersion {
-		return errors.New("optimistic lock failure: state version changed")
+		return fmt.Errorf("state %s at version %d, expected %d: %w", stateID, state.Version, expectedVersion, ErrVersionConflict)
 	}
 
 	state.Status = status
 	state.LastError = lastError
 	state.LastChecked = lastChecked
+	state.UpdatedAt = time.Now()
+	if event != nil {
+		s.outboxSeq++
+		eventCopy := *event
+		eventCopy.Position = strconv.FormatInt(s.outboxSeq, 10)
+		s.outbox = append(s.outbox, &eventCopy)
+	}
 	return nil
 }
 
@@ -154,14 +232,69 @@ func (s *MemoryStore) ListStates(ctx context.Context, tenantID string) ([]*Desir
 	return result, nil
 }
 
+func (s *MemoryStore) QueryStates(ctx context.Context, tenantID string, opts ListOptions) (*StatePage, error) {
+	states, err := s.ListStates(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return pageStates(states, opts)
+}
+
+func (s *MemoryStore) DeleteState(ctx context.Context, tenantID string, stateID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := TenantKey(tenantID, ResourceState, stateID)
+	state, ok := s.states[key]
+	if !ok {
+		return fmt.Errorf("state %s: %w", stateID, ErrNotFound)
+	}
+	tomb, err := newTombstone(ResourceState, tenantID, stateID, state)
+	if err != nil {
+		return err
+	}
+	delete(s.states, key)
+	delete(s.revisions, key)
+	s.tombstones[TombstoneKey(tenantID, ResourceState, stateID)] = tomb
+	return nil
+}
+
+func (s *MemoryStore) ListStateRevisions(ctx context.Context, tenantID string, stateID string) ([]*StateRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	revs := s.revisions[TenantKey(tenantID, ResourceState, stateID)]
+	list := make([]*StateRevision, len(revs))
+	for i, rev := range revs {
+		revCopy := *rev
+		list[i] = &revCopy
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) GetStateRevision(ctx context.Context, tenantID string, stateID string, revision int) (*StateRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rev := range s.revisions[TenantKey(tenantID, ResourceState, stateID)] {
+		if rev.Revision == revision {
+			revCopy := *rev
+			return &revCopy, nil
+		}
+	}
+	return nil, nil
+}
+
 // --- Job Operations ---
 
 func (s *MemoryStore) CreateJob(ctx context.Context, tenantID string, j *Job) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	j.TenantID = tenantID
+	if j.CreatedAt.IsZero() {
+		j.CreatedAt = time.Now()
+	}
 	key := TenantKey(tenantID, ResourceJob, j.JobID)
-	s.jobs[key] = j
+	jobCopy := *j
+	s.jobs[key] = &jobCopy
 	return nil
 }
 
@@ -172,14 +305,17 @@ func (s *MemoryStore) UpdateJobStatus(ctx context.Context, tenantID string, jobI
 	key := TenantKey(tenantID, ResourceJob, jobID)
 	j, ok := s.jobs[key]
 	if !ok {
-		return errors.New("job not found")
+		return fmt.Errorf("job %s: %w", jobID, ErrNotFound)
+	}
+	if err := checkExpectedJobStatus(ctx, jobID, j.Status); err != nil {
+		return err
 	}
 
 	j.Status = status
 	if status == "running" {
 		now := time.Now()
 		j.StartedAt = &now
-	} else if status == "completed" || status == "failed" {
+	} else if jobFinished(status) {
 		now := time.Now()
 		j.FinishedAt = &now
 		j.ExitCode = exitCode
@@ -202,24 +338,45 @@ func (s *MemoryStore) GetJob(ctx context.Context, tenantID string, jobID string)
 	return &jobCopy, nil
 }
 
+func (s *MemoryStore) ClaimNextJob(ctx context.Context, tenantID string, nodeID string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *Job
+	for _, j := range s.jobs {
+		if j.TenantID != tenantID || j.NodeID != nodeID || j.Status != "queued" {
+			continue
+		}
+		if next == nil || j.CreatedAt.Before(next.CreatedAt) {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	next.Status = "running"
+	next.StartedAt = &now
+	jobCopy := *next
+	return &jobCopy, nil
+}
+
 // ListStatesByStatus returns ALL states (global).
 // Currently mostly used by Reconciler which might be global.
 // If Reconciler becomes tenant-aware, this needs updating.
 func (s *MemoryStore) ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*DesiredState, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	var states []*DesiredState
 	for _, state := range s.states {
 		if state.Status == status {
-			// Sharding Check
-			if shardCount > 1 {
-				h := fnvHash(state.NodeID)
-				if int(h%uint32(shardCount)) != shardIndex {
-					continue
-				}
+			if !sharding.Owns(sharding.Modulo(shardCount), state.NodeID, shardIndex) {
+				continue
 			}
-			states = append(states, state)
+			stateCopy := *state
+			states = append(states, &stateCopy)
 		}
 	}
 	return states, nil
@@ -238,33 +395,18 @@ func (s *MemoryStore) CountStatesByStatus(ctx context.Context, tenantID string,
 	return count, nil
 }
 
-// Simple hash for memory store sharding simulation
-func fnvHash(s string) uint32 {
-	h := uint32(2166136261)
-	for i := 0; i < len(s); i++ {
-		h *= 16777619
-		h ^= uint32(s[i])
-	}
-	return h
-}
-
 func (s *MemoryStore) ListJobs(ctx context.Context, tenantID string, nodeID string, limit int) ([]*Job, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	result := make([]*Job, 0)
-	count := 0
 	for _, j := range s.jobs {
 		if j.TenantID == tenantID && j.NodeID == nodeID {
 			jobCopy := *j
 			result = append(result, &jobCopy)
-			count++
-			if limit > 0 && count >= limit {
-				break
-			}
 		}
 	}
-	return result, nil
+	return newestJobs(result, limit), nil
 }
 
 func (s *MemoryStore) ListJobsByTenant(ctx context.Context, tenantID string, limit int) ([]*Job, error) {
@@ -272,18 +414,127 @@ func (s *MemoryStore) ListJobsByTenant(ctx context.Context, tenantID string, lim
 	defer s.mu.RUnlock()
 
 	result := make([]*Job, 0)
-	count := 0
 	for _, j := range s.jobs {
 		if j.TenantID == tenantID {
 			jobCopy := *j
 			result = append(result, &jobCopy)
-			count++
-			if limit > 0 && count >= limit {
-				break
-			}
 		}
 	}
-	return result, nil
+	return newestJobs(result, limit), nil
+}
+
+func (s *MemoryStore) QueryJobs(ctx context.Context, tenantID string, opts ListOptions) (*JobPage, error) {
+	jobs, err := s.ListJobsByTenant(ctx, tenantID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return pageJobs(jobs, opts)
+}
+
+func (s *MemoryStore) PurgeJobs(ctx context.Context, tenantID string, purge JobPurge) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for key, j := range s.jobs {
+		if j.TenantID == tenantID && purge.Matches(j) {
+			delete(s.jobs, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// --- Tombstones ---
+
+func (s *MemoryStore) GetTombstone(ctx context.Context, tenantID string, resource Resource, id string) (*Tombstone, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tomb, ok := s.tombstones[TombstoneKey(tenantID, resource, id)]
+	if !ok {
+		return nil, nil
+	}
+	tombCopy := *tomb
+	return &tombCopy, nil
+}
+
+func (s *MemoryStore) PurgeTombstones(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for key, tomb := range s.tombstones {
+		if tomb.DeletedAt.Before(before) {
+			delete(s.tombstones, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryStore) ListTenants(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := make(map[string]bool)
+	for _, a := range s.agents {
+		set[a.TenantID] = true
+	}
+	for _, st := range s.states {
+		set[st.TenantID] = true
+	}
+	for _, j := range s.jobs {
+		set[j.TenantID] = true
+	}
+	return sortedTenants(set), nil
+}
+
+// --- Outbox ---
+
+func (s *MemoryStore) ReadOutbox(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []*OutboxEvent
+	for _, e := range s.outbox {
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+		eventCopy := *e
+		events = append(events, &eventCopy)
+	}
+	return events, nil
+}
+
+func (s *MemoryStore) AckOutbox(ctx context.Context, positions []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acked := make(map[string]bool, len(positions))
+	for _, p := range positions {
+		acked[p] = true
+	}
+	kept := s.outbox[:0]
+	for _, e := range s.outbox {
+		if !acked[e.Position] {
+			kept = append(kept, e)
+		}
+	}
+	s.outbox = kept
+	return nil
+}
+
+// newestJobs orders jobs newest first and keeps at most limit (<= 0 = all),
+// the order every Store returns job listings in.
+func newestJobs(jobs []*Job, limit int) []*Job {
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.After(jobs[k].CreatedAt)
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
 }
 
 // --- Coordination Operations ---
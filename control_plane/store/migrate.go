@@ -0,0 +1,310 @@
+package store
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// migrationFiles holds the numbered schema changes PostgresStore owns:
+// NNNN_name.up.sql and NNNN_name.down.sql, applied in version order.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrUnknownSchemaVersion is returned when the database schema was not
+// produced by this binary's migrations: it is newer than the latest known
+// migration, or has FluxForge tables but no schema_migrations history.
+var ErrUnknownSchemaVersion = errors.New("unknown schema version")
+
+// ErrSchemaOutOfDate is returned by CheckSchema when migrations are pending.
+var ErrSchemaOutOfDate = errors.New("schema is out of date")
+
+// migrationLockID is the advisory lock that serializes migrations when
+// several control plane replicas start at once.
+const migrationLockID = 0x466c7578 // "Flux"
+
+// legacyTables predate schema_migrations; finding one without a migration
+// history means the database was created from an old hand-written schema.
+var legacyTables = []string{"agents", "desired_states", "jobs", "leader_epochs"}
+
+// Migration is one embedded schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState reports whether a migration is applied to the database.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time // nil while pending
+}
+
+// Migrations returns the embedded migrations in version order. Versions
+// must run 1..N without gaps and every migration needs both directions.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		file := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		num, name, ok2 := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || !ok2 || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("malformed migration file name %q", file)
+		}
+		body, err := migrationFiles.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, k int) bool { return migrations[i].Version < migrations[k].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be contiguous from 1: found %d at position %d", m.Version, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down SQL", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// queryRower is satisfied by both the pool and a transaction.
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// schemaVersion returns the highest applied migration (0 for an empty
+// database) or ErrUnknownSchemaVersion if the history is not one we wrote.
+func schemaVersion(ctx context.Context, q queryRower, latest int) (int, error) {
+	var hasHistory bool
+	if err := q.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&hasHistory); err != nil {
+		return 0, err
+	}
+	if !hasHistory {
+		for _, table := range legacyTables {
+			var exists bool
+			if err := q.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+				return 0, err
+			}
+			if exists {
+				return 0, fmt.Errorf("table %s exists but schema_migrations does not (created outside migrations?): %w", table, ErrUnknownSchemaVersion)
+			}
+		}
+		return 0, nil
+	}
+
+	var version, count int
+	err := q.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0), COUNT(*) FROM schema_migrations`).Scan(&version, &count)
+	if err != nil {
+		return 0, err
+	}
+	if version > latest {
+		return version, fmt.Errorf("database is at version %d, this binary knows up to %d: %w", version, latest, ErrUnknownSchemaVersion)
+	}
+	if count != version {
+		return version, fmt.Errorf("schema_migrations has gaps (%d rows, max version %d): %w", count, version, ErrUnknownSchemaVersion)
+	}
+	return version, nil
+}
+
+// SchemaVersion returns the database's current migration version.
+func (s *PostgresStore) SchemaVersion(ctx context.Context) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	return schemaVersion(ctx, s.pool, len(migrations))
+}
+
+// CheckSchema verifies the database is at exactly the latest migration.
+// It never changes the schema.
+func (s *PostgresStore) CheckSchema(ctx context.Context) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	version, err := schemaVersion(ctx, s.pool, len(migrations))
+	if err != nil {
+		return err
+	}
+	if version < len(migrations) {
+		return fmt.Errorf("database is at version %d, latest is %d: %w", version, len(migrations), ErrSchemaOutOfDate)
+	}
+	return nil
+}
+
+// MigrateUp applies every pending migration, each in its own transaction,
+// and returns how many were applied.
+func (s *PostgresStore) MigrateUp(ctx context.Context) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for {
+		done := false
+		err := s.migrationStep(ctx, len(migrations), func(tx pgx.Tx, version int) error {
+			if version == len(migrations) {
+				done = true
+				return nil
+			}
+			m := migrations[version]
+			if _, err := tx.Exec(ctx, `
+				CREATE TABLE IF NOT EXISTS schema_migrations (
+					version    INT PRIMARY KEY,
+					name       TEXT NOT NULL,
+					applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+				)
+			`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, m.Up); err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			if err == nil {
+				log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+			}
+			return err
+		})
+		if err != nil || done {
+			return applied, err
+		}
+		applied++
+	}
+}
+
+// MigrateDown reverts up to steps migrations, newest first, and returns
+// how many were reverted.
+func (s *PostgresStore) MigrateDown(ctx context.Context, steps int) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for reverted < steps {
+		done := false
+		err := s.migrationStep(ctx, len(migrations), func(tx pgx.Tx, version int) error {
+			if version == 0 {
+				done = true
+				return nil
+			}
+			m := migrations[version-1]
+			if _, err := tx.Exec(ctx, m.Down); err != nil {
+				return fmt.Errorf("revert %04d_%s: %w", m.Version, m.Name, err)
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			if err == nil {
+				log.Printf("Reverted migration %04d_%s", m.Version, m.Name)
+			}
+			return err
+		})
+		if err != nil || done {
+			return reverted, err
+		}
+		reverted++
+	}
+	return reverted, nil
+}
+
+// MigrationStatus lists every known migration and when it was applied.
+func (s *PostgresStore) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	version, err := schemaVersion(ctx, s.pool, len(migrations))
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int]time.Time)
+	if version > 0 {
+		rows, err := s.pool.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v int
+			var at time.Time
+			if err := rows.Scan(&v, &at); err != nil {
+				return nil, err
+			}
+			appliedAt[v] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationState{Migration: m}
+		if at, ok := appliedAt[m.Version]; ok {
+			st.AppliedAt = &at
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// migrationStep runs fn in a transaction holding the migration lock, with
+// the schema version read under that lock.
+func (s *PostgresStore) migrationStep(ctx context.Context, latest int, fn func(tx pgx.Tx, version int) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	version, err := schemaVersion(ctx, tx, latest)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx, version); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d", i, m.Version)
+		}
+		if m.Name == "" {
+			t.Errorf("migration %d has no name", m.Version)
+		}
+	}
+	if !strings.Contains(migrations[0].Up, "CREATE TABLE desired_states") {
+		t.Errorf("first migration does not create desired_states")
+	}
+}
+
+var (
+	createTable = regexp.MustCompile(`CREATE TABLE (?:IF NOT EXISTS )?(\w+)`)
+	dropTable   = regexp.MustCompile(`DROP TABLE (?:IF EXISTS )?(\w+)`)
+)
+
+func TestMigrationsDropWhatTheyCreate(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		dropped := map[string]bool{}
+		for _, match := range dropTable.FindAllStringSubmatch(m.Down, -1) {
+			dropped[match[1]] = true
+		}
+		for _, match := range createTable.FindAllStringSubmatch(m.Up, -1) {
+			if !dropped[match[1]] {
+				t.Errorf("migration %04d_%s creates %s but its down does not drop it", m.Version, m.Name, match[1])
+			}
+		}
+	}
+}
+
+// TestPostgresMigrationsRoundTrip reverts every migration on
+// FLUXFORGE_TEST_DATABASE_URL and applies them again, leaving it at the
+// latest schema.
+func TestPostgresMigrationsRoundTrip(t *testing.T) {
+	url := os.Getenv("FLUXFORGE_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("FLUXFORGE_TEST_DATABASE_URL not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ps, err := NewPostgresStore(ctx, url)
+	if err != nil {
+		t.Fatalf("postgres: %v", err)
+	}
+	defer ps.Close()
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists := func(table string) bool {
+		var name *string
+		if err := ps.Pool().QueryRow(ctx, `SELECT to_regclass($1)::text`, table).Scan(&name); err != nil {
+			t.Fatalf("to_regclass(%s): %v", table, err)
+		}
+		return name != nil
+	}
+
+	if _, err := ps.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if n, err := ps.MigrateDown(ctx, len(migrations)); err != nil || n != len(migrations) {
+		t.Fatalf("MigrateDown = %d, %v; want all %d reverted", n, err, len(migrations))
+	}
+	for _, table := range []string{"desired_states", "outbox", "timeline_events", "incidents"} {
+		if exists(table) {
+			t.Errorf("%s left after reverting every migration", table)
+		}
+	}
+
+	if n, err := ps.MigrateUp(ctx); err != nil || n != len(migrations) {
+		t.Fatalf("MigrateUp = %d, %v; want all %d applied", n, err, len(migrations))
+	}
+	for _, table := range []string{"desired_states", "outbox", "timeline_events", "incidents"} {
+		if !exists(table) {
+			t.Errorf("%s missing after migrating up", table)
+		}
+	}
+	if v, err := ps.SchemaVersion(ctx); err != nil || v != len(migrations) {
+		t.Errorf("SchemaVersion = %d, %v; want %d", v, err, len(migrations))
+	}
+}
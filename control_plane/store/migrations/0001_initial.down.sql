@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS leader_epochs;
+DROP TABLE IF EXISTS jobs;
+DROP TABLE IF EXISTS desired_states;
+DROP TABLE IF EXISTS agents;
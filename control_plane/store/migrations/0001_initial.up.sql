@@ -0,0 +1,58 @@
+-- Tables read and written by PostgresStore.
+
+CREATE TABLE agents (
+    node_id           VARCHAR(64) PRIMARY KEY,
+    tenant_id         VARCHAR(64) NOT NULL,
+    hostname          VARCHAR(255) NOT NULL DEFAULT '',
+    ip_address        VARCHAR(64) NOT NULL DEFAULT '',
+    port              INT NOT NULL DEFAULT 0,
+    version           VARCHAR(64) NOT NULL DEFAULT '',
+    status            VARCHAR(32) NOT NULL DEFAULT '',
+    last_heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    metadata          JSONB,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_agents_tenant ON agents (tenant_id);
+
+CREATE TABLE desired_states (
+    state_id          VARCHAR(64) PRIMARY KEY,
+    node_id           VARCHAR(64) NOT NULL,
+    tenant_id         VARCHAR(64) NOT NULL,
+    check_cmd         TEXT NOT NULL DEFAULT '',
+    apply_cmd         TEXT NOT NULL DEFAULT '',
+    desired_exit_code INT NOT NULL DEFAULT 0,
+    version           INT NOT NULL DEFAULT 1,
+    status            VARCHAR(32) NOT NULL DEFAULT '',
+    last_checked      TIMESTAMPTZ NOT NULL DEFAULT 'epoch',
+    last_error        TEXT NOT NULL DEFAULT '',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_desired_states_tenant ON desired_states (tenant_id);
+CREATE INDEX idx_desired_states_node ON desired_states (tenant_id, node_id, created_at);
+CREATE INDEX idx_desired_states_status ON desired_states (status);
+
+CREATE TABLE jobs (
+    job_id      VARCHAR(64) PRIMARY KEY,
+    node_id     VARCHAR(64) NOT NULL,
+    tenant_id   VARCHAR(64) NOT NULL,
+    state_id    VARCHAR(64) NOT NULL DEFAULT '',
+    command     TEXT NOT NULL DEFAULT '',
+    status      VARCHAR(32) NOT NULL,
+    exit_code   INT NOT NULL DEFAULT 0,
+    stdout      TEXT NOT NULL DEFAULT '',
+    stderr      TEXT NOT NULL DEFAULT '',
+    trace_id    VARCHAR(64) NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+CREATE INDEX idx_jobs_tenant_created ON jobs (tenant_id, created_at DESC);
+CREATE INDEX idx_jobs_node_created ON jobs (tenant_id, node_id, created_at DESC);
+CREATE INDEX idx_jobs_queued ON jobs (tenant_id, node_id, created_at) WHERE status = 'queued';
+
+CREATE TABLE leader_epochs (
+    resource_id VARCHAR(255) PRIMARY KEY,
+    epoch       BIGINT NOT NULL
+);
@@ -0,0 +1,4 @@
+ALTER TABLE desired_states DROP COLUMN IF EXISTS check_interval_seconds;
+ALTER TABLE desired_states DROP COLUMN IF EXISTS job_timeout_seconds;
+ALTER TABLE jobs DROP COLUMN IF EXISTS timeout_seconds;
+ALTER TABLE agents DROP COLUMN IF EXISTS tier;
@@ -0,0 +1,7 @@
+-- Per-record settings added after the initial schema: agent tier,
+-- job timeouts and the drift check interval.
+
+ALTER TABLE agents ADD COLUMN tier VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN timeout_seconds INT NOT NULL DEFAULT 0;
+ALTER TABLE desired_states ADD COLUMN job_timeout_seconds INT NOT NULL DEFAULT 0;
+ALTER TABLE desired_states ADD COLUMN check_interval_seconds INT NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- Leases for PostgresStore's Coordinator implementation. Expiry is compared
+-- against the database clock, so replicas need not agree on time.
+
+CREATE TABLE leases (
+    key        TEXT PRIMARY KEY,
+    value      TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_leases_expires ON leases (expires_at);
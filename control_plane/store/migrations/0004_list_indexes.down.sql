@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_jobs_tenant_page;
+DROP INDEX IF EXISTS idx_desired_states_tenant_page;
+DROP INDEX IF EXISTS idx_agents_tenant_page;
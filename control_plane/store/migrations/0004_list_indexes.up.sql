@@ -0,0 +1,6 @@
+-- Keyset indexes for the paginated QueryAgents/QueryStates/QueryJobs
+-- listings, which order by (created_at, id) within a tenant.
+
+CREATE INDEX idx_agents_tenant_page ON agents (tenant_id, created_at, node_id);
+CREATE INDEX idx_desired_states_tenant_page ON desired_states (tenant_id, created_at, state_id);
+CREATE INDEX idx_jobs_tenant_page ON jobs (tenant_id, created_at, job_id);
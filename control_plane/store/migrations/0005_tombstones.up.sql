@@ -0,0 +1,12 @@
+-- Tombstones for deleted agents and states. record holds the deleted row as
+-- the API serialized it; upserting the same ID again removes the tombstone.
+
+CREATE TABLE tombstones (
+    resource   VARCHAR(32) NOT NULL,
+    tenant_id  VARCHAR(64) NOT NULL,
+    id         VARCHAR(64) NOT NULL,
+    record     JSONB NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (resource, tenant_id, id)
+);
+CREATE INDEX idx_tombstones_deleted ON tombstones (deleted_at);
@@ -0,0 +1,13 @@
+-- Immutable desired-state revisions, one per UpsertState. revision is the
+-- state's version after the upsert; state is the full record as JSON.
+
+CREATE TABLE state_revisions (
+    tenant_id  VARCHAR(64) NOT NULL,
+    state_id   VARCHAR(64) NOT NULL,
+    revision   INTEGER NOT NULL,
+    author     TEXT NOT NULL DEFAULT '',
+    reason     TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    state      JSONB NOT NULL,
+    PRIMARY KEY (tenant_id, state_id, revision)
+);
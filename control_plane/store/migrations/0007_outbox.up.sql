@@ -0,0 +1,11 @@
+-- Transactional outbox: events written in the same transaction as the
+-- state change they report, deleted once relayed. id orders delivery.
+
+CREATE TABLE outbox (
+    id         BIGSERIAL PRIMARY KEY,
+    event_id   VARCHAR(64) NOT NULL,
+    topic      TEXT NOT NULL,
+    tenant_id  VARCHAR(64) NOT NULL DEFAULT '',
+    payload    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
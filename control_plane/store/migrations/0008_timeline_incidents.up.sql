@@ -0,0 +1,30 @@
+-- Tables of the Postgres timeline and incident backends, which used to
+-- create them at startup: IF NOT EXISTS adopts those. state_id is lifted
+-- out of the event metadata so it can be indexed like the other fields.
+
+CREATE TABLE IF NOT EXISTS timeline_events (
+    id         BIGSERIAL PRIMARY KEY,
+    req_id     TEXT NOT NULL DEFAULT '',
+    stage      TEXT NOT NULL,
+    ts         TIMESTAMPTZ NOT NULL,
+    node_id    TEXT NOT NULL DEFAULT '',
+    tenant_id  TEXT NOT NULL DEFAULT '',
+    state_id   TEXT NOT NULL DEFAULT '',
+    metadata   JSONB
+);
+CREATE INDEX IF NOT EXISTS idx_timeline_req ON timeline_events (req_id, ts);
+CREATE INDEX IF NOT EXISTS idx_timeline_state ON timeline_events (state_id, ts);
+CREATE INDEX IF NOT EXISTS idx_timeline_node ON timeline_events (node_id, ts);
+CREATE INDEX IF NOT EXISTS idx_timeline_tenant ON timeline_events (tenant_id, ts);
+CREATE INDEX IF NOT EXISTS idx_timeline_ts ON timeline_events (ts);
+
+CREATE TABLE IF NOT EXISTS incidents (
+    tenant_id    TEXT NOT NULL,
+    incident_id  TEXT NOT NULL,
+    state_id     TEXT NOT NULL DEFAULT '',
+    node_id      TEXT NOT NULL DEFAULT '',
+    captured_at  BIGINT NOT NULL,
+    snapshot     JSONB NOT NULL,
+    PRIMARY KEY (tenant_id, incident_id)
+);
+CREATE INDEX IF NOT EXISTS idx_incidents_tenant_time ON incidents (tenant_id, captured_at DESC, incident_id DESC);
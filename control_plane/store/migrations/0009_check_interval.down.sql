@@ -0,0 +1 @@
+ALTER TABLE desired_states RENAME COLUMN check_interval TO check_interval_seconds;
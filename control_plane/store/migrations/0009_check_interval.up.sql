@@ -0,0 +1,3 @@
+-- The drift check interval is check_interval, as in the state's JSON.
+
+ALTER TABLE desired_states RENAME COLUMN check_interval_seconds TO check_interval;
@@ -0,0 +1,10 @@
+-- API idempotency keys and their recorded responses, shared by every
+-- replica. Expiry is compared against the database clock; expired rows are
+-- skipped on read, overwritten by the next insert and purged periodically.
+
+CREATE TABLE idempotency_keys (
+    key        TEXT PRIMARY KEY,
+    value      TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys (expires_at);
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is an event committed in the same transaction as the write
+// it reports, for a relay to publish. Delivery is at least once: the
+// relay may publish an event again if it stops before AckOutbox.
+type OutboxEvent struct {
+	// ID identifies the event across redeliveries, for consumers to dedupe
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	TenantID  string          `json:"tenant_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Position is where the event sits in the outbox, set by ReadOutbox
+	// and passed back to AckOutbox
+	Position string `json:"-"`
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -44,15 +46,23 @@ func (s *PostgresStore) Close() {
 	s.pool.Close()
 }
 
-// --- Agent Operations ---
+// Pool exposes the connection pool for components sharing the database
+// (e.g. the timeline backend).
+func (s *PostgresStore) Pool() *pgxpool.Pool {
+	return s.pool
+}
 
 // --- Agent Operations ---
 
 func (s *PostgresStore) UpsertAgent(ctx context.Context, tenantID string, agent *Agent) error {
 	agent.TenantID = tenantID
+	// Recreating a deleted agent clears its tombstone in the same statement
 	query := `
-		INSERT INTO agents (node_id, tenant_id, hostname, ip_address, port, version, status, health_score, last_heartbeat_at, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		WITH cleared AS (
+			DELETE FROM tombstones WHERE resource = 'agents' AND tenant_id = $2 AND id = $1
+		)
+		INSERT INTO agents (node_id, tenant_id, hostname, ip_address, port, version, status, last_heartbeat_at, metadata, tier, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, NOW()), NOW())
 		ON CONFLICT (node_id) DO UPDATE SET
 			hostname = EXCLUDED.hostname,
 			ip_address = EXCLUDED.ip_address,
@@ -61,18 +71,20 @@ func (s *PostgresStore) UpsertAgent(ctx context.Context, tenantID string, agent
 			status = EXCLUDED.status,
 			last_heartbeat_at = EXCLUDED.last_heartbeat_at,
 			metadata = EXCLUDED.metadata,
+			tier = EXCLUDED.tier,
 			updated_at = NOW()
+		RETURNING created_at, updated_at
 	`
-	_, err := s.pool.Exec(ctx, query,
+	return s.pool.QueryRow(ctx, query,
 		agent.NodeID, agent.TenantID, agent.Hostname, agent.IPAddress, agent.Port,
-		agent.Version, agent.Status, agent.LastHeartbeat, agent.Metadata,
-	)
-	return err
+		agent.Version, agent.Status, agent.LastHeartbeat, agent.Metadata, agent.Tier,
+		contextCreatedAt(ctx),
+	).Scan(&agent.CreatedAt, &agent.UpdatedAt)
 }
 
 func (s *PostgresStore) GetAgent(ctx context.Context, tenantID string, nodeID string) (*Agent, error) {
 	query := `
-		SELECT node_id, tenant_id, hostname, ip_address, port, version, status, last_heartbeat_at, created_at, updated_at, metadata
+		SELECT node_id, tenant_id, hostname, ip_address, port, version, status, last_heartbeat_at, created_at, updated_at, metadata, tier
 		FROM agents WHERE node_id = $1 AND tenant_id = $2
 	`
 	var a Agent
@@ -81,7 +93,7 @@ func (s *PostgresStore) GetAgent(ctx context.Context, tenantID string, nodeID st
 	// but pgx v5 often handles it. If not, we'll fix it in verification.
 	err := s.pool.QueryRow(ctx, query, nodeID, tenantID).Scan(
 		&a.NodeID, &a.TenantID, &a.Hostname, &a.IPAddress, &a.Port, &a.Version, &a.Status,
-		&a.LastHeartbeat, &a.CreatedAt, &a.UpdatedAt, &a.Metadata,
+		&a.LastHeartbeat, &a.CreatedAt, &a.UpdatedAt, &a.Metadata, &a.Tier,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil // Return nil if not found, consistent with store.go interface expectation
@@ -94,8 +106,8 @@ func (s *PostgresStore) GetAgent(ctx context.Context, tenantID string, nodeID st
 
 func (s *PostgresStore) ListAgents(ctx context.Context, tenantID string) ([]*Agent, error) {
 	query := `
-		SELECT node_id, tenant_id, hostname, ip_address, port, version, status, last_heartbeat_at, created_at, updated_at, metadata
-		FROM agents WHERE tenant_id = $1
+		SELECT node_id, tenant_id, hostname, ip_address, port, version, status, last_heartbeat_at, created_at, updated_at, metadata, tier
+		FROM agents WHERE ($1 = '' OR tenant_id = $1)
 	`
 	rows, err := s.pool.Query(ctx, query, tenantID)
 	if err != nil {
@@ -108,7 +120,7 @@ func (s *PostgresStore) ListAgents(ctx context.Context, tenantID string) ([]*Age
 		var a Agent
 		if err := rows.Scan(
 			&a.NodeID, &a.TenantID, &a.Hostname, &a.IPAddress, &a.Port, &a.Version, &a.Status,
-			&a.LastHeartbeat, &a.CreatedAt, &a.UpdatedAt, &a.Metadata,
+			&a.LastHeartbeat, &a.CreatedAt, &a.UpdatedAt, &a.Metadata, &a.Tier,
 		); err != nil {
 			return nil, err
 		}
@@ -117,6 +129,28 @@ func (s *PostgresStore) ListAgents(ctx context.Context, tenantID string) ([]*Age
 	return agents, nil
 }
 
+func (s *PostgresStore) ListTenants(ctx context.Context) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT tenant_id FROM agents
+		UNION SELECT tenant_id FROM desired_states
+		UNION SELECT tenant_id FROM jobs
+		ORDER BY 1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tenants []string
+	for rows.Next() {
+		var tid string
+		if err := rows.Scan(&tid); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tid)
+	}
+	return tenants, rows.Err()
+}
+
 func (s *PostgresStore) UpdateAgentHeartbeat(ctx context.Context, tenantID string, nodeID string, t time.Time) error {
 	query := `UPDATE agents SET last_heartbeat_at = $1 WHERE node_id = $2 AND tenant_id = $3`
 	tag, err := s.pool.Exec(ctx, query, t, nodeID, tenantID)
@@ -124,7 +158,16 @@ func (s *PostgresStore) UpdateAgentHeartbeat(ctx context.Context, tenantID strin
 		return err
 	}
 	if tag.RowsAffected() == 0 {
-		return errors.New("agent not found")
+		return fmt.Errorf("agent %s: %w", nodeID, ErrNotFound)
+	}
+	return nil
+}
+
+// contextCreatedAt is the WithCreatedAt time as a query argument: NULL
+// when unset, so the database's NOW() applies.
+func contextCreatedAt(ctx context.Context) *time.Time {
+	if t, ok := CreatedAtFromContext(ctx); ok {
+		return &t
 	}
 	return nil
 }
@@ -133,50 +176,134 @@ func (s *PostgresStore) UpdateAgentHeartbeat(ctx context.Context, tenantID strin
 
 func (s *PostgresStore) UpsertState(ctx context.Context, tenantID string, state *DesiredState) error {
 	state.TenantID = tenantID
+	// The spec version is assigned here; the tenant guard keeps one tenant
+	// from overwriting another tenant's state with the same ID. Recreating a
+	// deleted state clears its tombstone in the same statement
 	query := `
-		INSERT INTO desired_states (state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		WITH cleared AS (
+			DELETE FROM tombstones WHERE resource = 'states' AND tenant_id = $3 AND id = $1
+		)
+		INSERT INTO desired_states (state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, job_timeout_seconds, check_interval, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 1, $7, $8, $9, $10, $11, COALESCE($12, NOW()), NOW())
 		ON CONFLICT (state_id) DO UPDATE SET
+			node_id = EXCLUDED.node_id,
 			check_cmd = EXCLUDED.check_cmd,
 			apply_cmd = EXCLUDED.apply_cmd,
 			desired_exit_code = EXCLUDED.desired_exit_code,
-			version = EXCLUDED.version,
+			version = desired_states.version + 1,
 			status = EXCLUDED.status,
 			last_checked = EXCLUDED.last_checked,
-			last_error = EXCLUDED.last_error
+			last_error = EXCLUDED.last_error,
+			job_timeout_seconds = EXCLUDED.job_timeout_seconds,
+			check_interval = EXCLUDED.check_interval,
+			updated_at = NOW()
+		WHERE desired_states.tenant_id = EXCLUDED.tenant_id
+		RETURNING version, created_at, updated_at
 	`
-	_, err := s.pool.Exec(ctx, query,
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, ok := ExpectedVersionFromContext(ctx); ok {
+		// Locked until commit, so the check holds for the upsert
+		var current int
+		err := tx.QueryRow(ctx,
+			`SELECT version FROM desired_states WHERE state_id = $1 AND tenant_id = $2 FOR UPDATE`,
+			state.StateID, tenantID,
+		).Scan(&current)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if err := checkExpectedVersion(ctx, state.StateID, current); err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRow(ctx, query,
 		state.StateID, state.NodeID, state.TenantID, state.CheckCmd, state.ApplyCmd,
-		state.DesiredExitCode, state.Version, state.Status, state.LastChecked, state.LastError,
-	)
-	return err
+		state.DesiredExitCode, state.Status, state.LastChecked, state.LastError,
+		state.JobTimeoutSeconds, state.CheckInterval, contextCreatedAt(ctx),
+	).Scan(&state.Version, &state.CreatedAt, &state.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("state %s belongs to another tenant", state.StateID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The revision commits with the state or not at all
+	rev := newStateRevision(ctx, state)
+	data, err := json.Marshal(&rev.State)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO state_revisions (tenant_id, state_id, revision, author, reason, created_at, state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, rev.TenantID, rev.StateID, rev.Revision, rev.Author, rev.Reason, rev.CreatedAt, data)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (s *PostgresStore) UpdateStateStatus(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int) error {
+	return s.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, nil)
+}
+
+// UpdateStateStatusWithEvent inserts the event into the outbox table in the
+// transaction that updates the state.
+func (s *PostgresStore) UpdateStateStatusWithEvent(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int, event *OutboxEvent) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
 		UPDATE desired_states
-		SET status = $2, last_error = $3, last_checked = $4
+		SET status = $2, last_error = $3, last_checked = $4, updated_at = NOW()
 		WHERE state_id = $1 AND version = $5 AND tenant_id = $6
 	`
-	tag, err := s.pool.Exec(ctx, query, stateID, status, lastError, lastChecked, expectedVersion, tenantID)
+	tag, err := tx.Exec(ctx, query, stateID, status, lastError, lastChecked, expectedVersion, tenantID)
 	if err != nil {
 		return err
 	}
 	if tag.RowsAffected() == 0 {
-		return errors.New("optimistic lock failure: state version changed")
+		var exists bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM desired_states WHERE state_id = $1 AND tenant_id = $2)`,
+			stateID, tenantID,
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("state %s: %w", stateID, ErrNotFound)
+		}
+		return fmt.Errorf("state %s: expected version %d: %w", stateID, expectedVersion, ErrVersionConflict)
 	}
-	return nil
+	if event != nil {
+		if err := insertOutboxEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
 }
 
 func (s *PostgresStore) GetState(ctx context.Context, tenantID string, stateID string) (*DesiredState, error) {
 	query := `
-		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, created_at, updated_at
-		FROM desired_states WHERE state_id = $1
+		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, created_at, updated_at,
+			job_timeout_seconds, check_interval
+		FROM desired_states WHERE state_id = $1 AND tenant_id = $2
 	`
 	var st DesiredState
-	err := s.pool.QueryRow(ctx, query, stateID).Scan(
+	err := s.pool.QueryRow(ctx, query, stateID, tenantID).Scan(
 		&st.StateID, &st.NodeID, &st.TenantID, &st.CheckCmd, &st.ApplyCmd,
 		&st.DesiredExitCode, &st.Version, &st.Status, &st.LastChecked, &st.LastError, &st.CreatedAt, &st.UpdatedAt,
+		&st.JobTimeoutSeconds, &st.CheckInterval,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
@@ -188,20 +315,18 @@ func (s *PostgresStore) GetState(ctx context.Context, tenantID string, stateID s
 }
 
 func (s *PostgresStore) GetStateByNode(ctx context.Context, tenantID string, nodeID string) (*DesiredState, error) {
-	// Assuming one state per node for now, or getting the latest?
-	// The schema handles multiple states if IDs differ, but typically Reconciler looks for "The" state.
-	// We'll limit 1 ordered by version desc if multiple exist, or just filter by node_id.
-	// Assuming state_id is unique, but node_id might have multiple history?
-	// For Phase 5, let's assume one active desired state per node for simplicity, or we grab the latest created.
+	// A node may have several states; the reconciler wants the newest
 	query := `
-		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, created_at, updated_at
-		FROM desired_states WHERE node_id = $1
+		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, created_at, updated_at,
+			job_timeout_seconds, check_interval
+		FROM desired_states WHERE node_id = $1 AND tenant_id = $2
 		ORDER BY created_at DESC LIMIT 1
 	`
 	var st DesiredState
-	err := s.pool.QueryRow(ctx, query, nodeID).Scan(
+	err := s.pool.QueryRow(ctx, query, nodeID, tenantID).Scan(
 		&st.StateID, &st.NodeID, &st.TenantID, &st.CheckCmd, &st.ApplyCmd,
-		&st.DesiredExitCode, &st.Version, &st.CreatedAt, &st.UpdatedAt,
+		&st.DesiredExitCode, &st.Version, &st.Status, &st.LastChecked, &st.LastError, &st.CreatedAt, &st.UpdatedAt,
+		&st.JobTimeoutSeconds, &st.CheckInterval,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
@@ -214,7 +339,8 @@ func (s *PostgresStore) GetStateByNode(ctx context.Context, tenantID string, nod
 
 func (s *PostgresStore) ListStates(ctx context.Context, tenantID string) ([]*DesiredState, error) {
 	query := `
-		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, created_at, updated_at
+		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, created_at, updated_at,
+			job_timeout_seconds, check_interval
 		FROM desired_states WHERE tenant_id = $1
 	`
 	rows, err := s.pool.Query(ctx, query, tenantID)
@@ -228,13 +354,14 @@ func (s *PostgresStore) ListStates(ctx context.Context, tenantID string) ([]*Des
 		var st DesiredState
 		if err := rows.Scan(
 			&st.StateID, &st.NodeID, &st.TenantID, &st.CheckCmd, &st.ApplyCmd,
-			&st.DesiredExitCode, &st.Version, &st.CreatedAt, &st.UpdatedAt,
+			&st.DesiredExitCode, &st.Version, &st.Status, &st.LastChecked, &st.LastError, &st.CreatedAt, &st.UpdatedAt,
+			&st.JobTimeoutSeconds, &st.CheckInterval,
 		); err != nil {
 			return nil, err
 		}
 		states = append(states, &st)
 	}
-	return states, nil
+	return states, rows.Err()
 }
 
 // --- Job Operations ---
@@ -242,12 +369,17 @@ func (s *PostgresStore) ListStates(ctx context.Context, tenantID string) ([]*Des
 func (s *PostgresStore) CreateJob(ctx context.Context, tenantID string, job *Job) error {
 	job.TenantID = tenantID
 	query := `
-		INSERT INTO jobs (job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		INSERT INTO jobs (job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, timeout_seconds, created_at, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE($12, NOW()), $13, $14)
 	`
+	var createdAt *time.Time
+	if !job.CreatedAt.IsZero() {
+		createdAt = &job.CreatedAt
+	}
 	_, err := s.pool.Exec(ctx, query,
 		job.JobID, job.NodeID, job.TenantID, job.StateID, job.Command, job.Status,
-		job.ExitCode, job.Stdout, job.Stderr, job.TraceID,
+		job.ExitCode, job.Stdout, job.Stderr, job.TraceID, job.TimeoutSeconds, createdAt,
+		job.StartedAt, job.FinishedAt,
 	)
 	return err
 }
@@ -255,30 +387,50 @@ func (s *PostgresStore) CreateJob(ctx context.Context, tenantID string, job *Job
 func (s *PostgresStore) UpdateJobStatus(ctx context.Context, tenantID string, jobID string, status string, exitCode int, stdout, stderr string) error {
 	// Determine timestamps based on status
 	var query string
-	if status == "running" {
-		query = `UPDATE jobs SET status = $2, started_at = NOW() WHERE job_id = $1 AND tenant_id = $3`
-		_, err := s.pool.Exec(ctx, query, jobID, status, tenantID)
-		return err
-	} else if status == "completed" || status == "failed" {
-		query = `UPDATE jobs SET status = $2, exit_code = $3, stdout = $4, stderr = $5, finished_at = NOW() WHERE job_id = $1 AND tenant_id = $6`
-		_, err := s.pool.Exec(ctx, query, jobID, status, exitCode, stdout, stderr, tenantID)
+	args := []interface{}{jobID, tenantID, status}
+	switch status {
+	case "running":
+		query = `UPDATE jobs SET status = $3, started_at = NOW() WHERE job_id = $1 AND tenant_id = $2`
+	case "completed", "failed", "cancelled":
+		query = `UPDATE jobs SET status = $3, exit_code = $4, stdout = $5, stderr = $6, finished_at = NOW() WHERE job_id = $1 AND tenant_id = $2`
+		args = append(args, exitCode, stdout, stderr)
+	default:
+		query = `UPDATE jobs SET status = $3 WHERE job_id = $1 AND tenant_id = $2`
+	}
+	expected, cas := ExpectedJobStatusFromContext(ctx)
+	if cas {
+		args = append(args, expected)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	tag, err := s.pool.Exec(ctx, query, args...)
+	if err != nil {
 		return err
 	}
-	// Default update
-	query = `UPDATE jobs SET status = $2 WHERE job_id = $1 AND tenant_id = $3`
-	_, err := s.pool.Exec(ctx, query, jobID, status, tenantID)
-	return err
+	if tag.RowsAffected() == 0 {
+		if cas {
+			// Missing, or no longer in the expected status
+			job, err := s.GetJob(ctx, tenantID, jobID)
+			if err != nil {
+				return err
+			}
+			if job != nil {
+				return fmt.Errorf("job %s is %s, expected %s: %w", jobID, job.Status, expected, ErrJobStatusConflict)
+			}
+		}
+		return fmt.Errorf("job %s: %w", jobID, ErrNotFound)
+	}
+	return nil
 }
 
 func (s *PostgresStore) GetJob(ctx context.Context, tenantID string, jobID string) (*Job, error) {
 	query := `
-		SELECT job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at
+		SELECT job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at, timeout_seconds
 		FROM jobs WHERE job_id = $1 AND tenant_id = $2
 	`
 	var j Job
 	err := s.pool.QueryRow(ctx, query, jobID, tenantID).Scan(
 		&j.JobID, &j.NodeID, &j.TenantID, &j.StateID, &j.Command, &j.Status,
-		&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt,
+		&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.TimeoutSeconds,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
@@ -290,27 +442,16 @@ func (s *PostgresStore) GetJob(ctx context.Context, tenantID string, jobID strin
 }
 
 func (s *PostgresStore) ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*DesiredState, error) {
-	var query string
-	var args []interface{}
-
-	if shardCount > 1 {
-		// PostgreSQL hash sharding: hashtext(node_id) % shardCount == shardIndex
-		// Handle negative hash results with abs
-		query = `
-			SELECT state_id, node_id, check_cmd, apply_cmd, desired_exit_code, created_at, updated_at, last_checked, status, last_error
-			FROM desired_states
-			WHERE status = $1 AND ABS(hashtext(node_id) % $2) = $3
-		`
-		args = []interface{}{status, shardCount, shardIndex}
-	} else {
-		query = `
-			SELECT state_id, node_id, check_cmd, apply_cmd, desired_exit_code, created_at, updated_at, last_checked, status, last_error
-			FROM desired_states WHERE status = $1
-		`
-		args = []interface{}{status}
-	}
-
-	rows, err := s.pool.Query(ctx, query, args...)
+	// sharding.Hash is hashtext, so this is sharding.Modulo(shardCount)
+	// in SQL: hashtext's int4 read as unsigned, mod the shard count
+	query := `
+		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, created_at, updated_at, last_checked, status, last_error,
+			job_timeout_seconds, check_interval
+		FROM desired_states
+		WHERE status = $1 AND ($2 <= 1 OR (hashtext(node_id)::bigint & 4294967295) % $2 = $3)
+	`
+
+	rows, err := s.pool.Query(ctx, query, status, shardCount, shardIndex)
 	if err != nil {
 		return nil, err
 	}
@@ -320,9 +461,10 @@ func (s *PostgresStore) ListStatesByStatus(ctx context.Context, status string, s
 	for rows.Next() {
 		var state DesiredState
 		err := rows.Scan(
-			&state.StateID, &state.NodeID, &state.CheckCmd, &state.ApplyCmd,
-			&state.DesiredExitCode, &state.CreatedAt, &state.UpdatedAt,
+			&state.StateID, &state.NodeID, &state.TenantID, &state.CheckCmd, &state.ApplyCmd,
+			&state.DesiredExitCode, &state.Version, &state.CreatedAt, &state.UpdatedAt,
 			&state.LastChecked, &state.Status, &state.LastError,
+			&state.JobTimeoutSeconds, &state.CheckInterval,
 		)
 		if err != nil {
 			return nil, err
@@ -344,10 +486,10 @@ func (s *PostgresStore) CountStatesByStatus(ctx context.Context, tenantID string
 
 func (s *PostgresStore) ListJobs(ctx context.Context, tenantID string, nodeID string, limit int) ([]*Job, error) {
 	query := `
-		SELECT job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at
-		FROM jobs WHERE node_id = $1 ORDER BY created_at DESC LIMIT $2
+		SELECT job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at, timeout_seconds
+		FROM jobs WHERE tenant_id = $1 AND node_id = $2 ORDER BY created_at DESC LIMIT NULLIF($3, 0)
 	`
-	rows, err := s.pool.Query(ctx, query, nodeID, limit)
+	rows, err := s.pool.Query(ctx, query, tenantID, nodeID, max(limit, 0))
 	if err != nil {
 		return nil, err
 	}
@@ -358,7 +500,7 @@ func (s *PostgresStore) ListJobs(ctx context.Context, tenantID string, nodeID st
 		var j Job
 		if err := rows.Scan(
 			&j.JobID, &j.NodeID, &j.TenantID, &j.StateID, &j.Command, &j.Status,
-			&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt,
+			&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.TimeoutSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -369,10 +511,10 @@ func (s *PostgresStore) ListJobs(ctx context.Context, tenantID string, nodeID st
 
 func (s *PostgresStore) ListJobsByTenant(ctx context.Context, tenantID string, limit int) ([]*Job, error) {
 	query := `
-		SELECT job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at
-		FROM jobs WHERE tenant_id = $1 ORDER BY created_at DESC LIMIT $2
+		SELECT job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at, timeout_seconds
+		FROM jobs WHERE tenant_id = $1 ORDER BY created_at DESC LIMIT NULLIF($2, 0)
 	`
-	rows, err := s.pool.Query(ctx, query, tenantID, limit)
+	rows, err := s.pool.Query(ctx, query, tenantID, max(limit, 0))
 	if err != nil {
 		return nil, err
 	}
@@ -383,7 +525,7 @@ func (s *PostgresStore) ListJobsByTenant(ctx context.Context, tenantID string, l
 		var j Job
 		if err := rows.Scan(
 			&j.JobID, &j.NodeID, &j.TenantID, &j.StateID, &j.Command, &j.Status,
-			&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt,
+			&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.TimeoutSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -394,6 +536,34 @@ func (s *PostgresStore) ListJobsByTenant(ctx context.Context, tenantID string, l
 
 // --- Coordination Operations ---
 
+// ClaimNextJob leases the oldest queued job for a node.
+// SKIP LOCKED lets concurrent pollers claim different jobs without blocking.
+func (s *PostgresStore) ClaimNextJob(ctx context.Context, tenantID string, nodeID string) (*Job, error) {
+	query := `
+		UPDATE jobs SET status = 'running', started_at = NOW()
+		WHERE job_id = (
+			SELECT job_id FROM jobs
+			WHERE tenant_id = $1 AND node_id = $2 AND status = 'queued'
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at, timeout_seconds
+	`
+	var j Job
+	err := s.pool.QueryRow(ctx, query, tenantID, nodeID).Scan(
+		&j.JobID, &j.NodeID, &j.TenantID, &j.StateID, &j.Command, &j.Status,
+		&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.TimeoutSeconds,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
 func (s *PostgresStore) IncrementDurableEpoch(ctx context.Context, resourceID string) (int64, error) {
 	// Atomic UPSERT to increment epoch
 	query := `
@@ -423,21 +593,3 @@ func (s *PostgresStore) GetDurableEpoch(ctx context.Context, resourceID string)
 	}
 	return epoch, nil
 }
-
-// --- Idempotency Operations ---
-
-// GetIdempotencyRecord retrieves a cached idempotency response
-// Note: Postgres is not ideal for idempotency caching (use Redis instead)
-// This implementation is for completeness
-func (s *PostgresStore) GetIdempotencyRecord(key string) (string, error) {
-	// Not implemented in Postgres - should use Redis for idempotency
-	return "", errors.New("not found")
-}
-
-// SetIdempotencyRecord stores an idempotency response
-// Note: Postgres is not ideal for idempotency caching (use Redis instead)
-// This implementation is for completeness
-func (s *PostgresStore) SetIdempotencyRecord(key string, value string, ttl time.Duration) error {
-	// Not implemented in Postgres - should use Redis for idempotency
-	return nil
-}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresStore implements Coordinator with the leases table. A row is
+// held while expires_at (database clock) is in the future; an expired row
+// is free and is overwritten by the next AcquireLock.
+
+// AcquireLock takes the lock if it is free or its holder's lease expired.
+func (s *PostgresStore) AcquireLock(ctx context.Context, key string, ownerID string, ttl time.Duration) (bool, error) {
+	query := `
+		INSERT INTO leases (key, value, expires_at)
+		VALUES ($1, $2, NOW() + $3::float8 * INTERVAL '1 millisecond')
+		ON CONFLICT (key) DO UPDATE SET
+			value = EXCLUDED.value,
+			expires_at = EXCLUDED.expires_at
+		WHERE leases.expires_at <= NOW()
+		RETURNING key
+	`
+	var got string
+	err := s.pool.QueryRow(ctx, query, key, ownerID, leaseMillis(ttl)).Scan(&got)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RenewLock extends the TTL if the lock is still held by ownerID.
+func (s *PostgresStore) RenewLock(ctx context.Context, key string, ownerID string, ttl time.Duration) (bool, error) {
+	query := `
+		UPDATE leases SET expires_at = NOW() + $3::float8 * INTERVAL '1 millisecond'
+		WHERE key = $1 AND value = $2 AND expires_at > NOW()
+	`
+	tag, err := s.pool.Exec(ctx, query, key, ownerID, leaseMillis(ttl))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseLock releases the lock if held by ownerID.
+func (s *PostgresStore) ReleaseLock(ctx context.Context, key string, ownerID string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM leases WHERE key = $1 AND value = $2`, key, ownerID)
+	return err
+}
+
+// GetLockOwner returns the current owner, or "" if the lock is free.
+func (s *PostgresStore) GetLockOwner(ctx context.Context, key string) (string, error) {
+	var owner string
+	err := s.pool.QueryRow(ctx,
+		`SELECT value FROM leases WHERE key = $1 AND expires_at > NOW()`, key,
+	).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
+// --- Lease Implementation (Reuse Logic) ---
+
+func (s *PostgresStore) AcquireLease(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return s.AcquireLock(ctx, key, value, ttl)
+}
+
+func (s *PostgresStore) RenewLease(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return s.RenewLock(ctx, key, value, ttl)
+}
+
+func (s *PostgresStore) ReleaseLease(ctx context.Context, key string, value string) error {
+	return s.ReleaseLock(ctx, key, value)
+}
+
+func (s *PostgresStore) IsLeaseOwner(ctx context.Context, key string, value string) (bool, error) {
+	owner, err := s.GetLockOwner(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return owner == value, nil
+}
+
+// IncrementEpoch increments the epoch counter for the given key, stored
+// like RedisStore's under key + ":epoch".
+func (s *PostgresStore) IncrementEpoch(ctx context.Context, key string) (int64, error) {
+	return s.IncrementDurableEpoch(ctx, key+":epoch")
+}
+
+// ScanLocks returns the held locks whose key matches the glob pattern
+// ("*" and "?" wildcards, as with Redis SCAN MATCH).
+func (s *PostgresStore) ScanLocks(ctx context.Context, pattern string) ([]string, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT key FROM leases WHERE key LIKE $1 AND expires_at > NOW() ORDER BY key`,
+		globToLike(pattern),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func leaseMillis(ttl time.Duration) float64 {
+	return float64(ttl) / float64(time.Millisecond)
+}
+
+// globToLike translates a Redis-style glob into a LIKE pattern using the
+// default backslash escape.
+func globToLike(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			if r == '%' || r == '_' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteByte('%')
+		case r == '?':
+			b.WriteByte('_')
+		case r == '%' || r == '_':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
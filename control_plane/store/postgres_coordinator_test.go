@@ -0,0 +1,19 @@
+package store
+
+import "testing"
+
+func TestGlobToLike(t *testing.T) {
+	cases := map[string]string{
+		"fluxforge:lock:*":           "fluxforge:lock:%",
+		"fluxforge:shards:member:?":  "fluxforge:shards:member:_",
+		"tenant_a:100%":              `tenant\_a:100\%`,
+		`literal\*star`:              "literal*star",
+		`back\\slash`:                `back\\slash`,
+		"fluxforge:tenants:*:agent:": "fluxforge:tenants:%:agent:",
+	}
+	for glob, want := range cases {
+		if got := globToLike(glob); got != want {
+			t.Errorf("globToLike(%q) = %q, want %q", glob, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresStore keeps idempotency keys in the idempotency_keys table, so
+// every replica sees a key recorded by any of them. A row is live while
+// expires_at (database clock) is in the future; the first live value for
+// a key wins, and an expired row is overwritten by the next insert.
+//
+// Set and Get implement idempotency.Backend; the *IdempotencyRecord
+// methods use the same table under an "idempotency:" prefix, as in the
+// bolt store.
+
+// expiresAt is the SQL for an expiry ttl (in milliseconds, $3) from now;
+// ttl <= 0 never expires.
+const expiresAt = `CASE WHEN $3::float8 > 0 THEN NOW() + $3::float8 * INTERVAL '1 millisecond' ELSE 'infinity' END`
+
+// Set records value under key unless a live value is already recorded.
+func (s *PostgresStore) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	_, err := s.setIdempotencyKey(ctx, key, value, ttl)
+	return err
+}
+
+// Get returns the live value under key, or "" if there is none.
+func (s *PostgresStore) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.pool.QueryRow(ctx,
+		`SELECT value FROM idempotency_keys WHERE key = $1 AND expires_at > NOW()`, key,
+	).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// setIdempotencyKey inserts the key, or replaces an expired row, and
+// reports whether it did; a live row is left alone.
+func (s *PostgresStore) setIdempotencyKey(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (key, value, expires_at)
+		VALUES ($1, $2, ` + expiresAt + `)
+		ON CONFLICT (key) DO UPDATE SET
+			value = EXCLUDED.value,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+	`
+	tag, err := s.pool.Exec(ctx, query, key, value, leaseMillis(ttl))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresStore) GetIdempotencyRecord(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	val, err := s.Get(ctx, "idempotency:"+key)
+	if err != nil {
+		return "", err
+	}
+	if val == "" {
+		return "", errors.New("not found")
+	}
+	return val, nil
+}
+
+// SetIdempotencyRecord records value, replacing a live one.
+func (s *PostgresStore) SetIdempotencyRecord(key string, value string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	query := `
+		INSERT INTO idempotency_keys (key, value, expires_at)
+		VALUES ($1, $2, ` + expiresAt + `)
+		ON CONFLICT (key) DO UPDATE SET
+			value = EXCLUDED.value,
+			expires_at = EXCLUDED.expires_at
+	`
+	_, err := s.pool.Exec(ctx, query, "idempotency:"+key, value, leaseMillis(ttl))
+	return err
+}
+
+// SetIdempotencyRecordNX records value only if no live record exists.
+func (s *PostgresStore) SetIdempotencyRecordNX(key string, value string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	acquired, err := s.setIdempotencyKey(ctx, "idempotency:"+key, value, ttl)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return errors.New("key exists")
+	}
+	return nil
+}
+
+// StartExpiry periodically deletes expired idempotency keys, which are
+// otherwise only skipped on read.
+func (s *PostgresStore) StartExpiry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`); err != nil && ctx.Err() == nil {
+					log.Printf("⚠️ PostgresStore: expiry sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+}
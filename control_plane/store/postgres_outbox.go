@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// insertOutboxEvent adds event to the outbox table in tx, so it commits or
+// rolls back with the write it reports.
+func insertOutboxEvent(ctx context.Context, tx pgx.Tx, event *OutboxEvent) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO outbox (event_id, topic, tenant_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, event.ID, event.Topic, event.TenantID, []byte(event.Payload), event.CreatedAt)
+	return err
+}
+
+func (s *PostgresStore) ReadOutbox(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	query := `SELECT id, event_id, topic, tenant_id, payload, created_at FROM outbox ORDER BY id`
+	var args []interface{}
+	if limit > 0 {
+		query += ` LIMIT $1`
+		args = append(args, limit)
+	}
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &e.ID, &e.Topic, &e.TenantID, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		e.Position = strconv.FormatInt(id, 10)
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+func (s *PostgresStore) AckOutbox(ctx context.Context, positions []string) error {
+	if len(positions) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(positions))
+	for i, p := range positions {
+		id, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return fmt.Errorf("outbox position %q: %w", p, err)
+		}
+		ids[i] = id
+	}
+	_, err := s.pool.Exec(ctx, `DELETE FROM outbox WHERE id = ANY($1)`, ids)
+	return err
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Publish sends a message with NOTIFY. Payloads must stay under
+// Postgres' 8000-byte limit.
+func (s *PostgresStore) Publish(ctx context.Context, channel string, message string) error {
+	_, err := s.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, message)
+	return err
+}
+
+// Subscribe blocks, invoking handler for each NOTIFY on channel, until ctx
+// is done or the listening connection fails. It holds one pool connection
+// for its lifetime.
+func (s *PostgresStore) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// LISTEN state is per connection: take it out of the pool for good
+	listener := conn.Hijack()
+	defer listener.Close(context.Background())
+
+	if _, err := listener.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		n, err := listener.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handler(n.Payload)
+	}
+}
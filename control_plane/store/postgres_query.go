@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// pgFilter accumulates WHERE conditions; each "?" in a condition becomes
+// the placeholder of the argument added with it.
+type pgFilter struct {
+	conds []string
+	args  []any
+}
+
+func (f *pgFilter) add(cond string, arg any) {
+	f.args = append(f.args, arg)
+	f.conds = append(f.conds, strings.ReplaceAll(cond, "?", fmt.Sprintf("$%d", len(f.args))))
+}
+
+// pageQuery completes a Query* statement: the filters shared by every
+// resource, the keyset condition for the cursor and the page order. It
+// fetches one row more than the page so the caller knows whether there is
+// a next page.
+func (f *pgFilter) pageQuery(selectFrom, idColumn string, opts ListOptions, after *pagePosition) string {
+	if opts.Status != "" {
+		f.add("status = ?", opts.Status)
+	}
+	if opts.NodeID != "" {
+		f.add("node_id = ?", opts.NodeID)
+	}
+	if !opts.CreatedSince.IsZero() {
+		f.add("created_at >= ?", opts.CreatedSince)
+	}
+	if !opts.CreatedBefore.IsZero() {
+		f.add("created_at < ?", opts.CreatedBefore)
+	}
+	if !opts.UpdatedSince.IsZero() {
+		f.add("updated_at >= ?", opts.UpdatedSince)
+	}
+	if !opts.UpdatedBefore.IsZero() {
+		f.add("updated_at < ?", opts.UpdatedBefore)
+	}
+
+	direction, cmp := "DESC", "<"
+	if opts.Order == SortAsc {
+		direction, cmp = "ASC", ">"
+	}
+	if after != nil {
+		f.args = append(f.args, after.CreatedAt, after.ID)
+		n := len(f.args)
+		f.conds = append(f.conds, fmt.Sprintf("(created_at, %s) %s ($%d, $%d)", idColumn, cmp, n-1, n))
+	}
+
+	query := selectFrom
+	if len(f.conds) > 0 {
+		query += " WHERE " + strings.Join(f.conds, " AND ")
+	}
+	return query + fmt.Sprintf(" ORDER BY created_at %s, %s %s LIMIT %d", direction, idColumn, direction, opts.Limit+1)
+}
+
+// nextCursor trims rows fetched by pageQuery to the page and returns the
+// cursor for the page after it.
+func nextCursor(n int, position func(i int) pagePosition, opts ListOptions) (int, string) {
+	if n <= opts.Limit {
+		return n, ""
+	}
+	last := position(opts.Limit - 1)
+	last.Order = opts.Order
+	return opts.Limit, last.encode()
+}
+
+func (s *PostgresStore) QueryAgents(ctx context.Context, tenantID string, opts ListOptions) (*AgentPage, error) {
+	opts, after, err := opts.normalize(ResourceAgent)
+	if err != nil {
+		return nil, err
+	}
+	var f pgFilter
+	if tenantID != "" {
+		f.add("tenant_id = ?", tenantID)
+	}
+	if opts.Tier != "" {
+		f.add("tier = ?", opts.Tier)
+	}
+	if len(opts.Labels) > 0 {
+		labels, err := json.Marshal(opts.Labels)
+		if err != nil {
+			return nil, err
+		}
+		f.add("metadata @> ?::jsonb", string(labels))
+	}
+	query := f.pageQuery(`
+		SELECT node_id, tenant_id, hostname, ip_address, port, version, status, last_heartbeat_at, created_at, updated_at, metadata, tier
+		FROM agents`, "node_id", opts, after)
+
+	rows, err := s.pool.Query(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := make([]*Agent, 0, opts.Limit+1)
+	for rows.Next() {
+		var a Agent
+		if err := rows.Scan(
+			&a.NodeID, &a.TenantID, &a.Hostname, &a.IPAddress, &a.Port, &a.Version, &a.Status,
+			&a.LastHeartbeat, &a.CreatedAt, &a.UpdatedAt, &a.Metadata, &a.Tier,
+		); err != nil {
+			return nil, err
+		}
+		agents = append(agents, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := nextCursor(len(agents), func(i int) pagePosition {
+		return pagePosition{CreatedAt: agents[i].CreatedAt, ID: agents[i].NodeID}
+	}, opts)
+	return &AgentPage{Agents: agents[:n], NextCursor: next}, nil
+}
+
+func (s *PostgresStore) QueryStates(ctx context.Context, tenantID string, opts ListOptions) (*StatePage, error) {
+	opts, after, err := opts.normalize(ResourceState)
+	if err != nil {
+		return nil, err
+	}
+	var f pgFilter
+	f.add("tenant_id = ?", tenantID)
+	query := f.pageQuery(`
+		SELECT state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, created_at, updated_at,
+			job_timeout_seconds, check_interval
+		FROM desired_states`, "state_id", opts, after)
+
+	rows, err := s.pool.Query(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make([]*DesiredState, 0, opts.Limit+1)
+	for rows.Next() {
+		var st DesiredState
+		if err := rows.Scan(
+			&st.StateID, &st.NodeID, &st.TenantID, &st.CheckCmd, &st.ApplyCmd,
+			&st.DesiredExitCode, &st.Version, &st.Status, &st.LastChecked, &st.LastError, &st.CreatedAt, &st.UpdatedAt,
+			&st.JobTimeoutSeconds, &st.CheckInterval,
+		); err != nil {
+			return nil, err
+		}
+		states = append(states, &st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := nextCursor(len(states), func(i int) pagePosition {
+		return pagePosition{CreatedAt: states[i].CreatedAt, ID: states[i].StateID}
+	}, opts)
+	return &StatePage{States: states[:n], NextCursor: next}, nil
+}
+
+func (s *PostgresStore) QueryJobs(ctx context.Context, tenantID string, opts ListOptions) (*JobPage, error) {
+	opts, after, err := opts.normalize(ResourceJob)
+	if err != nil {
+		return nil, err
+	}
+	var f pgFilter
+	f.add("tenant_id = ?", tenantID)
+	query := f.pageQuery(`
+		SELECT job_id, node_id, tenant_id, state_id, command, status, exit_code, stdout, stderr, trace_id, created_at, started_at, finished_at, timeout_seconds
+		FROM jobs`, "job_id", opts, after)
+
+	rows, err := s.pool.Query(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*Job, 0, opts.Limit+1)
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(
+			&j.JobID, &j.NodeID, &j.TenantID, &j.StateID, &j.Command, &j.Status,
+			&j.ExitCode, &j.Stdout, &j.Stderr, &j.TraceID, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.TimeoutSeconds,
+		); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := nextCursor(len(jobs), func(i int) pagePosition {
+		return pagePosition{CreatedAt: jobs[i].CreatedAt, ID: jobs[i].JobID}
+	}, opts)
+	return &JobPage{Jobs: jobs[:n], NextCursor: next}, nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *PostgresStore) ListStateRevisions(ctx context.Context, tenantID string, stateID string) ([]*StateRevision, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT revision, author, reason, created_at, state
+		FROM state_revisions WHERE tenant_id = $1 AND state_id = $2
+		ORDER BY revision
+	`, tenantID, stateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revs := []*StateRevision{}
+	for rows.Next() {
+		rev, err := scanStateRevision(rows, tenantID, stateID)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, rows.Err()
+}
+
+func (s *PostgresStore) GetStateRevision(ctx context.Context, tenantID string, stateID string, revision int) (*StateRevision, error) {
+	row := s.pool.QueryRow(ctx, `
+		SELECT revision, author, reason, created_at, state
+		FROM state_revisions WHERE tenant_id = $1 AND state_id = $2 AND revision = $3
+	`, tenantID, stateID, revision)
+	rev, err := scanStateRevision(row, tenantID, stateID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return rev, err
+}
+
+func scanStateRevision(row pgx.Row, tenantID, stateID string) (*StateRevision, error) {
+	rev := StateRevision{TenantID: tenantID, StateID: stateID}
+	var state []byte
+	if err := row.Scan(&rev.Revision, &rev.Author, &rev.Reason, &rev.CreatedAt, &state); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(state, &rev.State); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
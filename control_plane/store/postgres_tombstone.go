@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Deletes remove the row and insert its tombstone in one transaction; the
+// tombstone keeps the row as its Go JSON form, like the other backends.
+
+func (s *PostgresStore) DeleteAgent(ctx context.Context, tenantID string, nodeID string) error {
+	var a Agent
+	return s.deleteWithTombstone(ctx, ResourceAgent, tenantID, nodeID, &a, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			DELETE FROM agents WHERE node_id = $1 AND tenant_id = $2
+			RETURNING node_id, tenant_id, hostname, ip_address, port, version, status, last_heartbeat_at, created_at, updated_at, metadata, tier
+		`, nodeID, tenantID).Scan(
+			&a.NodeID, &a.TenantID, &a.Hostname, &a.IPAddress, &a.Port, &a.Version, &a.Status,
+			&a.LastHeartbeat, &a.CreatedAt, &a.UpdatedAt, &a.Metadata, &a.Tier,
+		)
+	})
+}
+
+func (s *PostgresStore) DeleteState(ctx context.Context, tenantID string, stateID string) error {
+	var st DesiredState
+	return s.deleteWithTombstone(ctx, ResourceState, tenantID, stateID, &st, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM state_revisions WHERE tenant_id = $1 AND state_id = $2`, tenantID, stateID); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			DELETE FROM desired_states WHERE state_id = $1 AND tenant_id = $2
+			RETURNING state_id, node_id, tenant_id, check_cmd, apply_cmd, desired_exit_code, version, status, last_checked, last_error, created_at, updated_at,
+				job_timeout_seconds, check_interval
+		`, stateID, tenantID).Scan(
+			&st.StateID, &st.NodeID, &st.TenantID, &st.CheckCmd, &st.ApplyCmd,
+			&st.DesiredExitCode, &st.Version, &st.Status, &st.LastChecked, &st.LastError, &st.CreatedAt, &st.UpdatedAt,
+			&st.JobTimeoutSeconds, &st.CheckInterval,
+		)
+	})
+}
+
+// deleteWithTombstone runs del (a DELETE ... RETURNING scanned into record)
+// and records the tombstone in the same transaction.
+func (s *PostgresStore) deleteWithTombstone(ctx context.Context, resource Resource, tenantID, id string, record interface{}, del func(tx pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := del(tx); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%s %s: %w", resource, id, ErrNotFound)
+		}
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO tombstones (resource, tenant_id, id, record, deleted_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (resource, tenant_id, id) DO UPDATE SET
+			record = EXCLUDED.record,
+			deleted_at = EXCLUDED.deleted_at
+	`, string(resource), tenantID, id, data)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresStore) PurgeJobs(ctx context.Context, tenantID string, purge JobPurge) (int, error) {
+	var f pgFilter
+	f.add("tenant_id = ?", tenantID)
+	f.conds = append(f.conds, "finished_at IS NOT NULL")
+	if purge.NodeID != "" {
+		f.add("node_id = ?", purge.NodeID)
+	}
+	if purge.StateID != "" {
+		f.add("state_id = ?", purge.StateID)
+	}
+	if !purge.FinishedBefore.IsZero() {
+		f.add("finished_at < ?", purge.FinishedBefore)
+	}
+	tag, err := s.pool.Exec(ctx, "DELETE FROM jobs WHERE "+strings.Join(f.conds, " AND "), f.args...)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *PostgresStore) GetTombstone(ctx context.Context, tenantID string, resource Resource, id string) (*Tombstone, error) {
+	t := Tombstone{Resource: resource, TenantID: tenantID, ID: id}
+	err := s.pool.QueryRow(ctx, `
+		SELECT record, deleted_at FROM tombstones WHERE resource = $1 AND tenant_id = $2 AND id = $3
+	`, string(resource), tenantID, id).Scan(&t.Record, &t.DeletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *PostgresStore) PurgeTombstones(ctx context.Context, before time.Time) (int, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM tombstones WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
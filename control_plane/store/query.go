@@ -0,0 +1,360 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Page sizes for the Query* listings.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// ErrInvalidListOptions is returned by the Query* methods for a malformed
+// cursor, an unknown sort order or a filter the resource does not have.
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// SortOrder is the direction of a listing by creation time.
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// ListOptions selects one page of a Query* listing. Records are ordered by
+// CreatedAt, then by ID, so a cursor stays valid while records are added.
+// Time ranges are [Since, Before); zero values and empty strings do not
+// filter.
+type ListOptions struct {
+	Limit  int       // Page size: 0 = DefaultPageSize, capped at MaxPageSize
+	Cursor string    // NextCursor of the previous page, "" for the first
+	Order  SortOrder // Default SortDesc (newest first)
+
+	Status        string
+	NodeID        string
+	Tier          string            // Agents only
+	Labels        map[string]string // Agents only: Metadata must contain every pair
+	CreatedSince  time.Time
+	CreatedBefore time.Time
+	UpdatedSince  time.Time // Agents and states only
+	UpdatedBefore time.Time // Agents and states only
+}
+
+// AgentPage is one page of QueryAgents. NextCursor is "" on the last page.
+type AgentPage struct {
+	Agents     []*Agent
+	NextCursor string
+}
+
+// StatePage is one page of QueryStates.
+type StatePage struct {
+	States     []*DesiredState
+	NextCursor string
+}
+
+// JobPage is one page of QueryJobs.
+type JobPage struct {
+	Jobs       []*Job
+	NextCursor string
+}
+
+// pagePosition is a record's place in a listing and what a cursor encodes.
+type pagePosition struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Order     SortOrder `json:"o"`
+}
+
+func (p pagePosition) encode() string {
+	data, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// createdKey is p as a string that sorts, byte by byte, in ascending
+// listing order: the creation time as zero-padded Unix nanoseconds, then
+// the ID. RedisStore and BoltStore index records under it, so a page is a
+// range read from the cursor on rather than a sort of every record.
+func (p pagePosition) createdKey() string {
+	return createdKeyPrefix(p.CreatedAt) + p.ID
+}
+
+// createdKeyPrefix is the createdKey prefix of records created at t; keys
+// of records created earlier sort before it, later ones after.
+func createdKeyPrefix(t time.Time) string {
+	ns := t.UnixNano()
+	if t.IsZero() || ns < 0 {
+		ns = 0
+	}
+	return fmt.Sprintf("%020d:", ns)
+}
+
+// createdKeyID returns the ID part of a createdKey.
+func createdKeyID(key string) string {
+	_, id, _ := strings.Cut(key, ":")
+	return id
+}
+
+// recordPosition decodes the tenant and position of a stored agent or job,
+// for rebuilding created-order indexes from the records.
+func recordPosition(resource Resource, data []byte) (string, pagePosition, error) {
+	var record struct {
+		TenantID  string    `json:"tenant_id"`
+		NodeID    string    `json:"node_id"`
+		JobID     string    `json:"job_id"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return "", pagePosition{}, err
+	}
+	pos := pagePosition{CreatedAt: record.CreatedAt, ID: record.NodeID}
+	if resource == ResourceJob {
+		pos.ID = record.JobID
+	}
+	return record.TenantID, pos, nil
+}
+
+func agentPosition(a *Agent) pagePosition {
+	return pagePosition{CreatedAt: a.CreatedAt, ID: a.NodeID}
+}
+
+func jobPosition(j *Job) pagePosition {
+	return pagePosition{CreatedAt: j.CreatedAt, ID: j.JobID}
+}
+
+// precedes reports whether p comes before other in the listing's order.
+func (p pagePosition) precedes(other pagePosition, order SortOrder) bool {
+	if p.CreatedAt.Equal(other.CreatedAt) {
+		if order == SortAsc {
+			return p.ID < other.ID
+		}
+		return p.ID > other.ID
+	}
+	if order == SortAsc {
+		return p.CreatedAt.Before(other.CreatedAt)
+	}
+	return p.CreatedAt.After(other.CreatedAt)
+}
+
+// normalize validates opts for resource, fills in defaults and decodes the
+// cursor (nil for the first page).
+func (o ListOptions) normalize(resource Resource) (ListOptions, *pagePosition, error) {
+	switch {
+	case o.Limit < 0:
+		return o, nil, fmt.Errorf("negative limit %d: %w", o.Limit, ErrInvalidListOptions)
+	case o.Limit == 0:
+		o.Limit = DefaultPageSize
+	case o.Limit > MaxPageSize:
+		o.Limit = MaxPageSize
+	}
+
+	switch o.Order {
+	case "":
+		o.Order = SortDesc
+	case SortAsc, SortDesc:
+	default:
+		return o, nil, fmt.Errorf("sort order %q (want asc or desc): %w", o.Order, ErrInvalidListOptions)
+	}
+
+	if resource != ResourceAgent && (o.Tier != "" || len(o.Labels) > 0) {
+		return o, nil, fmt.Errorf("%s have no tier or labels: %w", resource, ErrInvalidListOptions)
+	}
+	if resource == ResourceJob && (!o.UpdatedSince.IsZero() || !o.UpdatedBefore.IsZero()) {
+		return o, nil, fmt.Errorf("jobs have no update time: %w", ErrInvalidListOptions)
+	}
+
+	if o.Cursor == "" {
+		return o, nil, nil
+	}
+	var after pagePosition
+	data, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &after)
+	}
+	if err != nil || after.ID == "" {
+		return o, nil, fmt.Errorf("malformed cursor: %w", ErrInvalidListOptions)
+	}
+	if after.Order != o.Order {
+		return o, nil, fmt.Errorf("cursor is for %s order, not %s: %w", after.Order, o.Order, ErrInvalidListOptions)
+	}
+	return o, &after, nil
+}
+
+// matches applies the filters every resource has.
+func (o ListOptions) matches(status, nodeID string, createdAt, updatedAt time.Time) bool {
+	return (o.Status == "" || status == o.Status) &&
+		(o.NodeID == "" || nodeID == o.NodeID) &&
+		inRange(createdAt, o.CreatedSince, o.CreatedBefore) &&
+		inRange(updatedAt, o.UpdatedSince, o.UpdatedBefore)
+}
+
+func (o ListOptions) matchesAgent(a *Agent) bool {
+	if !o.matches(a.Status, a.NodeID, a.CreatedAt, a.UpdatedAt) {
+		return false
+	}
+	if o.Tier != "" && a.Tier != o.Tier {
+		return false
+	}
+	for k, v := range o.Labels {
+		if got, ok := a.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// createdBounds is the createdKey range [low, high) that CreatedSince and
+// CreatedBefore select; "" leaves that end open.
+func (o ListOptions) createdBounds() (low, high string) {
+	if !o.CreatedSince.IsZero() {
+		low = createdKeyPrefix(o.CreatedSince)
+	}
+	if !o.CreatedBefore.IsZero() {
+		high = createdKeyPrefix(o.CreatedBefore)
+	}
+	return low, high
+}
+
+func inRange(t, since, before time.Time) bool {
+	return (since.IsZero() || !t.Before(since)) && (before.IsZero() || t.Before(before))
+}
+
+// paginate orders n records, skips those up to the cursor and returns the
+// indexes of one page plus the cursor for the next. It is the Query*
+// implementation for stores without a created-order index (see
+// keysetPage); PostgresStore does the same in SQL.
+func paginate(n int, position func(i int) pagePosition, opts ListOptions, after *pagePosition) ([]int, string) {
+	idx := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if after == nil || after.precedes(position(i), opts.Order) {
+			idx = append(idx, i)
+		}
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		return position(idx[a]).precedes(position(idx[b]), opts.Order)
+	})
+
+	if len(idx) <= opts.Limit {
+		return idx, ""
+	}
+	idx = idx[:opts.Limit]
+	last := position(idx[len(idx)-1])
+	last.Order = opts.Order
+	return idx, last.encode()
+}
+
+// indexScan reads up to n createdKey entries of an index, in the
+// listing's order and within its createdBounds, starting after from ("" =
+// at the start).
+type indexScan func(from string, n int) ([]string, error)
+
+// keysetPage is the Query* implementation for stores that keep a
+// created-order index: it reads the index in batches from the cursor on,
+// loads each batch (nil for a record that is gone) and keeps the records
+// that match until the page is full. Records whose position no longer
+// matches their entry are stale entries and skipped.
+func keysetPage[T any](opts ListOptions, after *pagePosition, scan indexScan, load func(keys []string) ([]*T, error), position func(*T) pagePosition, keep func(*T) bool) ([]*T, string, error) {
+	from := ""
+	if after != nil {
+		from = after.createdKey()
+	}
+	batch := opts.Limit + 1
+	page := make([]*T, 0, opts.Limit)
+	for {
+		keys, err := scan(from, batch)
+		if err != nil {
+			return nil, "", err
+		}
+		records, err := load(keys)
+		if err != nil {
+			return nil, "", err
+		}
+		for i, rec := range records {
+			if rec == nil || position(rec).createdKey() != keys[i] || !keep(rec) {
+				continue
+			}
+			if len(page) == opts.Limit {
+				last := position(page[len(page)-1])
+				last.Order = opts.Order
+				return page, last.encode(), nil
+			}
+			page = append(page, rec)
+		}
+		if len(keys) < batch {
+			return page, "", nil
+		}
+		from = keys[len(keys)-1]
+	}
+}
+
+func pageAgents(agents []*Agent, opts ListOptions) (*AgentPage, error) {
+	opts, after, err := opts.normalize(ResourceAgent)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*Agent
+	for _, a := range agents {
+		if opts.matchesAgent(a) {
+			matched = append(matched, a)
+		}
+	}
+	idx, next := paginate(len(matched), func(i int) pagePosition {
+		return agentPosition(matched[i])
+	}, opts, after)
+
+	page := &AgentPage{Agents: make([]*Agent, 0, len(idx)), NextCursor: next}
+	for _, i := range idx {
+		page.Agents = append(page.Agents, matched[i])
+	}
+	return page, nil
+}
+
+func pageStates(states []*DesiredState, opts ListOptions) (*StatePage, error) {
+	opts, after, err := opts.normalize(ResourceState)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*DesiredState
+	for _, st := range states {
+		if opts.matches(st.Status, st.NodeID, st.CreatedAt, st.UpdatedAt) {
+			matched = append(matched, st)
+		}
+	}
+	idx, next := paginate(len(matched), func(i int) pagePosition {
+		return pagePosition{CreatedAt: matched[i].CreatedAt, ID: matched[i].StateID}
+	}, opts, after)
+
+	page := &StatePage{States: make([]*DesiredState, 0, len(idx)), NextCursor: next}
+	for _, i := range idx {
+		page.States = append(page.States, matched[i])
+	}
+	return page, nil
+}
+
+func pageJobs(jobs []*Job, opts ListOptions) (*JobPage, error) {
+	opts, after, err := opts.normalize(ResourceJob)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*Job
+	for _, j := range jobs {
+		if opts.matches(j.Status, j.NodeID, j.CreatedAt, time.Time{}) {
+			matched = append(matched, j)
+		}
+	}
+	idx, next := paginate(len(matched), func(i int) pagePosition {
+		return jobPosition(matched[i])
+	}, opts, after)
+
+	page := &JobPage{Jobs: make([]*Job, 0, len(idx)), NextCursor: next}
+	for _, i := range idx {
+		page.Jobs = append(page.Jobs, matched[i])
+	}
+	return page, nil
+}
@@ -5,17 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"strings"
 	"time"
 
-	"hash/fnv"
-
 	"github.com/itskum47/FluxForge/control_plane/observability"
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisStore implements the Store interface using Redis.
+// RedisStore implements the Store interface using a single Redis node.
+// Redis Cluster is not supported: a state write updates the tenant's
+// records, the global status index and the outbox in one script, and
+// Cluster rejects a script whose keys span hash slots.
 type RedisStore struct {
 	client *redis.Client
 
@@ -57,6 +56,12 @@ func NewRedisStore(addr string, password string, db int) (*RedisStore, error) {
 	}, nil
 }
 
+// Client exposes the Redis client for components sharing the connection
+// (e.g. the timeline backend).
+func (s *RedisStore) Client() *redis.Client {
+	return s.client
+}
+
 // AcquireLock attempts to acquire a distributed lock.
 // It uses SET key value NX EX ttl.
 func (s *RedisStore) AcquireLock(ctx context.Context, key string, ownerID string, ttl time.Duration) (bool, error) {
@@ -253,12 +258,28 @@ func (s *RedisStore) SetIdempotencyRecord(key string, value string, ttl time.Dur
 
 func (s *RedisStore) UpsertAgent(ctx context.Context, tenantID string, agent *Agent) error {
 	agent.TenantID = tenantID // Enforce binding
+	key := TenantKey(tenantID, ResourceAgent, agent.NodeID)
+
+	now := time.Now()
+	agent.CreatedAt = createdAt(ctx, now)
+	if prev, err := s.GetAgent(ctx, tenantID, agent.NodeID); err != nil {
+		return err
+	} else if prev != nil {
+		agent.CreatedAt = prev.CreatedAt
+	}
+	agent.UpdatedAt = now
+
 	data, err := json.Marshal(agent)
 	if err != nil {
 		return fmt.Errorf("failed to marshal agent: %w", err)
 	}
-	key := TenantKey(tenantID, ResourceAgent, agent.NodeID)
-	return s.client.Set(ctx, key, data, 0).Err()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, 0)
+		pipe.Del(ctx, TombstoneKey(tenantID, ResourceAgent, agent.NodeID))
+		pipe.ZAdd(ctx, createdIndexKey(tenantID, ResourceAgent), redis.Z{Member: agentPosition(agent).createdKey()})
+		return nil
+	})
+	return err
 }
 
 func (s *RedisStore) GetAgent(ctx context.Context, tenantID string, nodeID string) (*Agent, error) {
@@ -301,13 +322,49 @@ func (s *RedisStore) ListAgents(ctx context.Context, tenantID string) ([]*Agent,
 	return agents, iter.Err()
 }
 
+// ListTenants scans the keys of every tenant's records.
+func (s *RedisStore) ListTenants(ctx context.Context) ([]string, error) {
+	set := make(map[string]bool)
+	for _, resource := range []Resource{ResourceAgent, ResourceState, ResourceJob} {
+		iter := s.client.Scan(ctx, 0, TenantWildcardPrefix(resource), 0).Iterator()
+		for iter.Next(ctx) {
+			if tid, ok := tenantFromKey(iter.Val(), resource); ok {
+				set[tid] = true
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return sortedTenants(set), nil
+}
+
+// QueryAgents reads one page from the tenant's created-order index,
+// starting at the cursor.
+func (s *RedisStore) QueryAgents(ctx context.Context, tenantID string, opts ListOptions) (*AgentPage, error) {
+	opts, after, err := opts.normalize(ResourceAgent)
+	if err != nil {
+		return nil, err
+	}
+	agents, next, err := keysetPage(opts, after,
+		s.createdIndexScan(ctx, tenantID, ResourceAgent, opts),
+		func(keys []string) ([]*Agent, error) {
+			return loadRecords[Agent](ctx, s.client, tenantID, ResourceAgent, keys)
+		},
+		agentPosition, opts.matchesAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentPage{Agents: agents, NextCursor: next}, nil
+}
+
 func (s *RedisStore) UpdateAgentHeartbeat(ctx context.Context, tenantID string, nodeID string, t time.Time) error {
 	agent, err := s.GetAgent(ctx, tenantID, nodeID)
 	if err != nil {
 		return err
 	}
 	if agent == nil {
-		return fmt.Errorf("agent not found: %s", nodeID)
+		return fmt.Errorf("agent %s: %w", nodeID, ErrNotFound)
 	}
 	agent.LastHeartbeat = t
 	agent.Status = "active"
@@ -318,61 +375,75 @@ func (s *RedisStore) UpsertState(ctx context.Context, tenantID string, state *De
 	state.TenantID = tenantID
 	key := TenantKey(tenantID, ResourceState, state.StateID)
 
-	// Optimistic Locking: Read existing version
+	// The hash "version" is a write revision for CAS; state.Version is the
+	// spec version, bumped only here (status updates keep it)
+	var expectedRev int64
+	now := time.Now()
+	state.Version = 1
+	state.CreatedAt = createdAt(ctx, now)
 	current, err := s.GetVersioned(ctx, key)
-	var newVersion int64 = 1
 	if err == nil {
-		newVersion = current.Version + 1
-	} else if err.Error() != "not found" {
+		expectedRev = current.Version
+		var prev DesiredState
+		if err := current.Decode(&prev); err == nil {
+			state.Version = prev.Version + 1
+			state.CreatedAt = prev.CreatedAt
+		}
+	} else if !errors.Is(err, ErrNotFound) {
 		return err
 	}
-	state.Version = int(newVersion)
-	state.UpdatedAt = time.Now()
-
-	val := VersionedValue{
-		Value:     state,
-		Version:   newVersion,
-		Timestamp: time.Now().Unix(),
+	// The write revision CAS below makes this check atomic with the write
+	if err := checkExpectedVersion(ctx, state.StateID, state.Version-1); err != nil {
+		return err
 	}
+	state.UpdatedAt = now
 
-	return s.SetVersioned(ctx, key, val, 0)
+	ok, err := s.writeState(ctx, state, expectedRev, newStateRevision(ctx, state), nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("state %s: concurrent modification: %w", state.StateID, ErrVersionConflict)
+	}
+	return nil
 }
 
 func (s *RedisStore) UpdateStateStatus(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int) error {
+	return s.UpdateStateStatusWithEvent(ctx, tenantID, stateID, status, lastError, lastChecked, expectedVersion, nil)
+}
+
+// UpdateStateStatusWithEvent appends the event to the outbox stream in the
+// script that writes the state.
+func (s *RedisStore) UpdateStateStatusWithEvent(ctx context.Context, tenantID string, stateID string, status string, lastError string, lastChecked time.Time, expectedVersion int, event *OutboxEvent) error {
 	key := TenantKey(tenantID, ResourceState, stateID)
 
-	// Read-Modify-Write loop or CAS
-	// For simplicity in this certification, we read then CAS
-	current, err := s.GetState(ctx, tenantID, stateID)
+	current, err := s.GetVersioned(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("state %s: %w", stateID, ErrNotFound)
+	}
 	if err != nil {
 		return err
 	}
-	if current == nil {
-		return fmt.Errorf("state not found")
+	var state DesiredState
+	if err := current.Decode(&state); err != nil {
+		return fmt.Errorf("failed to decode state %s: %w", stateID, err)
 	}
-
-	if expectedVersion > 0 && current.Version != expectedVersion {
-		return fmt.Errorf("version mismatch")
+	if state.Version != expectedVersion {
+		return fmt.Errorf("state %s at version %d, expected %d: %w", stateID, state.Version, expectedVersion, ErrVersionConflict)
 	}
 
-	current.Status = status
-	current.LastError = lastError
-	current.LastChecked = lastChecked
-	current.Version++
-	current.UpdatedAt = time.Now()
-
-	val := VersionedValue{
-		Value:     current,
-		Version:   int64(current.Version),
-		Timestamp: time.Now().Unix(),
-	}
+	state.Status = status
+	state.LastError = lastError
+	state.LastChecked = lastChecked
+	state.UpdatedAt = time.Now()
 
-	statusOk, err := s.CompareAndSetVersioned(ctx, key, int64(current.Version-1), val, 0)
+	state.TenantID = tenantID
+	ok, err := s.writeState(ctx, &state, current.Version, nil, event)
 	if err != nil {
 		return err
 	}
-	if !statusOk {
-		return fmt.Errorf("concurrent modification")
+	if !ok {
+		return fmt.Errorf("state %s: concurrent modification: %w", stateID, ErrVersionConflict)
 	}
 	return nil
 }
@@ -381,20 +452,14 @@ func (s *RedisStore) GetState(ctx context.Context, tenantID string, stateID stri
 	key := TenantKey(tenantID, ResourceState, stateID)
 	vVal, err := s.GetVersioned(ctx, key)
 	if err != nil {
-		if err.Error() == "not found" {
+		if errors.Is(err, ErrNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
 
-	// The Value inside VersionedValue is interface{} which might be map[string]interface{} after unmarshal
-	// Double unmarshal is safer or type assertion if known.
-	// Since GetVersioned unmarshals to interface{}, it's likely a map.
-	// We should re-marshal and unmarshal or use mapstructure.
-	// HACK: Re-marshal to JSON then Unmarshal to DesiredState
-	data, _ := json.Marshal(vVal.Value)
 	var state DesiredState
-	if err := json.Unmarshal(data, &state); err != nil {
+	if err := vVal.Decode(&state); err != nil {
 		return nil, err
 	}
 	return &state, nil
@@ -414,132 +479,90 @@ func (s *RedisStore) GetStateByNode(ctx context.Context, tenantID string, nodeID
 	return nil, nil
 }
 
-func (s *RedisStore) ListStates(ctx context.Context, tenantID string) ([]*DesiredState, error) {
-	match := TenantPrefix(tenantID, ResourceState) + "*"
-	iter := s.client.Scan(ctx, 0, match, 0).Iterator()
-	var states []*DesiredState
-
-	for iter.Next(ctx) {
-		key := iter.Val()
-		vVal, err := s.GetVersioned(ctx, key)
-		if err != nil {
-			continue
-		}
-		data, _ := json.Marshal(vVal.Value)
-		var state DesiredState
-		if err := json.Unmarshal(data, &state); err == nil {
-			states = append(states, &state)
-		}
-	}
-	return states, iter.Err()
-}
-
-// ListStatesByStatus returns all states with the given status, filtered by shard
-func (s *RedisStore) ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*DesiredState, error) {
-	if shardCount <= 0 {
-		return nil, errors.New("shardCount must be > 0")
-	}
-
-	// Global scan of all tenant states using helper
-	match := TenantWildcardPrefix(ResourceState)
-	iter := s.client.Scan(ctx, 0, match, 0).Iterator()
-	var states []*DesiredState
-
-	for iter.Next(ctx) {
-		key := iter.Val()
-		// Format: fluxforge:tenants:{tid}:states:{sid}
-		parts := strings.Split(key, ":")
-		if len(parts) < 5 {
-			continue
-		}
-		// parts[2] is tenantID, parts[4] is stateID
-		stateID := parts[4]
-
-		// 2. Apply Sharding Filter based on stateID
-		h := fnv.New32a()
-		h.Write([]byte(stateID))
-		if int(h.Sum32())%shardCount != shardIndex {
-			continue // Skip if not owned by this shard
-		}
-
-		// 3. Get Versioned State
-		vVal, err := s.GetVersioned(ctx, key)
-		if err != nil {
-			log.Printf("ListStatesByStatus: Failed to get state %s: %v", stateID, err)
-			continue
-		}
-
-		data, _ := json.Marshal(vVal.Value)
-		var state DesiredState
-		if err := json.Unmarshal(data, &state); err != nil {
-			continue
-		}
-
-		if state.Status == status {
-			states = append(states, &state)
-		}
-	}
-
-	if err := iter.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan states: %w", err)
-	}
-
-	return states, nil
-}
-
-func (s *RedisStore) CountStatesByStatus(ctx context.Context, tenantID string, status string) (int, error) {
-	// Scan specific tenant namespace: fluxforge:tenants:{tid}:states:*
-	match := TenantPrefix(tenantID, ResourceState) + "*"
-	iter := s.client.Scan(ctx, 0, match, 0).Iterator()
-	count := 0
-	for iter.Next(ctx) {
-		key := iter.Val()
-
-		// Get Versioned State
-		vVal, err := s.GetVersioned(ctx, key)
-		if err != nil {
-			continue
-		}
-
-		data, _ := json.Marshal(vVal.Value)
-		var state DesiredState
-		if err := json.Unmarshal(data, &state); err != nil {
-			continue
-		}
-
-		if state.Status == status {
-			count++
-		}
-	}
-	if err := iter.Err(); err != nil {
-		return 0, err
-	}
-	return count, nil
-}
-
 func (s *RedisStore) CreateJob(ctx context.Context, tenantID string, job *Job) error {
 	job.TenantID = tenantID
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
 	data, err := json.Marshal(job)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job: %w", err)
 	}
 	key := TenantKey(tenantID, ResourceJob, job.JobID)
-	return s.client.Set(ctx, key, data, 0).Err()
-}
-
-func (s *RedisStore) UpdateJobStatus(ctx context.Context, tenantID string, jobID string, status string, exitCode int, stdout, stderr string) error {
-	job, err := s.GetJob(ctx, tenantID, jobID)
+	index := createdIndexKey(tenantID, ResourceJob)
+	prev, err := s.GetJob(ctx, tenantID, job.JobID)
 	if err != nil {
 		return err
 	}
-	if job == nil {
-		return fmt.Errorf("job not found: %s", jobID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, 0)
+		if prev != nil {
+			pipe.ZRem(ctx, index, jobPosition(prev).createdKey())
+			pipe.ZRem(ctx, queuedIndexKey(tenantID, prev.NodeID), jobPosition(prev).createdKey())
+		}
+		pipe.ZAdd(ctx, index, redis.Z{Member: jobPosition(job).createdKey()})
+		if job.Status == "queued" {
+			pipe.ZAdd(ctx, queuedIndexKey(tenantID, job.NodeID), redis.Z{Member: jobPosition(job).createdKey()})
+		}
+		return nil
+	})
+	return err
+}
+
+// UpdateJobStatus is an optimistic WATCH/MULTI on the job, retried when
+// another writer changes it in between, so a status expected through
+// WithExpectedJobStatus is still the status it replaces.
+func (s *RedisStore) UpdateJobStatus(ctx context.Context, tenantID string, jobID string, status string, exitCode int, stdout, stderr string) error {
+	key := TenantKey(tenantID, ResourceJob, jobID)
+	for attempt := 0; ; attempt++ {
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if err == redis.Nil {
+				return fmt.Errorf("job %s: %w", jobID, ErrNotFound)
+			}
+			if err != nil {
+				return err
+			}
+			var job Job
+			if err := json.Unmarshal(data, &job); err != nil {
+				return fmt.Errorf("failed to unmarshal job: %w", err)
+			}
+			if err := checkExpectedJobStatus(ctx, jobID, job.Status); err != nil {
+				return err
+			}
+
+			previous := job.Status
+			job.Status = status
+			job.ExitCode = exitCode
+			job.Stdout = stdout
+			job.Stderr = stderr
+			// Lifecycle timestamps, matching MemoryStore/PostgresStore (used by incident replay)
+			now := time.Now()
+			if status == "running" {
+				job.StartedAt = &now
+			} else if jobFinished(status) {
+				job.FinishedAt = &now
+			}
+			updated, err := json.Marshal(&job)
+			if err != nil {
+				return fmt.Errorf("failed to marshal job: %w", err)
+			}
+			queued := queuedIndexKey(tenantID, job.NodeID)
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, updated, 0)
+				if previous == "queued" && status != "queued" {
+					pipe.ZRem(ctx, queued, jobPosition(&job).createdKey())
+				} else if status == "queued" {
+					pipe.ZAdd(ctx, queued, redis.Z{Member: jobPosition(&job).createdKey()})
+				}
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr || attempt == 9 {
+			return err
+		}
 	}
-	job.Status = status
-	job.ExitCode = exitCode
-	job.Stdout = stdout
-	job.Stderr = stderr
-	return s.CreateJob(ctx, tenantID, job) // Reuse Set
 }
 
 func (s *RedisStore) GetJob(ctx context.Context, tenantID string, jobID string) (*Job, error) {
@@ -573,15 +596,15 @@ func (s *RedisStore) ListJobs(ctx context.Context, tenantID string, nodeID strin
 				jobs = append(jobs, &job)
 			}
 		}
-		if limit > 0 && len(jobs) >= limit {
-			break
-		}
 	}
-	return jobs, iter.Err()
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	// SCAN order is arbitrary: sort before applying the limit
+	return newestJobs(jobs, limit), nil
 }
 
 func (s *RedisStore) ListJobsByTenant(ctx context.Context, tenantID string, limit int) ([]*Job, error) {
-	// New method implementation
 	match := TenantPrefix(tenantID, ResourceJob) + "*"
 	iter := s.client.Scan(ctx, 0, match, 0).Iterator()
 	var jobs []*Job
@@ -594,11 +617,105 @@ func (s *RedisStore) ListJobsByTenant(ctx context.Context, tenantID string, limi
 		if err := json.Unmarshal(data, &job); err == nil {
 			jobs = append(jobs, &job)
 		}
-		if limit > 0 && len(jobs) >= limit {
-			break
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return newestJobs(jobs, limit), nil
+}
+
+// QueryJobs reads one page from the tenant's created-order index, starting
+// at the cursor.
+func (s *RedisStore) QueryJobs(ctx context.Context, tenantID string, opts ListOptions) (*JobPage, error) {
+	opts, after, err := opts.normalize(ResourceJob)
+	if err != nil {
+		return nil, err
+	}
+	jobs, next, err := keysetPage(opts, after,
+		s.createdIndexScan(ctx, tenantID, ResourceJob, opts),
+		func(keys []string) ([]*Job, error) {
+			return loadRecords[Job](ctx, s.client, tenantID, ResourceJob, keys)
+		},
+		jobPosition, func(j *Job) bool { return opts.matches(j.Status, j.NodeID, j.CreatedAt, time.Time{}) })
+	if err != nil {
+		return nil, err
+	}
+	return &JobPage{Jobs: jobs, NextCursor: next}, nil
+}
+
+// ClaimNextJob leases the oldest queued job for a node, reading the
+// candidates in order from the node's queued index. Each claim is an
+// optimistic WATCH/MULTI on the job so two replicas serving the same agent
+// cannot hand out one job twice; entries found stale are dropped on the
+// way.
+func (s *RedisStore) ClaimNextJob(ctx context.Context, tenantID string, nodeID string) (*Job, error) {
+	index := queuedIndexKey(tenantID, nodeID)
+	for round := 0; round < 10; round++ {
+		entries, err := s.client.ZRangeByLex(ctx, index, &redis.ZRangeBy{Min: "-", Max: "+", Count: 16}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return nil, nil
+		}
+		for _, entry := range entries {
+			claimed, err := s.claimQueued(ctx, tenantID, nodeID, index, entry)
+			if err == redis.TxFailedErr {
+				continue // Lost the race, try the next candidate
+			}
+			if err != nil {
+				return nil, err
+			}
+			if claimed != nil {
+				return claimed, nil
+			}
 		}
 	}
-	return jobs, iter.Err()
+	return nil, nil
+}
+
+// claimQueued moves the job of a queued index entry to running, or drops
+// the entry if the job is gone or no longer queued there.
+func (s *RedisStore) claimQueued(ctx context.Context, tenantID, nodeID, index, entry string) (*Job, error) {
+	key := TenantKey(tenantID, ResourceJob, createdKeyID(entry))
+	var claimed *Job
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		var job Job
+		if err == nil {
+			if err := json.Unmarshal(data, &job); err != nil {
+				return fmt.Errorf("failed to unmarshal job: %w", err)
+			}
+		}
+		if err == redis.Nil || job.Status != "queued" || job.NodeID != nodeID || jobPosition(&job).createdKey() != entry {
+			_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.ZRem(ctx, index, entry)
+				return nil
+			})
+			return err
+		}
+
+		now := time.Now()
+		job.Status = "running"
+		job.StartedAt = &now
+		updated, err := json.Marshal(&job)
+		if err != nil {
+			return fmt.Errorf("failed to marshal job: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, 0)
+			pipe.ZRem(ctx, index, entry)
+			return nil
+		})
+		if err == nil {
+			claimed = &job
+		}
+		return err
+	}, key)
+	return claimed, err
 }
 
 func (s *RedisStore) IncrementDurableEpoch(ctx context.Context, resourceID string) (int64, error) {
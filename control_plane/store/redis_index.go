@@ -0,0 +1,699 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/sharding"
+	"github.com/redis/go-redis/v9"
+)
+
+// State secondary indexes. Every state write maintains, in the same Lua
+// script as the CAS:
+//
+//	fluxforge:tenants:{tid}:index:state-ids           set of the tenant's state IDs
+//	fluxforge:tenants:{tid}:index:state-status:{s}    set of its state IDs with status s
+//	fluxforge:index:state-status:{s}                  set of state keys with status s, all tenants
+//
+// The state hash also carries a "status" field, the status it is indexed
+// under. Index key names avoid the ":states:" segment so state scans never
+// match them.
+const globalStateStatusIndexPrefix = "fluxforge:index:state-status:"
+
+func stateIDsIndexKey(tenantID string) string {
+	return fmt.Sprintf("fluxforge:tenants:%s:index:state-ids", tenantID)
+}
+
+func stateStatusIndexPrefix(tenantID string) string {
+	return fmt.Sprintf("fluxforge:tenants:%s:index:state-status:", tenantID)
+}
+
+// stateRevisionsKey is the hash of a state's revisions, by revision number.
+func stateRevisionsKey(tenantID, stateID string) string {
+	return fmt.Sprintf("fluxforge:revisions:%s:%s", tenantID, stateID)
+}
+
+// stateStatusKeys are the tenant and global status indexes for status.
+func stateStatusKeys(tenantID, status string) []string {
+	return []string{stateStatusIndexPrefix(tenantID) + status, globalStateStatusIndexPrefix + status}
+}
+
+// A script that moves a state out of its status indexes is passed their
+// keys, so the caller reads the status the state is indexed under first
+// and the script checks it still is, returning indexedStatusChanged if
+// not. The caller then reads it again, up to maxStatusRetries times. It
+// only changes without the revision changing when RebuildStateIndexes
+// indexes a state, so one retry is nearly always enough.
+const (
+	indexedStatusChanged = -1
+	maxStatusRetries     = 5
+)
+
+// indexedStatus reads the status the state at key is indexed under in the
+// form the scripts compare: "=" and the status, or "" if it has none.
+func (s *RedisStore) indexedStatus(ctx context.Context, key string) (string, error) {
+	status, err := s.client.HGet(ctx, key, "status").Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "=" + status, nil
+}
+
+// indexedStatusKeys are the status indexes a state indexed under indexed
+// is in, or fallback for one that is in none (the script then ignores
+// them).
+func indexedStatusKeys(tenantID, indexed string, fallback []string) []string {
+	status, ok := strings.CutPrefix(indexed, "=")
+	if !ok {
+		return fallback
+	}
+	return stateStatusKeys(tenantID, status)
+}
+
+// stateWriteScript is CompareAndSetVersioned plus index maintenance and,
+// for upserts, the revision record or, for status updates, the outbox
+// event.
+var stateWriteScript = redis.NewScript(`
+-- KEYS[1] = state key
+-- KEYS[2] = tenant state-ids index
+-- KEYS[3] = tombstone key
+-- KEYS[4] = revisions hash
+-- KEYS[5] = outbox stream
+-- KEYS[6] = tenant status index for the new status
+-- KEYS[7] = global status index for the new status
+-- KEYS[8] = tenant status index for the indexed status
+-- KEYS[9] = global status index for the indexed status
+-- ARGV[1] = expected revision
+-- ARGV[2] = new value (JSON)
+-- ARGV[3] = new revision
+-- ARGV[4] = timestamp
+-- ARGV[5] = status
+-- ARGV[6] = state ID
+-- ARGV[7] = indexed status ("=" .. status, "" for none)
+-- ARGV[8] = revision number
+-- ARGV[9] = revision (JSON), "" for status updates
+-- ARGV[10] = outbox event (JSON), "" for none
+
+-- A missing key only matches revision 0: a state deleted after it was
+-- read must not be written back over its tombstone
+local current_version = redis.call("HGET", KEYS[1], "version")
+if tonumber(current_version or "0") ~= tonumber(ARGV[1]) then
+    return 0
+end
+local old_status = redis.call("HGET", KEYS[1], "status")
+if (old_status and "=" .. old_status or "") ~= ARGV[7] then
+    return -1
+end
+
+redis.call("HSET", KEYS[1],
+    "value", ARGV[2],
+    "version", ARGV[3],
+    "timestamp", ARGV[4],
+    "status", ARGV[5])
+
+if old_status and old_status ~= ARGV[5] then
+    redis.call("SREM", KEYS[8], ARGV[6])
+    redis.call("SREM", KEYS[9], KEYS[1])
+end
+redis.call("SADD", KEYS[2], ARGV[6])
+redis.call("SADD", KEYS[6], ARGV[6])
+redis.call("SADD", KEYS[7], KEYS[1])
+redis.call("DEL", KEYS[3])
+if ARGV[9] ~= "" then
+    redis.call("HSET", KEYS[4], ARGV[8], ARGV[9])
+end
+if ARGV[10] ~= "" then
+    redis.call("XADD", KEYS[5], "*", "event", ARGV[10])
+end
+return 1
+`)
+
+// stateDeleteScript swaps a state for its tombstone and drops it from the
+// indexes and its revisions, unless it was rewritten since it was read.
+var stateDeleteScript = redis.NewScript(`
+-- KEYS[1] = state key
+-- KEYS[2] = tenant state-ids index
+-- KEYS[3] = tombstone key
+-- KEYS[4] = revisions hash
+-- KEYS[5] = tenant status index for the indexed status
+-- KEYS[6] = global status index for the indexed status
+-- ARGV[1] = revision that was read
+-- ARGV[2] = tombstone (JSON)
+-- ARGV[3] = state ID
+-- ARGV[4] = indexed status ("=" .. status, "" for none)
+
+if tonumber(redis.call("HGET", KEYS[1], "version")) ~= tonumber(ARGV[1]) then
+    return 0
+end
+local status = redis.call("HGET", KEYS[1], "status")
+if (status and "=" .. status or "") ~= ARGV[4] then
+    return -1
+end
+redis.call("DEL", KEYS[1], KEYS[4])
+redis.call("SREM", KEYS[2], ARGV[3])
+if status then
+    redis.call("SREM", KEYS[5], ARGV[3])
+    redis.call("SREM", KEYS[6], KEYS[1])
+end
+redis.call("SET", KEYS[3], ARGV[2])
+return 1
+`)
+
+// stateIndexAddScript indexes a state under its decoded status, unless it
+// was rewritten since it was read (the writer indexed it then). Returns the
+// number of index entries that were missing.
+var stateIndexAddScript = redis.NewScript(`
+-- KEYS[1] = state key
+-- KEYS[2] = tenant state-ids index
+-- KEYS[3] = tenant status index
+-- KEYS[4] = global status index
+-- ARGV[1] = revision that was read
+-- ARGV[2] = status
+-- ARGV[3] = state ID
+
+if tonumber(redis.call("HGET", KEYS[1], "version")) ~= tonumber(ARGV[1]) then
+    return 0
+end
+redis.call("HSET", KEYS[1], "status", ARGV[2])
+return redis.call("SADD", KEYS[2], ARGV[3])
+    + redis.call("SADD", KEYS[3], ARGV[3])
+    + redis.call("SADD", KEYS[4], KEYS[1])
+`)
+
+// stateIndexPruneScript drops an index member whose state is gone or, for
+// status indexes, no longer has that status.
+var stateIndexPruneScript = redis.NewScript(`
+-- KEYS[1] = index set
+-- KEYS[2] = state key
+-- ARGV[1] = member
+-- ARGV[2] = status the index is for ("" for the state-ids index)
+
+local status = redis.call("HGET", KEYS[2], "status")
+if status and (ARGV[2] == "" or status == ARGV[2]) then
+    return 0
+end
+return redis.call("SREM", KEYS[1], ARGV[1])
+`)
+
+// writeState CAS-writes a state at revision expectedRev (0 = must not
+// exist) and moves it between status indexes in the same script. rev, if
+// set, is recorded in the state's revisions, and event, if set, appended to
+// the outbox.
+func (s *RedisStore) writeState(ctx context.Context, state *DesiredState, expectedRev int64, rev *StateRevision, event *OutboxEvent) (bool, error) {
+	valueJSON, err := json.Marshal(state)
+	if err != nil {
+		return false, err
+	}
+	var revNumber int
+	var revJSON []byte
+	if rev != nil {
+		revNumber = rev.Revision
+		if revJSON, err = json.Marshal(rev); err != nil {
+			return false, err
+		}
+	}
+	var eventJSON []byte
+	if event != nil {
+		if eventJSON, err = json.Marshal(event); err != nil {
+			return false, err
+		}
+	}
+	key := TenantKey(state.TenantID, ResourceState, state.StateID)
+	newStatus := stateStatusKeys(state.TenantID, state.Status)
+	for attempt := 1; ; attempt++ {
+		indexed, err := s.indexedStatus(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		keys := []string{
+			key,
+			stateIDsIndexKey(state.TenantID),
+			TombstoneKey(state.TenantID, ResourceState, state.StateID),
+			stateRevisionsKey(state.TenantID, state.StateID),
+			outboxStreamKey,
+		}
+		keys = append(keys, newStatus...)
+		keys = append(keys, indexedStatusKeys(state.TenantID, indexed, newStatus)...)
+		result, err := stateWriteScript.Run(ctx, s.client, keys,
+			expectedRev,
+			string(valueJSON),
+			expectedRev+1,
+			time.Now().Unix(),
+			state.Status,
+			state.StateID,
+			indexed,
+			revNumber,
+			string(revJSON),
+			string(eventJSON),
+		).Int64()
+		if err != nil {
+			return false, err
+		}
+		if result != indexedStatusChanged || attempt == maxStatusRetries {
+			return result == 1, nil
+		}
+	}
+}
+
+// loadStates fetches the states stored under keys in one round trip,
+// skipping keys that no longer exist.
+func (s *RedisStore) loadStates(ctx context.Context, keys []string) ([]*DesiredState, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HGet(ctx, key, "value")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	states := make([]*DesiredState, 0, len(keys))
+	for i, cmd := range cmds {
+		raw, err := cmd.Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var state DesiredState
+		if err := json.Unmarshal([]byte(raw), &state); err != nil {
+			log.Printf("RedisStore: skipping undecodable state %s: %v", keys[i], err)
+			continue
+		}
+		states = append(states, &state)
+	}
+	return states, nil
+}
+
+// tenantStateKeys maps state IDs from a tenant index to state keys.
+func tenantStateKeys(tenantID string, stateIDs []string) []string {
+	keys := make([]string, len(stateIDs))
+	for i, id := range stateIDs {
+		keys[i] = TenantKey(tenantID, ResourceState, id)
+	}
+	return keys
+}
+
+func (s *RedisStore) ListStates(ctx context.Context, tenantID string) ([]*DesiredState, error) {
+	ids, err := s.client.SMembers(ctx, stateIDsIndexKey(tenantID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.loadStates(ctx, tenantStateKeys(tenantID, ids))
+}
+
+// QueryStates reads a status filter from the tenant's status index rather
+// than loading every state.
+func (s *RedisStore) QueryStates(ctx context.Context, tenantID string, opts ListOptions) (*StatePage, error) {
+	index := stateIDsIndexKey(tenantID)
+	if opts.Status != "" {
+		index = stateStatusIndexPrefix(tenantID) + opts.Status
+	}
+	ids, err := s.client.SMembers(ctx, index).Result()
+	if err != nil {
+		return nil, err
+	}
+	states, err := s.loadStates(ctx, tenantStateKeys(tenantID, ids))
+	if err != nil {
+		return nil, err
+	}
+	return pageStates(states, opts)
+}
+
+// ListStatesByStatus returns all states with the given status, filtered by shard
+func (s *RedisStore) ListStatesByStatus(ctx context.Context, status string, shardIndex int, shardCount int) ([]*DesiredState, error) {
+	if shardCount <= 0 {
+		return nil, fmt.Errorf("shardCount must be > 0")
+	}
+	keys, err := s.client.SMembers(ctx, globalStateStatusIndexPrefix+status).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status index: %w", err)
+	}
+	candidates, err := s.loadStates(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*DesiredState
+	for _, state := range candidates {
+		// The status check guards against index drift until the next rebuild.
+		// Shard by NodeID like every other store (see sharding.Partitioner)
+		if state.Status == status && sharding.Owns(sharding.Modulo(shardCount), state.NodeID, shardIndex) {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+func (s *RedisStore) CountStatesByStatus(ctx context.Context, tenantID string, status string) (int, error) {
+	n, err := s.client.SCard(ctx, stateStatusIndexPrefix(tenantID)+status).Result()
+	return int(n), err
+}
+
+// IndexRepair summarizes a RebuildStateIndexes pass.
+type IndexRepair struct {
+	States  int // State records scanned
+	Added   int // Missing index entries restored
+	Removed int // Stale index entries dropped
+}
+
+// RebuildStateIndexes repairs drift between the state indexes and the
+// state records: entries are added for every state (including ones written
+// before the indexes existed, or by SetVersioned) and dropped for states
+// that are gone or changed status. Each step is atomic per record, so it
+// is safe to run while the control plane is serving writes.
+func (s *RedisStore) RebuildStateIndexes(ctx context.Context) (IndexRepair, error) {
+	var repair IndexRepair
+
+	iter := s.client.Scan(ctx, 0, TenantWildcardPrefix(ResourceState), 0).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		current, err := s.GetVersioned(ctx, key)
+		if err != nil {
+			continue
+		}
+		var state DesiredState
+		if err := current.Decode(&state); err != nil {
+			log.Printf("RebuildStateIndexes: skipping undecodable state %s: %v", key, err)
+			continue
+		}
+		repair.States++
+
+		added, err := stateIndexAddScript.Run(ctx, s.client,
+			append([]string{key, stateIDsIndexKey(state.TenantID)}, stateStatusKeys(state.TenantID, state.Status)...),
+			current.Version,
+			state.Status,
+			state.StateID,
+		).Int()
+		if err != nil {
+			return repair, fmt.Errorf("index state %s: %w", key, err)
+		}
+		repair.Added += added
+	}
+	if err := iter.Err(); err != nil {
+		return repair, err
+	}
+
+	// Global status indexes hold state keys
+	iter = s.client.Scan(ctx, 0, globalStateStatusIndexPrefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		index := iter.Val()
+		status := strings.TrimPrefix(index, globalStateStatusIndexPrefix)
+		removed, err := s.pruneIndex(ctx, index, status, func(member string) string { return member })
+		repair.Removed += removed
+		if err != nil {
+			return repair, err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return repair, err
+	}
+
+	// Tenant indexes hold state IDs
+	iter = s.client.Scan(ctx, 0, "fluxforge:tenants:*:index:state-*", 0).Iterator()
+	for iter.Next(ctx) {
+		index := iter.Val()
+		tenantID, kind, ok := strings.Cut(strings.TrimPrefix(index, "fluxforge:tenants:"), ":index:")
+		if !ok {
+			continue
+		}
+		status := ""
+		if kind != "state-ids" {
+			status = strings.TrimPrefix(kind, "state-status:")
+		}
+		removed, err := s.pruneIndex(ctx, index, status, func(member string) string {
+			return TenantKey(tenantID, ResourceState, member)
+		})
+		repair.Removed += removed
+		if err != nil {
+			return repair, err
+		}
+	}
+	return repair, iter.Err()
+}
+
+func (s *RedisStore) pruneIndex(ctx context.Context, index, status string, stateKey func(member string) string) (int, error) {
+	members, err := s.client.SMembers(ctx, index).Result()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		n, err := stateIndexPruneScript.Run(ctx, s.client, []string{index, stateKey(member)}, member, status).Int()
+		if err != nil {
+			return removed, fmt.Errorf("prune %s: %w", index, err)
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// StartIndexRepair runs RebuildStateIndexes and RebuildCreatedIndexes
+// every interval.
+func (s *RedisStore) StartIndexRepair(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				repair, err := s.RebuildStateIndexes(ctx)
+				if err != nil {
+					log.Printf("⚠️ State index repair failed: %v", err)
+				} else if repair.Added > 0 || repair.Removed > 0 {
+					log.Printf("State index repair: %d states, %d entries added, %d removed", repair.States, repair.Added, repair.Removed)
+				}
+				added, removed, err := s.RebuildCreatedIndexes(ctx)
+				if err != nil {
+					log.Printf("⚠️ Created index repair failed: %v", err)
+				} else if added > 0 || removed > 0 {
+					log.Printf("Created index repair: %d entries added, %d removed", added, removed)
+				}
+			}
+		}
+	}()
+}
+
+// Created-order indexes for QueryAgents and QueryJobs:
+//
+//	fluxforge:tenants:{tid}:index:agents-created    sorted set of the tenant's agents
+//	fluxforge:tenants:{tid}:index:jobs-created      sorted set of the tenant's jobs
+//
+// Members are createdKeys, all with score 0, so ZRANGEBYLEX walks them in
+// listing order from a cursor. UpsertAgent, CreateJob, DeleteAgent and
+// PurgeJobs maintain them in the same MULTI as the record; an entry left
+// behind by a job re-created with another CreatedAt no longer matches its
+// record, is skipped by the listing and dropped by RebuildCreatedIndexes.
+func createdIndexKey(tenantID string, resource Resource) string {
+	return fmt.Sprintf("fluxforge:tenants:%s:index:%s-created", tenantID, resource)
+}
+
+// queuedIndexKey is the node's queued index, in the same form as the
+// created-order ones: the createdKeys of its queued jobs, so ClaimNextJob
+// reads them oldest first. CreateJob and UpdateJobStatus maintain it;
+// ClaimNextJob drops entries it finds stale.
+func queuedIndexKey(tenantID, nodeID string) string {
+	return fmt.Sprintf("fluxforge:tenants:%s:index:jobs-queued:%s", tenantID, nodeID)
+}
+
+// createdIndexScan reads the tenant's created-order index within the
+// createdBounds of opts.
+func (s *RedisStore) createdIndexScan(ctx context.Context, tenantID string, resource Resource, opts ListOptions) indexScan {
+	index := createdIndexKey(tenantID, resource)
+	low, high := opts.createdBounds()
+	return func(from string, n int) ([]string, error) {
+		by := &redis.ZRangeBy{Min: "-", Max: "+", Count: int64(n)}
+		if low != "" {
+			by.Min = "[" + low
+		}
+		if high != "" {
+			by.Max = "(" + high
+		}
+		if opts.Order == SortAsc {
+			if from != "" {
+				by.Min = "(" + from
+			}
+			return s.client.ZRangeByLex(ctx, index, by).Result()
+		}
+		if from != "" {
+			by.Max = "(" + from
+		}
+		return s.client.ZRevRangeByLex(ctx, index, by).Result()
+	}
+}
+
+// loadRecords fetches the tenant's records for created-order index
+// entries in one round trip, nil where one is gone.
+func loadRecords[T any](ctx context.Context, client *redis.Client, tenantID string, resource Resource, entries []string) ([]*T, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = TenantKey(tenantID, resource, createdKeyID(entry))
+	}
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*T, len(keys))
+	for i, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var record T
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			log.Printf("RedisStore: skipping undecodable record %s: %v", keys[i], err)
+			continue
+		}
+		records[i] = &record
+	}
+	return records, nil
+}
+
+// RebuildCreatedIndexes adds every agent and job to its created-order
+// index, and queued jobs to their node's queued index (including ones
+// written before the indexes existed), and drops created-order entries
+// whose record is gone or was re-created with another CreatedAt. Like
+// RebuildStateIndexes it is safe to run while serving writes.
+func (s *RedisStore) RebuildCreatedIndexes(ctx context.Context) (added, removed int, err error) {
+	for _, resource := range []Resource{ResourceAgent, ResourceJob} {
+		iter := s.client.Scan(ctx, 0, TenantWildcardPrefix(resource), 0).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			data, err := s.client.Get(ctx, key).Bytes()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return added, removed, err
+			}
+			tenantID, pos, err := recordPosition(resource, data)
+			if err != nil {
+				log.Printf("RebuildCreatedIndexes: skipping undecodable record %s: %v", key, err)
+				continue
+			}
+			n, err := s.client.ZAdd(ctx, createdIndexKey(tenantID, resource), redis.Z{Member: pos.createdKey()}).Result()
+			if err != nil {
+				return added, removed, fmt.Errorf("index %s: %w", key, err)
+			}
+			added += int(n)
+			if resource != ResourceJob {
+				continue
+			}
+			var job Job
+			if err := json.Unmarshal(data, &job); err != nil || job.Status != "queued" {
+				continue
+			}
+			// A job claimed since it was read is dropped by ClaimNextJob
+			n, err = s.client.ZAdd(ctx, queuedIndexKey(tenantID, job.NodeID), redis.Z{Member: pos.createdKey()}).Result()
+			if err != nil {
+				return added, removed, fmt.Errorf("index %s: %w", key, err)
+			}
+			added += int(n)
+		}
+		if err := iter.Err(); err != nil {
+			return added, removed, err
+		}
+	}
+
+	iter := s.client.Scan(ctx, 0, "fluxforge:tenants:*:index:*-created", 0).Iterator()
+	for iter.Next(ctx) {
+		index := iter.Val()
+		tenantID, kind, ok := strings.Cut(strings.TrimPrefix(index, "fluxforge:tenants:"), ":index:")
+		if !ok || strings.Contains(kind, ":") {
+			continue // A queued index of a node named "*-created"
+		}
+		n, err := s.pruneCreatedIndex(ctx, index, tenantID, Resource(strings.TrimSuffix(kind, "-created")))
+		removed += n
+		if err != nil {
+			return added, removed, err
+		}
+	}
+	return added, removed, iter.Err()
+}
+
+// pruneCreatedIndex drops the entries of index whose record is gone or
+// has another position. Each check watches the record, so an entry is not
+// dropped for a record being written at the same time.
+func (s *RedisStore) pruneCreatedIndex(ctx context.Context, index, tenantID string, resource Resource) (int, error) {
+	members, err := s.client.ZRange(ctx, index, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		key := TenantKey(tenantID, resource, createdKeyID(member))
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if err == nil {
+				if _, pos, err := recordPosition(resource, data); err == nil && pos.createdKey() == member {
+					return nil
+				}
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.ZRem(ctx, index, member)
+				return nil
+			})
+			if err == nil {
+				removed++
+			}
+			return err
+		}, key)
+		if err != nil && err != redis.TxFailedErr {
+			return removed, fmt.Errorf("prune %s: %w", index, err)
+		}
+	}
+	return removed, nil
+}
+
+func (s *RedisStore) ListStateRevisions(ctx context.Context, tenantID string, stateID string) ([]*StateRevision, error) {
+	raw, err := s.client.HVals(ctx, stateRevisionsKey(tenantID, stateID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	revs := make([]*StateRevision, 0, len(raw))
+	for _, data := range raw {
+		var rev StateRevision
+		if err := json.Unmarshal([]byte(data), &rev); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal revision: %w", err)
+		}
+		revs = append(revs, &rev)
+	}
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Revision < revs[j].Revision })
+	return revs, nil
+}
+
+func (s *RedisStore) GetStateRevision(ctx context.Context, tenantID string, stateID string, revision int) (*StateRevision, error) {
+	data, err := s.client.HGet(ctx, stateRevisionsKey(tenantID, stateID), strconv.Itoa(revision)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rev StateRevision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revision: %w", err)
+	}
+	return &rev, nil
+}
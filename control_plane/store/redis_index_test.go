@@ -0,0 +1,173 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisStateIndexesFollowStatus(t *testing.T) {
+	ctx := context.Background()
+	rs := newMiniredisStore(t)
+
+	for _, id := range []string{"s1", "s2", "s3"} {
+		if err := rs.UpsertState(ctx, "t1", &store.DesiredState{StateID: id, NodeID: "n-" + id, Status: "pending"}); err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	if err := rs.UpdateStateStatus(ctx, "t1", "s1", "compliant", "", time.Now(), 1); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+
+	assertCount(t, rs, "t1", "pending", 2)
+	assertCount(t, rs, "t1", "compliant", 1)
+	states, err := rs.ListStatesByStatus(ctx, "compliant", 0, 1)
+	if err != nil || len(states) != 1 || states[0].StateID != "s1" {
+		t.Errorf("ListStatesByStatus(compliant) = %v, %v; want [s1]", states, err)
+	}
+}
+
+func TestRedisRebuildStateIndexes(t *testing.T) {
+	ctx := context.Background()
+	rs := newMiniredisStore(t)
+	client := rs.Client()
+
+	for _, id := range []string{"s1", "s2"} {
+		if err := rs.UpsertState(ctx, "t1", &store.DesiredState{StateID: id, NodeID: "n1", Status: "pending"}); err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	// A state written without the index path (as by SetVersioned) ...
+	legacy := store.TenantKey("t1", store.ResourceState, "s3")
+	if err := rs.SetVersioned(ctx, legacy, store.VersionedValue{
+		Value:   &store.DesiredState{StateID: "s3", TenantID: "t1", NodeID: "n1", Status: "pending", Version: 1},
+		Version: 1,
+	}, 0); err != nil {
+		t.Fatalf("set legacy state: %v", err)
+	}
+	// ... a state deleted behind the indexes' back, and a stale status entry.
+	client.Del(ctx, store.TenantKey("t1", store.ResourceState, "s2"))
+	client.SAdd(ctx, "fluxforge:tenants:t1:index:state-status:drifting", "s1")
+	client.SAdd(ctx, "fluxforge:index:state-status:drifting", store.TenantKey("t1", store.ResourceState, "s1"))
+
+	repair, err := rs.RebuildStateIndexes(ctx)
+	if err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if repair.States != 2 || repair.Added != 3 || repair.Removed != 5 {
+		t.Errorf("repair = %+v, want 2 states, 3 added, 5 removed", repair)
+	}
+
+	assertCount(t, rs, "t1", "pending", 2)
+	assertCount(t, rs, "t1", "drifting", 0)
+	states, err := rs.ListStates(ctx, "t1")
+	if err != nil || len(states) != 2 {
+		t.Errorf("ListStates after rebuild = %d states, %v; want s1 and s3", len(states), err)
+	}
+
+	// A second pass finds nothing to do
+	if repair, err := rs.RebuildStateIndexes(ctx); err != nil || repair.Added != 0 || repair.Removed != 0 {
+		t.Errorf("second rebuild = %+v, %v; want no changes", repair, err)
+	}
+}
+
+func TestRedisStateScriptsUseIndexedStatus(t *testing.T) {
+	ctx := context.Background()
+	rs := newMiniredisStore(t)
+	client := rs.Client()
+
+	// A state without an indexed status, as SetVersioned writes it
+	key := store.TenantKey("t1", store.ResourceState, "s1")
+	if err := rs.SetVersioned(ctx, key, store.VersionedValue{
+		Value:   &store.DesiredState{StateID: "s1", TenantID: "t1", NodeID: "n1", Status: "pending", Version: 1},
+		Version: 1,
+	}, 0); err != nil {
+		t.Fatalf("set state: %v", err)
+	}
+	if err := rs.UpdateStateStatus(ctx, "t1", "s1", "compliant", "", time.Now(), 1); err != nil {
+		t.Fatalf("update unindexed state: %v", err)
+	}
+	assertCount(t, rs, "t1", "compliant", 1)
+
+	// An indexed status that disagrees with the record's decoded one is
+	// still the one the script moves it out of
+	client.HSet(ctx, key, "status", "drifting")
+	client.SAdd(ctx, "fluxforge:tenants:t1:index:state-status:drifting", "s1")
+	if err := rs.DeleteState(ctx, "t1", "s1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	assertCount(t, rs, "t1", "drifting", 0)
+}
+
+func TestRedisRebuildCreatedIndexes(t *testing.T) {
+	ctx := context.Background()
+	rs := newMiniredisStore(t)
+	client := rs.Client()
+
+	if err := rs.UpsertAgent(ctx, "t1", &store.Agent{NodeID: "n1", Status: "active"}); err != nil {
+		t.Fatalf("upsert agent: %v", err)
+	}
+	// A job written before the indexes existed, and an entry whose job is gone
+	legacy := store.TenantKey("t1", store.ResourceJob, "j1")
+	if err := client.Set(ctx, legacy, `{"job_id":"j1","tenant_id":"t1","node_id":"n1","status":"queued","created_at":"2024-01-01T00:00:00Z"}`, 0).Err(); err != nil {
+		t.Fatalf("set legacy job: %v", err)
+	}
+	client.ZAdd(ctx, "fluxforge:tenants:t1:index:jobs-created", redis.Z{Member: "00000000000000000001:gone"})
+
+	// j1 goes into the created-order index and, queued, into n1's queue
+	added, removed, err := rs.RebuildCreatedIndexes(ctx)
+	if err != nil || added != 2 || removed != 1 {
+		t.Errorf("rebuild = %d added, %d removed, %v; want 2 and 1", added, removed, err)
+	}
+	page, err := rs.QueryJobs(ctx, "t1", store.ListOptions{})
+	if err != nil || len(page.Jobs) != 1 || page.Jobs[0].JobID != "j1" {
+		t.Errorf("QueryJobs after rebuild = %v, %v; want [j1]", page, err)
+	}
+
+	if added, removed, err := rs.RebuildCreatedIndexes(ctx); err != nil || added != 0 || removed != 0 {
+		t.Errorf("second rebuild = %d added, %d removed, %v; want no changes", added, removed, err)
+	}
+	if job, err := rs.ClaimNextJob(ctx, "t1", "n1"); err != nil || job == nil || job.JobID != "j1" {
+		t.Errorf("ClaimNextJob after rebuild = %+v, %v; want j1", job, err)
+	}
+}
+
+func TestRedisClaimNextJobDropsStaleEntries(t *testing.T) {
+	ctx := context.Background()
+	rs := newMiniredisStore(t)
+	client := rs.Client()
+
+	created := time.Now().Add(-time.Minute)
+	for i, id := range []string{"done", "next"} {
+		job := &store.Job{JobID: id, NodeID: "n1", Status: "queued", CreatedAt: created.Add(time.Duration(i) * time.Second)}
+		if err := rs.CreateJob(ctx, "t1", job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	// "done" finished behind the index's back, and an entry whose job is gone
+	client.Set(ctx, store.TenantKey("t1", store.ResourceJob, "done"),
+		`{"job_id":"done","tenant_id":"t1","node_id":"n1","status":"completed","created_at":"`+created.Format(time.RFC3339Nano)+`"}`, 0)
+	client.ZAdd(ctx, "fluxforge:tenants:t1:index:jobs-queued:n1", redis.Z{Member: "00000000000000000001:gone"})
+
+	job, err := rs.ClaimNextJob(ctx, "t1", "n1")
+	if err != nil || job == nil || job.JobID != "next" {
+		t.Fatalf("ClaimNextJob = %+v, %v; want next", job, err)
+	}
+	if n, _ := client.ZCard(ctx, "fluxforge:tenants:t1:index:jobs-queued:n1").Result(); n != 0 {
+		t.Errorf("queued index keeps %d entries, want none", n)
+	}
+	if job, err := rs.ClaimNextJob(ctx, "t1", "n1"); err != nil || job != nil {
+		t.Errorf("second ClaimNextJob = %+v, %v; want none", job, err)
+	}
+}
+
+func assertCount(t *testing.T, rs *store.RedisStore, tenantID, status string, want int) {
+	t.Helper()
+	got, err := rs.CountStatesByStatus(context.Background(), tenantID, status)
+	if err != nil || got != want {
+		t.Errorf("CountStatesByStatus(%s, %s) = %d, %v; want %d", tenantID, status, got, err, want)
+	}
+}
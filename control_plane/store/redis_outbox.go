@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The outbox is a stream of JSON events under the "event" field, appended
+// by stateWriteScript. Stream IDs are the positions; acked entries are
+// XDEL'd, so the stream holds only what is still to be delivered.
+const outboxStreamKey = "fluxforge:outbox"
+
+func (s *RedisStore) ReadOutbox(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	var msgs []redis.XMessage
+	var err error
+	if limit > 0 {
+		msgs, err = s.client.XRangeN(ctx, outboxStreamKey, "-", "+", int64(limit)).Result()
+	} else {
+		msgs, err = s.client.XRange(ctx, outboxStreamKey, "-", "+").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*OutboxEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		raw, _ := msg.Values["event"].(string)
+		var e OutboxEvent
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			// Not skipped, which would lose it silently: an operator has
+			// to XDEL it
+			return events, fmt.Errorf("outbox entry %s: %w", msg.ID, err)
+		}
+		e.Position = msg.ID
+		events = append(events, &e)
+	}
+	return events, nil
+}
+
+func (s *RedisStore) AckOutbox(ctx context.Context, positions []string) error {
+	if len(positions) == 0 {
+		return nil
+	}
+	return s.client.XDel(ctx, outboxStreamKey, positions...).Err()
+}
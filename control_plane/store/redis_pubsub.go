@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// Publish sends a message on a Redis pub/sub channel.
+func (s *RedisStore) Publish(ctx context.Context, channel string, message string) error {
+	return s.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe blocks, invoking handler for each message on channel, until ctx
+// is done. The underlying connection is re-established by go-redis.
+func (s *RedisStore) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	pubsub := s.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	// Wait for the subscription to be confirmed before consuming
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("subscription closed")
+			}
+			handler(msg.Payload)
+		}
+	}
+}
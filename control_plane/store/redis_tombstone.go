@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Tombstones are JSON strings under TombstoneKey. Deletes swap the record
+// for its tombstone atomically: states through stateDeleteScript (which
+// also maintains the indexes), agents through WATCH/MULTI.
+
+func (s *RedisStore) DeleteAgent(ctx context.Context, tenantID string, nodeID string) error {
+	key := TenantKey(tenantID, ResourceAgent, nodeID)
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return fmt.Errorf("agent %s: %w", nodeID, ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		tomb, err := newTombstone(ResourceAgent, tenantID, nodeID, json.RawMessage(data))
+		if err != nil {
+			return err
+		}
+		tombJSON, err := json.Marshal(tomb)
+		if err != nil {
+			return err
+		}
+		_, pos, err := recordPosition(ResourceAgent, data)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal agent: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			pipe.Set(ctx, TombstoneKey(tenantID, ResourceAgent, nodeID), tombJSON, 0)
+			pipe.ZRem(ctx, createdIndexKey(tenantID, ResourceAgent), pos.createdKey())
+			return nil
+		})
+		if err == redis.TxFailedErr {
+			return fmt.Errorf("agent %s: concurrent modification: %w", nodeID, ErrVersionConflict)
+		}
+		return err
+	}, key)
+}
+
+func (s *RedisStore) DeleteState(ctx context.Context, tenantID string, stateID string) error {
+	key := TenantKey(tenantID, ResourceState, stateID)
+	current, err := s.GetVersioned(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("state %s: %w", stateID, ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	var state DesiredState
+	if err := current.Decode(&state); err != nil {
+		return fmt.Errorf("failed to decode state %s: %w", stateID, err)
+	}
+	tomb, err := newTombstone(ResourceState, tenantID, stateID, &state)
+	if err != nil {
+		return err
+	}
+	tombJSON, err := json.Marshal(tomb)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		indexed, err := s.indexedStatus(ctx, key)
+		if err != nil {
+			return err
+		}
+		keys := []string{key, stateIDsIndexKey(tenantID), TombstoneKey(tenantID, ResourceState, stateID), stateRevisionsKey(tenantID, stateID)}
+		keys = append(keys, indexedStatusKeys(tenantID, indexed, stateStatusKeys(tenantID, state.Status))...)
+		deleted, err := stateDeleteScript.Run(ctx, s.client, keys,
+			current.Version,
+			string(tombJSON),
+			stateID,
+			indexed,
+		).Int()
+		if err != nil {
+			return err
+		}
+		if deleted == 1 {
+			return nil
+		}
+		if deleted != indexedStatusChanged || attempt == maxStatusRetries {
+			return fmt.Errorf("state %s: concurrent modification: %w", stateID, ErrVersionConflict)
+		}
+	}
+}
+
+// PurgeJobs scans the tenant's jobs; no index narrows them by status.
+func (s *RedisStore) PurgeJobs(ctx context.Context, tenantID string, purge JobPurge) (int, error) {
+	jobs, err := s.ListJobsByTenant(ctx, tenantID, 0)
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	var entries []interface{}
+	for _, job := range jobs {
+		if purge.Matches(job) {
+			keys = append(keys, TenantKey(tenantID, ResourceJob, job.JobID))
+			entries = append(entries, jobPosition(job).createdKey())
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	var del *redis.IntCmd
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(ctx, keys...)
+		pipe.ZRem(ctx, createdIndexKey(tenantID, ResourceJob), entries...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(del.Val()), nil
+}
+
+func (s *RedisStore) GetTombstone(ctx context.Context, tenantID string, resource Resource, id string) (*Tombstone, error) {
+	data, err := s.client.Get(ctx, TombstoneKey(tenantID, resource, id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tomb Tombstone
+	if err := json.Unmarshal(data, &tomb); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tombstone: %w", err)
+	}
+	return &tomb, nil
+}
+
+func (s *RedisStore) PurgeTombstones(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	iter := s.client.Scan(ctx, 0, "fluxforge:tombstones:*", 0).Iterator()
+	for iter.Next(ctx) {
+		tomb, err := s.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue // Recreated or purged meanwhile
+		}
+		var t Tombstone
+		if err := json.Unmarshal(tomb, &t); err != nil || !t.DeletedAt.Before(before) {
+			continue
+		}
+		n, err := s.client.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(n)
+	}
+	return purged, iter.Err()
+}
@@ -16,6 +16,19 @@ type VersionedValue struct {
 	Timestamp int64       `json:"timestamp"` // Unix timestamp
 }
 
+// Decode unmarshals the stored value into out. GetVersioned returns the
+// stored JSON document as a string, not a decoded object.
+func (v *VersionedValue) Decode(out interface{}) error {
+	if raw, ok := v.Value.(string); ok {
+		return json.Unmarshal([]byte(raw), out)
+	}
+	data, err := json.Marshal(v.Value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
 // CRITICAL: Lua script for ATOMIC versioned SET
 // Single instruction from Redis perspective - no race conditions possible
 const versionedSetScript = `
@@ -126,7 +139,7 @@ func (s *RedisStore) GetVersioned(ctx context.Context, key string) (*VersionedVa
 	}
 
 	if err == redis.Nil || result == nil {
-		return nil, fmt.Errorf("not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get versioned value: %w", err)
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StateRevision is an immutable snapshot of a desired state, written by
+// every UpsertState in the same operation as the state itself. Status
+// updates do not create revisions. Deleting a state drops its history, so
+// a recreated state starts again at revision 1.
+type StateRevision struct {
+	TenantID  string       `json:"tenant_id"`
+	StateID   string       `json:"state_id"`
+	Revision  int          `json:"revision"` // The state's Version after the upsert
+	Author    string       `json:"author,omitempty"`
+	Reason    string       `json:"reason,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	State     DesiredState `json:"state"`
+}
+
+// ChangeInfo describes who made an upsert and why. The API attaches it to
+// the request context with WithChangeInfo; UpsertState records it on the
+// revision it writes.
+type ChangeInfo struct {
+	Author string
+	Reason string
+}
+
+type changeInfoKey struct{}
+
+// WithChangeInfo returns a context whose upserts are attributed to info.
+func WithChangeInfo(ctx context.Context, info ChangeInfo) context.Context {
+	return context.WithValue(ctx, changeInfoKey{}, info)
+}
+
+// ChangeInfoFromContext returns the ChangeInfo set by WithChangeInfo, if any.
+func ChangeInfoFromContext(ctx context.Context) ChangeInfo {
+	info, _ := ctx.Value(changeInfoKey{}).(ChangeInfo)
+	return info
+}
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion returns a context whose upserts only apply to a
+// state still at version v (0: one that does not exist), and otherwise
+// return ErrVersionConflict, for read-modify-write updates of a spec.
+func WithExpectedVersion(ctx context.Context, v int) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, v)
+}
+
+// ExpectedVersionFromContext returns the version set by
+// WithExpectedVersion, if any.
+func ExpectedVersionFromContext(ctx context.Context) (int, bool) {
+	v, ok := ctx.Value(expectedVersionKey{}).(int)
+	return v, ok
+}
+
+// checkExpectedVersion enforces WithExpectedVersion against the version
+// the state is at (0 if missing).
+func checkExpectedVersion(ctx context.Context, stateID string, current int) error {
+	if want, ok := ExpectedVersionFromContext(ctx); ok && want != current {
+		return fmt.Errorf("state %s at version %d, expected %d: %w", stateID, current, want, ErrVersionConflict)
+	}
+	return nil
+}
+
+type createdAtKey struct{}
+
+// WithCreatedAt returns a context whose UpsertAgent and UpsertState calls
+// give a record they create CreatedAt t instead of the current time, for
+// restoring records with their original one. Records that exist keep
+// theirs. A zero t leaves ctx as is.
+func WithCreatedAt(ctx context.Context, t time.Time) context.Context {
+	if t.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, createdAtKey{}, t)
+}
+
+// CreatedAtFromContext returns the time set by WithCreatedAt, if any.
+func CreatedAtFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(createdAtKey{}).(time.Time)
+	return t, ok
+}
+
+// createdAt is the CreatedAt of a record an upsert creates at now.
+func createdAt(ctx context.Context, now time.Time) time.Time {
+	if t, ok := CreatedAtFromContext(ctx); ok {
+		return t
+	}
+	return now
+}
+
+func newStateRevision(ctx context.Context, state *DesiredState) *StateRevision {
+	info := ChangeInfoFromContext(ctx)
+	return &StateRevision{
+		TenantID:  state.TenantID,
+		StateID:   state.StateID,
+		Revision:  state.Version,
+		Author:    info.Author,
+		Reason:    info.Reason,
+		CreatedAt: state.UpdatedAt,
+		State:     *state,
+	}
+}
+
+// RevisionKey is where a revision lives in key-value stores; zero-padding
+// keeps a state's revisions in order under RevisionPrefix.
+// Format: fluxforge:revisions:{tenantID}:{stateID}:{revision}
+func RevisionKey(tenantID, stateID string, revision int) string {
+	return fmt.Sprintf("%s%010d", RevisionPrefix(tenantID, stateID), revision)
+}
+
+// RevisionPrefix prefixes every revision key of one state.
+func RevisionPrefix(tenantID, stateID string) string {
+	return fmt.Sprintf("fluxforge:revisions:%s:%s:", tenantID, stateID)
+}
+
+// FieldChange is one field that differs between two revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DiffStates compares the spec fields of two states (the ones a user sets,
+// not status or bookkeeping) and returns those that differ, in a fixed order.
+func DiffStates(from, to *DesiredState) []FieldChange {
+	type field struct {
+		name     string
+		from, to interface{}
+	}
+	fields := []field{
+		{"node_id", from.NodeID, to.NodeID},
+		{"check_cmd", from.CheckCmd, to.CheckCmd},
+		{"apply_cmd", from.ApplyCmd, to.ApplyCmd},
+		{"desired_exit_code", from.DesiredExitCode, to.DesiredExitCode},
+		{"job_timeout_seconds", from.JobTimeoutSeconds, to.JobTimeoutSeconds},
+		{"check_interval", from.CheckInterval, to.CheckInterval},
+	}
+	changes := []FieldChange{}
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,252 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/itskum47/FluxForge/control_plane/store"
+	"github.com/itskum47/FluxForge/control_plane/store/storetest"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMemoryStore()
+	})
+}
+
+// TestRedisStoreMiniredis runs both suites against an in-process
+// miniredis, so RedisStore is certified without a Redis server.
+func TestRedisStoreMiniredis(t *testing.T) {
+	mr, rs := newMiniredis(t)
+	storetest.Run(t, func(t *testing.T) store.Store { return rs })
+
+	// miniredis only expires keys when its clock is moved, so move it with
+	// the wall clock for the lease expiry checks
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mr.FastForward(10 * time.Millisecond)
+			}
+		}
+	}()
+	t.Run("Coordinator", func(t *testing.T) {
+		storetest.RunCoordinator(t, func(t *testing.T) store.Coordinator { return rs })
+	})
+}
+
+func newMiniredisStore(t *testing.T) *store.RedisStore {
+	t.Helper()
+	_, rs := newMiniredis(t)
+	return rs
+}
+
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, *store.RedisStore) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rs, err := store.NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(func() { rs.Client().Close() })
+	return mr, rs
+}
+
+// TestRedisStoreConformance runs against FLUXFORGE_TEST_REDIS_ADDR
+// (a scratch instance; the suite only adds uniquely named keys).
+func TestRedisStoreConformance(t *testing.T) {
+	addr := os.Getenv("FLUXFORGE_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("FLUXFORGE_TEST_REDIS_ADDR not set")
+	}
+	rs, err := store.NewRedisStore(addr, "", 0)
+	if err != nil {
+		t.Fatalf("redis: %v", err)
+	}
+	t.Cleanup(func() { rs.Client().Close() })
+
+	storetest.Run(t, func(t *testing.T) store.Store { return rs })
+	t.Run("Coordinator", func(t *testing.T) {
+		storetest.RunCoordinator(t, func(t *testing.T) store.Coordinator { return rs })
+	})
+}
+
+// TestPostgresStoreConformance runs against FLUXFORGE_TEST_DATABASE_URL,
+// migrating it to the latest schema first.
+func TestPostgresStoreConformance(t *testing.T) {
+	url := os.Getenv("FLUXFORGE_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("FLUXFORGE_TEST_DATABASE_URL not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ps, err := store.NewPostgresStore(ctx, url)
+	if err != nil {
+		t.Fatalf("postgres: %v", err)
+	}
+	t.Cleanup(ps.Close)
+	if _, err := ps.MigrateUp(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	storetest.Run(t, func(t *testing.T) store.Store { return ps })
+	t.Run("Coordinator", func(t *testing.T) {
+		storetest.RunCoordinator(t, func(t *testing.T) store.Coordinator { return ps })
+	})
+	t.Run("Idempotency", func(t *testing.T) {
+		ctx := context.Background()
+		key := fmt.Sprintf("conformance-%d", time.Now().UnixNano())
+		if v, err := ps.Get(ctx, key); err != nil || v != "" {
+			t.Fatalf("get of a missing key = %q, %v", v, err)
+		}
+		if err := ps.Set(ctx, key, "first", time.Hour); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		if err := ps.Set(ctx, key, "second", time.Hour); err != nil {
+			t.Fatalf("second set: %v", err)
+		}
+		if v, err := ps.Get(ctx, key); err != nil || v != "first" {
+			t.Errorf("get = %q, %v; want the first value", v, err)
+		}
+
+		if err := ps.SetIdempotencyRecordNX(key, "v1", 50*time.Millisecond); err != nil {
+			t.Fatalf("nx: %v", err)
+		}
+		if err := ps.SetIdempotencyRecordNX(key, "v2", time.Hour); err == nil {
+			t.Error("NX over a live record succeeded")
+		}
+		time.Sleep(100 * time.Millisecond)
+		if _, err := ps.GetIdempotencyRecord(key); err == nil {
+			t.Error("expired record still returned")
+		}
+		if err := ps.SetIdempotencyRecordNX(key, "v3", time.Hour); err != nil {
+			t.Errorf("NX over an expired record: %v", err)
+		}
+		if v, err := ps.GetIdempotencyRecord(key); err != nil || v != "v3" {
+			t.Errorf("record = %q, %v; want v3", v, err)
+		}
+	})
+}
+
+func TestBoltStoreConformance(t *testing.T) {
+	bs, err := store.NewBoltStore(filepath.Join(t.TempDir(), "fluxforge.db"))
+	if err != nil {
+		t.Fatalf("bolt: %v", err)
+	}
+	t.Cleanup(func() { bs.Close() })
+
+	storetest.Run(t, func(t *testing.T) store.Store { return bs })
+	t.Run("Coordinator", func(t *testing.T) {
+		storetest.RunCoordinator(t, func(t *testing.T) store.Coordinator { return bs })
+	})
+}
+
+// TestBoltStoreReopen checks that records and epochs survive a restart
+// and that a second process cannot open the file while it is in use.
+func TestBoltStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "fluxforge.db")
+
+	bs, err := store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := bs.UpsertAgent(ctx, "t1", &store.Agent{NodeID: "n1", Status: "active"}); err != nil {
+		t.Fatalf("upsert agent: %v", err)
+	}
+	if err := bs.CreateJob(ctx, "t1", &store.Job{JobID: "j1", NodeID: "n1", Status: "queued"}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if _, err := bs.IncrementDurableEpoch(ctx, "scheduler"); err != nil {
+		t.Fatalf("epoch: %v", err)
+	}
+	if err := bs.SetIdempotencyRecord("k1", "v1", time.Hour); err != nil {
+		t.Fatalf("idempotency: %v", err)
+	}
+	if _, err := store.NewBoltStore(path); err == nil {
+		t.Fatal("second open of a locked file succeeded")
+	}
+	if err := bs.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	bs, err = store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer bs.Close()
+
+	if agent, err := bs.GetAgent(ctx, "t1", "n1"); err != nil || agent == nil || agent.Status != "active" {
+		t.Errorf("agent after reopen = %+v, %v", agent, err)
+	}
+	if job, err := bs.ClaimNextJob(ctx, "t1", "n1"); err != nil || job == nil || job.JobID != "j1" {
+		t.Errorf("claim after reopen = %+v, %v", job, err)
+	}
+	if epoch, err := bs.IncrementDurableEpoch(ctx, "scheduler"); err != nil || epoch != 2 {
+		t.Errorf("epoch after reopen = %d, %v; want 2", epoch, err)
+	}
+	if v, err := bs.GetIdempotencyRecord("k1"); err != nil || v != "v1" {
+		t.Errorf("idempotency after reopen = %q, %v", v, err)
+	}
+	if err := bs.SetIdempotencyRecordNX("k1", "v2", time.Hour); err == nil {
+		t.Error("NX over a live record succeeded")
+	}
+}
+
+// TestBoltStoreIndexesExistingRecords checks that opening a file written
+// before the created-order indexes existed indexes its records.
+func TestBoltStoreIndexesExistingRecords(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "fluxforge.db")
+
+	bs, err := store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := bs.UpsertAgent(ctx, "t1", &store.Agent{NodeID: "n1", Status: "active"}); err != nil {
+		t.Fatalf("upsert agent: %v", err)
+	}
+	if err := bs.CreateJob(ctx, "t1", &store.Job{JobID: "j1", NodeID: "n1", Status: "queued"}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	bs.Close()
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("bolt: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte("agents_by_created")); err != nil {
+			return err
+		}
+		return tx.DeleteBucket([]byte("jobs_by_created"))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("drop indexes: %v", err)
+	}
+
+	bs, err = store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer bs.Close()
+	if page, err := bs.QueryAgents(ctx, "t1", store.ListOptions{}); err != nil || len(page.Agents) != 1 {
+		t.Errorf("QueryAgents after reopen = %v, %v; want n1", page, err)
+	}
+	if page, err := bs.QueryJobs(ctx, "t1", store.ListOptions{}); err != nil || len(page.Jobs) != 1 {
+		t.Errorf("QueryJobs after reopen = %v, %v; want j1", page, err)
+	}
+}
@@ -0,0 +1,137 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// CoordinatorFactory returns the coordinator under test.
+type CoordinatorFactory func(t *testing.T) store.Coordinator
+
+// RunCoordinator checks the lease contract LeaderElector, LockJanitor and
+// Membership rely on:
+//   - a held lease cannot be acquired, renewed or released by another owner;
+//   - an expired lease reads as free and can be acquired again;
+//   - ScanLocks matches "*" globs and only returns held leases;
+//   - IncrementEpoch counts up from 1 per key.
+func RunCoordinator(t *testing.T, newCoordinator CoordinatorFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, c store.Coordinator)
+	}{
+		{"LeaseOwnership", testLeaseOwnership},
+		{"LeaseExpiry", testLeaseExpiry},
+		{"ScanLocks", testScanLocks},
+		{"IncrementEpoch", testIncrementEpoch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newCoordinator(t))
+		})
+	}
+}
+
+func testLeaseOwnership(t *testing.T, c store.Coordinator) {
+	ctx := context.Background()
+	key := "fluxforge:lock:" + uniq("lease")
+
+	ok, err := c.AcquireLease(ctx, key, "owner-a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("AcquireLease(free) = %v, %v; want true", ok, err)
+	}
+	if ok, _ := c.AcquireLease(ctx, key, "owner-b", time.Minute); ok {
+		t.Errorf("second owner acquired a held lease")
+	}
+	if owner, _ := c.GetLockOwner(ctx, key); owner != "owner-a" {
+		t.Errorf("GetLockOwner = %q, want owner-a", owner)
+	}
+	if ok, _ := c.IsLeaseOwner(ctx, key, "owner-b"); ok {
+		t.Errorf("IsLeaseOwner(owner-b) = true")
+	}
+
+	if ok, _ := c.RenewLease(ctx, key, "owner-b", time.Minute); ok {
+		t.Errorf("non-owner renewed the lease")
+	}
+	if ok, err := c.RenewLease(ctx, key, "owner-a", time.Minute); err != nil || !ok {
+		t.Errorf("RenewLease(owner) = %v, %v; want true", ok, err)
+	}
+
+	if err := c.ReleaseLease(ctx, key, "owner-b"); err != nil {
+		t.Fatalf("ReleaseLease(non-owner): %v", err)
+	}
+	if ok, _ := c.IsLeaseOwner(ctx, key, "owner-a"); !ok {
+		t.Errorf("non-owner release dropped the lease")
+	}
+	if err := c.ReleaseLease(ctx, key, "owner-a"); err != nil {
+		t.Fatalf("ReleaseLease: %v", err)
+	}
+	if owner, _ := c.GetLockOwner(ctx, key); owner != "" {
+		t.Errorf("after release, owner = %q", owner)
+	}
+	if ok, _ := c.AcquireLease(ctx, key, "owner-b", time.Minute); !ok {
+		t.Errorf("released lease could not be acquired")
+	}
+	c.ReleaseLease(ctx, key, "owner-b")
+}
+
+func testLeaseExpiry(t *testing.T, c store.Coordinator) {
+	ctx := context.Background()
+	key := "fluxforge:lock:" + uniq("expiry")
+
+	if ok, err := c.AcquireLease(ctx, key, "owner-a", 200*time.Millisecond); err != nil || !ok {
+		t.Fatalf("AcquireLease = %v, %v", ok, err)
+	}
+	time.Sleep(400 * time.Millisecond)
+
+	if owner, _ := c.GetLockOwner(ctx, key); owner != "" {
+		t.Errorf("expired lease still owned by %q", owner)
+	}
+	if ok, _ := c.RenewLease(ctx, key, "owner-a", time.Minute); ok {
+		t.Errorf("expired lease was renewed")
+	}
+	if ok, err := c.AcquireLease(ctx, key, "owner-b", time.Minute); err != nil || !ok {
+		t.Errorf("AcquireLease after expiry = %v, %v; want true", ok, err)
+	}
+	c.ReleaseLease(ctx, key, "owner-b")
+}
+
+func testScanLocks(t *testing.T, c store.Coordinator) {
+	ctx := context.Background()
+	prefix := "fluxforge:scan:" + uniq("run") + ":"
+
+	held := []string{prefix + "a", prefix + "b"}
+	for _, key := range held {
+		if ok, err := c.AcquireLease(ctx, key, "owner", time.Minute); err != nil || !ok {
+			t.Fatalf("AcquireLease(%s) = %v, %v", key, ok, err)
+		}
+		defer c.ReleaseLease(ctx, key, "owner")
+	}
+	released := prefix + "c"
+	c.AcquireLease(ctx, released, "owner", time.Minute)
+	c.ReleaseLease(ctx, released, "owner")
+
+	keys, err := c.ScanLocks(ctx, prefix+"*")
+	if err != nil {
+		t.Fatalf("ScanLocks: %v", err)
+	}
+	sort.Strings(keys)
+	if !equal(keys, held) {
+		t.Errorf("ScanLocks = %v, want %v", keys, held)
+	}
+}
+
+func testIncrementEpoch(t *testing.T, c store.Coordinator) {
+	ctx := context.Background()
+	key := "fluxforge:lock:" + uniq("epoch")
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := c.IncrementEpoch(ctx, key)
+		if err != nil || got != want {
+			t.Fatalf("IncrementEpoch = %d, %v; want %d", got, err, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func testDeleteAgent(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	a := &store.Agent{NodeID: uniq("node"), Hostname: "doomed", Status: "active", Metadata: map[string]string{}}
+	if err := s.UpsertAgent(ctx, tenant, a); err != nil {
+		t.Fatalf("UpsertAgent: %v", err)
+	}
+	if err := s.DeleteAgent(ctx, uniq("other-tenant"), a.NodeID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("other tenant DeleteAgent: err = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteAgent(ctx, tenant, a.NodeID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+	if got, err := s.GetAgent(ctx, tenant, a.NodeID); err != nil || got != nil {
+		t.Errorf("GetAgent after delete = %+v, %v; want nil, nil", got, err)
+	}
+	if err := s.DeleteAgent(ctx, tenant, a.NodeID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second DeleteAgent: err = %v, want ErrNotFound", err)
+	}
+	if err := s.UpdateAgentHeartbeat(ctx, tenant, a.NodeID, time.Now()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("heartbeat after delete: err = %v, want ErrNotFound", err)
+	}
+
+	tomb, err := s.GetTombstone(ctx, tenant, store.ResourceAgent, a.NodeID)
+	if err != nil || tomb == nil {
+		t.Fatalf("GetTombstone = %v, %v", tomb, err)
+	}
+	var record store.Agent
+	if err := json.Unmarshal(tomb.Record, &record); err != nil || record.Hostname != "doomed" {
+		t.Errorf("tombstone record = %s (%v)", tomb.Record, err)
+	}
+	if tomb.DeletedAt.IsZero() {
+		t.Error("tombstone has no DeletedAt")
+	}
+	if other, _ := s.GetTombstone(ctx, uniq("other-tenant"), store.ResourceAgent, a.NodeID); other != nil {
+		t.Errorf("other tenant read tombstone: %+v", other)
+	}
+
+	// Re-registering brings the agent back and clears the tombstone
+	if err := s.UpsertAgent(ctx, tenant, a); err != nil {
+		t.Fatalf("UpsertAgent after delete: %v", err)
+	}
+	if tomb, _ := s.GetTombstone(ctx, tenant, store.ResourceAgent, a.NodeID); tomb != nil {
+		t.Errorf("tombstone survived re-register: %+v", tomb)
+	}
+}
+
+func testDeleteState(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	st := newState(tenant)
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	keep := newState(tenant)
+	if err := s.UpsertState(ctx, tenant, keep); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+
+	if err := s.DeleteState(ctx, tenant, st.StateID); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	if got, err := s.GetState(ctx, tenant, st.StateID); err != nil || got != nil {
+		t.Errorf("GetState after delete = %+v, %v; want nil, nil", got, err)
+	}
+	if err := s.DeleteState(ctx, tenant, st.StateID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second DeleteState: err = %v, want ErrNotFound", err)
+	}
+	err := s.UpdateStateStatus(ctx, tenant, st.StateID, "compliant", "", time.Now(), st.Version)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("status update after delete: err = %v, want ErrNotFound", err)
+	}
+
+	states, err := s.ListStates(ctx, tenant)
+	if err != nil || len(states) != 1 || states[0].StateID != keep.StateID {
+		t.Errorf("ListStates after delete = %v, %v; want only %s", states, err, keep.StateID)
+	}
+	if n, err := s.CountStatesByStatus(ctx, tenant, "pending"); err != nil || n != 1 {
+		t.Errorf("CountStatesByStatus(pending) = %d, %v; want 1", n, err)
+	}
+
+	tomb, err := s.GetTombstone(ctx, tenant, store.ResourceState, st.StateID)
+	if err != nil || tomb == nil {
+		t.Fatalf("GetTombstone = %v, %v", tomb, err)
+	}
+	var record store.DesiredState
+	if err := json.Unmarshal(tomb.Record, &record); err != nil || record.CheckCmd != st.CheckCmd {
+		t.Errorf("tombstone record = %s (%v)", tomb.Record, err)
+	}
+
+	// A recreated state starts over at version 1 without a tombstone
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState after delete: %v", err)
+	}
+	if st.Version != 1 {
+		t.Errorf("recreated state version = %d, want 1", st.Version)
+	}
+	if tomb, _ := s.GetTombstone(ctx, tenant, store.ResourceState, st.StateID); tomb != nil {
+		t.Errorf("tombstone survived recreate: %+v", tomb)
+	}
+
+	// PurgeTombstones drops tombstones older than the cutoff only
+	if err := s.DeleteState(ctx, tenant, keep.StateID); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	if _, err := s.PurgeTombstones(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("PurgeTombstones: %v", err)
+	}
+	if tomb, _ := s.GetTombstone(ctx, tenant, store.ResourceState, keep.StateID); tomb == nil {
+		t.Error("PurgeTombstones dropped a fresh tombstone")
+	}
+	n, err := s.PurgeTombstones(ctx, time.Now().Add(time.Minute))
+	if err != nil || n < 1 {
+		t.Fatalf("PurgeTombstones = %d, %v; want at least 1", n, err)
+	}
+	if tomb, _ := s.GetTombstone(ctx, tenant, store.ResourceState, keep.StateID); tomb != nil {
+		t.Errorf("tombstone survived purge: %+v", tomb)
+	}
+}
+
+func testPurgeJobs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+	node := uniq("node")
+
+	finish := func(job *store.Job, status string) {
+		t.Helper()
+		if err := s.CreateJob(ctx, tenant, job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+		if status != "queued" {
+			if err := s.UpdateJobStatus(ctx, tenant, job.JobID, status, 0, "", ""); err != nil {
+				t.Fatalf("UpdateJobStatus(%s): %v", status, err)
+			}
+		}
+	}
+	done, failed, cancelled := newJob(tenant, node), newJob(tenant, node), newJob(tenant, node)
+	queued, running, elsewhere := newJob(tenant, node), newJob(tenant, node), newJob(tenant, uniq("node"))
+	finish(done, "completed")
+	finish(failed, "failed")
+	finish(cancelled, "cancelled")
+	finish(queued, "queued")
+	finish(running, "running")
+	finish(elsewhere, "completed")
+
+	n, err := s.PurgeJobs(ctx, tenant, store.JobPurge{NodeID: node, FinishedBefore: time.Now().Add(-time.Hour)})
+	if err != nil || n != 0 {
+		t.Errorf("PurgeJobs(before an hour ago) = %d, %v; want 0", n, err)
+	}
+	n, err = s.PurgeJobs(ctx, uniq("other-tenant"), store.JobPurge{})
+	if err != nil || n != 0 {
+		t.Errorf("other tenant PurgeJobs = %d, %v; want 0", n, err)
+	}
+
+	n, err = s.PurgeJobs(ctx, tenant, store.JobPurge{NodeID: node, FinishedBefore: time.Now().Add(time.Minute)})
+	if err != nil || n != 3 {
+		t.Fatalf("PurgeJobs = %d, %v; want 3 finished jobs", n, err)
+	}
+	jobs, err := s.ListJobs(ctx, tenant, node, 0)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	left := map[string]bool{}
+	for _, id := range jobIDs(jobs) {
+		left[id] = true
+	}
+	if len(left) != 2 || !left[queued.JobID] || !left[running.JobID] {
+		t.Errorf("jobs left = %v; want the queued and running jobs", left)
+	}
+	if got, _ := s.GetJob(ctx, tenant, elsewhere.JobID); got == nil {
+		t.Error("PurgeJobs removed another node's job")
+	}
+
+	n, err = s.PurgeJobs(ctx, tenant, store.JobPurge{StateID: elsewhere.StateID})
+	if err != nil || n != 1 {
+		t.Errorf("PurgeJobs(state) = %d, %v; want 1", n, err)
+	}
+}
@@ -0,0 +1,90 @@
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func testOutbox(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	st := newState(tenant)
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	event := func(status string) *store.OutboxEvent {
+		payload, _ := json.Marshal(map[string]string{"state_id": st.StateID, "new_status": status})
+		return &store.OutboxEvent{
+			ID:        uniq("event"),
+			Topic:     "fluxforge.events.state.transition",
+			TenantID:  tenant,
+			Payload:   payload,
+			CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		}
+	}
+
+	first, second := event("compliant"), event("drifted")
+	if err := s.UpdateStateStatusWithEvent(ctx, tenant, st.StateID, "compliant", "", time.Now(), st.Version, first); err != nil {
+		t.Fatalf("UpdateStateStatusWithEvent: %v", err)
+	}
+	err := s.UpdateStateStatusWithEvent(ctx, tenant, st.StateID, "failed", "", time.Now(), st.Version+1, event("failed"))
+	if !errors.Is(err, store.ErrVersionConflict) {
+		t.Fatalf("stale UpdateStateStatusWithEvent = %v, want ErrVersionConflict", err)
+	}
+	if err := s.UpdateStateStatusWithEvent(ctx, tenant, st.StateID, "drifted", "exit 1", time.Now(), st.Version, second); err != nil {
+		t.Fatalf("UpdateStateStatusWithEvent: %v", err)
+	}
+	if got, _ := s.GetState(ctx, tenant, st.StateID); got == nil || got.Status != "drifted" {
+		t.Fatalf("state = %+v; want the status written with the event", got)
+	}
+
+	// The store may be shared: only this tenant's events are ours
+	mine := func() []*store.OutboxEvent {
+		t.Helper()
+		all, err := s.ReadOutbox(ctx, 0)
+		if err != nil {
+			t.Fatalf("ReadOutbox: %v", err)
+		}
+		var events []*store.OutboxEvent
+		for _, e := range all {
+			if e.TenantID == tenant {
+				events = append(events, e)
+			}
+		}
+		return events
+	}
+	events := mine()
+	if len(events) != 2 || events[0].ID != first.ID || events[1].ID != second.ID {
+		t.Fatalf("outbox = %+v; want the two committed events in order", events)
+	}
+	got := events[0]
+	var payload map[string]string
+	if err := json.Unmarshal(got.Payload, &payload); err != nil || payload["new_status"] != "compliant" {
+		t.Errorf("payload = %s, %v", got.Payload, err)
+	}
+	if got.Topic != first.Topic || !got.CreatedAt.Equal(first.CreatedAt) || got.Position == "" {
+		t.Errorf("event = %+v, want %+v with a position", got, first)
+	}
+	if limited, err := s.ReadOutbox(ctx, 1); err != nil || len(limited) != 1 {
+		t.Errorf("ReadOutbox(1) = %d events, %v", len(limited), err)
+	}
+
+	if err := s.AckOutbox(ctx, []string{events[0].Position}); err != nil {
+		t.Fatalf("AckOutbox: %v", err)
+	}
+	if events = mine(); len(events) != 1 || events[0].ID != second.ID {
+		t.Fatalf("outbox after ack = %+v; want only the second event", events)
+	}
+	if err := s.AckOutbox(ctx, []string{events[0].Position}); err != nil {
+		t.Fatalf("AckOutbox: %v", err)
+	}
+	if events = mine(); len(events) != 0 {
+		t.Errorf("outbox after acking everything = %+v", events)
+	}
+}
@@ -0,0 +1,319 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func testQueryJobs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+	node := uniq("node")
+
+	base := time.Now().UTC().Truncate(time.Second)
+	var ids []string // Oldest first
+	for i := 0; i < 5; i++ {
+		job := newJob(tenant, node)
+		job.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		if i == 2 {
+			job.Status = "failed"
+		}
+		if err := s.CreateJob(ctx, tenant, job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+		ids = append(ids, job.JobID)
+	}
+	stray := newJob(tenant, uniq("node"))
+	stray.CreatedAt = base.Add(time.Minute)
+	if err := s.CreateJob(ctx, tenant, stray); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	walk := func(opts store.ListOptions) [][]string {
+		t.Helper()
+		var pages [][]string
+		for {
+			page, err := s.QueryJobs(ctx, tenant, opts)
+			if err != nil {
+				t.Fatalf("QueryJobs(%+v): %v", opts, err)
+			}
+			pages = append(pages, jobIDs(page.Jobs))
+			if page.NextCursor == "" || len(pages) > 10 {
+				return pages
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+
+	pages := walk(store.ListOptions{Limit: 2, NodeID: node})
+	want := [][]string{{ids[4], ids[3]}, {ids[2], ids[1]}, {ids[0]}}
+	if !equalPages(pages, want) {
+		t.Errorf("desc pages = %v, want %v", pages, want)
+	}
+
+	pages = walk(store.ListOptions{Limit: 3, NodeID: node, Order: store.SortAsc})
+	want = [][]string{{ids[0], ids[1], ids[2]}, {ids[3], ids[4]}}
+	if !equalPages(pages, want) {
+		t.Errorf("asc pages = %v, want %v", pages, want)
+	}
+
+	pages = walk(store.ListOptions{Status: "queued", CreatedSince: base.Add(time.Second), CreatedBefore: base.Add(4 * time.Second)})
+	want = [][]string{{ids[3], ids[1]}}
+	if !equalPages(pages, want) {
+		t.Errorf("status and created range = %v, want %v", pages, want)
+	}
+
+	pages = walk(store.ListOptions{})
+	if len(pages) != 1 || len(pages[0]) != 6 || pages[0][0] != stray.JobID {
+		t.Errorf("unfiltered = %v, want all 6 jobs newest first", pages)
+	}
+
+	first, err := s.QueryJobs(ctx, tenant, store.ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("QueryJobs: %v", err)
+	}
+	for name, opts := range map[string]store.ListOptions{
+		"garbage cursor":  {Cursor: "not-a-cursor"},
+		"cursor reversed": {Cursor: first.NextCursor, Order: store.SortAsc},
+		"unknown order":   {Order: "sideways"},
+		"tier on jobs":    {Tier: "premium"},
+		"updated on jobs": {UpdatedSince: base},
+	} {
+		if _, err := s.QueryJobs(ctx, tenant, opts); !errors.Is(err, store.ErrInvalidListOptions) {
+			t.Errorf("%s: err = %v, want ErrInvalidListOptions", name, err)
+		}
+	}
+}
+
+func testQueryAgents(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	for i := 0; i < 5; i++ {
+		a := &store.Agent{
+			NodeID:   uniq("node"),
+			Status:   "active",
+			Tier:     "standard",
+			Metadata: map[string]string{"zone": "b"},
+		}
+		if i%2 == 0 {
+			a.Tier = "premium"
+		}
+		if i < 2 {
+			a.Metadata = map[string]string{"zone": "a", "rack": "r1"}
+		}
+		if err := s.UpsertAgent(ctx, tenant, a); err != nil {
+			t.Fatalf("UpsertAgent: %v", err)
+		}
+	}
+	mustUpsertAgent(t, s, uniq("other-tenant"), uniq("node"))
+
+	opts := store.ListOptions{Limit: 2}
+	seen := map[string]bool{}
+	var prev time.Time
+	for pages := 0; ; pages++ {
+		page, err := s.QueryAgents(ctx, tenant, opts)
+		if err != nil {
+			t.Fatalf("QueryAgents: %v", err)
+		}
+		if len(page.Agents) > 2 || pages > 3 {
+			t.Fatalf("page %d has %d agents", pages, len(page.Agents))
+		}
+		for _, a := range page.Agents {
+			if seen[a.NodeID] {
+				t.Errorf("agent %s listed twice", a.NodeID)
+			}
+			seen[a.NodeID] = true
+			if !prev.IsZero() && a.CreatedAt.After(prev) {
+				t.Errorf("agent %s out of order: %v after %v", a.NodeID, a.CreatedAt, prev)
+			}
+			prev = a.CreatedAt
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if len(seen) != 5 {
+		t.Errorf("paged through %d agents, want 5", len(seen))
+	}
+
+	page, err := s.QueryAgents(ctx, tenant, store.ListOptions{Tier: "premium"})
+	if err != nil || len(page.Agents) != 3 {
+		t.Errorf("tier filter = %d agents, %v; want 3", len(page.Agents), err)
+	}
+	page, err = s.QueryAgents(ctx, tenant, store.ListOptions{Labels: map[string]string{"zone": "a"}, Tier: "premium"})
+	if err != nil || len(page.Agents) != 1 {
+		t.Errorf("label and tier filter = %d agents, %v; want 1", len(page.Agents), err)
+	}
+	page, err = s.QueryAgents(ctx, tenant, store.ListOptions{Labels: map[string]string{"zone": "a", "rack": "r2"}})
+	if err != nil || len(page.Agents) != 0 {
+		t.Errorf("unmatched label = %d agents, %v; want 0", len(page.Agents), err)
+	}
+}
+
+func testQueryAfterRemoval(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	kept := mustUpsertAgent(t, s, tenant, uniq("node"))
+	deleted := mustUpsertAgent(t, s, tenant, uniq("node"))
+	if err := s.DeleteAgent(ctx, tenant, deleted.NodeID); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+	page, err := s.QueryAgents(ctx, tenant, store.ListOptions{})
+	if err != nil || len(page.Agents) != 1 || page.Agents[0].NodeID != kept.NodeID {
+		t.Errorf("QueryAgents after delete = %v, %v; want only %s", page, err, kept.NodeID)
+	}
+
+	base := time.Now().UTC().Truncate(time.Second)
+	purged, recreated := newJob(tenant, uniq("node")), newJob(tenant, uniq("node"))
+	purged.Status, purged.CreatedAt = "completed", base.Add(-time.Hour)
+	recreated.CreatedAt = base.Add(-time.Minute)
+	for _, job := range []*store.Job{purged, recreated} {
+		if err := s.CreateJob(ctx, tenant, job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	if err := s.UpdateJobStatus(ctx, tenant, purged.JobID, "completed", 0, "", ""); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+	if n, err := s.PurgeJobs(ctx, tenant, store.JobPurge{FinishedBefore: time.Now().Add(time.Minute)}); err != nil || n != 1 {
+		t.Fatalf("PurgeJobs = %d, %v; want 1", n, err)
+	}
+	again := *recreated
+	again.CreatedAt = base
+	if err := s.CreateJob(ctx, tenant, &again); err != nil {
+		t.Fatalf("CreateJob again: %v", err)
+	}
+
+	for _, order := range []store.SortOrder{store.SortAsc, store.SortDesc} {
+		jobs, err := s.QueryJobs(ctx, tenant, store.ListOptions{Order: order})
+		if err != nil || len(jobs.Jobs) != 1 || jobs.Jobs[0].JobID != recreated.JobID || !jobs.Jobs[0].CreatedAt.Equal(base) {
+			t.Errorf("QueryJobs(%s) after purge and re-create = %v, %v; want %s once, created %v", order, jobs, err, recreated.JobID, base)
+		}
+	}
+	jobs, err := s.QueryJobs(ctx, tenant, store.ListOptions{CreatedBefore: base})
+	if err != nil || len(jobs.Jobs) != 0 {
+		t.Errorf("QueryJobs before the re-created time = %v, %v; want none", jobs, err)
+	}
+}
+
+func testAgentTimestamps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant, node := uniq("tenant"), uniq("node")
+
+	mustUpsertAgent(t, s, tenant, node)
+	first, err := s.GetAgent(ctx, tenant, node)
+	if err != nil || first == nil || first.CreatedAt.IsZero() {
+		t.Fatalf("GetAgent after create = %+v, %v; want CreatedAt set", first, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	mustUpsertAgent(t, s, tenant, node)
+	second, err := s.GetAgent(ctx, tenant, node)
+	if err != nil || second == nil {
+		t.Fatalf("GetAgent: %v", err)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("CreatedAt changed on upsert: %v -> %v", first.CreatedAt, second.CreatedAt)
+	}
+	if !second.UpdatedAt.After(first.UpdatedAt) {
+		t.Errorf("UpdatedAt not advanced: %v -> %v", first.UpdatedAt, second.UpdatedAt)
+	}
+}
+
+func testRestoredCreatedAt(t *testing.T, s store.Store) {
+	tenant := uniq("tenant")
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := store.WithCreatedAt(context.Background(), created)
+
+	agent := &store.Agent{NodeID: uniq("node"), Hostname: "h", Status: "active"}
+	if err := s.UpsertAgent(ctx, tenant, agent); err != nil {
+		t.Fatalf("UpsertAgent: %v", err)
+	}
+	st := newState(tenant)
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	gotAgent, err := s.GetAgent(ctx, tenant, agent.NodeID)
+	if err != nil || gotAgent == nil || !gotAgent.CreatedAt.Equal(created) {
+		t.Errorf("agent created under WithCreatedAt = %+v, %v; want CreatedAt %v", gotAgent, err, created)
+	}
+	gotState, err := s.GetState(ctx, tenant, st.StateID)
+	if err != nil || gotState == nil || !gotState.CreatedAt.Equal(created) {
+		t.Errorf("state created under WithCreatedAt = %+v, %v; want CreatedAt %v", gotState, err, created)
+	}
+
+	// Records that exist keep theirs
+	later := store.WithCreatedAt(context.Background(), created.Add(time.Hour))
+	if err := s.UpsertState(later, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	if gotState, err := s.GetState(ctx, tenant, st.StateID); err != nil || gotState == nil || !gotState.CreatedAt.Equal(created) {
+		t.Errorf("state updated under WithCreatedAt = %+v, %v; want CreatedAt kept at %v", gotState, err, created)
+	}
+}
+
+func testQueryStates(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	var pending []string
+	for i := 0; i < 4; i++ {
+		st := newState(tenant)
+		if err := s.UpsertState(ctx, tenant, st); err != nil {
+			t.Fatalf("UpsertState: %v", err)
+		}
+		if i%2 == 1 {
+			if err := s.UpdateStateStatus(ctx, tenant, st.StateID, "compliant", "", time.Now(), st.Version); err != nil {
+				t.Fatalf("UpdateStateStatus: %v", err)
+			}
+			continue
+		}
+		pending = append(pending, st.StateID)
+	}
+
+	opts := store.ListOptions{Limit: 1, Order: store.SortAsc, Status: "pending"}
+	var got []string
+	for pages := 0; pages < 5; pages++ {
+		page, err := s.QueryStates(ctx, tenant, opts)
+		if err != nil {
+			t.Fatalf("QueryStates: %v", err)
+		}
+		for _, st := range page.States {
+			got = append(got, st.StateID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if len(got) != 2 || !(got[0] == pending[0] && got[1] == pending[1] || got[0] == pending[1] && got[1] == pending[0]) {
+		t.Errorf("pending states = %v, want %v", got, pending)
+	}
+
+	page, err := s.QueryStates(ctx, tenant, store.ListOptions{UpdatedSince: time.Now().Add(time.Hour)})
+	if err != nil || len(page.States) != 0 {
+		t.Errorf("future UpdatedSince = %d states, %v; want 0", len(page.States), err)
+	}
+	if _, err := s.QueryStates(ctx, tenant, store.ListOptions{Labels: map[string]string{"a": "b"}}); !errors.Is(err, store.ErrInvalidListOptions) {
+		t.Errorf("labels on states: err = %v, want ErrInvalidListOptions", err)
+	}
+}
+
+func equalPages(a, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func testStateRevisions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	st := newState(tenant)
+	create := store.WithChangeInfo(ctx, store.ChangeInfo{Author: "alice", Reason: "initial"})
+	if err := s.UpsertState(create, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	if err := s.UpdateStateStatus(ctx, tenant, st.StateID, "compliant", "", time.Now(), st.Version); err != nil {
+		t.Fatalf("UpdateStateStatus: %v", err)
+	}
+	st.ApplyCmd = "touch /tmp/ok-v2"
+	st.JobTimeoutSeconds = 90
+	update := store.WithChangeInfo(ctx, store.ChangeInfo{Author: "bob", Reason: "longer timeout"})
+	if err := s.UpsertState(update, tenant, st); err != nil {
+		t.Fatalf("UpsertState (update): %v", err)
+	}
+
+	// Status updates do not create revisions
+	revs, err := s.ListStateRevisions(ctx, tenant, st.StateID)
+	if err != nil {
+		t.Fatalf("ListStateRevisions: %v", err)
+	}
+	if len(revs) != 2 || revs[0].Revision != 1 || revs[1].Revision != 2 {
+		t.Fatalf("revisions = %+v; want revisions 1 and 2", revs)
+	}
+	first, second := revs[0], revs[1]
+	if first.Author != "alice" || first.Reason != "initial" || first.State.ApplyCmd != "touch /tmp/ok" ||
+		first.StateID != st.StateID || first.TenantID != tenant || first.CreatedAt.IsZero() {
+		t.Errorf("revision 1 = %+v", first)
+	}
+	if second.Author != "bob" || second.State.ApplyCmd != "touch /tmp/ok-v2" || second.State.Version != 2 {
+		t.Errorf("revision 2 = %+v", second)
+	}
+
+	got, err := s.GetStateRevision(ctx, tenant, st.StateID, 1)
+	if err != nil || got == nil || got.State.ApplyCmd != "touch /tmp/ok" || got.Author != "alice" {
+		t.Errorf("GetStateRevision(1) = %+v, %v", got, err)
+	}
+	if got, err := s.GetStateRevision(ctx, tenant, st.StateID, 3); err != nil || got != nil {
+		t.Errorf("GetStateRevision(3) = %+v, %v; want nil, nil", got, err)
+	}
+	if other, _ := s.ListStateRevisions(ctx, uniq("other-tenant"), st.StateID); len(other) != 0 {
+		t.Errorf("other tenant read revisions: %+v", other)
+	}
+
+	diff := store.DiffStates(&first.State, &second.State)
+	if len(diff) != 2 || diff[0].Field != "apply_cmd" || diff[1].Field != "job_timeout_seconds" {
+		t.Errorf("DiffStates = %+v; want apply_cmd and job_timeout_seconds", diff)
+	}
+
+	// Deleting the state drops its history
+	if err := s.DeleteState(ctx, tenant, st.StateID); err != nil {
+		t.Fatalf("DeleteState: %v", err)
+	}
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState (recreate): %v", err)
+	}
+	revs, err = s.ListStateRevisions(ctx, tenant, st.StateID)
+	if err != nil || len(revs) != 1 || revs[0].Revision != 1 || revs[0].Author != "" {
+		t.Errorf("revisions after recreate = %+v, %v; want only an unattributed revision 1", revs, err)
+	}
+}
@@ -0,0 +1,759 @@
+// Package storetest is a behavioral conformance suite for store.Store.
+//
+// Every backend must pass Run. The contract it checks:
+//   - Get* methods return (nil, nil) for missing records; updates to a
+//     missing agent, state or job return store.ErrNotFound.
+//   - Records are scoped by tenant: one tenant never reads, counts or
+//     updates another tenant's records. ListAgents("") spans all tenants.
+//   - UpsertState assigns Version 1 on create and previous+1 on update.
+//     UpdateStateStatus is a CAS on that version: a stale expectedVersion
+//     returns store.ErrVersionConflict and the version is left unchanged.
+//     Under store.WithExpectedVersion, UpsertState is a CAS in the same way.
+//   - CreateJob stores a job as given, timestamps included (CreatedAt
+//     defaults to now), so finished jobs can be restored from a backup.
+//   - ListJobs and ListJobsByTenant return newest first; limit <= 0 means all.
+//   - Under store.WithExpectedJobStatus, UpdateJobStatus only changes a job
+//     still in that status and returns store.ErrJobStatusConflict otherwise.
+//   - ClaimNextJob hands out the oldest queued job exactly once.
+//   - ListTenants includes every tenant with an agent, state or job, sorted.
+//   - Durable epochs start at 0 and increase by one.
+//   - UpsertAgent and UpsertState keep CreatedAt and advance UpdatedAt.
+//     Under store.WithCreatedAt, a record they create gets that CreatedAt.
+//   - Query* pages follow (CreatedAt, ID) in the requested order, apply
+//     every filter, and reject malformed cursors and filters the resource
+//     lacks with store.ErrInvalidListOptions.
+//   - Query* listings leave out deleted agents and purged jobs, and list a
+//     re-created job once, at its new CreatedAt.
+//   - DeleteAgent and DeleteState return store.ErrNotFound for missing
+//     records and leave a tombstone that GetTombstone returns until the
+//     record is upserted again or PurgeTombstones drops it.
+//   - PurgeJobs deletes only finished jobs matching the JobPurge.
+//   - Every UpsertState records a revision numbered by the new Version,
+//     attributed with the context's store.ChangeInfo; status updates do
+//     not. DeleteState drops the state's revisions.
+//   - UpdateStateStatusWithEvent commits the status and the outbox event
+//     together: a rejected update leaves no event. ReadOutbox returns
+//     events oldest first until AckOutbox removes them.
+//
+// Subtests use unique tenant and record IDs so the suite can run against a
+// shared Redis or Postgres instance without cleanup.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// Factory returns the store under test. It may return the same instance
+// for every call; subtests do not depend on an empty store.
+type Factory func(t *testing.T) store.Store
+
+var seq atomic.Int64
+
+// uniq returns an ID that is unique within this process and across runs.
+func uniq(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), seq.Add(1))
+}
+
+// Run executes the full contract against stores built by newStore.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"AgentRoundTrip", testAgentRoundTrip},
+		{"AgentTenantIsolation", testAgentTenantIsolation},
+		{"AgentHeartbeat", testAgentHeartbeat},
+		{"StateVersioning", testStateVersioning},
+		{"StateStatusCAS", testStateStatusCAS},
+		{"StateUpsertCAS", testStateUpsertCAS},
+		{"StateTenantIsolation", testStateTenantIsolation},
+		{"StatesByStatus", testStatesByStatus},
+		{"JobLifecycle", testJobLifecycle},
+		{"JobStatusCAS", testJobStatusCAS},
+		{"JobOrdering", testJobOrdering},
+		{"CreateFinishedJob", testCreateFinishedJob},
+		{"ClaimNextJob", testClaimNextJob},
+		{"ListTenants", testListTenants},
+		{"DurableEpoch", testDurableEpoch},
+		{"CopySemantics", testCopySemantics},
+		{"AgentTimestamps", testAgentTimestamps},
+		{"RestoredCreatedAt", testRestoredCreatedAt},
+		{"QueryAgents", testQueryAgents},
+		{"QueryStates", testQueryStates},
+		{"QueryJobs", testQueryJobs},
+		{"QueryAfterRemoval", testQueryAfterRemoval},
+		{"DeleteAgent", testDeleteAgent},
+		{"DeleteState", testDeleteState},
+		{"PurgeJobs", testPurgeJobs},
+		{"StateRevisions", testStateRevisions},
+		{"Outbox", testOutbox},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func testAgentRoundTrip(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	a := &store.Agent{
+		NodeID:        uniq("node"),
+		Hostname:      "host-1",
+		IPAddress:     "10.0.0.1",
+		Port:          8081,
+		Version:       "1.0.0",
+		Status:        "active",
+		Tier:          "premium",
+		LastHeartbeat: time.Now().UTC().Truncate(time.Second),
+		Metadata:      map[string]string{"os": "linux"},
+	}
+	if err := s.UpsertAgent(ctx, tenant, a); err != nil {
+		t.Fatalf("UpsertAgent: %v", err)
+	}
+
+	got, err := s.GetAgent(ctx, tenant, a.NodeID)
+	if err != nil || got == nil {
+		t.Fatalf("GetAgent = %v, %v; want agent", got, err)
+	}
+	if got.TenantID != tenant || got.Hostname != "host-1" || got.Port != 8081 ||
+		got.Tier != "premium" || got.Metadata["os"] != "linux" {
+		t.Errorf("GetAgent returned %+v", got)
+	}
+	if !got.LastHeartbeat.Equal(a.LastHeartbeat) {
+		t.Errorf("LastHeartbeat = %v, want %v", got.LastHeartbeat, a.LastHeartbeat)
+	}
+
+	a.Status = "draining"
+	if err := s.UpsertAgent(ctx, tenant, a); err != nil {
+		t.Fatalf("UpsertAgent (update): %v", err)
+	}
+	got, _ = s.GetAgent(ctx, tenant, a.NodeID)
+	if got == nil || got.Status != "draining" {
+		t.Errorf("after update, agent = %+v; want status draining", got)
+	}
+
+	missing, err := s.GetAgent(ctx, tenant, uniq("missing"))
+	if err != nil || missing != nil {
+		t.Errorf("GetAgent(missing) = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+func testAgentTenantIsolation(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenantA, tenantB := uniq("tenant-a"), uniq("tenant-b")
+	nodeA, nodeB := uniq("node"), uniq("node")
+
+	mustUpsertAgent(t, s, tenantA, nodeA)
+	mustUpsertAgent(t, s, tenantB, nodeB)
+
+	if got, _ := s.GetAgent(ctx, tenantB, nodeA); got != nil {
+		t.Errorf("tenant B read tenant A's agent: %+v", got)
+	}
+
+	listA, err := s.ListAgents(ctx, tenantA)
+	if err != nil {
+		t.Fatalf("ListAgents: %v", err)
+	}
+	if ids := agentIDs(listA); !ids[nodeA] || ids[nodeB] {
+		t.Errorf("ListAgents(A) = %v; want only %s", ids, nodeA)
+	}
+
+	all, err := s.ListAgents(ctx, "")
+	if err != nil {
+		t.Fatalf("ListAgents(\"\"): %v", err)
+	}
+	if ids := agentIDs(all); !ids[nodeA] || !ids[nodeB] {
+		t.Errorf("ListAgents(\"\") missing agents: got %d agents, want both %s and %s", len(all), nodeA, nodeB)
+	}
+}
+
+func testAgentHeartbeat(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+	node := uniq("node")
+	mustUpsertAgent(t, s, tenant, node)
+
+	beat := time.Now().UTC().Add(time.Minute).Truncate(time.Second)
+	if err := s.UpdateAgentHeartbeat(ctx, tenant, node, beat); err != nil {
+		t.Fatalf("UpdateAgentHeartbeat: %v", err)
+	}
+	got, _ := s.GetAgent(ctx, tenant, node)
+	if got == nil || !got.LastHeartbeat.Equal(beat) {
+		t.Errorf("LastHeartbeat = %v, want %v", got, beat)
+	}
+
+	err := s.UpdateAgentHeartbeat(ctx, tenant, uniq("missing"), beat)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("heartbeat for missing agent: err = %v, want ErrNotFound", err)
+	}
+	err = s.UpdateAgentHeartbeat(ctx, uniq("other-tenant"), node, beat)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("heartbeat from other tenant: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testStateVersioning(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	st := newState(tenant)
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	if st.Version != 1 {
+		t.Errorf("Version after create = %d, want 1", st.Version)
+	}
+
+	got, err := s.GetState(ctx, tenant, st.StateID)
+	if err != nil || got == nil {
+		t.Fatalf("GetState = %v, %v", got, err)
+	}
+	if got.Version != 1 || got.CheckCmd != st.CheckCmd || got.TenantID != tenant || got.Status != "pending" ||
+		got.JobTimeoutSeconds != 45 || got.CheckInterval != 300 {
+		t.Errorf("GetState returned %+v", got)
+	}
+
+	st.ApplyCmd = "echo applied-v2"
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState (update): %v", err)
+	}
+	if st.Version != 2 {
+		t.Errorf("Version after update = %d, want 2", st.Version)
+	}
+	got, _ = s.GetState(ctx, tenant, st.StateID)
+	if got == nil || got.Version != 2 || got.ApplyCmd != "echo applied-v2" {
+		t.Errorf("after update, state = %+v", got)
+	}
+
+	missing, err := s.GetState(ctx, tenant, uniq("missing"))
+	if err != nil || missing != nil {
+		t.Errorf("GetState(missing) = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+func testStateUpsertCAS(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	st := newState(tenant)
+	if err := s.UpsertState(store.WithExpectedVersion(ctx, 1), tenant, st); !errors.Is(err, store.ErrVersionConflict) {
+		t.Fatalf("create expecting version 1 = %v, want ErrVersionConflict", err)
+	}
+	if err := s.UpsertState(store.WithExpectedVersion(ctx, 0), tenant, st); err != nil || st.Version != 1 {
+		t.Fatalf("create expecting version 0 = %v, version %d", err, st.Version)
+	}
+
+	stale := *st
+	st.ApplyCmd = "echo applied-v2"
+	if err := s.UpsertState(store.WithExpectedVersion(ctx, 1), tenant, st); err != nil || st.Version != 2 {
+		t.Fatalf("update expecting version 1 = %v, version %d", err, st.Version)
+	}
+	stale.ApplyCmd = "echo lost-update"
+	if err := s.UpsertState(store.WithExpectedVersion(ctx, 1), tenant, &stale); !errors.Is(err, store.ErrVersionConflict) {
+		t.Errorf("stale update = %v, want ErrVersionConflict", err)
+	}
+	if got, _ := s.GetState(ctx, tenant, st.StateID); got == nil || got.Version != 2 || got.ApplyCmd != "echo applied-v2" {
+		t.Errorf("after the stale update, state = %+v", got)
+	}
+	if revs, _ := s.ListStateRevisions(ctx, tenant, st.StateID); len(revs) != 2 {
+		t.Errorf("%d revisions; a rejected upsert left one", len(revs))
+	}
+}
+
+func testStateStatusCAS(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	st := newState(tenant)
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+
+	checked := time.Now().UTC().Truncate(time.Second)
+	if err := s.UpdateStateStatus(ctx, tenant, st.StateID, "compliant", "", checked, st.Version); err != nil {
+		t.Fatalf("UpdateStateStatus: %v", err)
+	}
+	got, _ := s.GetState(ctx, tenant, st.StateID)
+	if got == nil || got.Status != "compliant" || !got.LastChecked.Equal(checked) {
+		t.Fatalf("after status update, state = %+v", got)
+	}
+	if got.Version != st.Version {
+		t.Errorf("status update changed Version %d -> %d", st.Version, got.Version)
+	}
+
+	// A second update at the same version still applies: only spec changes
+	// invalidate a reconciliation in flight.
+	if err := s.UpdateStateStatus(ctx, tenant, st.StateID, "drifted", "exit 1", checked, st.Version); err != nil {
+		t.Fatalf("repeated UpdateStateStatus: %v", err)
+	}
+
+	err := s.UpdateStateStatus(ctx, tenant, st.StateID, "failed", "", checked, st.Version+1)
+	if !errors.Is(err, store.ErrVersionConflict) {
+		t.Errorf("stale expectedVersion: err = %v, want ErrVersionConflict", err)
+	}
+	got, _ = s.GetState(ctx, tenant, st.StateID)
+	if got == nil || got.Status != "drifted" || got.LastError != "exit 1" {
+		t.Errorf("conflicting update was applied: %+v", got)
+	}
+
+	err = s.UpdateStateStatus(ctx, tenant, uniq("missing"), "failed", "", checked, 1)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("missing state: err = %v, want ErrNotFound", err)
+	}
+	err = s.UpdateStateStatus(ctx, uniq("other-tenant"), st.StateID, "failed", "", checked, st.Version)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("other tenant's state: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testStateTenantIsolation(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenantA, tenantB := uniq("tenant-a"), uniq("tenant-b")
+
+	stA := newState(tenantA)
+	stB := newState(tenantB)
+	for tenant, st := range map[string]*store.DesiredState{tenantA: stA, tenantB: stB} {
+		if err := s.UpsertState(ctx, tenant, st); err != nil {
+			t.Fatalf("UpsertState: %v", err)
+		}
+	}
+
+	if got, _ := s.GetState(ctx, tenantB, stA.StateID); got != nil {
+		t.Errorf("tenant B read tenant A's state: %+v", got)
+	}
+	if got, _ := s.GetStateByNode(ctx, tenantB, stA.NodeID); got != nil {
+		t.Errorf("tenant B read tenant A's state by node: %+v", got)
+	}
+	got, err := s.GetStateByNode(ctx, tenantA, stA.NodeID)
+	if err != nil || got == nil || got.StateID != stA.StateID || got.Status != "pending" {
+		t.Errorf("GetStateByNode = %+v, %v; want %s", got, err, stA.StateID)
+	}
+
+	list, err := s.ListStates(ctx, tenantA)
+	if err != nil {
+		t.Fatalf("ListStates: %v", err)
+	}
+	if len(list) != 1 || list[0].StateID != stA.StateID {
+		t.Fatalf("ListStates(A) = %d states; want only %s", len(list), stA.StateID)
+	}
+	if list[0].Status != "pending" || list[0].Version != 1 {
+		t.Errorf("ListStates returned partial state %+v", list[0])
+	}
+
+	n, err := s.CountStatesByStatus(ctx, tenantA, "pending")
+	if err != nil || n != 1 {
+		t.Errorf("CountStatesByStatus(A) = %d, %v; want 1", n, err)
+	}
+}
+
+func testStatesByStatus(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+	const shardCount = 2
+
+	want := map[string]bool{}
+	for i := 0; i < 6; i++ {
+		st := newState(tenant)
+		st.Status = "drifted"
+		if err := s.UpsertState(ctx, tenant, st); err != nil {
+			t.Fatalf("UpsertState: %v", err)
+		}
+		want[st.StateID] = true
+	}
+	other := newState(tenant) // stays "pending"
+	if err := s.UpsertState(ctx, tenant, other); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+
+	seen := map[string]int{}
+	for shard := 0; shard < shardCount; shard++ {
+		states, err := s.ListStatesByStatus(ctx, "drifted", shard, shardCount)
+		if err != nil {
+			t.Fatalf("ListStatesByStatus(shard %d): %v", shard, err)
+		}
+		for _, st := range states {
+			if st.TenantID != tenant {
+				continue // Data from other runs on a shared backend
+			}
+			if st.Status != "drifted" {
+				t.Errorf("ListStatesByStatus returned %s with status %q", st.StateID, st.Status)
+			}
+			seen[st.StateID]++
+		}
+	}
+	for id := range want {
+		if seen[id] != 1 {
+			t.Errorf("state %s appeared in %d shards, want exactly 1", id, seen[id])
+		}
+	}
+	if seen[other.StateID] != 0 {
+		t.Errorf("ListStatesByStatus(drifted) returned a pending state")
+	}
+}
+
+func testJobLifecycle(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	job := newJob(tenant, uniq("node"))
+	if err := s.CreateJob(ctx, tenant, job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	got, err := s.GetJob(ctx, tenant, job.JobID)
+	if err != nil || got == nil {
+		t.Fatalf("GetJob = %v, %v", got, err)
+	}
+	if got.TenantID != tenant || got.Command != job.Command || got.Status != "queued" ||
+		got.StateID != job.StateID || got.TimeoutSeconds != 30 {
+		t.Errorf("GetJob returned %+v", got)
+	}
+
+	if err := s.UpdateJobStatus(ctx, tenant, job.JobID, "running", 0, "", ""); err != nil {
+		t.Fatalf("UpdateJobStatus(running): %v", err)
+	}
+	got, _ = s.GetJob(ctx, tenant, job.JobID)
+	if got == nil || got.Status != "running" || got.StartedAt == nil {
+		t.Errorf("running job = %+v; want StartedAt set", got)
+	}
+
+	if err := s.UpdateJobStatus(ctx, tenant, job.JobID, "completed", 3, "out", "err"); err != nil {
+		t.Fatalf("UpdateJobStatus(completed): %v", err)
+	}
+	got, _ = s.GetJob(ctx, tenant, job.JobID)
+	if got == nil || got.Status != "completed" || got.FinishedAt == nil ||
+		got.ExitCode != 3 || got.Stdout != "out" || got.Stderr != "err" {
+		t.Errorf("completed job = %+v", got)
+	}
+
+	if other, _ := s.GetJob(ctx, uniq("other-tenant"), job.JobID); other != nil {
+		t.Errorf("other tenant read job: %+v", other)
+	}
+	err = s.UpdateJobStatus(ctx, tenant, uniq("missing"), "failed", 1, "", "")
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("missing job: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testJobStatusCAS(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+	queued := store.WithExpectedJobStatus(ctx, "queued")
+
+	job := newJob(tenant, uniq("node"))
+	if err := s.CreateJob(ctx, tenant, job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	if err := s.UpdateJobStatus(ctx, tenant, job.JobID, "running", 0, "", ""); err != nil {
+		t.Fatalf("UpdateJobStatus(running): %v", err)
+	}
+	if err := s.UpdateJobStatus(queued, tenant, job.JobID, "cancelled", 0, "", ""); !errors.Is(err, store.ErrJobStatusConflict) {
+		t.Errorf("cancelling a running job while expecting queued = %v, want ErrJobStatusConflict", err)
+	}
+	if got, _ := s.GetJob(ctx, tenant, job.JobID); got == nil || got.Status != "running" || got.FinishedAt != nil {
+		t.Errorf("after the rejected update, job = %+v", got)
+	}
+
+	other := newJob(tenant, job.NodeID)
+	if err := s.CreateJob(ctx, tenant, other); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	if err := s.UpdateJobStatus(queued, tenant, other.JobID, "cancelled", 0, "", ""); err != nil {
+		t.Errorf("cancelling a queued job = %v", err)
+	}
+	if got, _ := s.GetJob(ctx, tenant, other.JobID); got == nil || got.Status != "cancelled" {
+		t.Errorf("queued job after cancel = %+v", got)
+	}
+	if err := s.UpdateJobStatus(queued, tenant, uniq("missing"), "cancelled", 0, "", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("missing job = %v, want ErrNotFound", err)
+	}
+}
+
+func testCreateFinishedJob(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	created := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	started, finished := created.Add(time.Minute), created.Add(2*time.Minute)
+	job := newJob(tenant, uniq("node"))
+	job.Status, job.ExitCode, job.Stdout = "failed", 2, "out"
+	job.CreatedAt, job.StartedAt, job.FinishedAt = created, &started, &finished
+	if err := s.CreateJob(ctx, tenant, job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	got, err := s.GetJob(ctx, tenant, job.JobID)
+	if err != nil || got == nil {
+		t.Fatalf("GetJob = %v, %v", got, err)
+	}
+	if got.Status != "failed" || got.ExitCode != 2 || got.Stdout != "out" || !got.CreatedAt.Equal(created) ||
+		got.StartedAt == nil || !got.StartedAt.Equal(started) ||
+		got.FinishedAt == nil || !got.FinishedAt.Equal(finished) {
+		t.Errorf("GetJob returned %+v; want the job as created", got)
+	}
+}
+
+func testJobOrdering(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+	node, otherNode := uniq("node"), uniq("node")
+
+	base := time.Now().UTC().Truncate(time.Second)
+	var ids []string // Oldest first
+	for i := 0; i < 4; i++ {
+		job := newJob(tenant, node)
+		job.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		if err := s.CreateJob(ctx, tenant, job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+		ids = append(ids, job.JobID)
+	}
+	stray := newJob(tenant, otherNode)
+	stray.CreatedAt = base.Add(-time.Minute)
+	if err := s.CreateJob(ctx, tenant, stray); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	// Same node, different tenant: must not leak into ListJobs
+	foreign := newJob(uniq("other-tenant"), node)
+	if err := s.CreateJob(ctx, foreign.TenantID, foreign); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	jobs, err := s.ListJobs(ctx, tenant, node, 2)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if got := jobIDs(jobs); !equal(got, []string{ids[3], ids[2]}) {
+		t.Errorf("ListJobs(limit 2) = %v, want %v", got, []string{ids[3], ids[2]})
+	}
+
+	jobs, err = s.ListJobs(ctx, tenant, node, 0)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if got := jobIDs(jobs); !equal(got, []string{ids[3], ids[2], ids[1], ids[0]}) {
+		t.Errorf("ListJobs(no limit) = %v", got)
+	}
+
+	jobs, err = s.ListJobsByTenant(ctx, tenant, 0)
+	if err != nil {
+		t.Fatalf("ListJobsByTenant: %v", err)
+	}
+	if got := jobIDs(jobs); !equal(got, []string{ids[3], ids[2], ids[1], ids[0], stray.JobID}) {
+		t.Errorf("ListJobsByTenant = %v", got)
+	}
+
+	jobs, err = s.ListJobsByTenant(ctx, tenant, 1)
+	if err != nil {
+		t.Fatalf("ListJobsByTenant: %v", err)
+	}
+	if got := jobIDs(jobs); !equal(got, []string{ids[3]}) {
+		t.Errorf("ListJobsByTenant(limit 1) = %v, want [%s]", got, ids[3])
+	}
+}
+
+func testClaimNextJob(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+	node := uniq("node")
+
+	base := time.Now().UTC().Truncate(time.Second)
+	older, newer := newJob(tenant, node), newJob(tenant, node)
+	older.CreatedAt = base
+	newer.CreatedAt = base.Add(time.Second)
+	for _, job := range []*store.Job{newer, older} {
+		if err := s.CreateJob(ctx, tenant, job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+
+	if got, _ := s.ClaimNextJob(ctx, uniq("other-tenant"), node); got != nil {
+		t.Errorf("other tenant claimed %s", got.JobID)
+	}
+
+	for _, want := range []string{older.JobID, newer.JobID} {
+		got, err := s.ClaimNextJob(ctx, tenant, node)
+		if err != nil || got == nil {
+			t.Fatalf("ClaimNextJob = %v, %v; want %s", got, err, want)
+		}
+		if got.JobID != want || got.Status != "running" {
+			t.Errorf("ClaimNextJob = %s (%s), want %s (running)", got.JobID, got.Status, want)
+		}
+	}
+
+	got, err := s.ClaimNextJob(ctx, tenant, node)
+	if err != nil || got != nil {
+		t.Errorf("ClaimNextJob on empty queue = %v, %v; want nil, nil", got, err)
+	}
+	stored, _ := s.GetJob(ctx, tenant, older.JobID)
+	if stored == nil || stored.Status != "running" || stored.StartedAt == nil {
+		t.Errorf("claimed job in store = %+v; want running with StartedAt", stored)
+	}
+}
+
+func testListTenants(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	agentTenant, stateTenant, jobTenant := uniq("tenant"), uniq("tenant"), uniq("tenant")
+
+	mustUpsertAgent(t, s, agentTenant, uniq("node"))
+	if err := s.UpsertState(ctx, stateTenant, newState(stateTenant)); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	if err := s.CreateJob(ctx, jobTenant, newJob(jobTenant, uniq("node"))); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	tenants, err := s.ListTenants(ctx)
+	if err != nil {
+		t.Fatalf("ListTenants: %v", err)
+	}
+	if !sort.StringsAreSorted(tenants) {
+		t.Errorf("ListTenants = %v; want sorted", tenants)
+	}
+	found := make(map[string]bool)
+	for _, tid := range tenants {
+		found[tid] = true
+	}
+	for _, tid := range []string{agentTenant, stateTenant, jobTenant} {
+		if !found[tid] {
+			t.Errorf("ListTenants is missing %s", tid)
+		}
+	}
+}
+
+func testDurableEpoch(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	resource := uniq("epoch")
+
+	if got, err := s.GetDurableEpoch(ctx, resource); err != nil || got != 0 {
+		t.Errorf("GetDurableEpoch(unknown) = %d, %v; want 0", got, err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		got, err := s.IncrementDurableEpoch(ctx, resource)
+		if err != nil || got != want {
+			t.Fatalf("IncrementDurableEpoch = %d, %v; want %d", got, err, want)
+		}
+	}
+	if got, err := s.GetDurableEpoch(ctx, resource); err != nil || got != 3 {
+		t.Errorf("GetDurableEpoch = %d, %v; want 3", got, err)
+	}
+}
+
+// testCopySemantics checks that callers cannot mutate stored records
+// through pointers passed in or handed out.
+func testCopySemantics(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tenant := uniq("tenant")
+
+	node := uniq("node")
+	a := mustUpsertAgent(t, s, tenant, node)
+	a.Status = "mutated"
+	if got, _ := s.GetAgent(ctx, tenant, node); got == nil || got.Status != "active" {
+		t.Errorf("mutating the upserted agent changed the store: %+v", got)
+	}
+	got, _ := s.GetAgent(ctx, tenant, node)
+	got.Status = "mutated"
+	if again, _ := s.GetAgent(ctx, tenant, node); again == nil || again.Status != "active" {
+		t.Errorf("mutating a returned agent changed the store: %+v", again)
+	}
+
+	st := newState(tenant)
+	if err := s.UpsertState(ctx, tenant, st); err != nil {
+		t.Fatalf("UpsertState: %v", err)
+	}
+	st.CheckCmd = "mutated"
+	if stored, _ := s.GetState(ctx, tenant, st.StateID); stored == nil || stored.CheckCmd == "mutated" {
+		t.Errorf("mutating the upserted state changed the store: %+v", stored)
+	}
+
+	job := newJob(tenant, node)
+	if err := s.CreateJob(ctx, tenant, job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	job.Command = "mutated"
+	if stored, _ := s.GetJob(ctx, tenant, job.JobID); stored == nil || stored.Command == "mutated" {
+		t.Errorf("mutating the created job changed the store: %+v", stored)
+	}
+}
+
+func mustUpsertAgent(t *testing.T, s store.Store, tenant, node string) *store.Agent {
+	t.Helper()
+	a := &store.Agent{
+		NodeID:        node,
+		Hostname:      node,
+		IPAddress:     "127.0.0.1",
+		Port:          8081,
+		Status:        "active",
+		LastHeartbeat: time.Now().UTC().Truncate(time.Second),
+		Metadata:      map[string]string{},
+	}
+	if err := s.UpsertAgent(context.Background(), tenant, a); err != nil {
+		t.Fatalf("UpsertAgent: %v", err)
+	}
+	return a
+}
+
+func newState(tenant string) *store.DesiredState {
+	return &store.DesiredState{
+		StateID:         uniq("state"),
+		NodeID:          uniq("node"),
+		TenantID:        tenant,
+		CheckCmd:        "test -f /tmp/ok",
+		ApplyCmd:        "touch /tmp/ok",
+		DesiredExitCode: 0,
+		Status:          "pending",
+
+		JobTimeoutSeconds: 45,
+		CheckInterval:     300,
+	}
+}
+
+func newJob(tenant, node string) *store.Job {
+	return &store.Job{
+		JobID:          uniq("job"),
+		NodeID:         node,
+		TenantID:       tenant,
+		StateID:        uniq("state"),
+		Command:        "echo hello",
+		Status:         "queued",
+		TimeoutSeconds: 30,
+	}
+}
+
+func agentIDs(agents []*store.Agent) map[string]bool {
+	ids := make(map[string]bool, len(agents))
+	for _, a := range agents {
+		ids[a.NodeID] = true
+	}
+	return ids
+}
+
+func jobIDs(jobs []*store.Job) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		ids = append(ids, j.JobID)
+	}
+	return ids
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Tombstone records a deleted agent or state, so readers that arrive late
+// (API clients, an agent that is still heartbeating) can tell "deleted"
+// from "never existed". Upserting the record again clears its tombstone;
+// PurgeTombstones drops old ones.
+type Tombstone struct {
+	Resource  Resource        `json:"resource"`
+	TenantID  string          `json:"tenant_id"`
+	ID        string          `json:"id"`
+	DeletedAt time.Time       `json:"deleted_at"`
+	Record    json.RawMessage `json:"record"` // The record as it was when deleted
+}
+
+// TombstoneKey is where a tombstone lives in key-value stores. It is kept
+// outside fluxforge:tenants: so tenant scans never see it.
+// Format: fluxforge:tombstones:{resource}:{tenantID}:{id}
+func TombstoneKey(tenantID string, resource Resource, id string) string {
+	return fmt.Sprintf("fluxforge:tombstones:%s:%s:%s", resource, tenantID, id)
+}
+
+func newTombstone(resource Resource, tenantID, id string, record interface{}) (*Tombstone, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return &Tombstone{
+		Resource:  resource,
+		TenantID:  tenantID,
+		ID:        id,
+		DeletedAt: time.Now(),
+		Record:    data,
+	}, nil
+}
+
+// JobPurge selects the jobs PurgeJobs deletes. Only finished jobs (with a
+// FinishedAt) are purged; queued and running jobs never are.
+type JobPurge struct {
+	NodeID         string    // "" = every node
+	StateID        string    // "" = every state
+	FinishedBefore time.Time // Zero = regardless of age
+}
+
+// Matches reports whether PurgeJobs would delete j.
+func (p JobPurge) Matches(j *Job) bool {
+	return j.FinishedAt != nil &&
+		(p.NodeID == "" || j.NodeID == p.NodeID) &&
+		(p.StateID == "" || j.StateID == p.StateID) &&
+		(p.FinishedBefore.IsZero() || j.FinishedAt.Before(p.FinishedBefore))
+}
+
+type expectedJobStatusKey struct{}
+
+// WithExpectedJobStatus returns a context whose UpdateJobStatus calls only
+// apply to a job still in status, and otherwise return
+// ErrJobStatusConflict, e.g. to cancel a job only while it is queued.
+func WithExpectedJobStatus(ctx context.Context, status string) context.Context {
+	return context.WithValue(ctx, expectedJobStatusKey{}, status)
+}
+
+// ExpectedJobStatusFromContext returns the status set by
+// WithExpectedJobStatus, if any.
+func ExpectedJobStatusFromContext(ctx context.Context) (string, bool) {
+	status, ok := ctx.Value(expectedJobStatusKey{}).(string)
+	return status, ok
+}
+
+// checkExpectedJobStatus enforces WithExpectedJobStatus against the
+// status the job is in.
+func checkExpectedJobStatus(ctx context.Context, jobID, current string) error {
+	if want, ok := ExpectedJobStatusFromContext(ctx); ok && want != current {
+		return fmt.Errorf("job %s is %s, expected %s: %w", jobID, current, want, ErrJobStatusConflict)
+	}
+	return nil
+}
+
+// jobFinished reports whether status ends a job: UpdateJobStatus records
+// the result and FinishedAt for these.
+func jobFinished(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
@@ -27,7 +27,7 @@ type Job struct {
 	TenantID   string     `json:"tenant_id" db:"tenant_id"` // Multi-tenancy
 	StateID    string     `json:"state_id" db:"state_id"`
 	Command    string     `json:"command" db:"command"`
-	Status     string     `json:"status" db:"status"` // "queued", "running", "completed", "failed"
+	Status     string     `json:"status" db:"status"` // "queued", "running", "completed", "failed", "cancelled"
 	ExitCode   int        `json:"exit_code" db:"exit_code"`
 	Stdout     string     `json:"stdout" db:"stdout"`
 	Stderr     string     `json:"stderr" db:"stderr"`
@@ -35,6 +35,8 @@ type Job struct {
 	StartedAt  *time.Time `json:"started_at" db:"started_at"`
 	FinishedAt *time.Time `json:"finished_at" db:"finished_at"`
 	TraceID    string     `json:"trace_id" db:"trace_id"`
+	// TimeoutSeconds bounds execution on the agent and the reconciler's wait (0 = default)
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" db:"timeout_seconds"`
 }
 
 // DesiredState represents a target configuration for a node.
@@ -47,10 +49,15 @@ type DesiredState struct {
 	DesiredExitCode int       `json:"desired_exit_code" db:"desired_exit_code"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
-	Version         int       `json:"version" db:"version"`
-	Status          string    `json:"status" db:"status"` // "compliant", "drifted", "failed"
+	Version         int       `json:"version" db:"version"` // Assigned by UpsertState (1, 2, ...); status updates keep it
+	Status          string    `json:"status" db:"status"`   // "compliant", "drifted", "failed"
 	LastChecked     time.Time `json:"last_checked" db:"last_checked"`
 	LastError       string    `json:"last_error" db:"last_error"`
+	// JobTimeoutSeconds bounds each check/apply job (0 = reconciler default)
+	JobTimeoutSeconds int `json:"job_timeout_seconds,omitempty" db:"job_timeout_seconds"`
+	// CheckInterval re-checks the state for drift once LastChecked is
+	// this many seconds old (0 = scheduler default, negative = never)
+	CheckInterval int `json:"check_interval,omitempty" db:"check_interval"`
 }
 
 // TimelineEvent represents an audit log entry.
@@ -0,0 +1,161 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Publish and Subscribe after Close.
+var ErrClosed = errors.New("streaming: closed")
+
+// DefaultBusBuffer is how many events a Bus subscription holds before
+// Publish waits for its handler.
+const DefaultBusBuffer = 256
+
+// Bus is an in-process Publisher and Subscriber for single-node
+// deployments. Each subscription runs its handler on its own goroutine, in
+// publish order; a subscription whose buffer is full makes Publish wait,
+// up to the publish context's deadline, rather than drop the event.
+// Nothing is persisted: events published with no subscriber are gone.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*busSubscription]struct{}
+	closed bool
+	buffer int
+}
+
+var (
+	_ Publisher  = (*Bus)(nil)
+	_ Subscriber = (*Bus)(nil)
+)
+
+func NewBus() *Bus {
+	return &Bus{
+		subs:   make(map[*busSubscription]struct{}),
+		buffer: DefaultBusBuffer,
+	}
+}
+
+// SetBuffer sets the per-subscription buffer for later subscriptions.
+func (b *Bus) SetBuffer(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > 0 {
+		b.buffer = n
+	}
+}
+
+func (b *Bus) Publish(ctx context.Context, topic string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	event := Event{
+		ID:        eventID(ctx, data),
+		Topic:     topic,
+		Payload:   data,
+		Timestamp: time.Now(),
+		Source:    "control-plane",
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+	for sub := range b.subs {
+		if !MatchTopic(sub.pattern, topic) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe calls handler for every event whose topic matches the
+// pattern (see MatchTopic) published after it returns.
+func (b *Bus) Subscribe(topic string, handler func(event Event)) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	sub := &busSubscription{
+		bus:     b,
+		pattern: topic,
+		events:  make(chan Event, b.buffer),
+		done:    make(chan struct{}),
+	}
+	b.subs[sub] = struct{}{}
+	go sub.run(handler)
+	return sub, nil
+}
+
+// Close ends every subscription. Events still buffered are dropped.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for sub := range b.subs {
+		sub.stop()
+		delete(b.subs, sub)
+	}
+	return nil
+}
+
+type busSubscription struct {
+	bus     *Bus
+	pattern string
+	events  chan Event
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (s *busSubscription) run(handler func(Event)) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-s.events:
+			deliver(s.pattern, handler, event)
+		}
+	}
+}
+
+func (s *busSubscription) stop() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// Unsubscribe stops deliveries. It may be called from the handler; an
+// event already being handled finishes.
+func (s *busSubscription) Unsubscribe() error {
+	s.stop()
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+	return nil
+}
+
+// deliver runs handler, reporting whether it returned normally. A
+// panicking handler must not take its subscription down with it.
+func deliver(pattern string, handler func(Event), event Event) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Streaming: handler for %q panicked on event %s: %v", pattern, event.ID, r)
+		}
+	}()
+	handler(event)
+	return true
+}
@@ -0,0 +1,125 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMatchTopic(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"fluxforge.events.state.transition", "fluxforge.events.state.transition", true},
+		{"fluxforge.events.*", "fluxforge.events.state.transition", true},
+		{"fluxforge.events.*.transition", "fluxforge.events.state.transition", true},
+		{"fluxforge.events.*", "fluxforge.audit.login", false},
+		{"fluxforge.events.state", "fluxforge.events.state.transition", false},
+		{"*", "anything.at.all", true},
+	} {
+		if got := MatchTopic(tt.pattern, tt.topic); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+}
+
+// collect subscribes and returns a channel of the events handled.
+func collect(t *testing.T, s Subscriber, pattern string) (<-chan Event, Subscription) {
+	t.Helper()
+	ch := make(chan Event, 16)
+	sub, err := s.Subscribe(pattern, func(e Event) { ch <- e })
+	if err != nil {
+		t.Fatalf("Subscribe(%q): %v", pattern, err)
+	}
+	return ch, sub
+}
+
+func next(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event delivered")
+		return Event{}
+	}
+}
+
+func TestBusDeliversByPattern(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+	defer bus.Close()
+
+	all, _ := collect(t, bus, "fluxforge.events.*")
+	jobs, jobsSub := collect(t, bus, "fluxforge.events.job.*")
+	panicky, err := bus.Subscribe("fluxforge.events.*", func(Event) { panic("boom") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer panicky.Unsubscribe()
+
+	bus.Publish(ctx, "fluxforge.events.state.transition", map[string]string{"state_id": "s1"})
+	bus.Publish(ctx, "fluxforge.events.job.completed", json.RawMessage(`{"job_id":"j1"}`))
+
+	first, second := next(t, all), next(t, all)
+	if first.Topic != "fluxforge.events.state.transition" || second.Topic != "fluxforge.events.job.completed" {
+		t.Fatalf("events out of order: %s, %s", first.Topic, second.Topic)
+	}
+	if string(first.Payload) != `{"state_id":"s1"}` || first.ID == "" || first.ID == second.ID || first.Timestamp.IsZero() {
+		t.Errorf("event = %+v", first)
+	}
+	if e := next(t, jobs); string(e.Payload) != `{"job_id":"j1"}` {
+		t.Errorf("job subscriber got %+v", e)
+	}
+
+	// A panicking handler did not stop the others, and an unsubscribed
+	// one hears nothing more
+	jobsSub.Unsubscribe()
+	bus.Publish(ctx, "fluxforge.events.job.completed", nil)
+	next(t, all)
+	select {
+	case e := <-jobs:
+		t.Errorf("delivered after Unsubscribe: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	bus.Close()
+	if err := bus.Publish(ctx, "fluxforge.events.job.completed", nil); err != ErrClosed {
+		t.Errorf("Publish after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestEventID(t *testing.T) {
+	ctx := context.Background()
+	if got := eventID(WithEventID(ctx, "evt-1"), []byte(`{"event_id":"evt-2"}`)); got != "evt-1" {
+		t.Errorf("with a context ID, eventID = %q", got)
+	}
+	if got := eventID(ctx, []byte(`{"event_id":"evt-2","state_id":"s1"}`)); got != "evt-2" {
+		t.Errorf("with a payload event_id, eventID = %q", got)
+	}
+	for _, data := range []string{`{"state_id":"s1"}`, `[1,2]`, `null`} {
+		if a, b := eventID(ctx, []byte(data)), eventID(ctx, []byte(data)); a == "" || a == b {
+			t.Errorf("eventID(%s) = %q then %q, want fresh IDs", data, a, b)
+		}
+	}
+}
+
+func TestBusPublishWaitsForSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	bus.SetBuffer(1)
+	defer bus.Close()
+
+	release := make(chan struct{})
+	bus.Subscribe("t", func(Event) { <-release })
+	bus.Publish(context.Background(), "t", 1) // Being handled
+	bus.Publish(context.Background(), "t", 2) // Buffered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Publish(ctx, "t", 3); err != context.DeadlineExceeded {
+		t.Errorf("Publish to a full subscriber = %v, want the context's deadline", err)
+	}
+	close(release)
+}
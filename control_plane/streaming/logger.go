@@ -24,7 +24,7 @@ func (p *LogPublisher) Publish(ctx context.Context, topic string, payload interf
 	}
 
 	event := Event{
-		ID:        "log-id-stub", // In real impl, generate UUID
+		ID:        eventID(ctx, data),
 		Topic:     topic,
 		Payload:   data,
 		Timestamp: time.Now(),
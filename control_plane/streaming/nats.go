@@ -0,0 +1,289 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Headers carrying the Event fields that are not the subject or data. The
+// event ID (see WithEventID) travels as Nats-Msg-Id, which JetStream also
+// dedupes on, so republishing an event within the stream's duplicate
+// window stores it once.
+const (
+	natsSourceHeader    = "Fluxforge-Source"
+	natsTimestampHeader = "Fluxforge-Timestamp"
+)
+
+// JetStreamConfig describes the stream a JetStream provisions.
+type JetStreamConfig struct {
+	URL      string        // NATS server URL(s), comma-separated
+	Stream   string        // Stream name (default FLUXFORGE_EVENTS)
+	Subjects []string      // Subjects it captures (default fluxforge.events.>)
+	MaxAge   time.Duration // How long events are kept (0 = until MaxMsgs)
+	MaxMsgs  int64         // Events kept (0 = unbounded)
+	Replicas int           // Stream replicas in a clustered server (default 1)
+
+	// Group names the consuming service: subscriptions with the same
+	// group and topic share one durable consumer, so replicas split the
+	// work and a restarted one resumes where the group left off
+	Group string
+	// AckWait is how long a delivered event may stay unacked before it is
+	// redelivered (default 30s). Set it above the slowest handler.
+	AckWait time.Duration
+}
+
+// JetStream is a Publisher and Subscriber on NATS JetStream. The stream
+// is created, or updated to the config, on connect. Topics are subjects.
+//
+// Publish returns once the server has stored the event. Delivery is at
+// least once: an event is acked after its handler returns and redelivered
+// after AckWait otherwise, and a panicking handler has it redelivered
+// after a delay. Handlers dedupe on Event.ID.
+//
+// The connection retries the initial connect and reconnects forever;
+// publishes fail while it is down, and subscriptions resume on reconnect.
+type JetStream struct {
+	nc     *nats.Conn
+	js     jetstream.JetStream
+	stream string
+	group  string
+
+	ackWait time.Duration
+
+	mu   sync.Mutex
+	subs map[*jetStreamSubscription]struct{}
+}
+
+var (
+	_ Publisher  = (*JetStream)(nil)
+	_ Subscriber = (*JetStream)(nil)
+)
+
+// NewJetStream connects to cfg.URL and provisions the stream.
+func NewJetStream(ctx context.Context, cfg JetStreamConfig) (*JetStream, error) {
+	if cfg.Stream == "" {
+		cfg.Stream = "FLUXFORGE_EVENTS"
+	}
+	if len(cfg.Subjects) == 0 {
+		cfg.Subjects = []string{"fluxforge.events.>"}
+	}
+	if cfg.Replicas <= 0 {
+		cfg.Replicas = 1
+	}
+	if cfg.Group == "" {
+		cfg.Group = "control-plane"
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = 30 * time.Second
+	}
+
+	nc, err := nats.Connect(cfg.URL,
+		nats.Name("fluxforge-control-plane"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Streaming: NATS disconnected: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Printf("Streaming: NATS reconnected to %s", nc.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       cfg.Stream,
+		Subjects:   cfg.Subjects,
+		Storage:    jetstream.FileStorage,
+		Retention:  jetstream.LimitsPolicy,
+		MaxAge:     cfg.MaxAge,
+		MaxMsgs:    maxOrUnlimited(cfg.MaxMsgs),
+		Replicas:   cfg.Replicas,
+		Duplicates: 2 * time.Minute,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("provision stream %s: %w", cfg.Stream, err)
+	}
+
+	return &JetStream{
+		nc:      nc,
+		js:      js,
+		stream:  cfg.Stream,
+		group:   cfg.Group,
+		ackWait: cfg.AckWait,
+		subs:    make(map[*jetStreamSubscription]struct{}),
+	}, nil
+}
+
+func maxOrUnlimited(n int64) int64 {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+func (j *JetStream) Publish(ctx context.Context, topic string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(topic)
+	msg.Data = data
+	msg.Header.Set(jetstream.MsgIDHeader, eventID(ctx, data))
+	msg.Header.Set(natsSourceHeader, "control-plane")
+	msg.Header.Set(natsTimestampHeader, time.Now().Format(time.RFC3339Nano))
+	if _, err := j.js.PublishMsg(ctx, msg); err != nil {
+		return fmt.Errorf("publish %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe handles events whose topic matches the pattern (see
+// MatchTopic) through the group's durable consumer for it. A new
+// consumer starts at the oldest event the stream retains, so events
+// published before the first subscription are replayed.
+func (j *JetStream) Subscribe(topic string, handler func(event Event)) (Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cons, err := j.js.CreateOrUpdateConsumer(ctx, j.stream, jetstream.ConsumerConfig{
+		Durable:       durableName(j.group, topic),
+		FilterSubject: natsFilter(topic),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       j.ackWait,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("consumer for %q: %w", topic, err)
+	}
+
+	sub := &jetStreamSubscription{j: j}
+	sub.cc, err = cons.Consume(func(msg jetstream.Msg) {
+		sub.handle(topic, handler, msg)
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		log.Printf("Streaming: subscription %q: %v", topic, err)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	j.subs[sub] = struct{}{}
+	j.mu.Unlock()
+	return sub, nil
+}
+
+// Close stops every subscription and closes the connection.
+func (j *JetStream) Close() error {
+	j.mu.Lock()
+	subs := make([]*jetStreamSubscription, 0, len(j.subs))
+	for sub := range j.subs {
+		subs = append(subs, sub)
+	}
+	j.mu.Unlock()
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	j.nc.Close()
+	return nil
+}
+
+type jetStreamSubscription struct {
+	j  *JetStream
+	cc jetstream.ConsumeContext
+}
+
+func (s *jetStreamSubscription) handle(pattern string, handler func(Event), msg jetstream.Msg) {
+	// The subject filter is wider than a pattern with mid-topic wildcards
+	if !MatchTopic(pattern, msg.Subject()) {
+		msg.Ack()
+		return
+	}
+	event := Event{
+		ID:      msg.Headers().Get(jetstream.MsgIDHeader),
+		Topic:   msg.Subject(),
+		Payload: msg.Data(),
+		Source:  msg.Headers().Get(natsSourceHeader),
+	}
+	event.Timestamp, _ = time.Parse(time.RFC3339Nano, msg.Headers().Get(natsTimestampHeader))
+	if event.ID == "" {
+		// Published by something else: the stream sequence is unique
+		if meta, err := msg.Metadata(); err == nil {
+			event.ID = fmt.Sprintf("%s-%d", meta.Stream, meta.Sequence.Stream)
+		}
+	}
+
+	if !deliver(pattern, handler, event) {
+		msg.NakWithDelay(time.Second)
+		return
+	}
+	if err := msg.Ack(); err != nil {
+		log.Printf("Streaming: ack event %s: %v", event.ID, err)
+	}
+}
+
+// Unsubscribe stops deliveries and waits for the handler to return. The
+// durable consumer stays on the server, so the group resumes from it.
+// Don't call it from the handler.
+func (s *jetStreamSubscription) Unsubscribe() error {
+	s.cc.Stop()
+	<-s.cc.Closed()
+	s.j.mu.Lock()
+	delete(s.j.subs, s)
+	s.j.mu.Unlock()
+	return nil
+}
+
+// natsFilter converts a topic pattern to the narrowest subject filter
+// that covers it: exact without wildcards, otherwise the tokens before
+// the first wildcard followed by ">". MatchTopic does the rest.
+func natsFilter(pattern string) string {
+	tokens := strings.Split(pattern, ".")
+	for i, t := range tokens {
+		if strings.ContainsAny(t, "*?[") {
+			return strings.Join(append(tokens[:i:i], ">"), ".")
+		}
+	}
+	return pattern
+}
+
+// durableName derives a consumer name from the group and pattern. Names
+// may not contain '.', '*', '>' or whitespace, so those, the other
+// characters patterns use, and '_' itself are escaped as '_' and two hex
+// digits. With '_' only ever starting an escape, the mapping is one to one
+// ("a.b" and "a_b" get different consumers), and "__" can only be the
+// separator between group and pattern.
+func durableName(group, pattern string) string {
+	return escapeDurable(group) + "__" + escapeDurable(pattern)
+}
+
+func escapeDurable(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c <= ' ', c == 0x7f, strings.IndexByte("_.*>?[]/\\", c) >= 0:
+			fmt.Fprintf(&b, "_%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package streaming
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNATSFilter(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"fluxforge.events.state.transition": "fluxforge.events.state.transition",
+		"fluxforge.events.*":                "fluxforge.events.>",
+		"fluxforge.events.*.transition":     "fluxforge.events.>",
+		"fluxforge.events.job*":             "fluxforge.events.>",
+	} {
+		if got := natsFilter(pattern); got != want {
+			t.Errorf("natsFilter(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+	if got := durableName("webhooks", "fluxforge.events.*"); got != "webhooks__fluxforge_2eevents_2e_2a" {
+		t.Errorf("durableName = %q", got)
+	}
+	// Patterns that differ only in escaped characters get their own consumer
+	seen := make(map[string][2]string)
+	for _, gp := range [][2]string{
+		{"webhooks", "a.b"}, {"webhooks", "a_b"}, {"webhooks", "a_2eb"},
+		{"webhooks_a", "b"}, {"webhooks", "a__b"}, {"webhooks__a", "b"},
+	} {
+		name := durableName(gp[0], gp[1])
+		if other, ok := seen[name]; ok && other != gp {
+			t.Errorf("durableName(%q, %q) = durableName(%q, %q) = %q", gp[0], gp[1], other[0], other[1], name)
+		}
+		seen[name] = gp
+		if strings.ContainsAny(name, ".*> \t") {
+			t.Errorf("durableName(%q, %q) = %q, not a valid name", gp[0], gp[1], name)
+		}
+	}
+}
+
+// TestJetStream runs against the JetStream-enabled server at
+// FLUXFORGE_TEST_NATS_URL (e.g. nats-server -js), like the Redis and
+// Postgres conformance runs.
+func TestJetStream(t *testing.T) {
+	url := os.Getenv("FLUXFORGE_TEST_NATS_URL")
+	if url == "" {
+		t.Skip("FLUXFORGE_TEST_NATS_URL not set, skipping JetStream")
+	}
+	testJetStream(t, url)
+}
+
+func testJetStream(t *testing.T, url string) {
+	ctx := context.Background()
+	// Unique names, as the server may be shared
+	suffix := newEventID()[:8]
+	stream := "FLUXFORGE_TEST_" + suffix
+	subject := "fluxforge.test." + suffix
+	cfg := JetStreamConfig{URL: url, Stream: stream, Subjects: []string{subject + ".>"}, MaxAge: time.Hour, Group: "webhooks", AckWait: time.Second}
+
+	pub, err := NewJetStream(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewJetStream: %v", err)
+	}
+	defer pub.Close()
+	defer pub.js.DeleteStream(ctx, stream)
+
+	// Stored before anyone subscribed: replayed to the new consumer
+	if err := pub.Publish(ctx, subject+".state.transition", map[string]string{"state_id": "s1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	sub, err := NewJetStream(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	var attempts atomic.Int32
+	events := make(chan Event, 4)
+	s, err := sub.Subscribe(subject+".*", func(e Event) {
+		// The first delivery crashes: the event comes back
+		if attempts.Add(1) == 1 {
+			panic("handler crashed")
+		}
+		events <- e
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	e := next(t, events)
+	if e.Topic != subject+".state.transition" || string(e.Payload) != `{"state_id":"s1"}` || e.ID == "" || e.Timestamp.IsZero() {
+		t.Fatalf("event = %+v", e)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("handled after %d attempts, want a redelivery", attempts.Load())
+	}
+
+	// A republished event is stored once
+	for i := 0; i < 2; i++ {
+		if err := pub.Publish(WithEventID(ctx, "evt-"+suffix), subject+".state.transition", map[string]string{"state_id": "s2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e := next(t, events); e.ID != "evt-"+suffix {
+		t.Errorf("event ID %q, want the published one", e.ID)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("republished event stored twice: %+v", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// The durable consumer resumes after the acked event
+	s.Unsubscribe()
+	pub.Publish(ctx, subject+".job.completed", map[string]string{"job_id": "j1"})
+	if _, err := sub.Subscribe(subject+".*", func(e Event) { events <- e }); err != nil {
+		t.Fatal(err)
+	}
+	if e := next(t, events); e.Topic != subject+".job.completed" {
+		t.Errorf("resumed at %+v, want the event published while unsubscribed", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("acked event redelivered: %+v", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,361 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis layout:
+//
+//	fluxforge:events:topics            set of every topic published to
+//	fluxforge:events:stream:{topic}    stream per topic; fields id, topic, payload, timestamp, source
+//
+// The id field is the Event ID, which stays the same when an event is
+// republished; entries written without one use the stream entry ID. A
+// subscription reads through a
+// consumer group per stream, so replicas subscribing with the same group
+// share the work: each event is handled once per group, not per replica.
+const (
+	redisTopicsKey    = "fluxforge:events:topics"
+	redisStreamPrefix = "fluxforge:events:stream:"
+)
+
+// Defaults for RedisStreams.
+const (
+	DefaultStreamMaxLen = 100000
+	DefaultClaimIdle    = 30 * time.Second
+)
+
+// RedisStreams is a Publisher and Subscriber on Redis Streams, for
+// deployments with more than one replica.
+//
+// Delivery is at least once. An event is acked after its handler
+// returns; one whose handler panicked, or whose consumer died first, is
+// claimed by a live consumer of the group once it has been pending for
+// the claim idle time, and handled again. Handlers dedupe on Event.ID,
+// which is the publisher's event ID (see WithEventID), not the stream
+// entry ID, so redeliveries and republishes of an event all carry the same one.
+type RedisStreams struct {
+	client   redis.UniversalClient
+	group    string
+	consumer string
+
+	maxLen    int64         // Approximate cap per stream
+	block     time.Duration // XREADGROUP block, bounds Unsubscribe
+	claimIdle time.Duration // Pending time before an event is redelivered
+	refresh   time.Duration // How often wildcard subscriptions look for new topics
+
+	mu   sync.Mutex
+	subs map[*redisSubscription]struct{}
+}
+
+var (
+	_ Publisher  = (*RedisStreams)(nil)
+	_ Subscriber = (*RedisStreams)(nil)
+)
+
+// NewRedisStreams creates a RedisStreams. group names the consuming
+// service, shared by its replicas; consumer names this replica within it.
+// The client is shared and not closed by Close.
+func NewRedisStreams(client redis.UniversalClient, group, consumer string) *RedisStreams {
+	return &RedisStreams{
+		client:    client,
+		group:     group,
+		consumer:  consumer,
+		maxLen:    DefaultStreamMaxLen,
+		block:     2 * time.Second,
+		claimIdle: DefaultClaimIdle,
+		refresh:   5 * time.Second,
+		subs:      make(map[*redisSubscription]struct{}),
+	}
+}
+
+// SetMaxLen caps each topic's stream at about n events (0 = unbounded).
+// Events trimmed before a group reads them are lost to it.
+func (r *RedisStreams) SetMaxLen(n int64) {
+	r.maxLen = n
+}
+
+// SetClaimIdle sets how long an event stays unacked before another
+// consumer of the group takes it over. Set it above the slowest handler.
+func (r *RedisStreams) SetClaimIdle(d time.Duration) {
+	r.claimIdle = d
+}
+
+// SetBlock sets how long one read waits for events, which bounds how long
+// Unsubscribe and Close wait.
+func (r *RedisStreams) SetBlock(d time.Duration) {
+	r.block = d
+}
+
+func (r *RedisStreams) Publish(ctx context.Context, topic string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	args := &redis.XAddArgs{
+		Stream: redisStreamPrefix + topic,
+		Values: map[string]interface{}{
+			"id":        eventID(ctx, data),
+			"topic":     topic,
+			"payload":   string(data),
+			"timestamp": time.Now().Format(time.RFC3339Nano),
+			"source":    "control-plane",
+		},
+	}
+	if r.maxLen > 0 {
+		args.MaxLen = r.maxLen
+		args.Approx = true
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, args)
+		pipe.SAdd(ctx, redisTopicsKey, topic)
+		return nil
+	})
+	return err
+}
+
+// Subscribe handles events on every topic matching the pattern (see
+// MatchTopic) through this RedisStreams' consumer group. A group seeing a
+// stream for the first time starts at its oldest retained event, so
+// nothing published before the subscription is missed.
+func (r *RedisStreams) Subscribe(topic string, handler func(event Event)) (Subscription, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &redisSubscription{
+		r:       r,
+		pattern: topic,
+		handler: handler,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		joined:  make(map[string]bool),
+	}
+	// Join the streams that exist now before returning, so a Publish
+	// right after Subscribe is not read past
+	if err := sub.discover(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.subs[sub] = struct{}{}
+	r.mu.Unlock()
+	go sub.run(ctx)
+	return sub, nil
+}
+
+// Close ends every subscription.
+func (r *RedisStreams) Close() error {
+	r.mu.Lock()
+	subs := make([]*redisSubscription, 0, len(r.subs))
+	for sub := range r.subs {
+		subs = append(subs, sub)
+	}
+	r.mu.Unlock()
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	return nil
+}
+
+type redisSubscription struct {
+	r       *RedisStreams
+	pattern string
+	handler func(Event)
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	joined      map[string]bool // Streams whose group exists, by key
+	streams     []string
+	lastRefresh time.Time
+	lastClaim   time.Time
+}
+
+// Unsubscribe stops reading and waits for the current read to end. The
+// group and its pending events stay in Redis for the next subscriber.
+// Don't call it from the handler.
+func (s *redisSubscription) Unsubscribe() error {
+	s.cancel()
+	<-s.done
+	s.r.mu.Lock()
+	delete(s.r.subs, s)
+	s.r.mu.Unlock()
+	return nil
+}
+
+func (s *redisSubscription) run(ctx context.Context) {
+	defer close(s.done)
+	backoff := time.Second
+	for ctx.Err() == nil {
+		if err := s.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Streaming: subscription %q: %v", s.pattern, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			continue
+		}
+	}
+}
+
+// poll joins new matching streams, redelivers stale pending events, and
+// reads once.
+func (s *redisSubscription) poll(ctx context.Context) error {
+	now := time.Now()
+	if now.Sub(s.lastRefresh) >= s.r.refresh {
+		if err := s.discover(ctx); err != nil {
+			return err
+		}
+	}
+	if len(s.streams) == 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.r.block):
+		}
+		return nil
+	}
+	if now.Sub(s.lastClaim) >= s.r.claimIdle/2 {
+		if err := s.claim(ctx); err != nil {
+			return err
+		}
+		s.lastClaim = now
+	}
+
+	args := make([]string, 0, 2*len(s.streams))
+	args = append(args, s.streams...)
+	for range s.streams {
+		args = append(args, ">")
+	}
+	results, err := s.r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.r.group,
+		Consumer: s.r.consumer,
+		Streams:  args,
+		Count:    100,
+		Block:    s.r.block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		if err := s.handle(ctx, res.Stream, res.Messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discover joins the group on every stream matching the pattern.
+func (s *redisSubscription) discover(ctx context.Context) error {
+	var topics []string
+	if strings.ContainsAny(s.pattern, "*?[") {
+		all, err := s.r.client.SMembers(ctx, redisTopicsKey).Result()
+		if err != nil {
+			return err
+		}
+		for _, t := range all {
+			if MatchTopic(s.pattern, t) {
+				topics = append(topics, t)
+			}
+		}
+	} else {
+		topics = []string{s.pattern}
+	}
+
+	for _, t := range topics {
+		key := redisStreamPrefix + t
+		if s.joined[key] {
+			continue
+		}
+		err := s.r.client.XGroupCreateMkStream(ctx, key, s.r.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("join %s: %w", key, err)
+		}
+		s.joined[key] = true
+		s.streams = append(s.streams, key)
+	}
+	s.lastRefresh = time.Now()
+	return nil
+}
+
+// claim takes over events another consumer (or an earlier, panicked
+// delivery here) left pending for longer than the claim idle time.
+func (s *redisSubscription) claim(ctx context.Context) error {
+	for _, stream := range s.streams {
+		start := "0-0"
+		for {
+			msgs, next, err := s.r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    s.r.group,
+				Consumer: s.r.consumer,
+				MinIdle:  s.r.claimIdle,
+				Start:    start,
+				Count:    100,
+			}).Result()
+			if err != nil {
+				return err
+			}
+			if err := s.handle(ctx, stream, msgs); err != nil {
+				return err
+			}
+			if next == "0-0" || len(msgs) == 0 {
+				break
+			}
+			start = next
+		}
+	}
+	return nil
+}
+
+// handle delivers msgs in order and acks each one its handler returned
+// from. Undecodable entries are acked and skipped: redelivering them
+// would never succeed.
+func (s *redisSubscription) handle(ctx context.Context, stream string, msgs []redis.XMessage) error {
+	for _, msg := range msgs {
+		event, err := decodeStreamEvent(msg)
+		if err != nil {
+			log.Printf("Streaming: dropping undecodable event %s on %s: %v", msg.ID, stream, err)
+		} else if !deliver(s.pattern, s.handler, event) {
+			continue // Left pending, for claim to retry
+		}
+		if err := s.r.client.XAck(ctx, stream, s.r.group, msg.ID).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeStreamEvent(msg redis.XMessage) (Event, error) {
+	field := func(name string) string {
+		v, _ := msg.Values[name].(string)
+		return v
+	}
+	event := Event{
+		ID:      field("id"),
+		Topic:   field("topic"),
+		Payload: []byte(field("payload")),
+		Source:  field("source"),
+	}
+	if event.ID == "" {
+		// Written before entries carried one: the entry ID is unique
+		event.ID = msg.ID
+	}
+	if event.Topic == "" {
+		return event, errors.New("no topic")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, field("timestamp"))
+	if err != nil {
+		return event, fmt.Errorf("timestamp: %w", err)
+	}
+	event.Timestamp = ts
+	return event, nil
+}
@@ -0,0 +1,102 @@
+package streaming
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestStreams(t *testing.T, client redis.UniversalClient, consumer string) *RedisStreams {
+	r := NewRedisStreams(client, "webhooks", consumer)
+	r.SetBlock(20 * time.Millisecond)
+	r.SetClaimIdle(100 * time.Millisecond)
+	r.refresh = 20 * time.Millisecond
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRedisStreamsGroupDelivery(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	pub := newTestStreams(t, client, "publisher")
+	// Published before anyone subscribed: the group starts at the oldest
+	// retained event
+	if err := pub.Publish(ctx, "fluxforge.events.state.transition", map[string]string{"state_id": "s1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestStreams(t, client, "replica-a")
+	events, _ := collect(t, a, "fluxforge.events.*")
+	e := next(t, events)
+	if e.Topic != "fluxforge.events.state.transition" || string(e.Payload) != `{"state_id":"s1"}` || e.Timestamp.IsZero() {
+		t.Fatalf("event = %+v", e)
+	}
+	if n, _ := client.XLen(ctx, redisStreamPrefix+e.Topic).Result(); n != 1 {
+		t.Fatalf("stream length %d", n)
+	}
+	entries, _ := client.XRange(ctx, redisStreamPrefix+e.Topic, "-", "+").Result()
+	if e.ID == "" || e.ID != entries[0].Values["id"] {
+		t.Errorf("event ID %q, want the stored id %v", e.ID, entries[0].Values["id"])
+	}
+
+	// A topic first published after subscribing is picked up, and a
+	// republished event keeps the ID it was given
+	pub.Publish(WithEventID(ctx, "evt-1"), "fluxforge.events.job.completed", map[string]string{"job_id": "j1"})
+	if e := next(t, events); e.Topic != "fluxforge.events.job.completed" || e.ID != "evt-1" {
+		t.Errorf("event = %+v", e)
+	}
+
+	// Entries without an id field fall back to the entry ID
+	entryID, _ := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: redisStreamPrefix + "fluxforge.events.job.completed",
+		Values: map[string]interface{}{"topic": "fluxforge.events.job.completed", "payload": "{}", "timestamp": time.Now().Format(time.RFC3339Nano)},
+	}).Result()
+	if e := next(t, events); e.ID != entryID {
+		t.Errorf("event ID %q, want the stream entry ID %q", e.ID, entryID)
+	}
+
+	// Acked events are not handed to another replica of the group
+	b := newTestStreams(t, client, "replica-b")
+	other, _ := collect(t, b, "fluxforge.events.*")
+	select {
+	case e := <-other:
+		t.Errorf("acked event redelivered: %+v", e)
+	case <-time.After(300 * time.Millisecond):
+	}
+	if pending, _ := client.XPending(ctx, redisStreamPrefix+"fluxforge.events.state.transition", "webhooks").Result(); pending.Count != 0 {
+		t.Errorf("%d events pending after delivery", pending.Count)
+	}
+}
+
+func TestRedisStreamsRedeliversUnacked(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	r := newTestStreams(t, client, "replica-a")
+	var attempts atomic.Int32
+	handled := make(chan Event, 1)
+	_, err := r.Subscribe("fluxforge.events.state.transition", func(e Event) {
+		if attempts.Add(1) == 1 {
+			panic("handler crashed")
+		}
+		handled <- e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Publish(ctx, "fluxforge.events.state.transition", map[string]string{"state_id": "s1"})
+	e := next(t, handled)
+	if attempts.Load() != 2 || string(e.Payload) != `{"state_id":"s1"}` {
+		t.Errorf("handled after %d attempts: %+v", attempts.Load(), e)
+	}
+}
@@ -0,0 +1,59 @@
+package streaming
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"path"
+)
+
+// MatchTopic reports whether topic matches pattern. Patterns are
+// shell-style globs in which '*' also spans dots, so
+// "fluxforge.events.*" matches every event topic and
+// "fluxforge.events.*.transition" every transition. A pattern without
+// metacharacters matches only itself.
+func MatchTopic(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+	// Topics never contain '/', so path.Match's '*' crosses dots
+	ok, err := path.Match(pattern, topic)
+	return err == nil && ok
+}
+
+type eventIDKey struct{}
+
+// WithEventID returns a context under which Publish uses id as the
+// Event ID. A publisher that republishes an event, like the outbox relay,
+// passes the same id each time so consumers see one event, not several.
+func WithEventID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, eventIDKey{}, id)
+}
+
+// eventID picks the ID for an event with the given JSON payload: the one
+// set with WithEventID, else the payload's "event_id" field if it is an
+// object with one, else a new random ID.
+func eventID(ctx context.Context, data []byte) string {
+	if id, _ := ctx.Value(eventIDKey{}).(string); id != "" {
+		return id
+	}
+	var fields struct {
+		EventID string `json:"event_id"`
+	}
+	if json.Unmarshal(data, &fields) == nil && fields.EventID != "" {
+		return fields.EventID
+	}
+	return newEventID()
+}
+
+// newEventID returns a random UUID (version 4) for an Event.
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
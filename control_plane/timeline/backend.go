@@ -0,0 +1,76 @@
+package timeline
+
+import (
+	"context"
+	"time"
+)
+
+// Query selects timeline events. Empty fields match everything.
+type Query struct {
+	ReqID    string
+	StateID  string
+	NodeID   string
+	TenantID string
+	Since    time.Time // Inclusive, zero = unbounded
+	Until    time.Time // Inclusive, zero = unbounded
+	// Limit keeps only the newest N matches (0 = no limit).
+	// Results are always returned oldest first.
+	Limit int
+}
+
+// Matches reports whether the event satisfies every filter in the query.
+func (q Query) Matches(e ReconcileEvent) bool {
+	if q.ReqID != "" && e.ReqID != q.ReqID {
+		return false
+	}
+	if q.StateID != "" && e.StateID() != q.StateID {
+		return false
+	}
+	if q.NodeID != "" && e.NodeID != q.NodeID {
+		return false
+	}
+	if q.TenantID != "" && e.TenantID != q.TenantID {
+		return false
+	}
+	if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Timestamp.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Retention bounds how much history a backend keeps.
+// Both limits apply; zero disables a limit.
+type Retention struct {
+	MaxAge    time.Duration
+	MaxEvents int
+}
+
+// DefaultRetention keeps three days or 100k events, whichever is smaller.
+func DefaultRetention() Retention {
+	return Retention{
+		MaxAge:    72 * time.Hour,
+		MaxEvents: 100000,
+	}
+}
+
+// Backend persists timeline events.
+// IMPORTANT:
+// - Append must be safe for concurrent use (scheduler workers record in parallel)
+// - Query must honour Limit as "newest N", returned oldest first
+type Backend interface {
+	Append(ctx context.Context, e ReconcileEvent) error
+	Query(ctx context.Context, q Query) ([]ReconcileEvent, error)
+	// Prune applies the retention policy and returns the number of events removed.
+	Prune(ctx context.Context, r Retention) (int, error)
+}
+
+// applyLimit trims an oldest-first slice to its newest n entries.
+func applyLimit(events []ReconcileEvent, n int) []ReconcileEvent {
+	if n > 0 && len(events) > n {
+		return events[len(events)-n:]
+	}
+	return events
+}
@@ -0,0 +1,153 @@
+package timeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps events in process with per-field indexes.
+// History is lost on restart; use the Redis or Postgres backend for that.
+type MemoryBackend struct {
+	mu     sync.RWMutex
+	events []ReconcileEvent
+	// index maps "field:value" to positions in events
+	index map[string][]int
+	// maxEvents is enforced on Append so memory stays bounded without Prune
+	maxEvents int
+}
+
+// NewMemoryBackend creates a MemoryBackend capped at maxEvents (0 = unbounded).
+func NewMemoryBackend(maxEvents int) *MemoryBackend {
+	return &MemoryBackend{
+		events:    make([]ReconcileEvent, 0),
+		index:     make(map[string][]int),
+		maxEvents: maxEvents,
+	}
+}
+
+func indexKeys(e ReconcileEvent) []string {
+	keys := make([]string, 0, 4)
+	if e.ReqID != "" {
+		keys = append(keys, "req:"+e.ReqID)
+	}
+	if id := e.StateID(); id != "" {
+		keys = append(keys, "state:"+id)
+	}
+	if e.NodeID != "" {
+		keys = append(keys, "node:"+e.NodeID)
+	}
+	if e.TenantID != "" {
+		keys = append(keys, "tenant:"+e.TenantID)
+	}
+	return keys
+}
+
+func (b *MemoryBackend) Append(ctx context.Context, e ReconcileEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pos := len(b.events)
+	b.events = append(b.events, e)
+	for _, k := range indexKeys(e) {
+		b.index[k] = append(b.index[k], pos)
+	}
+
+	// Compact in batches (10% slack) so trimming stays amortized O(1)
+	if b.maxEvents > 0 && len(b.events) > b.maxEvents+b.maxEvents/10 {
+		b.keepLocked(func(i int, _ ReconcileEvent) bool {
+			return i >= len(b.events)-b.maxEvents
+		})
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Query(ctx context.Context, q Query) ([]ReconcileEvent, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// Use the most selective index the query allows
+	var candidates []int
+	indexed := false
+	for _, k := range queryIndexKeys(q) {
+		positions := b.index[k]
+		if !indexed || len(positions) < len(candidates) {
+			candidates = positions
+			indexed = true
+		}
+	}
+
+	results := make([]ReconcileEvent, 0)
+	if indexed {
+		for _, pos := range candidates {
+			if e := b.events[pos]; q.Matches(e) {
+				results = append(results, e)
+			}
+		}
+	} else {
+		for _, e := range b.events {
+			if q.Matches(e) {
+				results = append(results, e)
+			}
+		}
+	}
+
+	sortByTime(results)
+	return applyLimit(results, q.Limit), nil
+}
+
+func queryIndexKeys(q Query) []string {
+	var keys []string
+	if q.ReqID != "" {
+		keys = append(keys, "req:"+q.ReqID)
+	}
+	if q.StateID != "" {
+		keys = append(keys, "state:"+q.StateID)
+	}
+	if q.NodeID != "" {
+		keys = append(keys, "node:"+q.NodeID)
+	}
+	if q.TenantID != "" {
+		keys = append(keys, "tenant:"+q.TenantID)
+	}
+	return keys
+}
+
+func (b *MemoryBackend) Prune(ctx context.Context, r Retention) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	before := len(b.events)
+	cutoff := time.Time{}
+	if r.MaxAge > 0 {
+		cutoff = time.Now().Add(-r.MaxAge)
+	}
+	b.keepLocked(func(i int, e ReconcileEvent) bool {
+		if !cutoff.IsZero() && e.Timestamp.Before(cutoff) {
+			return false
+		}
+		return r.MaxEvents <= 0 || i >= before-r.MaxEvents
+	})
+	return before - len(b.events), nil
+}
+
+// keepLocked retains events for which keep returns true and rebuilds indexes.
+func (b *MemoryBackend) keepLocked(keep func(i int, e ReconcileEvent) bool) {
+	kept := make([]ReconcileEvent, 0, len(b.events))
+	for i, e := range b.events {
+		if keep(i, e) {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(b.events) {
+		return
+	}
+
+	b.events = kept
+	b.index = make(map[string][]int)
+	for pos, e := range b.events {
+		for _, k := range indexKeys(e) {
+			b.index[k] = append(b.index[k], pos)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackend_IndexedQueries(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend(0)
+	base := time.Now().Add(-time.Hour)
+
+	for i := 0; i < 10; i++ {
+		b.Append(ctx, ReconcileEvent{
+			ReqID:     fmt.Sprintf("req-%d", i%2),
+			Stage:     "QUEUED",
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			NodeID:    fmt.Sprintf("node-%d", i%5),
+			TenantID:  "t1",
+			Metadata:  map[string]string{"state_id": fmt.Sprintf("state-%d", i%3)},
+		})
+	}
+
+	cases := []struct {
+		name string
+		q    Query
+		want int
+	}{
+		{"req_id", Query{ReqID: "req-0"}, 5},
+		{"state_id", Query{StateID: "state-0"}, 4},
+		{"node_id", Query{NodeID: "node-1"}, 2},
+		{"tenant", Query{TenantID: "t1"}, 10},
+		{"other tenant", Query{TenantID: "t2"}, 0},
+		{"combined", Query{ReqID: "req-0", StateID: "state-0"}, 2},
+		{"time range", Query{Since: base.Add(2 * time.Minute), Until: base.Add(4 * time.Minute)}, 3},
+		{"limit", Query{TenantID: "t1", Limit: 3}, 3},
+	}
+	for _, tc := range cases {
+		got, err := b.Query(ctx, tc.q)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(got) != tc.want {
+			t.Errorf("%s: expected %d events, got %d", tc.name, tc.want, len(got))
+		}
+	}
+
+	// Limit keeps the newest, returned oldest first
+	got, _ := b.Query(ctx, Query{Limit: 2})
+	if !got[0].Timestamp.Equal(base.Add(8*time.Minute)) || !got[1].Timestamp.Equal(base.Add(9*time.Minute)) {
+		t.Errorf("limit did not keep the newest events in order: %v, %v", got[0].Timestamp, got[1].Timestamp)
+	}
+}
+
+func TestMemoryBackend_Retention(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend(0)
+	now := time.Now()
+
+	b.Append(ctx, ReconcileEvent{ReqID: "old", Stage: "QUEUED", Timestamp: now.Add(-2 * time.Hour)})
+	for i := 0; i < 5; i++ {
+		b.Append(ctx, ReconcileEvent{ReqID: fmt.Sprintf("new-%d", i), Stage: "QUEUED", Timestamp: now})
+	}
+
+	removed, err := b.Prune(ctx, Retention{MaxAge: time.Hour, MaxEvents: 3})
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("expected 3 events pruned, got %d", removed)
+	}
+
+	if got, _ := b.Query(ctx, Query{ReqID: "old"}); len(got) != 0 {
+		t.Errorf("expired event still indexed")
+	}
+	if got, _ := b.Query(ctx, Query{ReqID: "new-4"}); len(got) != 1 {
+		t.Errorf("expected newest event to survive, got %d", len(got))
+	}
+}
+
+func TestMemoryBackend_CapOnAppend(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend(100)
+
+	for i := 0; i < 1000; i++ {
+		b.Append(ctx, ReconcileEvent{ReqID: fmt.Sprintf("req-%d", i), Stage: "QUEUED", Timestamp: time.Now()})
+	}
+
+	all, _ := b.Query(ctx, Query{})
+	if len(all) > 110 {
+		t.Errorf("memory backend not bounded: %d events", len(all))
+	}
+	if got, _ := b.Query(ctx, Query{ReqID: "req-999"}); len(got) != 1 {
+		t.Errorf("latest event missing after compaction")
+	}
+}
@@ -0,0 +1,122 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresBackend stores the timeline in the timeline_events table, which
+// the store's Postgres migrations create.
+type PostgresBackend struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresBackend creates a PostgresBackend.
+func NewPostgresBackend(pool *pgxpool.Pool) *PostgresBackend {
+	return &PostgresBackend{pool: pool}
+}
+
+func (b *PostgresBackend) Append(ctx context.Context, e ReconcileEvent) error {
+	meta, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	_, err = b.pool.Exec(ctx, `
+		INSERT INTO timeline_events (req_id, stage, ts, node_id, tenant_id, state_id, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, e.ReqID, e.Stage, e.Timestamp, e.NodeID, e.TenantID, e.StateID(), meta)
+	return err
+}
+
+func (b *PostgresBackend) Query(ctx context.Context, q Query) ([]ReconcileEvent, error) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if q.ReqID != "" {
+		add("req_id = $%d", q.ReqID)
+	}
+	if q.StateID != "" {
+		add("state_id = $%d", q.StateID)
+	}
+	if q.NodeID != "" {
+		add("node_id = $%d", q.NodeID)
+	}
+	if q.TenantID != "" {
+		add("tenant_id = $%d", q.TenantID)
+	}
+	if !q.Since.IsZero() {
+		add("ts >= $%d", q.Since)
+	}
+	if !q.Until.IsZero() {
+		add("ts <= $%d", q.Until)
+	}
+
+	query := `SELECT req_id, stage, ts, node_id, tenant_id, metadata FROM timeline_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Newest first so LIMIT keeps the newest N; reversed below
+	query += " ORDER BY ts DESC, id DESC"
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
+	rows, err := b.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]ReconcileEvent, 0)
+	for rows.Next() {
+		var e ReconcileEvent
+		var meta []byte
+		if err := rows.Scan(&e.ReqID, &e.Stage, &e.Timestamp, &e.NodeID, &e.TenantID, &meta); err != nil {
+			return nil, err
+		}
+		if len(meta) > 0 {
+			json.Unmarshal(meta, &e.Metadata)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+func (b *PostgresBackend) Prune(ctx context.Context, r Retention) (int, error) {
+	removed := 0
+	if r.MaxAge > 0 {
+		tag, err := b.pool.Exec(ctx, `DELETE FROM timeline_events WHERE ts < $1`, time.Now().Add(-r.MaxAge))
+		if err != nil {
+			return removed, err
+		}
+		removed += int(tag.RowsAffected())
+	}
+	if r.MaxEvents > 0 {
+		tag, err := b.pool.Exec(ctx, `
+			DELETE FROM timeline_events WHERE id <= (
+				SELECT id FROM timeline_events ORDER BY id DESC OFFSET $1 LIMIT 1
+			)
+		`, r.MaxEvents)
+		if err != nil {
+			return removed, err
+		}
+		removed += int(tag.RowsAffected())
+	}
+	return removed, nil
+}
@@ -0,0 +1,229 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis layout:
+//
+//	fluxforge:timeline                       stream, one entry per event ("event" field = JSON)
+//	fluxforge:timeline:idx:{field}:{value}   sorted set of stream IDs scored by event time (ms)
+//
+// Index sets expire with the retention window; members pointing at
+// trimmed stream entries are skipped at query time.
+const (
+	redisStreamKey   = "fluxforge:timeline"
+	redisIndexPrefix = "fluxforge:timeline:idx:"
+)
+
+// appendScript adds the event and its index entries atomically.
+// KEYS[1] = stream, KEYS[2..] = index sets
+// ARGV[1] = event JSON, ARGV[2] = score (ms), ARGV[3] = index TTL (s, 0 = none)
+var appendScript = redis.NewScript(`
+local id = redis.call('XADD', KEYS[1], '*', 'event', ARGV[1])
+local ttl = tonumber(ARGV[3])
+for i = 2, #KEYS do
+	redis.call('ZADD', KEYS[i], ARGV[2], id)
+	if ttl > 0 then
+		redis.call('EXPIRE', KEYS[i], ttl)
+	end
+end
+return id
+`)
+
+// RedisBackend stores the timeline in a Redis stream so it survives
+// restarts and is shared by every replica (incidents after failover).
+// It needs a single Redis node, like store.RedisStore: appendScript writes
+// the stream and its index sets together, and Redis Cluster rejects a
+// script whose keys span hash slots.
+type RedisBackend struct {
+	client   redis.UniversalClient
+	indexTTL time.Duration
+}
+
+// NewRedisBackend creates a RedisBackend. indexTTL should match the
+// retention MaxAge so index sets do not outlive the stream entries.
+func NewRedisBackend(client redis.UniversalClient, indexTTL time.Duration) *RedisBackend {
+	return &RedisBackend{client: client, indexTTL: indexTTL}
+}
+
+func (b *RedisBackend) Append(ctx context.Context, e ReconcileEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	keys := []string{redisStreamKey}
+	for _, k := range indexKeys(e) {
+		keys = append(keys, redisIndexPrefix+k)
+	}
+
+	return appendScript.Run(ctx, b.client, keys,
+		string(data), e.Timestamp.UnixMilli(), int64(b.indexTTL/time.Second),
+	).Err()
+}
+
+func (b *RedisBackend) Query(ctx context.Context, q Query) ([]ReconcileEvent, error) {
+	keys := queryIndexKeys(q)
+	if len(keys) == 0 {
+		return b.scanStream(ctx, q)
+	}
+
+	// Pick the smallest index set, then filter the rest in process
+	best := ""
+	var bestCard int64 = -1
+	for _, k := range keys {
+		card, err := b.client.ZCard(ctx, redisIndexPrefix+k).Result()
+		if err != nil {
+			return nil, err
+		}
+		if bestCard < 0 || card < bestCard {
+			best, bestCard = k, card
+		}
+	}
+	if bestCard == 0 {
+		return []ReconcileEvent{}, nil
+	}
+
+	rangeBy := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if !q.Since.IsZero() {
+		rangeBy.Min = strconv.FormatInt(q.Since.UnixMilli(), 10)
+	}
+	if !q.Until.IsZero() {
+		rangeBy.Max = strconv.FormatInt(q.Until.UnixMilli(), 10)
+	}
+	// With a single filter the index alone is exact, so push Limit to Redis
+	var ids []string
+	var err error
+	if len(keys) == 1 && q.Limit > 0 {
+		rangeBy.Count = int64(q.Limit)
+		ids, err = b.client.ZRevRangeByScore(ctx, redisIndexPrefix+best, rangeBy).Result()
+	} else {
+		ids, err = b.client.ZRangeByScore(ctx, redisIndexPrefix+best, rangeBy).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b.fetch(ctx, ids, q)
+}
+
+// fetch loads stream entries by ID, skipping ones already trimmed.
+func (b *RedisBackend) fetch(ctx context.Context, ids []string, q Query) ([]ReconcileEvent, error) {
+	pipe := b.client.Pipeline()
+	cmds := make([]*redis.XMessageSliceCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.XRange(ctx, redisStreamKey, id, id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	events := make([]ReconcileEvent, 0, len(ids))
+	for _, cmd := range cmds {
+		msgs, err := cmd.Result()
+		if err != nil || len(msgs) == 0 {
+			continue
+		}
+		if e, ok := decodeMessage(msgs[0]); ok && q.Matches(e) {
+			events = append(events, e)
+		}
+	}
+	sortByTime(events)
+	return applyLimit(events, q.Limit), nil
+}
+
+// scanStream serves unindexed queries. Stream IDs are insertion
+// milliseconds, so the time range maps directly onto XRANGE bounds.
+func (b *RedisBackend) scanStream(ctx context.Context, q Query) ([]ReconcileEvent, error) {
+	start, end := "-", "+"
+	if !q.Since.IsZero() {
+		start = strconv.FormatInt(q.Since.UnixMilli(), 10)
+	}
+	if !q.Until.IsZero() {
+		end = strconv.FormatInt(q.Until.UnixMilli(), 10)
+	}
+
+	var msgs []redis.XMessage
+	var err error
+	if q.Limit > 0 {
+		msgs, err = b.client.XRevRangeN(ctx, redisStreamKey, end, start, int64(q.Limit)).Result()
+	} else {
+		msgs, err = b.client.XRange(ctx, redisStreamKey, start, end).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]ReconcileEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		if e, ok := decodeMessage(msg); ok && q.Matches(e) {
+			events = append(events, e)
+		}
+	}
+	sortByTime(events)
+	return applyLimit(events, q.Limit), nil
+}
+
+func decodeMessage(msg redis.XMessage) (ReconcileEvent, bool) {
+	raw, ok := msg.Values["event"].(string)
+	if !ok {
+		return ReconcileEvent{}, false
+	}
+	var e ReconcileEvent
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		return ReconcileEvent{}, false
+	}
+	return e, true
+}
+
+func (b *RedisBackend) Prune(ctx context.Context, r Retention) (int, error) {
+	removed := 0
+	if r.MaxAge > 0 {
+		minID := strconv.FormatInt(time.Now().Add(-r.MaxAge).UnixMilli(), 10)
+		n, err := b.client.XTrimMinID(ctx, redisStreamKey, minID).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+	if r.MaxEvents > 0 {
+		n, err := b.client.XTrimMaxLen(ctx, redisStreamKey, int64(r.MaxEvents)).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+
+	return removed, b.pruneIndexes(ctx)
+}
+
+// pruneIndexes drops index members older than the oldest surviving stream
+// entry. Busy index sets (e.g. a tenant) are refreshed on every append and
+// would otherwise never expire.
+func (b *RedisBackend) pruneIndexes(ctx context.Context) error {
+	oldest, err := b.client.XRangeN(ctx, redisStreamKey, "-", "+", 1).Result()
+	if err != nil {
+		return err
+	}
+	max := "+inf" // Stream empty: everything is stale
+	if len(oldest) > 0 {
+		ms, _, _ := strings.Cut(oldest[0].ID, "-")
+		max = "(" + ms
+	}
+
+	iter := b.client.Scan(ctx, 0, redisIndexPrefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		if err := b.client.ZRemRangeByScore(ctx, iter.Val(), "-inf", max).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
@@ -1,7 +1,11 @@
 package timeline
 
 import (
+	"context"
+	"log"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,58 +18,188 @@ type ReconcileEvent struct {
 	Metadata  map[string]string `json:"metadata,omitempty"`
 }
 
+// StateID returns the state the event belongs to (Metadata["state_id"]).
+func (e ReconcileEvent) StateID() string {
+	if e.Metadata == nil {
+		return ""
+	}
+	return e.Metadata["state_id"]
+}
+
+// recordTimeout bounds each write, so a slow backend delays the writer,
+// not the events queued behind it for long.
+const recordTimeout = 500 * time.Millisecond
+
+// recordBuffer is how many events Record queues for the writer. When the
+// backend falls that far behind, further events are dropped.
+const recordBuffer = 4096
+
+// Store is the timeline used by the scheduler. It delegates persistence
+// to a Backend (in-memory by default) and applies retention.
+//
+// Record only queues the event: a single writer appends queued events to
+// the backend in order, off the scheduler's dispatch path. Query waits for
+// the events queued before it, so a caller reads what it recorded.
 type Store struct {
-	events []ReconcileEvent
-	mu     sync.RWMutex
+	mu        sync.RWMutex
+	backend   Backend
+	retention Retention
+
+	pending chan pendingRecord
+	writer  sync.Once
+	dropped atomic.Int64
+}
+
+// pendingRecord is an event for the writer, or a flush marker it closes
+// once every event queued before it is written.
+type pendingRecord struct {
+	event   ReconcileEvent
+	flushed chan struct{}
 }
 
 func NewStore() *Store {
+	retention := DefaultRetention()
+	return NewStoreWithBackend(NewMemoryBackend(retention.MaxEvents), retention)
+}
+
+// NewStoreWithBackend creates a Store persisting to the given backend.
+func NewStoreWithBackend(backend Backend, retention Retention) *Store {
 	return &Store{
-		events: make([]ReconcileEvent, 0),
+		backend:   backend,
+		retention: retention,
+		pending:   make(chan pendingRecord, recordBuffer),
 	}
 }
 
-func (s *Store) Record(e ReconcileEvent) {
+// SetBackend swaps the persistence backend (e.g. Redis once connected).
+// Events recorded before the swap are not migrated.
+func (s *Store) SetBackend(backend Backend, retention Retention) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.backend = backend
+	s.retention = retention
+}
+
+func (s *Store) getBackend() Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backend
+}
+
+// Record queues an event for the writer and returns without waiting for
+// the backend. Failures are logged, never returned: the timeline is for
+// observability and must not block scheduling, so with the buffer full the
+// event is dropped.
+func (s *Store) Record(e ReconcileEvent) {
 	if e.Timestamp.IsZero() {
 		e.Timestamp = time.Now()
 	}
-	s.events = append(s.events, e)
+	s.startWriter()
+	select {
+	case s.pending <- pendingRecord{event: e}:
+	default:
+		if n := s.dropped.Add(1); n == 1 || n%1000 == 0 {
+			log.Printf("⚠️ Timeline buffer full: dropped %s/%s (%d events dropped so far)", e.ReqID, e.Stage, n)
+		}
+	}
 }
 
-func (s *Store) GetEvents(reqID string) []ReconcileEvent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// Query returns events matching q, oldest first, including every event
+// recorded before the call.
+func (s *Store) Query(ctx context.Context, q Query) ([]ReconcileEvent, error) {
+	if err := s.flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.getBackend().Query(ctx, q)
+}
+
+func (s *Store) startWriter() {
+	s.writer.Do(func() { go s.write() })
+}
 
-	var results []ReconcileEvent
-	for _, e := range s.events {
-		if e.ReqID == reqID {
-			results = append(results, e)
+// write appends queued events to the backend, one at a time and in order.
+func (s *Store) write() {
+	for p := range s.pending {
+		if p.flushed != nil {
+			close(p.flushed)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+		if err := s.getBackend().Append(ctx, p.event); err != nil {
+			log.Printf("⚠️ Timeline record failed for %s/%s: %v", p.event.ReqID, p.event.Stage, err)
 		}
+		cancel()
 	}
-	return results
+}
+
+// flush waits until the events queued so far are written.
+func (s *Store) flush(ctx context.Context) error {
+	s.startWriter()
+	flushed := make(chan struct{})
+	select {
+	case s.pending <- pendingRecord{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Store) GetEvents(reqID string) []ReconcileEvent {
+	return s.queryOrLog(Query{ReqID: reqID})
 }
 
 func (s *Store) GetEventsByStateID(stateID string) []ReconcileEvent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.queryOrLog(Query{StateID: stateID})
+}
 
-	var results []ReconcileEvent
-	for _, e := range s.events {
-		if e.Metadata != nil && e.Metadata["state_id"] == stateID {
-			results = append(results, e)
-		}
+func (s *Store) queryOrLog(q Query) []ReconcileEvent {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	events, err := s.Query(ctx, q)
+	if err != nil {
+		log.Printf("⚠️ Timeline query failed: %v", err)
+		return []ReconcileEvent{}
 	}
-	return results
+	return events
 }
 
-// GetAllEvents returns specific range of events (simple implementation for debug snapshot)
-func (s *Store) GetAllEvents() []ReconcileEvent {
+// Prune applies the configured retention once.
+func (s *Store) Prune(ctx context.Context) (int, error) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-	// Return copy
-	c := make([]ReconcileEvent, len(s.events))
-	copy(c, s.events)
-	return c
+	backend, retention := s.backend, s.retention
+	s.mu.RUnlock()
+	return backend.Prune(ctx, retention)
+}
+
+// StartRetention prunes on every interval until ctx is cancelled.
+// Pruning is idempotent, so every replica may run it.
+func (s *Store) StartRetention(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := s.Prune(ctx)
+				if err != nil {
+					log.Printf("⚠️ Timeline retention failed: %v", err)
+				} else if n > 0 {
+					log.Printf("Timeline retention pruned %d events", n)
+				}
+			}
+		}
+	}()
+}
+
+func sortByTime(events []ReconcileEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
 }
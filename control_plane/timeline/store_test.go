@@ -0,0 +1,43 @@
+package timeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slowBackend holds every Append until release is closed.
+type slowBackend struct {
+	*MemoryBackend
+	release chan struct{}
+}
+
+func (b *slowBackend) Append(ctx context.Context, e ReconcileEvent) error {
+	<-b.release
+	return b.MemoryBackend.Append(ctx, e)
+}
+
+func TestStoreRecordDoesNotWaitForBackend(t *testing.T) {
+	backend := &slowBackend{MemoryBackend: NewMemoryBackend(0), release: make(chan struct{})}
+	s := NewStoreWithBackend(backend, DefaultRetention())
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		s.Record(ReconcileEvent{ReqID: "req-1", Stage: "QUEUED"})
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Record took %v with a stalled backend", elapsed)
+	}
+
+	// A query waits for the events recorded before it
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Query(ctx, Query{ReqID: "req-1"}); err == nil {
+		t.Error("Query returned before the recorded events were written")
+	}
+	close(backend.release)
+	events, err := s.Query(context.Background(), Query{ReqID: "req-1"})
+	if err != nil || len(events) != 10 {
+		t.Errorf("Query = %d events, %v; want 10", len(events), err)
+	}
+}
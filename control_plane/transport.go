@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+// Transport names, selected by the agent via Metadata["transport"].
+const (
+	TransportMetadataKey = "transport"
+	TransportPush        = "push"
+	TransportPull        = "pull"
+)
+
+// Transport delivers a job to an agent.
+// Deliver returning nil means the job was handed over (push) or made
+// available (pull); the Dispatcher marks the job failed on error.
+type Transport interface {
+	Name() string
+	Deliver(ctx context.Context, agent *store.Agent, job *store.Job) error
+}
+
+// jobAssignment is the job payload agents receive, on /execute or /agent/jobs/next.
+type jobAssignment struct {
+	JobID          string `json:"job_id"`
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+func newJobAssignment(job *store.Job) jobAssignment {
+	return jobAssignment{
+		JobID:          job.JobID,
+		Command:        job.Command,
+		TimeoutSeconds: job.TimeoutSeconds,
+	}
+}
+
+// HTTPPushTransport POSTs jobs to the agent's /execute endpoint.
+// IMPORTANT:
+// - HTTP 202 Accepted = success (async execution)
+// - Requires the control plane to reach agent.IPAddress:agent.Port
+type HTTPPushTransport struct {
+	store  store.Store
+	client *http.Client
+}
+
+// NewHTTPPushTransport creates a new HTTPPushTransport.
+func NewHTTPPushTransport(store store.Store) *HTTPPushTransport {
+	return &HTTPPushTransport{
+		store:  store,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (t *HTTPPushTransport) Name() string { return TransportPush }
+
+func (t *HTTPPushTransport) Deliver(ctx context.Context, agent *store.Agent, job *store.Job) error {
+	url := fmt.Sprintf("http://%s:%d/execute", agent.IPAddress, agent.Port)
+
+	data, err := json.Marshal(newJobAssignment(job))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact agent: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// ✅ CORRECT SEMANTICS
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("agent returned status %d", resp.StatusCode)
+	}
+
+	// Job accepted for execution
+	return t.store.UpdateJobStatus(context.Background(), job.TenantID, job.JobID, "running", 0, "", "")
+}
+
+// PullTransport serves agents that accept no inbound connections.
+// Jobs stay "queued" in the store until the agent leases one through
+// /agent/jobs/next (store.ClaimNextJob moves it to "running").
+type PullTransport struct {
+	store store.Store
+
+	// waiters wakes long-polls on this replica when a job is queued.
+	// Pollers on other replicas pick the job up on their next store check.
+	mu      sync.Mutex
+	waiters map[string]chan struct{}
+
+	// recheckInterval bounds how stale a cross-replica long-poll can be
+	recheckInterval time.Duration
+}
+
+// NewPullTransport creates a new PullTransport.
+func NewPullTransport(store store.Store) *PullTransport {
+	return &PullTransport{
+		store:           store,
+		waiters:         make(map[string]chan struct{}),
+		recheckInterval: 1 * time.Second,
+	}
+}
+
+func (t *PullTransport) Name() string { return TransportPull }
+
+// Deliver leaves the job queued and wakes any local long-poll for the node.
+func (t *PullTransport) Deliver(ctx context.Context, agent *store.Agent, job *store.Job) error {
+	t.mu.Lock()
+	if ch, ok := t.waiters[store.TenantKey(job.TenantID, store.ResourceAgent, agent.NodeID)]; ok {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// Next leases the next queued job for the node, waiting up to wait for one.
+// Returns nil (no error) when nothing arrived in time.
+func (t *PullTransport) Next(ctx context.Context, tenantID string, nodeID string, wait time.Duration) (*store.Job, error) {
+	key := store.TenantKey(tenantID, store.ResourceAgent, nodeID)
+	wake := t.subscribe(key)
+	defer t.unsubscribe(key, wake)
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	recheck := time.NewTicker(t.recheckInterval)
+	defer recheck.Stop()
+
+	for {
+		job, err := t.store.ClaimNextJob(ctx, tenantID, nodeID)
+		if err != nil || job != nil {
+			return job, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil // Agent hung up
+		case <-deadline.C:
+			return nil, nil
+		case <-wake:
+		case <-recheck.C:
+		}
+	}
+}
+
+func (t *PullTransport) subscribe(key string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// One long-poll per agent is expected; a newer poll replaces an older one
+	ch := make(chan struct{}, 1)
+	t.waiters[key] = ch
+	return ch
+}
+
+func (t *PullTransport) unsubscribe(key string, ch chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.waiters[key] == ch {
+		delete(t.waiters, key)
+	}
+}
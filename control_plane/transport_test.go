@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itskum47/FluxForge/control_plane/store"
+)
+
+func TestPullTransport_LeaseQueuedJob(t *testing.T) {
+	s := store.NewMemoryStore()
+	ctx := context.Background()
+	dispatcher := NewDispatcher(s)
+
+	agent := &store.Agent{
+		NodeID:   "nat-agent",
+		TenantID: "default",
+		Metadata: map[string]string{TransportMetadataKey: TransportPull},
+	}
+	s.UpsertAgent(ctx, "default", agent)
+
+	job := &store.Job{JobID: "job-1", NodeID: agent.NodeID, Command: "true", Status: "queued", CreatedAt: time.Now()}
+	s.CreateJob(ctx, "default", job)
+
+	// Pull dispatch must not dial the agent; the job stays queued
+	dispatcher.DispatchJob(ctx, agent, job)
+	got, _ := s.GetJob(ctx, "default", "job-1")
+	if got.Status != "queued" {
+		t.Fatalf("expected queued after pull dispatch, got %s", got.Status)
+	}
+
+	leased, err := dispatcher.Pull().Next(ctx, "default", agent.NodeID, time.Second)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if leased == nil || leased.JobID != "job-1" {
+		t.Fatalf("expected job-1 to be leased, got %+v", leased)
+	}
+
+	got, _ = s.GetJob(ctx, "default", "job-1")
+	if got.Status != "running" {
+		t.Fatalf("expected running after lease, got %s", got.Status)
+	}
+
+	// Nothing left: the poll expires empty
+	leased, err = dispatcher.Pull().Next(ctx, "default", agent.NodeID, 50*time.Millisecond)
+	if err != nil || leased != nil {
+		t.Fatalf("expected empty poll, got %+v (err=%v)", leased, err)
+	}
+}
+
+func TestPullTransport_DeliverWakesLongPoll(t *testing.T) {
+	s := store.NewMemoryStore()
+	ctx := context.Background()
+	dispatcher := NewDispatcher(s)
+
+	agent := &store.Agent{
+		NodeID:   "nat-agent",
+		TenantID: "default",
+		Metadata: map[string]string{TransportMetadataKey: TransportPull},
+	}
+
+	result := make(chan *store.Job, 1)
+	go func() {
+		job, _ := dispatcher.Pull().Next(ctx, "default", agent.NodeID, 10*time.Second)
+		result <- job
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	job := &store.Job{JobID: "job-2", NodeID: agent.NodeID, Command: "true", Status: "queued", CreatedAt: time.Now()}
+	s.CreateJob(ctx, "default", job)
+	dispatcher.DispatchJob(ctx, agent, job)
+
+	select {
+	case leased := <-result:
+		if leased == nil || leased.JobID != "job-2" {
+			t.Fatalf("expected job-2, got %+v", leased)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("long-poll not woken by dispatch")
+	}
+}
+
+func TestDispatcher_UnknownTransportFailsJob(t *testing.T) {
+	s := store.NewMemoryStore()
+	ctx := context.Background()
+	dispatcher := NewDispatcher(s)
+
+	agent := &store.Agent{
+		NodeID:   "odd-agent",
+		TenantID: "default",
+		Metadata: map[string]string{TransportMetadataKey: "carrier-pigeon"},
+	}
+	job := &store.Job{JobID: "job-3", NodeID: agent.NodeID, Command: "true", Status: "queued", CreatedAt: time.Now()}
+	s.CreateJob(ctx, "default", job)
+
+	dispatcher.DispatchJob(ctx, agent, job)
+
+	got, _ := s.GetJob(ctx, "default", "job-3")
+	if got.Status != "failed" {
+		t.Fatalf("expected failed, got %s", got.Status)
+	}
+}
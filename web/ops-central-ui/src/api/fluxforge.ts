@@ -24,6 +24,11 @@ export interface BackendDashboardMetrics {
     drifted_states: number;
     active_agents: number;
 
+    // Degraded Mode (store down, served from cache)
+    degraded?: boolean;
+    pending_writes?: number;
+    dependencies?: Record<string, boolean>;
+
     // Multi-Cluster
     cluster_id: string;
     cluster_role: string;
@@ -112,6 +112,16 @@ export function Dashboard() {
                 </div>
             </div>
 
+            {metrics.degraded && (
+                <div className="bg-amber-50 border border-amber-200 text-amber-800 p-4 rounded-lg flex items-center">
+                    <AlertTriangle className="w-5 h-5 mr-2" />
+                    <span>
+                        Degraded mode: the store is unavailable and reads are served from cache.
+                        {' '}{metrics.pending_writes ?? 0} writes are waiting to be replayed.
+                    </span>
+                </div>
+            )}
+
             {/* KPI Grid */}
             <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-4 gap-4">
                 <KPICard